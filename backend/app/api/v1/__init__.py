@@ -1,8 +1,50 @@
-from app.api.v1.endpoints import auth, health, market, portfolio
-from fastapi import APIRouter
+from app.api.v1.endpoints import (
+    admin,
+    alerts,
+    analytics,
+    auth,
+    errors,
+    health,
+    market,
+    portfolio,
+    watchlists,
+)
+from app.core.caching import no_store
+from app.core.deps import api_rate_limit
+from fastapi import APIRouter, Depends
 
-api_router = APIRouter()
+api_router = APIRouter(dependencies=[Depends(api_rate_limit)])
 api_router.include_router(health.router, prefix="/health", tags=["health"])
-api_router.include_router(auth.router, prefix="/auth", tags=["authentication"])
+api_router.include_router(
+    auth.router,
+    prefix="/auth",
+    tags=["authentication"],
+    dependencies=[Depends(no_store())],
+)
 api_router.include_router(market.router, prefix="/market", tags=["market"])
-api_router.include_router(portfolio.router, prefix="/portfolio", tags=["portfolio"])
+api_router.include_router(
+    portfolio.router,
+    prefix="/portfolio",
+    tags=["portfolio"],
+    dependencies=[Depends(no_store())],
+)
+api_router.include_router(
+    alerts.router,
+    prefix="/alerts",
+    tags=["alerts"],
+    dependencies=[Depends(no_store())],
+)
+api_router.include_router(
+    watchlists.router,
+    prefix="/watchlists",
+    tags=["watchlists"],
+    dependencies=[Depends(no_store())],
+)
+api_router.include_router(analytics.router, prefix="/analytics", tags=["analytics"])
+api_router.include_router(
+    admin.router,
+    prefix="/admin",
+    tags=["admin"],
+    dependencies=[Depends(no_store())],
+)
+api_router.include_router(errors.router, prefix="/errors", tags=["errors"])
@@ -0,0 +1,140 @@
+"""
+Administrative endpoints for Quant-Dash API.
+
+This module provides:
+1. Fault injection rules for resilience testing (non-production only)
+2. Service level objective compliance and error budgets
+3. Raw provider payloads for debugging quotes
+4. Taking the daily portfolio snapshots on demand
+
+All routes require the ADMIN role.
+"""
+
+import logging
+from typing import List
+
+from app.core import errors
+from app.core.deps import require_admin
+from app.core.faults import (
+    FaultInjector,
+    FaultRule,
+    fault_injection_available,
+    get_fault_injector,
+)
+from app.core.slo import SLOStatus, SLOTracker, get_slo_tracker
+from app.data.provider_base import ProviderNotSupportedError
+from app.models.schemas import PortfolioSnapshot, ProviderDebug
+from app.services.market import MarketService, get_market_service
+from app.services.snapshots import SnapshotService, get_snapshot_service
+from fastapi import APIRouter, Depends, Path
+
+logger = logging.getLogger(__name__)
+
+router = APIRouter(dependencies=[Depends(require_admin)])
+
+
+def require_fault_injection(
+    injector: FaultInjector = Depends(get_fault_injector),
+) -> FaultInjector:
+    """Hide the fault endpoints entirely unless injection is enabled."""
+    if not (injector.enabled and fault_injection_available()):
+        raise errors.route_not_found()
+    return injector
+
+
+@router.put(
+    "/faults",
+    response_model=List[FaultRule],
+    summary="Replace fault injection rules",
+)
+async def set_fault_rules(
+    rules: List[FaultRule],
+    injector: FaultInjector = Depends(require_fault_injection),
+) -> List[FaultRule]:
+    """
+    Replace all active fault rules.
+
+    Route rules match request paths by prefix; dependency rules match the
+    name a provider or repository was wrapped with: the provider's (e.g.
+    "finnhub") or "stocks", "market_data", "portfolios" or "actions" for the
+    repositories. A repository db_timeout is answered with a 503
+    database.unavailable, as a real pool timeout would be.
+    """
+    injector.set_rules(rules)
+    return injector.list_rules()
+
+
+@router.get(
+    "/faults",
+    response_model=List[FaultRule],
+    summary="List active fault injection rules",
+)
+async def list_fault_rules(
+    injector: FaultInjector = Depends(require_fault_injection),
+) -> List[FaultRule]:
+    return injector.list_rules()
+
+
+@router.delete("/faults", summary="Clear all fault injection rules")
+async def clear_fault_rules(
+    injector: FaultInjector = Depends(require_fault_injection),
+):
+    injector.clear()
+    return {"message": "Fault injection rules cleared"}
+
+
+@router.get(
+    "/slo",
+    response_model=List[SLOStatus],
+    summary="Service level objective status",
+)
+async def get_slo_status(
+    tracker: SLOTracker = Depends(get_slo_tracker),
+) -> List[SLOStatus]:
+    """
+    Compliance and error budget for each configured SLO over its window.
+
+    A burn rate above 1.0 means the budget will run out before the window
+    ends if the current failure rate keeps up.
+    """
+    return tracker.report()
+
+
+@router.get(
+    "/provider-debug/{symbol}",
+    response_model=ProviderDebug,
+    summary="Fetch a raw provider quote",
+)
+async def debug_provider_quote(
+    symbol: str = Path(..., description="Stock symbol (e.g., AAPL)"),
+    market_service: MarketService = Depends(get_market_service),
+) -> ProviderDebug:
+    """
+    Fetch a quote live, skipping caches, and return the provider's JSON
+    next to the stock parsed from it. Nothing is stored.
+
+    For when quotes look wrong: compare what the provider sent with what
+    the dashboard made of it.
+    """
+    try:
+        return await market_service.debug_quote(symbol)
+    except ProviderNotSupportedError as e:
+        raise errors.provider_not_supported(str(e))
+    except Exception:
+        logger.exception("Debug quote for %s failed", symbol)
+        raise errors.provider_unavailable(market_service.provider_name or "Provider")
+
+
+@router.post(
+    "/snapshots",
+    response_model=List[PortfolioSnapshot],
+    summary="Snapshot every portfolio now",
+)
+async def take_snapshots(
+    snapshot_service: SnapshotService = Depends(get_snapshot_service),
+) -> List[PortfolioSnapshot]:
+    """
+    Run the daily snapshot job now. Today's snapshots are replaced, so this
+    is safe to repeat.
+    """
+    return await snapshot_service.take_all()
@@ -0,0 +1,99 @@
+from datetime import datetime
+from typing import List, Optional
+from fastapi import APIRouter, Depends, Query, Response, status
+from app.core import errors
+from app.core.deps import get_current_user_id
+from app.models.schemas import AlertEvent, PriceAlert, PriceAlertCreate
+from app.services.alerts import AlertService, get_alert_service
+from app.utils.symbols import SymbolRestrictedError
+
+router = APIRouter()
+
+
+@router.get("/", response_model=List[PriceAlert])
+async def get_alerts(
+    user_id: int = Depends(get_current_user_id),
+    alert_service: AlertService = Depends(get_alert_service),
+):
+    """
+    List the user's alerts, active or not
+    """
+    return await alert_service.get_alerts(user_id)
+
+
+@router.post("/", response_model=PriceAlert, status_code=status.HTTP_201_CREATED)
+async def create_alert(
+    alert: PriceAlertCreate,
+    user_id: int = Depends(get_current_user_id),
+    alert_service: AlertService = Depends(get_alert_service),
+):
+    """
+    Create a price alert (201).
+
+    It's checked on every quote refresh and fires once, recording an event;
+    acknowledging it re-arms it.
+    """
+    try:
+        return await alert_service.create_alert(user_id, alert)
+    except SymbolRestrictedError as e:
+        raise errors.symbol_restricted(e.symbol)
+
+
+@router.get("/triggered", response_model=List[PriceAlert])
+async def get_triggered_alerts(
+    user_id: int = Depends(get_current_user_id),
+    alert_service: AlertService = Depends(get_alert_service),
+):
+    """
+    List the user's triggered alerts that haven't been acknowledged, latest
+    trigger first
+    """
+    return await alert_service.get_unacknowledged_alerts(user_id)
+
+
+@router.get("/events", response_model=List[AlertEvent])
+async def get_alert_events(
+    since: Optional[datetime] = Query(
+        None, description="Only events after this time; pass the last one seen"
+    ),
+    user_id: int = Depends(get_current_user_id),
+    alert_service: AlertService = Depends(get_alert_service),
+):
+    """
+    List the user's alert firings, oldest first, for polling notifications
+    """
+    return await alert_service.get_events(user_id, since)
+
+
+@router.delete(
+    "/{alert_id}",
+    status_code=status.HTTP_204_NO_CONTENT,
+    response_class=Response,
+)
+async def delete_alert(
+    alert_id: int,
+    user_id: int = Depends(get_current_user_id),
+    alert_service: AlertService = Depends(get_alert_service),
+):
+    """
+    Delete an alert (204); its events are kept
+    """
+    if not await alert_service.delete_alert(user_id, alert_id):
+        raise errors.alert_not_found(alert_id)
+
+
+@router.post("/{alert_id}/ack", response_model=PriceAlert)
+async def acknowledge_alert(
+    alert_id: int,
+    user_id: int = Depends(get_current_user_id),
+    alert_service: AlertService = Depends(get_alert_service),
+):
+    """
+    Acknowledge a triggered alert so it stops showing.
+
+    The alert is re-armed; if it triggers again it shows up unacknowledged.
+    """
+    alert = await alert_service.acknowledge(user_id, alert_id)
+    if alert is None:
+        raise errors.alert_not_found(alert_id)
+    return alert
@@ -0,0 +1,131 @@
+from datetime import date, timedelta
+from typing import Optional, Tuple
+from fastapi import APIRouter, Depends
+from app.core import errors
+from app.models.schemas import (
+    CointegrationRequest,
+    CointegrationResult,
+    CostEstimateRequest,
+    DCARequest,
+    DCAResult,
+    RiskParityRequest,
+    RiskParityResult,
+    TradeCost,
+)
+from app.services.analytics import (
+    AnalyticsService,
+    estimate_cost,
+    get_analytics_service,
+)
+from app.utils.history import (
+    HistoryRangeError,
+    check_date_range,
+    resolve_history_days,
+)
+from app.utils.params import ParamConflictError, mutually_exclusive, requires
+
+router = APIRouter()
+
+
+def _lookback(
+    range_token: Optional[str], start: Optional[date], end: Optional[date]
+) -> Tuple[date, Optional[date]]:
+    """The start and end of a range token or start/end dates, default 1Y."""
+    params = {"range": range_token, "start_date": start, "end_date": end}
+    try:
+        mutually_exclusive(params, ("range",), ("start_date", "end_date"))
+        requires(params, "end_date", "start_date")
+    except ParamConflictError as e:
+        raise errors.param_conflict(str(e))
+
+    try:
+        if start is not None:
+            check_date_range(start, end)
+        else:
+            days = resolve_history_days(range_token=range_token or "1Y")
+            start = date.today() - timedelta(days=days - 1)
+    except HistoryRangeError as e:
+        raise errors.history_range_invalid(str(e))
+    return start, end
+
+
+@router.post("/dca", response_model=DCAResult)
+async def simulate_dollar_cost_averaging(
+    request: DCARequest,
+    analytics_service: AnalyticsService = Depends(get_analytics_service),
+):
+    """
+    Backfill a periodic contribution strategy over historical prices.
+
+    Buys `contribution` worth of fractional shares of a symbol or weight map
+    each weekly, biweekly, monthly or quarterly `interval`, at the first
+    trading day on or after each date. Returns the shares accumulated and
+    compares the result with investing the same total as a lump sum at the
+    start. `costs` are charged on every purchase, lump sum included.
+    """
+    try:
+        check_date_range(request.start_date, request.end_date)
+    except HistoryRangeError as e:
+        raise errors.history_range_invalid(str(e))
+
+    try:
+        return await analytics_service.simulate_dca(request)
+    except ValueError as e:
+        raise errors.analytics_invalid_request(str(e))
+
+
+@router.post("/risk-parity", response_model=RiskParityResult)
+async def compute_risk_parity_weights(
+    request: RiskParityRequest,
+    analytics_service: AnalyticsService = Depends(get_analytics_service),
+):
+    """
+    Compute weights under which every symbol contributes equally to risk.
+
+    Solved iteratively from the covariance of daily returns over a range
+    token or start/end dates (default 1Y). Symbols and iterations are capped.
+    """
+    start, end = _lookback(request.range_token, request.start_date, request.end_date)
+    try:
+        return await analytics_service.risk_parity(request.symbols, start, end)
+    except ValueError as e:
+        raise errors.analytics_invalid_request(str(e))
+
+
+@router.post("/cointegration", response_model=CointegrationResult)
+async def check_pair_cointegration(
+    request: CointegrationRequest,
+    analytics_service: AnalyticsService = Depends(get_analytics_service),
+):
+    """
+    Test whether a pair's spread is mean-reverting, for pairs trading.
+
+    Regresses symbol_a's daily closes on symbol_b's over the days both have
+    one (a range token or start/end dates, default 1Y), then runs an
+    augmented Dickey-Fuller test on the residuals. The pair is cointegrated
+    when the statistic is below the Engle-Granger critical value at the
+    requested significance. Fewer than 30 common closes is a 400.
+    """
+    start, end = _lookback(request.range_token, request.start_date, request.end_date)
+    try:
+        return await analytics_service.cointegration(
+            request.symbol_a,
+            request.symbol_b,
+            start,
+            end,
+            request.significance,
+            request.lags,
+        )
+    except ValueError as e:
+        raise errors.analytics_invalid_request(str(e))
+
+
+@router.post("/cost-estimate", response_model=TradeCost)
+async def estimate_trade_cost(request: CostEstimateRequest):
+    """
+    Preview what a trade costs beyond its notional.
+
+    Adds a per-share commission, a fee and expected slippage (both in basis
+    points of the notional) from the request's cost model.
+    """
+    return estimate_cost(request.price, request.quantity, request.costs)
@@ -18,14 +18,16 @@ Design principles:
 import logging
 import traceback
 
+from app.core import errors
+from app.core.clientip import client_ip
 from app.core.deps import (
     get_current_user,
     login_rate_limit,
     registration_rate_limit,
     require_verified,
 )
+from app.core.errors import AppError
 from app.models.auth import (
-    AuthErrorResponse,
     EmailVerification,
     PasswordReset,
     PasswordResetConfirm,
@@ -35,8 +37,9 @@ from app.models.auth import (
     UserRegister,
     UserResponse,
 )
+from app.services.portfolio import PortfolioService, get_portfolio_service
 from app.services.user import UserService
-from fastapi import APIRouter, Depends, HTTPException, Request, status
+from fastapi import APIRouter, Depends, Request, status
 from fastapi.responses import JSONResponse
 
 # Configure logger
@@ -53,7 +56,11 @@ router = APIRouter()
     summary="Register new user",
     description="Register a new user account with email verification required",
 )
-async def register(user_data: UserRegister, user_service: UserService = Depends()):
+async def register(
+    user_data: UserRegister,
+    user_service: UserService = Depends(),
+    portfolio_service: PortfolioService = Depends(get_portfolio_service),
+):
     """
     Register a new user account.
 
@@ -62,16 +69,18 @@ async def register(user_data: UserRegister, user_service: UserService = Depends(
     2. Checks for duplicate email addresses
     3. Creates user account with PENDING status
     4. Sends email verification link
-    5. Returns user data (excluding sensitive information)
+    5. Gives the user an empty portfolio
+    6. Returns user data (excluding sensitive information)
 
     Rate limited to prevent spam registrations.
     """
     try:
         user = await user_service.register_user(user_data)
+        portfolio_service.ensure_portfolio(user["id"])
         return UserResponse(**user)
 
     except ValueError as e:
-        raise HTTPException(status_code=status.HTTP_400_BAD_REQUEST, detail=str(e))
+        raise errors.registration_rejected(str(e))
     except Exception as e:
         # Log the actual error with full context for debugging
         logger.error(
@@ -81,10 +90,7 @@ async def register(user_data: UserRegister, user_service: UserService = Depends(
             traceback.format_exc(),
             extra={"endpoint": "register", "user_email": user_data.email},
         )
-        raise HTTPException(
-            status_code=status.HTTP_500_INTERNAL_SERVER_ERROR,
-            detail="Registration failed. Please try again.",
-        )
+        raise errors.internal_error("Registration failed. Please try again.")
 
 
 @router.post(
@@ -116,26 +122,25 @@ async def login(
             logger.warning(
                 "Authentication failure for email: %s from IP: %s",
                 login_data.email,
-                request.client.host,
+                client_ip(request),
                 extra={
                     "event_type": "authentication_failure",
                     "email": login_data.email,
-                    "client_ip": request.client.host,
+                    "client_ip": client_ip(request),
                     "user_agent": request.headers.get("user-agent", "")[:100],
                 },
             )
             # Generic error message to prevent user enumeration
-            raise HTTPException(
-                status_code=status.HTTP_401_UNAUTHORIZED,
-                detail="Invalid email or password",
-            )
+            raise errors.invalid_credentials()
 
         tokens = await user_service.create_tokens(user)
         return tokens
 
+    except AppError:
+        raise
     except ValueError as e:
         # Handle specific errors like account lockout
-        raise HTTPException(status_code=status.HTTP_401_UNAUTHORIZED, detail=str(e))
+        raise errors.login_rejected(str(e))
     except Exception as e:
         # Log the actual error with context for debugging
         logger.error(
@@ -145,10 +150,7 @@ async def login(
             traceback.format_exc(),
             extra={"endpoint": "login", "user_email": login_data.email},
         )
-        raise HTTPException(
-            status_code=status.HTTP_500_INTERNAL_SERVER_ERROR,
-            detail="Login failed. Please try again.",
-        )
+        raise errors.internal_error("Login failed. Please try again.")
 
 
 @router.post(
@@ -174,7 +176,7 @@ async def refresh_token(
         return tokens
 
     except ValueError as e:
-        raise HTTPException(status_code=status.HTTP_401_UNAUTHORIZED, detail=str(e))
+        raise errors.refresh_rejected(str(e))
     except Exception as e:
         # Log the actual error with context for debugging
         logger.error(
@@ -183,10 +185,7 @@ async def refresh_token(
             traceback.format_exc(),
             extra={"endpoint": "refresh_token"},
         )
-        raise HTTPException(
-            status_code=status.HTTP_500_INTERNAL_SERVER_ERROR,
-            detail="Token refresh failed. Please login again.",
-        )
+        raise errors.internal_error("Token refresh failed. Please login again.")
 
 
 @router.post(
@@ -209,13 +208,12 @@ async def verify_email(
         success = await user_service.verify_email(verification_data.token)
 
         if not success:
-            raise HTTPException(
-                status_code=status.HTTP_400_BAD_REQUEST,
-                detail="Invalid or expired verification token",
-            )
+            raise errors.verification_token_invalid()
 
         return {"message": "Email verified successfully"}
 
+    except AppError:
+        raise
     except Exception as e:
         # Log the actual error with context for debugging
         logger.error(
@@ -225,10 +223,7 @@ async def verify_email(
             traceback.format_exc(),
             extra={"endpoint": "verify_email"},
         )
-        raise HTTPException(
-            status_code=status.HTTP_500_INTERNAL_SERVER_ERROR,
-            detail="Email verification failed. Please try again.",
-        )
+        raise errors.internal_error("Email verification failed. Please try again.")
 
 
 @router.post(
@@ -293,15 +288,14 @@ async def confirm_password_reset(
         )
 
         if not success:
-            raise HTTPException(
-                status_code=status.HTTP_400_BAD_REQUEST,
-                detail="Invalid or expired reset token",
-            )
+            raise errors.reset_token_invalid()
 
         return {"message": "Password reset successfully"}
 
+    except AppError:
+        raise
     except ValueError as e:
-        raise HTTPException(status_code=status.HTTP_400_BAD_REQUEST, detail=str(e))
+        raise errors.password_rejected(str(e))
     except Exception as e:
         logger.error(
             "Password reset confirmation failed. Error: %s. Traceback: %s",
@@ -309,10 +303,7 @@ async def confirm_password_reset(
             traceback.format_exc(),
             extra={"endpoint": "confirm_password_reset"},
         )
-        raise HTTPException(
-            status_code=status.HTTP_500_INTERNAL_SERVER_ERROR,
-            detail="Password reset failed. Please try again.",
-        )
+        raise errors.internal_error("Password reset failed. Please try again.")
 
 
 @router.get(
@@ -0,0 +1,17 @@
+from typing import List
+from fastapi import APIRouter
+from app.core.errors import get_error_catalog
+from app.models.schemas import ErrorCatalogEntry
+
+router = APIRouter()
+
+
+@router.get("/", response_model=List[ErrorCatalogEntry])
+async def list_error_codes():
+    """
+    List every error code the API can return.
+
+    Error bodies carry one of these codes; match on the code rather than
+    the message, which may change.
+    """
+    return get_error_catalog()
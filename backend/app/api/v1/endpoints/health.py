@@ -1,5 +1,7 @@
-from fastapi import APIRouter
-from app.models.schemas import HealthResponse
+from fastapi import APIRouter, Response, status
+from app.core.config import settings
+from app.database.session import ping
+from app.models.schemas import HealthResponse, ReadinessResponse
 
 router = APIRouter()
 
@@ -14,3 +16,27 @@ async def health_check():
         message="Quant-Dash Backend API is running",
         version="1.0.0"
     )
+
+
+@router.get(
+    "/ready",
+    response_model=ReadinessResponse,
+    responses={503: {"model": ReadinessResponse}},
+)
+async def readiness_check(response: Response):
+    """
+    Whether the app can serve traffic (503 if not), as opposed to the
+    liveness check above, which only says the process is up.
+
+    The database is pinged when DATABASE_REQUIRED is set, and counts as
+    down if it doesn't answer within READINESS_TIMEOUT_SECONDS.
+    """
+    checks = {}
+    if settings.DATABASE_REQUIRED:
+        failure = await ping(settings.READINESS_TIMEOUT_SECONDS)
+        checks["database"] = failure or "ok"
+
+    ready = all(result == "ok" for result in checks.values())
+    if not ready:
+        response.status_code = status.HTTP_503_SERVICE_UNAVAILABLE
+    return ReadinessResponse(status="ready" if ready else "unavailable", checks=checks)
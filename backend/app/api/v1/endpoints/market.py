@@ -1,109 +1,505 @@
-from typing import List
-from fastapi import APIRouter, HTTPException, Path
-from app.models.schemas import Stock
+from datetime import date, timedelta
+from typing import Dict, List, Optional, Tuple
+from fastapi import APIRouter, Depends, Path, Query, Response, status
+from fastapi.responses import StreamingResponse
+from app.core import errors
+from app.core.caching import cache_for
+from app.core.deps import get_optional_user_id, require_admin
+from app.data.provider_base import ProviderNotSupportedError
+from app.models.schemas import (
+    DataCoverage,
+    ExportFormat,
+    IndicatorSeries,
+    IndicatorType,
+    Level1Quote,
+    QuoteBatch,
+    QuoteBatchRequest,
+    SearchResult,
+    Stock,
+    StockCreate,
+    StockHistory,
+    StockPage,
+)
+from app.services.market import (
+    STOCK_SORT_FIELDS,
+    MarketService,
+    get_market_service,
+)
+from app.services.export import MEDIA_TYPES, history_csv
+from app.services.search import SearchService, get_search_service
+from app.utils.history import HistoryRangeError, resolve_history_days
+from app.utils.jsonenc import encode_response, int64_as_string
+from app.utils.multistatus import MultiStatus
+from app.utils.params import (
+    ParamConflictError,
+    mutually_exclusive,
+    parse_sort,
+    requires,
+)
+from app.utils.symbols import (
+    InvalidSymbolError,
+    SymbolRestrictedError,
+    check_symbol,
+    parse_symbol,
+)
 
 router = APIRouter()
 
+# How long shared caches may reuse responses
+SYMBOLS_CACHE_SECONDS = 60
+QUOTE_CACHE_SECONDS = 5
 
-@router.get("/stocks", response_model=List[Stock])
-async def get_stocks():
-    """
-    Get a list of all stocks with current market data
-    """
-    # Placeholder data - replace with actual market data service
-    stocks = [
-        Stock(
-            id=1,
-            symbol="AAPL",
-            name="Apple Inc.",
-            price=150.25,
-            change=2.15,
-            change_percent=1.45,
-            volume=50000000,
-            market_cap="2.4T",
-            pe_ratio=28.5,
-            updated_at="2025-08-05T10:30:00"
-        ),
-        Stock(
-            id=2,
-            symbol="GOOGL",
-            name="Alphabet Inc.",
-            price=2750.80,
-            change=-12.45,
-            change_percent=-0.45,
-            volume=1200000,
-            market_cap="1.8T",
-            pe_ratio=25.2,
-            updated_at="2025-08-05T10:30:00"
-        ),
-        Stock(
-            id=3,
-            symbol="MSFT",
-            name="Microsoft Corporation",
-            price=310.50,
-            change=5.25,
-            change_percent=1.72,
-            volume=25000000,
-            market_cap="2.3T",
-            pe_ratio=32.1,
-            updated_at="2025-08-05T10:30:00"
+MAX_BATCH_SYMBOLS = 50
+MAX_LIST_SYMBOLS = 100
+
+DEFAULT_HISTORY_DAYS = 90
+
+DEFAULT_PAGE_SIZE = 50
+MAX_PAGE_SIZE = 100
+
+
+def _allowed_symbol(symbol: str) -> str:
+    """
+    Normalize a path symbol, rejecting it with a 400 if it isn't a ticker and
+    a 403 if it's restricted.
+    """
+    try:
+        return check_symbol(parse_symbol(symbol))
+    except InvalidSymbolError as e:
+        raise errors.symbol_invalid(str(e))
+    except SymbolRestrictedError as e:
+        raise errors.symbol_restricted(e.symbol)
+
+
+def _symbol_list(raw: str) -> List[str]:
+    """
+    Parse a comma-separated symbol list, normalized and without repeats,
+    rejecting it with a 400 if it's empty, too long or has a non-ticker.
+    """
+    symbols: List[str] = []
+    for item in raw.split(","):
+        if not item.strip():
+            continue
+        try:
+            symbol = parse_symbol(item)
+        except InvalidSymbolError as e:
+            raise errors.symbol_invalid(str(e))
+        if symbol not in symbols:
+            symbols.append(symbol)
+    if not symbols:
+        raise errors.param_invalid("Give at least one symbol")
+    if len(symbols) > MAX_LIST_SYMBOLS:
+        raise errors.param_invalid(
+            f"At most {MAX_LIST_SYMBOLS} symbols can be listed at once"
         )
-    ]
-    return stocks
+    return symbols
 
 
-@router.get("/stocks/{symbol}", response_model=Stock)
-async def get_stock(
-    symbol: str = Path(..., description="Stock symbol (e.g., AAPL)")
+def _history_window(
+    days: Optional[int],
+    range_token: Optional[str],
+    start: Optional[date],
+    end: Optional[date],
+) -> Tuple[date, date]:
+    """
+    The first and last day asked for by at most one of `days`, `range`, or
+    `from`/`to`, defaulting to the last DEFAULT_HISTORY_DAYS days.
+    """
+    params = {"days": days, "range": range_token, "from": start, "to": end}
+    try:
+        mutually_exclusive(params, ("days",), ("range",), ("from", "to"))
+        requires(params, "to", "from")
+    except ParamConflictError as e:
+        raise errors.param_conflict(str(e))
+
+    try:
+        end = end or date.today()
+        if start is not None:
+            if end < start:
+                raise HistoryRangeError("'from' must not be after 'to'")
+            resolve_history_days((end - start).days + 1)
+        else:
+            if days is None and range_token is None:
+                days = DEFAULT_HISTORY_DAYS
+            days = resolve_history_days(days, range_token)
+            start = end - timedelta(days=days - 1)
+    except HistoryRangeError as e:
+        raise errors.history_range_invalid(str(e))
+    return start, end
+
+
+def _indicator_types(raw: str) -> List[IndicatorType]:
+    """Parse a comma-separated list of indicator names, dropping repeats."""
+    types: List[IndicatorType] = []
+    for name in raw.split(","):
+        name = name.strip().lower()
+        if not name:
+            continue
+        try:
+            indicator = IndicatorType(name)
+        except ValueError:
+            known = ", ".join(t.value for t in IndicatorType)
+            raise errors.analytics_invalid_request(
+                f"Unknown indicator '{name}'; expected one of {known}"
+            )
+        if indicator not in types:
+            types.append(indicator)
+    if not types:
+        raise errors.analytics_invalid_request("Give at least one indicator")
+    return types
+
+
+@router.get(
+    "/stocks",
+    response_model=StockPage,
+    dependencies=[Depends(cache_for(SYMBOLS_CACHE_SECONDS))],
+)
+async def get_stocks(
+    page: int = Query(1, ge=1),
+    page_size: Optional[int] = Query(
+        None, ge=1, le=MAX_PAGE_SIZE, description="50, or every symbol asked for"
+    ),
+    symbols: Optional[str] = Query(
+        None, description="Comma-separated; only these stocks, e.g. AAPL,MSFT"
+    ),
+    sort: Optional[str] = Query(
+        None, description="price, change_percent, volume or symbol; -price descends"
+    ),
+    min_price: Optional[float] = Query(None, ge=0),
+    max_price: Optional[float] = Query(None, ge=0),
+    as_string: bool = Depends(int64_as_string),
+    market_service: MarketService = Depends(get_market_service),
 ):
     """
-    Get detailed information about a specific stock
-    """
-    # Placeholder data - replace with actual market data service
-    stock_data = {
-        "AAPL": Stock(
-            id=1,
-            symbol="AAPL",
-            name="Apple Inc.",
-            price=150.25,
-            change=2.15,
-            change_percent=1.45,
-            volume=50000000,
-            market_cap="2.4T",
-            pe_ratio=28.5,
-            updated_at="2025-08-05T10:30:00"
+    Get a page of stocks with current market data.
+
+    `total` counts every matching stock, so clients can render page controls.
+    Send `X-Int64-As-String: true` to receive volume as a string. Symbols
+    restricted by compliance rules are left out.
+
+    With `symbols` (up to 100, repeats ignored) only those stocks are listed,
+    all on one page unless `page_size` is given; symbols with no stock are
+    simply absent.
+    """
+    try:
+        order = parse_sort(sort, STOCK_SORT_FIELDS)
+        if min_price is not None and max_price is not None and min_price > max_price:
+            raise ParamConflictError("'min_price' must not be above 'max_price'")
+    except ParamConflictError as e:
+        raise errors.param_conflict(str(e))
+
+    wanted = None if symbols is None else _symbol_list(symbols)
+    if page_size is None:
+        page_size = len(wanted) if wanted else DEFAULT_PAGE_SIZE
+    offset = (page - 1) * page_size
+    stocks = StockPage(
+        items=await market_service.list_stocks(
+            page_size, offset, order, min_price, max_price, wanted
         ),
-        "GOOGL": Stock(
-            id=2,
-            symbol="GOOGL",
-            name="Alphabet Inc.",
-            price=2750.80,
-            change=-12.45,
-            change_percent=-0.45,
-            volume=1200000,
-            market_cap="1.8T",
-            pe_ratio=25.2,
-            updated_at="2025-08-05T10:30:00"
-        )
-    }
-    
-    if symbol.upper() not in stock_data:
-        raise HTTPException(status_code=404, detail=f"Stock with symbol '{symbol}' not found")
-    
-    return stock_data[symbol.upper()]
+        total=await market_service.count_stocks(min_price, max_price, wanted),
+        page=page,
+        page_size=page_size,
+    )
+    return encode_response(stocks, as_string)
+
+
+@router.post(
+    "/stocks",
+    response_model=Stock,
+    status_code=status.HTTP_201_CREATED,
+    dependencies=[Depends(require_admin)],
+)
+async def upsert_stock(
+    stock: StockCreate,
+    response: Response,
+    market_service: MarketService = Depends(get_market_service),
+):
+    """
+    Add a stock to the catalog (201), or update the one with its symbol
+    (200); admins only, for seeding data by hand.
+
+    `change_percent` is recomputed from `price` and `change`, and
+    `updated_at` is set to now.
+    """
+    stock.symbol = _allowed_symbol(stock.symbol)
+    stored, is_new = await market_service.upsert_stock(stock)
+    if not is_new:
+        response.status_code = status.HTTP_200_OK
+    return stored
+
+
+@router.get(
+    "/search",
+    response_model=List[SearchResult],
+    dependencies=[Depends(cache_for(SYMBOLS_CACHE_SECONDS))],
+)
+async def search_stocks(
+    q: str = Query(..., min_length=1, description="Symbol or company name"),
+    limit: int = Query(10, ge=1, le=20),
+    user_id: Optional[int] = Depends(get_optional_user_id),
+    search_service: SearchService = Depends(get_search_service),
+):
+    """
+    Search stocks by symbol or name.
+
+    Falls back to fuzzy matching for typos, ranks by match quality and
+    market cap, and boosts symbols the authenticated user viewed recently.
+    """
+    return await search_service.search(q, limit=limit, user_id=user_id)
+
+
+async def _quote_batch(
+    requested: List[str],
+    live: bool,
+    response: Response,
+    as_string: bool,
+    market_service: MarketService,
+):
+    """Quote the requested symbols for /quotes, setting the batch's status."""
+    # Rejected symbols are reported as given, the rest once normalized; each
+    # counts once towards the limit however often it's repeated
+    items: Dict[str, Optional[errors.AppError]] = {}
+    for item in requested:
+        item = item.strip()
+        if not item:
+            continue
+        try:
+            items.setdefault(_allowed_symbol(item), None)
+        except errors.AppError as e:
+            items.setdefault(item, e)
+    limit = MAX_BATCH_SYMBOLS if live else MAX_LIST_SYMBOLS
+    if not items:
+        raise errors.field_invalid("Give at least one symbol")
+    if len(items) > limit:
+        raise errors.field_invalid(f"At most {limit} symbols can be quoted at once")
+
+    statuses = MultiStatus(items)
+    allowed = []
+    for item, error in items.items():
+        if error is None:
+            allowed.append(item)
+        else:
+            statuses.failed(item, error)
+
+    if allowed and live:
+        try:
+            batch = await market_service.get_live_quotes(allowed, statuses)
+        except ProviderNotSupportedError as e:
+            raise errors.provider_not_supported(str(e))
+    elif allowed:
+        batch = await market_service.find_stocks(allowed, statuses)
+    else:
+        batch = QuoteBatch(results=statuses.results)
+    # Unknown symbols are listed in not_found rather than failing the batch
+    response.status_code = statuses.status_code_without("stock.not_found")
+    return encode_response(batch, as_string, response.status_code)
+
+
+@router.get(
+    "/quotes",
+    response_model=QuoteBatch,
+    dependencies=[Depends(cache_for(QUOTE_CACHE_SECONDS))],
+)
+async def get_live_quotes(
+    response: Response,
+    symbols: str = Query(..., description="Comma-separated, e.g. AAPL,MSFT"),
+    live: bool = Query(False, description="Only fresh quotes from the provider"),
+    as_string: bool = Depends(int64_as_string),
+    market_service: MarketService = Depends(get_market_service),
+):
+    """
+    Get up to 100 stocks at once, each in `stocks` by symbol.
 
+    Symbols are uppercased and repeats ignored. Stocks are read from the
+    store in one query; only symbols not stored yet go to the provider.
+    Symbols with no stock are listed in `not_found` and don't fail the
+    request. `results` gives each symbol's outcome: the status is 200 unless
+    a symbol was rejected or its provider call failed, then 207, or the
+    symbols' own error status when none was found. Send
+    `X-Int64-As-String: true` to receive the volumes as strings.
 
-@router.get("/stocks/{symbol}/history")
+    With `live=true` (up to 50 symbols) each symbol's quote is fetched fresh
+    from the provider instead, and no stocks are returned.
+
+    The request spends at most PROVIDER_REQUEST_BUDGET seconds on provider
+    calls; if that runs out, the stocks fetched so far are returned with
+    `partial: true` and the rest listed as missing.
+    """
+    return await _quote_batch(
+        symbols.split(","), live, response, as_string, market_service
+    )
+
+
+@router.post("/quotes", response_model=QuoteBatch)
+async def post_live_quotes(
+    request: QuoteBatchRequest,
+    response: Response,
+    as_string: bool = Depends(int64_as_string),
+    market_service: MarketService = Depends(get_market_service),
+):
+    """
+    Get quotes like GET /quotes, for symbol lists too long for a URL.
+    """
+    return await _quote_batch(
+        request.symbols, request.live, response, as_string, market_service
+    )
+
+
+@router.get(
+    "/stocks/{symbol}",
+    response_model=Stock,
+    dependencies=[Depends(cache_for(QUOTE_CACHE_SECONDS))],
+)
+async def get_stock(
+    symbol: str = Path(..., description="Stock symbol (e.g., AAPL)"),
+    user_id: Optional[int] = Depends(get_optional_user_id),
+    as_string: bool = Depends(int64_as_string),
+    market_service: MarketService = Depends(get_market_service),
+    search_service: SearchService = Depends(get_search_service),
+):
+    """
+    Get detailed information about a specific stock.
+
+    Stocks not stored yet are fetched from the market data provider.
+    Send `X-Int64-As-String: true` to receive volume as a string.
+    """
+    symbol = _allowed_symbol(symbol)
+    stock = await market_service.find_stock(symbol)
+    if stock is None:
+        raise errors.stock_not_found(symbol)
+
+    if user_id is not None:
+        await search_service.record_view(user_id, stock.symbol)
+
+    return encode_response(stock, as_string)
+
+
+@router.get(
+    "/stocks/{symbol}/l1",
+    response_model=Level1Quote,
+    dependencies=[Depends(cache_for(QUOTE_CACHE_SECONDS))],
+)
+async def get_level1_quote(
+    symbol: str = Path(..., description="Stock symbol (e.g., AAPL)"),
+    market_service: MarketService = Depends(get_market_service),
+):
+    """
+    Get the current best bid/ask (level-1 quote) for a stock, with the spread.
+
+    Returns 501 when the configured market data provider has no level-1 data.
+    """
+    symbol = _allowed_symbol(symbol)
+    try:
+        return await market_service.get_level1(symbol)
+    except ProviderNotSupportedError as e:
+        raise errors.provider_not_supported(str(e))
+
+
+@router.get(
+    "/stocks/{symbol}/coverage",
+    response_model=DataCoverage,
+    dependencies=[Depends(cache_for(SYMBOLS_CACHE_SECONDS))],
+)
+async def get_data_coverage(
+    symbol: str = Path(..., description="Stock symbol (e.g., AAPL)"),
+    market_service: MarketService = Depends(get_market_service),
+):
+    """
+    Get the date range and granularity of stored history for a stock.
+
+    Lists gaps: trading days inside the range with no data, skipping
+    weekends and market holidays.
+    """
+    symbol = _allowed_symbol(symbol)
+    coverage = await market_service.get_coverage(symbol)
+    if coverage is None:
+        raise errors.stock_not_found(symbol)
+    return coverage
+
+
+@router.get("/stocks/{symbol}/history", response_model=StockHistory)
 async def get_stock_history(
     symbol: str = Path(..., description="Stock symbol"),
-    days: int = 30
+    days: Optional[int] = None,
+    range_token: Optional[str] = Query(
+        None, alias="range", description="1W, 1M, 3M, 6M, 1Y, 2Y, 5Y, 10Y or MAX"
+    ),
+    start: Optional[date] = Query(None, alias="from"),
+    end: Optional[date] = Query(None, alias="to", description="Defaults to today"),
+    market_service: MarketService = Depends(get_market_service),
+):
+    """
+    Get daily closing prices and OHLC bars for a stock, oldest first.
+
+    Give at most one of `days`, `range`, or `from`/`to` (RFC 3339 dates); the
+    default is the last DEFAULT_HISTORY_DAYS days. Ranges longer than
+    MAX_HISTORY_DAYS are rejected; "MAX" clamps to it. Days without data are
+    simply absent, so an empty range gives empty lists.
+    """
+    symbol = _allowed_symbol(symbol)
+    start, end = _history_window(days, range_token, start, end)
+    history = await market_service.get_stock_history(symbol, start, end)
+    if history is None:
+        raise errors.stock_not_found(symbol)
+    return history
+
+
+@router.get("/stocks/{symbol}/history/export", response_class=StreamingResponse)
+async def export_stock_history(
+    symbol: str = Path(..., description="Stock symbol"),
+    days: Optional[int] = None,
+    range_token: Optional[str] = Query(
+        None, alias="range", description="1W, 1M, 3M, 6M, 1Y, 2Y, 5Y, 10Y or MAX"
+    ),
+    start: Optional[date] = Query(None, alias="from"),
+    end: Optional[date] = Query(None, alias="to", description="Defaults to today"),
+    market_service: MarketService = Depends(get_market_service),
 ):
     """
-    Get historical market data for a stock
+    Download a stock's daily history as CSV (Date, Open, High, Low, Close,
+    Volume), oldest first, for the same range parameters as history.
     """
-    # Placeholder endpoint - implement with actual market data
-    return {
-        "symbol": symbol.upper(),
-        "days": days,
-        "message": "Historical data endpoint - to be implemented"
-    }
+    symbol = _allowed_symbol(symbol)
+    start, end = _history_window(days, range_token, start, end)
+    history = await market_service.get_stock_history(symbol, start, end)
+    if history is None:
+        raise errors.stock_not_found(symbol)
+    filename = f"{symbol}-{start.isoformat()}-{end.isoformat()}.csv"
+    return StreamingResponse(
+        iter([history_csv(history)]),
+        media_type=MEDIA_TYPES[ExportFormat.CSV],
+        headers={"Content-Disposition": f'attachment; filename="{filename}"'},
+    )
+
+
+@router.get("/stocks/{symbol}/indicators", response_model=IndicatorSeries)
+async def get_stock_indicators(
+    symbol: str = Path(..., description="Stock symbol"),
+    types: str = Query(..., description="Comma-separated: sma, ema, rsi"),
+    period: int = Query(14, description="Closes per window"),
+    days: Optional[int] = None,
+    start: Optional[date] = Query(None, alias="from"),
+    end: Optional[date] = Query(None, alias="to", description="Defaults to today"),
+    market_service: MarketService = Depends(get_market_service),
+):
+    """
+    Technical indicators over the stock's daily closes, only those asked for.
+
+    The range works as for history (`days` or `from`/`to`, last 90 days by
+    default). Each point is a close with the value of every requested
+    indicator, null until there are `period` closes (`period` + 1 for RSI).
+    Unknown indicators, a period under 2, or one needing more closes than the
+    range has are a 400.
+    """
+    symbol = _allowed_symbol(symbol)
+    indicator_types = _indicator_types(types)
+    start, end = _history_window(days, None, start, end)
+    try:
+        series = await market_service.get_indicators(
+            symbol, indicator_types, period, start, end
+        )
+    except ValueError as e:
+        raise errors.analytics_invalid_request(str(e))
+    if series is None:
+        raise errors.stock_not_found(symbol)
+    return series
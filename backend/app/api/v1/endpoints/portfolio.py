@@ -1,111 +1,938 @@
-from typing import List
-from fastapi import APIRouter, HTTPException
-from app.models.schemas import Portfolio, Position
+from datetime import date, timedelta
+from typing import List, Optional, Tuple
+from fastapi import (
+    APIRouter,
+    Depends,
+    File,
+    Query,
+    Request,
+    Response,
+    UploadFile,
+    status,
+)
+from fastapi.responses import StreamingResponse
+from app.core import errors
+from app.core.deps import get_current_user_id
+from app.models.schemas import (
+    AttentionPosition,
+    CashBalance,
+    CashBalanceUpdate,
+    CashDrag,
+    ExportFormat,
+    Granularity,
+    HoldingPeriod,
+    ImportMode,
+    PerformanceHistory,
+    PeriodComparison,
+    PeriodMetrics,
+    Portfolio,
+    PortfolioComposition,
+    PortfolioConcentration,
+    PortfolioCorporateActions,
+    PortfolioPE,
+    PortfolioSnapshot,
+    PortfolioYield,
+    Position,
+    PositionAdjustment,
+    PositionBase,
+    PositionCreate,
+    PositionImportResult,
+    PositionRebuild,
+    PositionUpdate,
+    RebalancePlan,
+    RollingBeta,
+    TradeStats,
+    Transaction,
+    TransactionCreate,
+    TransactionRequest,
+)
+from app.services.attention import AttentionService, get_attention_service
+from app.services.composition import CompositionService, get_composition_service
+from app.services.corporate_actions import (
+    CorporateActionService,
+    get_corporate_action_service,
+)
+from app.services.concentration import ConcentrationService, get_concentration_service
+from app.services.dividends import DividendService, get_dividend_service
+from app.services.export import MEDIA_TYPES, ExportService, get_export_service
+from app.services.holding_period import HoldingPeriodService, get_holding_period_service
+from app.services.ledger import (
+    DuplicateTransactionError,
+    LedgerService,
+    PriceCheckError,
+    PriceMovedError,
+    get_ledger_service,
+)
+from app.services.performance import PerformanceService, get_performance_service
+from app.services.portfolio import (
+    PortfolioService,
+    SymbolHeldError,
+    get_portfolio_service,
+)
+from app.services.position_import import (
+    PositionImportError,
+    PositionImportService,
+    get_position_import_service,
+)
+from app.services.rebalance import RebalanceService, get_rebalance_service
+from app.services.snapshots import SnapshotService, get_snapshot_service
+from app.services.trades import TradeStatsService, get_trade_stats_service
+from app.services.valuation import ValuationService, get_valuation_service
+from app.utils.history import HistoryRangeError, check_date_range
+from app.utils.params import ParamConflictError, check_sort
+from app.utils.symbols import SymbolRestrictedError
 
-router = APIRouter()
+
+async def _owned_portfolio(
+    request: Request,
+    user_id: int = Depends(get_current_user_id),
+    portfolio_service: PortfolioService = Depends(get_portfolio_service),
+) -> None:
+    """
+    Require a signed-in user, and that a portfolio in the path is theirs.
+
+    Other users' portfolios get the same 404 as missing ones, so ids can't
+    be probed.
+    """
+    portfolio_id = request.path_params.get("portfolio_id")
+    if portfolio_id is None or not portfolio_id.isdigit():
+        return  # No portfolio in the path, or path validation rejects it
+    if await portfolio_service.owner_of(int(portfolio_id)) != user_id:
+        raise errors.portfolio_not_found()
+
+
+router = APIRouter(dependencies=[Depends(_owned_portfolio)])
+
+POSITION_SORT_FIELDS = ("stock_symbol", "quantity", "current_value", "total_gain")
+
+
+def _check_period(start: date, end: date) -> None:
+    """Reject a backwards period or one longer than MAX_HISTORY_DAYS."""
+    try:
+        if end < start:
+            raise HistoryRangeError(f"Period start {start} is after its end {end}")
+        check_date_range(start, end)
+    except HistoryRangeError as e:
+        raise errors.history_range_invalid(str(e))
+
+
+async def _replace_position(
+    portfolio_id: int,
+    position_id: int,
+    position_data: PositionBase,
+    portfolio_service: PortfolioService,
+) -> Position:
+    try:
+        position = await portfolio_service.update_position(
+            portfolio_id, position_id, position_data.model_dump()
+        )
+    except SymbolRestrictedError as e:
+        raise errors.symbol_restricted(e.symbol)
+    except SymbolHeldError as e:
+        raise errors.position_symbol_held(e.symbol, e.position_id)
+    if position is None:
+        raise errors.position_not_found(portfolio_id, position_id)
+    return position
+
+
+def _performance_window(
+    start: Optional[date], end: Optional[date]
+) -> Tuple[date, date]:
+    """The period to chart: `to` defaults to today and `from` to 29 days before."""
+    end = end or date.today()
+    start = start or end - timedelta(days=29)
+    _check_period(start, end)
+    return start, end
 
 
 @router.get("/", response_model=Portfolio)
-async def get_portfolio(user_id: int = 1):
+async def get_portfolio(
+    user_id: int = Depends(get_current_user_id),
+    portfolio_service: PortfolioService = Depends(get_portfolio_service),
+):
     """
     Get portfolio information for a user
     """
-    # Placeholder data - replace with actual database service
-    positions = [
-        Position(
-            id=1,
-            portfolio_id=1,
-            stock_symbol="AAPL",
-            quantity=10,
-            average_price=145.00,
-            current_value=1502.50,
-            total_gain=52.50
-        ),
-        Position(
-            id=2,
-            portfolio_id=1,
-            stock_symbol="GOOGL",
-            quantity=2,
-            average_price=2700.00,
-            current_value=5501.60,
-            total_gain=101.60
-        ),
-        Position(
-            id=3,
-            portfolio_id=1,
-            stock_symbol="MSFT",
-            quantity=5,
-            average_price=300.00,
-            current_value=1552.50,
-            total_gain=52.50
-        )
-    ]
-    
-    portfolio = Portfolio(
-        id=1,
-        user_id=user_id,
-        total_value=8556.60,
-        total_gain=206.60,
-        created_at="2025-07-01T10:00:00",
-        updated_at="2025-08-05T10:30:00",
-        positions=positions
-    )
-    
+    portfolio = await portfolio_service.get_portfolio(user_id)
+    if portfolio is None:
+        raise errors.portfolio_not_found()
     return portfolio
 
 
 @router.get("/positions", response_model=List[Position])
-async def get_positions(user_id: int = 1):
+async def get_positions(
+    user_id: int = Depends(get_current_user_id),
+    tag: Optional[str] = Query(None, description="Only positions with this tag"),
+    sort_by: Optional[str] = Query(None, description=", ".join(POSITION_SORT_FIELDS)),
+    order: Optional[str] = Query(None, description="asc or desc; needs sort_by"),
+    portfolio_service: PortfolioService = Depends(get_portfolio_service),
+):
     """
     Get all positions for a user's portfolio
     """
-    # Placeholder data - replace with actual database service
-    positions = [
-        Position(
-            id=1,
-            portfolio_id=1,
-            stock_symbol="AAPL",
-            quantity=10,
-            average_price=145.00,
-            current_value=1502.50,
-            total_gain=52.50
-        ),
-        Position(
-            id=2,
-            portfolio_id=1,
-            stock_symbol="GOOGL",
-            quantity=2,
-            average_price=2700.00,
-            current_value=5501.60,
-            total_gain=101.60
-        )
-    ]
-    
+    try:
+        sort = check_sort(sort_by, order, POSITION_SORT_FIELDS)
+    except ParamConflictError as e:
+        raise errors.param_conflict(str(e))
+
+    portfolio = await portfolio_service.get_portfolio(user_id)
+    if portfolio is None:
+        raise errors.portfolio_not_found()
+    positions = await portfolio_service.get_positions(portfolio.id, tag)
+    if sort is not None:
+        field, descending = sort
+        positions.sort(key=lambda p: getattr(p, field), reverse=descending)
     return positions
 
 
-@router.post("/positions")
-async def create_position(position_data: dict):
+@router.post("/positions/import", response_model=PositionImportResult)
+async def import_positions(
+    file: UploadFile = File(..., description="CSV: symbol,quantity,average_price"),
+    mode: ImportMode = Query(ImportMode.MERGE),
+    user_id: int = Depends(get_current_user_id),
+    portfolio_service: PortfolioService = Depends(get_portfolio_service),
+    import_service: PositionImportService = Depends(get_position_import_service),
+):
+    """
+    Bulk-load positions from a CSV upload into the user's portfolio.
+
+    `merge` sets the quantity and average price of each symbol in the file
+    and keeps other positions; `replace` deletes every position first. Every
+    row is checked before anything changes: if any is invalid, nothing is
+    imported and the 422 lists each bad row and why in `context.errors`.
+    """
+    portfolio_id = portfolio_service.ensure_portfolio(user_id)
+    try:
+        return await import_service.import_csv(portfolio_id, await file.read(), mode)
+    except PositionImportError as e:
+        raise errors.position_import_invalid([error.model_dump() for error in e.errors])
+
+
+@router.get("/positions/export", response_class=StreamingResponse)
+async def export_positions(
+    user_id: int = Depends(get_current_user_id),
+    portfolio_service: PortfolioService = Depends(get_portfolio_service),
+    export_service: ExportService = Depends(get_export_service),
+):
+    """
+    Download the user's positions as CSV, in a format the import accepts.
+    """
+    portfolio = await portfolio_service.get_portfolio(user_id)
+    if portfolio is None:
+        raise errors.portfolio_not_found()
+    content = await export_service.positions_csv(portfolio.id)
+    filename = f"positions-{date.today().isoformat()}.csv"
+    return StreamingResponse(
+        iter([content]),
+        media_type=MEDIA_TYPES[ExportFormat.CSV],
+        headers={"Content-Disposition": f'attachment; filename="{filename}"'},
+    )
+
+
+@router.post("/positions", response_model=Position)
+async def create_position(
+    position_data: PositionCreate,
+    user_id: int = Depends(get_current_user_id),
+    portfolio_service: PortfolioService = Depends(get_portfolio_service),
+):
+    """
+    Create a new position in the portfolio, valued at cost until the next
+    quote refresh.
+
+    A symbol the portfolio already holds is added to the existing position,
+    at the quantity-weighted average price.
+    """
+    if await portfolio_service.owner_of(position_data.portfolio_id) != user_id:
+        raise errors.portfolio_not_found()
+    try:
+        return await portfolio_service.create_position(position_data.model_dump())
+    except SymbolRestrictedError as e:
+        raise errors.symbol_restricted(e.symbol)
+
+
+@router.put("/positions/{position_id}", response_model=Position)
+async def replace_own_position(
+    position_id: int,
+    position_data: PositionBase,
+    user_id: int = Depends(get_current_user_id),
+    portfolio_service: PortfolioService = Depends(get_portfolio_service),
+):
+    """
+    Replace a position in the user's portfolio, including its notes and tags.
+
+    Moving it to a symbol another position holds is a 409; update that
+    position instead. Portfolio totals are summed from the positions, so
+    they follow from the next read on.
+    """
+    portfolio = await portfolio_service.get_portfolio(user_id)
+    if portfolio is None:
+        raise errors.portfolio_not_found()
+    return await _replace_position(
+        portfolio.id, position_id, position_data, portfolio_service
+    )
+
+
+@router.delete(
+    "/positions/{position_id}",
+    status_code=status.HTTP_204_NO_CONTENT,
+    response_class=Response,
+)
+async def close_position(
+    position_id: int,
+    user_id: int = Depends(get_current_user_id),
+    portfolio_service: PortfolioService = Depends(get_portfolio_service),
+):
+    """
+    Close out a position in the user's portfolio.
+
+    Portfolio totals are summed from the remaining positions, so they drop
+    the position from the next read on.
+    """
+    portfolio = await portfolio_service.get_portfolio(user_id)
+    if portfolio is None:
+        raise errors.portfolio_not_found()
+    if not await portfolio_service.delete_position(portfolio.id, position_id):
+        raise errors.position_not_found(portfolio.id, position_id)
+
+
+@router.post(
+    "/{portfolio_id}/positions",
+    response_model=Position,
+    status_code=status.HTTP_201_CREATED,
+)
+async def add_position(
+    portfolio_id: int,
+    position_data: PositionBase,
+    portfolio_service: PortfolioService = Depends(get_portfolio_service),
+):
+    """
+    Add a position to a portfolio; see POST /positions for how it's valued
+    and merged.
+
+    stock_symbol, quantity and average_price are required, and quantity must
+    be positive. A body that isn't JSON gets a 400.
+    """
+    if await portfolio_service.get_portfolio_by_id(portfolio_id) is None:
+        raise errors.portfolio_not_found()
+    try:
+        return await portfolio_service.create_position(
+            {**position_data.model_dump(), "portfolio_id": portfolio_id}
+        )
+    except SymbolRestrictedError as e:
+        raise errors.symbol_restricted(e.symbol)
+
+
+@router.get("/performance", response_model=PerformanceHistory)
+async def get_portfolio_performance(
+    start: Optional[date] = Query(
+        None, alias="from", description="Defaults to 29 days before `to`"
+    ),
+    end: Optional[date] = Query(None, alias="to", description="Defaults to today"),
+    granularity: Granularity = Granularity.DAILY,
+    user_id: int = Depends(get_current_user_id),
+    portfolio_service: PortfolioService = Depends(get_portfolio_service),
+    snapshot_service: SnapshotService = Depends(get_snapshot_service),
+):
+    """
+    The user's portfolio value over time, from its daily snapshots, with the
+    period return and maximum drawdown.
+
+    Days without a snapshot (weekends, days before the first) are left out.
+    Weekly and monthly points are the last snapshot of each week or month.
+    """
+    portfolio = await portfolio_service.get_portfolio(user_id)
+    if portfolio is None:
+        raise errors.portfolio_not_found()
+    start, end = _performance_window(start, end)
+    return await snapshot_service.get_history(portfolio.id, start, end, granularity)
+
+
+@router.post(
+    "/{portfolio_id}/positions/{position_id}/adjust", response_model=Position
+)
+async def adjust_position(
+    portfolio_id: int,
+    position_id: int,
+    adjustment: PositionAdjustment,
+    portfolio_service: PortfolioService = Depends(get_portfolio_service),
+):
+    """
+    Correct a position after a corporate action such as a stock split.
+
+    Either applies a split ratio (quantity multiplied, average price divided)
+    or sets an explicit average price. The change is recorded in the audit log.
+    """
+    try:
+        position = await portfolio_service.adjust_position(
+            portfolio_id, position_id, adjustment
+        )
+    except ValueError as e:
+        raise errors.position_adjustment_invalid(str(e))
+
+    if position is None:
+        raise errors.position_not_found(portfolio_id, position_id)
+    return position
+
+
+@router.put("/{portfolio_id}/positions/{position_id}", response_model=Position)
+async def replace_position(
+    portfolio_id: int,
+    position_id: int,
+    position_data: PositionBase,
+    portfolio_service: PortfolioService = Depends(get_portfolio_service),
+):
+    """
+    Replace a position's fields, including its notes and tags; see PUT
+    /positions/{position_id}
+    """
+    return await _replace_position(
+        portfolio_id, position_id, position_data, portfolio_service
+    )
+
+
+@router.patch("/{portfolio_id}/positions/{position_id}", response_model=Position)
+async def update_position(
+    portfolio_id: int,
+    position_id: int,
+    position_data: PositionUpdate,
+    portfolio_service: PortfolioService = Depends(get_portfolio_service),
+):
     """
-    Create a new position in the portfolio
+    Update only the fields sent, e.g. {"notes": "...", "tags": ["long-term"]}
     """
-    # Placeholder endpoint - implement with actual database service
-    return {
-        "message": "Position created successfully",
-        "position": position_data
-    }
+    try:
+        position = await portfolio_service.update_position(
+            portfolio_id, position_id, position_data.model_dump(exclude_unset=True)
+        )
+    except SymbolRestrictedError as e:
+        raise errors.symbol_restricted(e.symbol)
+    if position is None:
+        raise errors.position_not_found(portfolio_id, position_id)
+    return position
 
 
-@router.get("/performance")
-async def get_portfolio_performance(user_id: int = 1, days: int = 30):
+@router.delete("/{portfolio_id}/positions/{position_id}")
+async def delete_position(
+    portfolio_id: int,
+    position_id: int,
+    portfolio_service: PortfolioService = Depends(get_portfolio_service),
+):
     """
-    Get portfolio performance over time
+    Delete a position from the portfolio
+    """
+    if not await portfolio_service.delete_position(portfolio_id, position_id):
+        raise errors.position_not_found(portfolio_id, position_id)
+    return {"message": "Position deleted"}
+
+
+@router.post("/{portfolio_id}/positions/rebuild", response_model=PositionRebuild)
+async def rebuild_positions(
+    portfolio_id: int,
+    apply: bool = Query(False, description="Rebuild; otherwise only compare"),
+    portfolio_service: PortfolioService = Depends(get_portfolio_service),
+    ledger_service: LedgerService = Depends(get_ledger_service),
+):
+    """
+    Compare each position with what the transactions add up to at average
+    cost, and with `apply=true`, rebuild the ones that differ and take the
+    ledger's realized gain.
+
+    Positions in symbols with no transactions (entered directly or seeded),
+    or whose transactions sell shares entered directly, are listed as
+    untracked and never changed; while the ledger has any of the latter, the
+    realized gain is left alone. 400 if the ledger leaves a fractional share
+    count.
+    """
+    transactions = await ledger_service.get_transactions(portfolio_id)
+    try:
+        rebuild = await portfolio_service.rebuild_positions(
+            portfolio_id, transactions, apply=apply
+        )
+    except ValueError as e:
+        raise errors.transaction_invalid(str(e))
+    if rebuild is None:
+        raise errors.portfolio_not_found()
+    return rebuild
+
+
+@router.get("/{portfolio_id}/attention", response_model=List[AttentionPosition])
+async def get_positions_needing_attention(
+    portfolio_id: int,
+    attention_service: AttentionService = Depends(get_attention_service),
+):
+    """
+    Get positions that need attention and why.
+
+    A position is flagged for a large single-day move, drift from its target
+    weight, or a triggered price alert. Positions with no flags are omitted.
+    """
+    flagged = await attention_service.get_attention(portfolio_id)
+    if flagged is None:
+        raise errors.portfolio_not_found()
+    return flagged
+
+
+@router.get("/{portfolio_id}/yield", response_model=PortfolioYield)
+async def get_portfolio_yield(
+    portfolio_id: int,
+    dividend_service: DividendService = Depends(get_dividend_service),
+):
+    """
+    Get the portfolio's value-weighted dividend yield.
+
+    Positions whose symbol has no dividend yield count as 0 and are listed
+    in missing_yield.
+    """
+    portfolio_yield = await dividend_service.get_portfolio_yield(portfolio_id)
+    if portfolio_yield is None:
+        raise errors.portfolio_not_found()
+    return portfolio_yield
+
+
+@router.get("/{portfolio_id}/pe", response_model=PortfolioPE)
+async def get_portfolio_pe(
+    portfolio_id: int,
+    valuation_service: ValuationService = Depends(get_valuation_service),
+):
+    """
+    Get the value-weighted average PE ratio of the portfolio's holdings.
+
+    Holdings with a negative or missing PE are excluded and counted in
+    excluded_count.
+    """
+    portfolio_pe = await valuation_service.get_portfolio_pe(portfolio_id)
+    if portfolio_pe is None:
+        raise errors.portfolio_not_found()
+    return portfolio_pe
+
+
+@router.get("/{portfolio_id}/composition", response_model=PortfolioComposition)
+async def get_portfolio_composition(
+    portfolio_id: int,
+    as_of: Optional[date] = Query(None, description="Defaults to today"),
+    composition_service: CompositionService = Depends(get_composition_service),
+):
+    """
+    What the portfolio held at the end of a day, replayed from its ledger
+    and valued at that day's closes.
+
+    Before the first transaction the composition is empty. Holdings with no
+    close in the week up to the day have no value and are listed in
+    missing_prices.
+    """
+    composition = await composition_service.get_composition(
+        portfolio_id, as_of or date.today()
+    )
+    if composition is None:
+        raise errors.portfolio_not_found()
+    return composition
+
+
+@router.get(
+    "/{portfolio_id}/corporate-actions", response_model=PortfolioCorporateActions
+)
+async def get_corporate_actions(
+    portfolio_id: int,
+    start: Optional[date] = Query(
+        None, alias="from", description="Defaults to a year before `to`"
+    ),
+    end: Optional[date] = Query(None, alias="to", description="Defaults to today"),
+    corporate_action_service: CorporateActionService = Depends(
+        get_corporate_action_service
+    ),
+):
+    """
+    Splits and dividends with an ex-date in the period on symbols the
+    portfolio held going into them.
+
+    Each comes with the shares held and, for dividends, the cash due, to
+    explain changes in quantities and cost basis.
+    """
+    end = end or date.today()
+    start = start or end - timedelta(days=365)
+    _check_period(start, end)
+
+    actions = await corporate_action_service.get_portfolio_actions(
+        portfolio_id, start, end
+    )
+    if actions is None:
+        raise errors.portfolio_not_found()
+    return actions
+
+
+@router.get("/{portfolio_id}/metrics", response_model=PeriodMetrics)
+async def get_performance_metrics(
+    portfolio_id: int,
+    start: date = Query(..., alias="from"),
+    end: Optional[date] = Query(None, alias="to", description="Defaults to today"),
+    performance_service: PerformanceService = Depends(get_performance_service),
+):
+    """
+    Return, volatility, CAGR, max drawdown, Ulcer Index, beta, and Sharpe,
+    Calmar and Treynor ratios.
+
+    The Ulcer Index is the root mean square of the percent drawdowns from the
+    running peak, so it weighs how long the portfolio stayed down as well as
+    how far. The Calmar ratio is CAGR over the maximum drawdown. Both are 0
+    when the portfolio never fell during the period. Beta is against the
+    default benchmark, and the Treynor ratio is CAGR over the risk-free rate
+    per unit of it; both are null when the benchmark has too few prices or is
+    flat.
+    """
+    end = end or date.today()
+    _check_period(start, end)
+
+    try:
+        metrics = await performance_service.metrics(portfolio_id, start, end)
+    except ValueError as e:
+        raise errors.analytics_invalid_request(str(e))
+    if metrics is None:
+        raise errors.portfolio_not_found()
+    return metrics
+
+
+@router.get("/{portfolio_id}/history", response_model=PerformanceHistory)
+async def get_portfolio_history(
+    portfolio_id: int,
+    start: Optional[date] = Query(
+        None, alias="from", description="Defaults to 29 days before `to`"
+    ),
+    end: Optional[date] = Query(None, alias="to", description="Defaults to today"),
+    granularity: Granularity = Granularity.DAILY,
+    portfolio_service: PortfolioService = Depends(get_portfolio_service),
+    snapshot_service: SnapshotService = Depends(get_snapshot_service),
+):
+    """
+    A portfolio's value and gain over time from its snapshots, like
+    /performance for any of the user's portfolios.
+
+    Points are in date order; there are none until the first snapshot.
+    """
+    start, end = _performance_window(start, end)
+    if await portfolio_service.get_portfolio_by_id(portfolio_id) is None:
+        raise errors.portfolio_not_found()
+    return await snapshot_service.get_history(portfolio_id, start, end, granularity)
+
+
+@router.get("/{portfolio_id}/snapshots", response_model=List[PortfolioSnapshot])
+async def get_snapshots(
+    portfolio_id: int,
+    start: date = Query(..., alias="from"),
+    end: Optional[date] = Query(None, alias="to", description="Defaults to today"),
+    portfolio_service: PortfolioService = Depends(get_portfolio_service),
+    snapshot_service: SnapshotService = Depends(get_snapshot_service),
+):
+    """
+    Daily snapshots of the portfolio's value and holdings, oldest first.
+
+    Metrics use these values where they exist.
+    """
+    end = end or date.today()
+    _check_period(start, end)
+    if await portfolio_service.get_portfolio_by_id(portfolio_id) is None:
+        raise errors.portfolio_not_found()
+    return await snapshot_service.get_snapshots(portfolio_id, start, end)
+
+
+@router.post(
+    "/{portfolio_id}/snapshots",
+    response_model=PortfolioSnapshot,
+    status_code=status.HTTP_201_CREATED,
+)
+async def take_snapshot(
+    portfolio_id: int,
+    snapshot_service: SnapshotService = Depends(get_snapshot_service),
+):
+    """
+    Snapshot the portfolio now, at the latest prices, rather than waiting for
+    the scheduled job. Replaces today's snapshot if there is one.
+    """
+    snapshot = await snapshot_service.take(portfolio_id)
+    if snapshot is None:
+        raise errors.portfolio_not_found()
+    return snapshot
+
+
+@router.get("/{portfolio_id}/compare-periods", response_model=PeriodComparison)
+async def compare_periods(
+    portfolio_id: int,
+    a_from: date,
+    a_to: date,
+    b_from: date,
+    b_to: date,
+    performance_service: PerformanceService = Depends(get_performance_service),
+):
+    """
+    Compare total return, volatility and Sharpe ratio of two periods.
+
+    Deltas are period a minus period b, so pass this quarter as a and last
+    quarter as b. Periods may overlap.
+    """
+    _check_period(a_from, a_to)
+    _check_period(b_from, b_to)
+
+    try:
+        comparison = await performance_service.compare_periods(
+            portfolio_id, a_from, a_to, b_from, b_to
+        )
+    except ValueError as e:
+        raise errors.analytics_invalid_request(str(e))
+    if comparison is None:
+        raise errors.portfolio_not_found()
+    return comparison
+
+
+@router.put("/{portfolio_id}/cash", response_model=CashBalance)
+async def set_cash_balance(
+    portfolio_id: int,
+    update: CashBalanceUpdate,
+    portfolio_service: PortfolioService = Depends(get_portfolio_service),
+):
+    """
+    Record the portfolio's uninvested cash, effective from `as_of` (today)
+    """
+    as_of = update.as_of or date.today()
+    if not await portfolio_service.set_cash_balance(
+        portfolio_id, update.balance, as_of
+    ):
+        raise errors.portfolio_not_found()
+    return CashBalance(portfolio_id=portfolio_id, balance=update.balance, as_of=as_of)
+
+
+@router.get("/{portfolio_id}/cash-drag", response_model=CashDrag)
+async def get_cash_drag(
+    portfolio_id: int,
+    start: date = Query(..., alias="from"),
+    end: Optional[date] = Query(None, alias="to", description="Defaults to today"),
+    benchmark: Optional[str] = Query(None, description="Defaults to BENCHMARK_SYMBOL"),
+    performance_service: PerformanceService = Depends(get_performance_service),
+):
+    """
+    Estimate what uninvested cash cost against being fully in the benchmark.
+
+    Uses the recorded cash balance history and the benchmark's daily closes;
+    a positive drag means the cash would have earned more invested.
+    """
+    end = end or date.today()
+    _check_period(start, end)
+
+    try:
+        drag = await performance_service.cash_drag(portfolio_id, start, end, benchmark)
+    except ValueError as e:
+        raise errors.analytics_invalid_request(str(e))
+    if drag is None:
+        raise errors.portfolio_not_found()
+    return drag
+
+
+@router.get("/{portfolio_id}/rolling-beta", response_model=RollingBeta)
+async def get_rolling_beta(
+    portfolio_id: int,
+    window: int = Query(60, ge=2, description="Daily returns per beta"),
+    start: Optional[date] = Query(
+        None, alias="from", description="Defaults to a year before `to`"
+    ),
+    end: Optional[date] = Query(None, alias="to", description="Defaults to today"),
+    benchmark: Optional[str] = Query(None, description="Defaults to BENCHMARK_SYMBOL"),
+    performance_service: PerformanceService = Depends(get_performance_service),
+):
+    """
+    Beta against the benchmark over a sliding window of daily returns.
+
+    Shows how the portfolio's sensitivity to the market changes over time;
+    a window longer than the available returns is rejected.
+    """
+    end = end or date.today()
+    start = start or end - timedelta(days=365)
+    _check_period(start, end)
+
+    try:
+        betas = await performance_service.rolling_beta(
+            portfolio_id, start, end, window, benchmark
+        )
+    except ValueError as e:
+        raise errors.analytics_invalid_request(str(e))
+    if betas is None:
+        raise errors.portfolio_not_found()
+    return betas
+
+
+@router.post("/{portfolio_id}/transactions", response_model=Transaction)
+async def record_transaction(
+    portfolio_id: int,
+    transaction: TransactionRequest,
+    force: bool = Query(False, description="Record it even if it looks duplicated"),
+    portfolio_service: PortfolioService = Depends(get_portfolio_service),
+    ledger_service: LedgerService = Depends(get_ledger_service),
+):
+    """
+    Record a buy or sell in the portfolio's ledger and apply it to the
+    position in the symbol.
+
+    Buys add to the position at a weighted average price, fees included,
+    creating it if needed. Sells reduce it, adding their gain over the
+    average price to the portfolio's realized gain. Sells of more shares than
+    the ledger held at the time, or than a position entered directly holds,
+    and transactions that would leave a fractional share count, are rejected
+    with 400. With
+    expected_price, the transaction is rejected with 409 and the current price
+    when the live price has moved beyond the tolerance, so the client can ask
+    the user to confirm again, and with the provider's error (e.g. 502) when
+    the live price couldn't be fetched.
+
+    A transaction with the same symbol, side, quantity and price as one
+    recorded in the last DUPLICATE_TRANSACTION_WINDOW_SECONDS is taken for a
+    double submission: it's rejected with 409 and the existing transaction,
+    unless sent with `force=true`.
+    """
+    if await portfolio_service.get_portfolio_by_id(portfolio_id) is None:
+        raise errors.portfolio_not_found()
+    execution = TransactionCreate(
+        **transaction.model_dump(exclude={"expected_price", "tolerance"})
+    )
+    try:
+        return await ledger_service.record(
+            portfolio_id,
+            execution,
+            expected_price=transaction.expected_price,
+            tolerance=transaction.tolerance,
+            force=force,
+        )
+    except SymbolRestrictedError as e:
+        raise errors.symbol_restricted(e.symbol)
+    except DuplicateTransactionError as e:
+        raise errors.transaction_duplicate(
+            e.existing.id, e.window, e.existing.model_dump(mode="json")
+        )
+    except PriceMovedError as e:
+        raise errors.price_moved(e.symbol, e.expected_price, e.current_price)
+    except PriceCheckError as e:
+        raise errors.provider_error(e.cause)
+    except ValueError as e:
+        raise errors.transaction_invalid(str(e))
+
+
+@router.get("/{portfolio_id}/transactions", response_model=List[Transaction])
+async def get_transactions(
+    portfolio_id: int,
+    symbol: Optional[str] = None,
+    start: Optional[date] = Query(None, alias="from"),
+    end: Optional[date] = Query(None, alias="to"),
+    portfolio_service: PortfolioService = Depends(get_portfolio_service),
+    ledger_service: LedgerService = Depends(get_ledger_service),
+):
+    """
+    Get the portfolio's transactions, oldest first, optionally only those in
+    a symbol or executed between from and to (inclusive)
+    """
+    if start is not None and end is not None and end < start:
+        raise errors.history_range_invalid(
+            f"Period start {start} is after its end {end}"
+        )
+    if await portfolio_service.get_portfolio_by_id(portfolio_id) is None:
+        raise errors.portfolio_not_found()
+    return await ledger_service.get_transactions(portfolio_id, symbol, start, end)
+
+
+@router.get("/{portfolio_id}/export", response_class=StreamingResponse)
+async def export_portfolio(
+    portfolio_id: int,
+    export_format: ExportFormat = Query(ExportFormat.CSV, alias="format"),
+    start: Optional[date] = Query(
+        None, alias="from", description="Defaults to a year before `to`"
+    ),
+    end: Optional[date] = Query(None, alias="to", description="Defaults to today"),
+    export_service: ExportService = Depends(get_export_service),
+):
+    """
+    Download the portfolio as a file.
+
+    `csv` holds the positions. `xlsx` is a workbook with Positions,
+    Transactions and Performance (daily value between from and to) sheets,
+    with numbers and dates stored as such.
+    """
+    end = end or date.today()
+    start = start or end - timedelta(days=365)
+    _check_period(start, end)
+
+    content = await export_service.export(portfolio_id, export_format, start, end)
+    if content is None:
+        raise errors.portfolio_not_found()
+    filename = f"portfolio-{portfolio_id}-{end.isoformat()}.{export_format.value}"
+    return StreamingResponse(
+        iter([content]),
+        media_type=MEDIA_TYPES[export_format],
+        headers={"Content-Disposition": f'attachment; filename="{filename}"'},
+    )
+
+
+@router.get("/{portfolio_id}/trade-stats", response_model=TradeStats)
+async def get_trade_stats(
+    portfolio_id: int,
+    trade_stats_service: TradeStatsService = Depends(get_trade_stats_service),
+):
+    """
+    Win rate, average win and loss, and profit factor of closed trades.
+
+    Each sell is a closed trade, matched against the oldest buys (FIFO);
+    shares still held don't count.
+    """
+    stats = await trade_stats_service.get_trade_stats(portfolio_id)
+    if stats is None:
+        raise errors.portfolio_not_found()
+    return stats
+
+
+@router.get("/{portfolio_id}/rebalance", response_model=RebalancePlan)
+async def get_rebalance_plan(
+    portfolio_id: int,
+    rebalance_service: RebalanceService = Depends(get_rebalance_service),
+):
+    """
+    Whole-share trades that bring positions to their target weights, and the
+    gains the sells would realize.
+
+    Sells close the ledger's oldest open lots first (FIFO); a lot's gain is
+    long term when it was bought more than a year before today. Positions
+    without a target weight aren't traded.
+    """
+    plan = await rebalance_service.get_plan(portfolio_id)
+    if plan is None:
+        raise errors.portfolio_not_found()
+    return plan
+
+
+@router.get("/{portfolio_id}/holding-period", response_model=HoldingPeriod)
+async def get_holding_period(
+    portfolio_id: int,
+    holding_period_service: HoldingPeriodService = Depends(get_holding_period_service),
+):
+    """
+    How long the open positions have been held, in days.
+
+    Sells close the ledger's oldest lots first (FIFO), and each lot still
+    open counts from its buy. The portfolio average weights lots by their
+    current value; each position's average by their shares.
+    """
+    holding_period = await holding_period_service.get_holding_period(portfolio_id)
+    if holding_period is None:
+        raise errors.portfolio_not_found()
+    return holding_period
+
+
+@router.get("/{portfolio_id}/concentration", response_model=PortfolioConcentration)
+async def get_portfolio_concentration(
+    portfolio_id: int,
+    concentration_service: ConcentrationService = Depends(get_concentration_service),
+):
+    """
+    Concentration of the portfolio's holdings by current value.
+
+    hhi is the sum of squared weights (1/N for N equal positions, 1 for a
+    single holding); the portfolio is flagged above
+    CONCENTRATION_HHI_THRESHOLD.
     """
-    # Placeholder endpoint - implement with actual calculation
-    return {
-        "user_id": user_id,
-        "days": days,
-        "total_return": 206.60,
-        "total_return_percent": 2.48,
-        "message": "Portfolio performance endpoint - to be implemented"
-    }
+    concentration = await concentration_service.get_concentration(portfolio_id)
+    if concentration is None:
+        raise errors.portfolio_not_found()
+    return concentration
@@ -0,0 +1,147 @@
+from typing import List
+from fastapi import APIRouter, Depends, Query, Response, status
+from app.core import errors
+from app.core.deps import get_current_user_id
+from app.models.schemas import Watchlist, WatchlistCreate, WatchlistOrder
+from app.services.market import MarketService, get_market_service
+from app.services.watchlists import (
+    WatchlistOrderError,
+    WatchlistService,
+    get_watchlist_service,
+)
+from app.utils.symbols import (
+    InvalidSymbolError,
+    SymbolRestrictedError,
+    check_symbol,
+    parse_symbol,
+)
+
+router = APIRouter()
+
+
+@router.get("/", response_model=List[Watchlist])
+async def get_watchlists(
+    user_id: int = Depends(get_current_user_id),
+    watchlist_service: WatchlistService = Depends(get_watchlist_service),
+):
+    """
+    List the user's watchlists in their display order, with each symbol's
+    current price; new watchlists go last
+    """
+    return await watchlist_service.get_watchlists(user_id)
+
+
+@router.put("/order", response_model=List[Watchlist])
+async def reorder_watchlists(
+    order: WatchlistOrder,
+    user_id: int = Depends(get_current_user_id),
+    watchlist_service: WatchlistService = Depends(get_watchlist_service),
+):
+    """
+    Set the display order of the user's watchlists, all at once.
+
+    `ids` must list every one of the user's watchlists exactly once; otherwise
+    it's a 400 and the order is left as it was.
+    """
+    try:
+        return await watchlist_service.reorder_watchlists(user_id, order.ids)
+    except WatchlistOrderError as e:
+        raise errors.watchlist_order_invalid(str(e))
+
+
+@router.post("/", response_model=Watchlist, status_code=status.HTTP_201_CREATED)
+async def create_watchlist(
+    watchlist: WatchlistCreate,
+    response: Response,
+    strict: bool = Query(False, description="409 instead of 200 if the name is taken"),
+    user_id: int = Depends(get_current_user_id),
+    watchlist_service: WatchlistService = Depends(get_watchlist_service),
+):
+    """
+    Create a watchlist (201).
+
+    Names are unique per user, so retrying a create is safe: if the user
+    already has a watchlist with the name, it's returned unchanged with a
+    200, or rejected with a 409 when `strict` is set.
+    """
+    try:
+        created, is_new = await watchlist_service.create_watchlist(user_id, watchlist)
+    except SymbolRestrictedError as e:
+        raise errors.symbol_restricted(e.symbol)
+    if not is_new:
+        if strict:
+            raise errors.watchlist_name_taken(watchlist.name)
+        response.status_code = status.HTTP_200_OK
+    return created
+
+
+@router.delete(
+    "/{watchlist_id}",
+    status_code=status.HTTP_204_NO_CONTENT,
+    response_class=Response,
+)
+async def delete_watchlist(
+    watchlist_id: int,
+    user_id: int = Depends(get_current_user_id),
+    watchlist_service: WatchlistService = Depends(get_watchlist_service),
+):
+    """
+    Delete a watchlist and its symbols (204)
+    """
+    if not await watchlist_service.delete_watchlist(user_id, watchlist_id):
+        raise errors.watchlist_not_found(watchlist_id)
+
+
+@router.post(
+    "/{watchlist_id}/symbols/{symbol}",
+    response_model=Watchlist,
+    status_code=status.HTTP_201_CREATED,
+)
+async def add_watchlist_symbol(
+    watchlist_id: int,
+    symbol: str,
+    response: Response,
+    user_id: int = Depends(get_current_user_id),
+    watchlist_service: WatchlistService = Depends(get_watchlist_service),
+    market_service: MarketService = Depends(get_market_service),
+):
+    """
+    Add a stock to a watchlist (201).
+
+    Adding a symbol that's already on the list changes nothing and returns a
+    200. The stock has to exist: unknown symbols are a 404.
+    """
+    try:
+        symbol = check_symbol(parse_symbol(symbol))
+    except InvalidSymbolError as e:
+        raise errors.symbol_invalid(str(e))
+    except SymbolRestrictedError as e:
+        raise errors.symbol_restricted(e.symbol)
+    if await market_service.get_stock_by_symbol(symbol) is None:
+        raise errors.stock_not_found(symbol)
+
+    added = await watchlist_service.add_symbol(user_id, watchlist_id, symbol)
+    if added is None:
+        raise errors.watchlist_not_found(watchlist_id)
+    watchlist, is_new = added
+    if not is_new:
+        response.status_code = status.HTTP_200_OK
+    return watchlist
+
+
+@router.delete(
+    "/{watchlist_id}/symbols/{symbol}",
+    status_code=status.HTTP_204_NO_CONTENT,
+    response_class=Response,
+)
+async def remove_watchlist_symbol(
+    watchlist_id: int,
+    symbol: str,
+    user_id: int = Depends(get_current_user_id),
+    watchlist_service: WatchlistService = Depends(get_watchlist_service),
+):
+    """
+    Remove a stock from a watchlist (204, also if it wasn't on the list)
+    """
+    if await watchlist_service.remove_symbol(user_id, watchlist_id, symbol) is None:
+        raise errors.watchlist_not_found(watchlist_id)
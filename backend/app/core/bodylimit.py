@@ -0,0 +1,66 @@
+"""
+Request body size limit for Quant-Dash.
+
+This module provides:
+1. Middleware answering 413 validation.body_too_large, before reading the
+   body, when Content-Length is over MAX_REQUEST_BODY_BYTES
+2. A byte count on bodies sent without a length (chunked), which stops
+   reading as soon as the limit is passed
+
+It wraps the ASGI receive channel rather than the request, so JSON bodies,
+forms and file uploads are all limited without the routes doing anything.
+Malformed JSON and unknown fields are rejected when the body is parsed;
+see errors.from_validation_errors and schemas.RequestBody.
+"""
+
+from app.core import errors
+from starlette.exceptions import HTTPException
+from starlette.responses import JSONResponse
+
+
+class RequestBodyTooLarge(HTTPException):
+    """Raised while reading a body that passed the limit; rendered as a 413."""
+
+    def __init__(self, max_bytes: int):
+        super().__init__(413, f"Request body is larger than {max_bytes} bytes")
+
+
+def declared_length(headers) -> int:
+    """The request's Content-Length, or -1 if it has none or it's unreadable."""
+    for name, value in headers:
+        if name.lower() == b"content-length":
+            try:
+                return int(value)
+            except ValueError:
+                return -1
+    return -1
+
+
+class BodySizeLimitMiddleware:
+    """Rejects request bodies over max_bytes (0 = no limit)."""
+
+    def __init__(self, app, max_bytes: int):
+        self.app = app
+        self.max_bytes = max_bytes
+
+    async def __call__(self, scope, receive, send):
+        if scope["type"] != "http" or not self.max_bytes:
+            return await self.app(scope, receive, send)
+
+        if declared_length(scope.get("headers", [])) > self.max_bytes:
+            error = errors.body_too_large(self.max_bytes)
+            response = JSONResponse(errors.error_body(error), error.status_code)
+            return await response(scope, receive, send)
+
+        received = 0
+
+        async def limited_receive():
+            nonlocal received
+            message = await receive()
+            if message["type"] == "http.request":
+                received += len(message.get("body", b""))
+                if received > self.max_bytes:
+                    raise RequestBodyTooLarge(self.max_bytes)
+            return message
+
+        await self.app(scope, limited_receive, send)
@@ -0,0 +1,87 @@
+"""
+Per-route HTTP caching headers.
+
+This module provides:
+1. Route dependencies declaring a route's Cache-Control policy
+2. Middleware that writes the declared policy onto the response
+
+Routes opt in with `dependencies=[Depends(cache_for(60))]`, or a whole
+router does so when it's included. A route's own policy wins over its
+router's. Only successful, complete responses are marked cacheable (a 207
+batch is partly failed), and any request carrying credentials gets
+`private, no-store` whatever the route declared, so a shared cache never
+keeps one user's data for another. Dependencies that read a request header
+shaping the response call vary_on, so caches key on that header too.
+"""
+
+from typing import Callable, Optional
+
+from app.utils.multistatus import MULTI_STATUS
+from starlette.middleware.base import BaseHTTPMiddleware
+from starlette.requests import Request
+
+PRIVATE_NO_STORE = "private, no-store"
+
+
+def _declare(directive: str) -> Callable[[Request], None]:
+    def declare_cache_control(request: Request) -> None:
+        request.state.cache_control = directive
+
+    return declare_cache_control
+
+
+def cache_for(seconds: int) -> Callable[[Request], None]:
+    """Dependency letting shared caches reuse a route's response for seconds."""
+    return _declare(f"public, max-age={seconds}")
+
+
+def no_store() -> Callable[[Request], None]:
+    """Dependency marking a route's responses private and never stored."""
+    return _declare(PRIVATE_NO_STORE)
+
+
+def vary_on(request: Request, header: str) -> None:
+    """Record that the response depends on the request's header."""
+    vary = getattr(request.state, "cache_vary", [])
+    if header not in vary:
+        request.state.cache_vary = vary + [header]
+
+
+def resolve_cache_control(
+    declared: Optional[str], authenticated: bool, status_code: int
+) -> Optional[str]:
+    """
+    The Cache-Control value for a response, or None to leave it unset.
+
+    Credentials always mean no-store. Otherwise the declared policy applies
+    to successful responses only, so errors and partly failed batches are
+    never cached.
+    """
+    if authenticated:
+        return PRIVATE_NO_STORE
+    if declared is None or not 200 <= status_code < 300:
+        return None
+    if status_code == MULTI_STATUS:
+        # Some items failed; a cached copy would keep repeating their failures
+        return None
+    return declared
+
+
+class CacheControlMiddleware(BaseHTTPMiddleware):
+    """Applies the Cache-Control policy and Vary headers a route declared."""
+
+    async def dispatch(self, request: Request, call_next):
+        response = await call_next(request)
+        for header in getattr(request.state, "cache_vary", []):
+            response.headers.add_vary_header(header)
+        if "cache-control" in response.headers:
+            # The endpoint chose its own caching
+            return response
+        directive = resolve_cache_control(
+            getattr(request.state, "cache_control", None),
+            authenticated="authorization" in request.headers,
+            status_code=response.status_code,
+        )
+        if directive is not None:
+            response.headers["Cache-Control"] = directive
+        return response
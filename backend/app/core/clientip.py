@@ -0,0 +1,35 @@
+"""
+Client addresses for Quant-Dash.
+
+Everything keyed on the caller's address (the per-IP and API-wide rate
+limits, the login and registration limits, security logs) reads it through
+client_ip, so they all agree on who the client is behind a proxy.
+"""
+
+from typing import Optional
+
+from app.core.config import settings
+from starlette.requests import Request
+
+
+def client_ip(request: Request, trusted_proxies: Optional[int] = None) -> str:
+    """
+    The address a request came from.
+
+    Each proxy appends the address it was called from to X-Forwarded-For,
+    so with n trusted proxies the client is the n-th entry from the right.
+    Entries further left were sent by the client and could be anything.
+
+    Args:
+        trusted_proxies: Defaults to TRUSTED_PROXY_COUNT
+    """
+    if trusted_proxies is None:
+        trusted_proxies = settings.TRUSTED_PROXY_COUNT
+    peer = request.client.host if request.client else "unknown"
+    if trusted_proxies <= 0:
+        return peer
+    header = request.headers.get("x-forwarded-for", "")
+    hops = [hop.strip() for hop in header.split(",") if hop.strip()]
+    if len(hops) < trusted_proxies:
+        return peer
+    return hops[-trusted_proxies]
@@ -1,15 +1,40 @@
 import secrets
 from typing import Any, Dict, List, Optional, Union
 
-from pydantic import AnyHttpUrl, EmailStr, validator
+from app.core.cors import origin_pattern
+from pydantic import (
+    AliasChoices,
+    AnyHttpUrl,
+    EmailStr,
+    Field,
+    model_validator,
+    validator,
+)
 from pydantic_settings import BaseSettings
 
 
 class Settings(BaseSettings):
     API_V1_STR: str = "/api/v1"
-    SECRET_KEY: str
+    # Signs access tokens; required unless DEMO_MODE generates one. Also read
+    # from JWT_SECRET.
+    SECRET_KEY: str = Field(
+        "", validation_alias=AliasChoices("SECRET_KEY", "JWT_SECRET")
+    )
     PROJECT_NAME: str = "Quant-Dash"
     DEBUG: bool = False  # Enable debug mode for development
+    ENVIRONMENT: str = "development"  # development, staging or production
+
+    # Logging. LOG_LEVEL defaults to DEBUG with DEBUG on, INFO otherwise;
+    # LOG_FORMAT "json" writes one JSON object per line instead of text.
+    LOG_LEVEL: Optional[str] = None
+    LOG_FORMAT: str = "text"
+
+    @validator("LOG_FORMAT")
+    def check_log_format(cls, v: str) -> str:
+        v = v.strip().lower()
+        if v not in ("text", "json"):
+            raise ValueError("LOG_FORMAT must be text or json")
+        return v
 
     # JWT Configuration - Critical for financial platform security
     # Short access token = better security, refresh token = better UX
@@ -23,6 +48,13 @@ class Settings(BaseSettings):
     JWT_AUDIENCE: str = "quant-dash:auth"
     JWT_ISSUER: str = "quant-dash"
 
+    @validator("JWT_ALGORITHM")
+    def require_signed_tokens(cls, v: str) -> str:
+        # With "none", unsigned tokens would verify
+        if v.lower() == "none":
+            raise ValueError("JWT_ALGORITHM must be a signing algorithm, not none")
+        return v
+
     # Password Security - Enterprise grade
     PWD_CONTEXT_SCHEMES: List[str] = ["bcrypt"]
     PWD_CONTEXT_DEPRECATED: str = "auto"
@@ -33,22 +65,56 @@ class Settings(BaseSettings):
     EMAIL_VERIFICATION_EXPIRE_HOURS: int = 24
     SERVER_NAME: str = "localhost"
     SERVER_HOST: AnyHttpUrl = "http://localhost"
-    # BACKEND_CORS_ORIGINS is a JSON-formatted list of origins
-    # e.g: '["http://localhost", "http://localhost:4200", "http://localhost:3000", \
-    # "http://localhost:8080", "http://local.dockertoolbox.tiangolo.com"]'
-    BACKEND_CORS_ORIGINS: List[AnyHttpUrl] = [
-        "http://localhost:3000",  # React default
-        "http://localhost:8080",  # Alternative frontend port
-        "http://localhost:4200",  # Angular default
-    ]
+    # Origins browsers may call the API from, comma-separated (also read from
+    # BACKEND_CORS_ORIGINS): exact origins, or a leading wildcard such as
+    # https://*.example.com. Matching origins are echoed back with credentials
+    # allowed; unset or "*", any origin may call without credentials.
+    CORS_ALLOWED_ORIGINS: Optional[List[str]] = Field(
+        None,
+        validation_alias=AliasChoices("CORS_ALLOWED_ORIGINS", "BACKEND_CORS_ORIGINS"),
+    )
+    CORS_MAX_AGE_SECONDS: int = 600  # How long browsers may cache a preflight
 
-    @validator("BACKEND_CORS_ORIGINS", pre=True)
-    def assemble_cors_origins(cls, v: Union[str, List[str]]) -> Union[List[str], str]:
-        if isinstance(v, str) and not v.startswith("["):
-            return [i.strip() for i in v.split(",")]
-        elif isinstance(v, (list, str)):
-            return v
-        raise ValueError(v)
+    @validator("CORS_ALLOWED_ORIGINS", pre=True)
+    def assemble_cors_origins(
+        cls, v: Union[str, List[str], None]
+    ) -> Optional[List[str]]:
+        if v is None or (isinstance(v, str) and not v.strip()):
+            return None
+        if isinstance(v, str):
+            v = v.split(",")
+        # Browsers send origins without a trailing slash
+        origins = [origin.strip().rstrip("/") for origin in v if origin.strip()]
+        for origin in origins:
+            if origin != "*" and "*" in origin:
+                origin_pattern(origin)  # Raises for a misplaced wildcard
+        return origins
+
+    # Serving (python -m app.serve). HTTP/1.1 through uvicorn unless TLS or
+    # cleartext HTTP/2 (h2c) is configured, in which case Hypercorn serves
+    # HTTP/2 alongside HTTP/1.1.
+    BIND_HOST: str = "0.0.0.0"
+    BIND_PORT: int = 8000
+    TLS_CERT_FILE: Optional[str] = None  # PEM paths; h2 negotiated over ALPN
+    TLS_KEY_FILE: Optional[str] = None
+    HTTP2_CLEARTEXT: bool = False
+    # Server timeouts in seconds. A response not finished within the write
+    # timeout is cut off, and keep-alive connections close after sitting
+    # idle. The read timeout only applies under Hypercorn; uvicorn has no
+    # such setting (0 turns either off). On SIGINT/SIGTERM new connections
+    # are refused and in-flight requests get the grace period to finish.
+    SERVER_READ_TIMEOUT_SECONDS: int = 30
+    SERVER_WRITE_TIMEOUT_SECONDS: int = 60
+    SERVER_IDLE_TIMEOUT_SECONDS: int = 120
+    SHUTDOWN_GRACE_SECONDS: int = 10
+
+    # Largest request body accepted, uploads included; bigger ones get a 413
+    # before they're read into memory
+    MAX_REQUEST_BODY_BYTES: int = 1_048_576
+
+    # Port for a separate /metrics listener on BIND_HOST. Set, /metrics moves
+    # off the API port, so scrapes don't go through the public router.
+    METRICS_PORT: Optional[int] = None
 
     # Database
     POSTGRES_SERVER: str = "localhost"
@@ -63,6 +129,29 @@ class Settings(BaseSettings):
             return v
         return f"postgresql://{values.get('POSTGRES_USER')}:{values.get('POSTGRES_PASSWORD')}@{values.get('POSTGRES_SERVER')}/{values.get('POSTGRES_DB')}"
 
+    # Connection pool: at most DB_MAX_OPEN_CONNS connections, DB_MAX_IDLE_CONNS
+    # of which stay open between uses. Connections older than the lifetime
+    # are replaced, so none outlive a failover or a server-side idle timeout.
+    DB_MAX_OPEN_CONNS: int = 20
+    DB_MAX_IDLE_CONNS: int = 5
+    DB_CONN_MAX_LIFETIME_SECONDS: int = 1800
+
+    @validator("DB_MAX_IDLE_CONNS")
+    def idle_within_open_conns(cls, v: int, values: Dict[str, Any]) -> int:
+        max_open = values.get("DB_MAX_OPEN_CONNS")
+        if v < 0 or (max_open is not None and v > max_open):
+            raise ValueError(
+                "DB_MAX_IDLE_CONNS must be between 0 and DB_MAX_OPEN_CONNS"
+            )
+        return v
+
+    # Whether serving traffic needs the database, i.e. whether startup applies
+    # the pending migrations and /health/ready pings it. The services keep
+    # their data in memory until they're backed by it, so this is off by
+    # default.
+    DATABASE_REQUIRED: bool = False
+    READINESS_TIMEOUT_SECONDS: float = 2.0
+
     # Email
     SMTP_TLS: bool = True
     SMTP_PORT: Optional[int] = None
@@ -78,6 +167,135 @@ class Settings(BaseSettings):
     POLYGON_API_KEY: Optional[str] = None
     IEX_CLOUD_API_KEY: Optional[str] = None
 
+    # Live market data source: "finnhub", "alphavantage", or "synthetic" for
+    # generated prices that need no key (local development)
+    MARKET_DATA_PROVIDER: str = "finnhub"
+
+    @validator("MARKET_DATA_PROVIDER")
+    def known_market_data_provider(cls, v: str) -> str:
+        v = v.strip().lower()
+        if v not in ("finnhub", "alphavantage", "synthetic"):
+            raise ValueError(f"Unknown market data provider: {v}")
+        return v
+
+    # Symbol input is trimmed of whitespace and quotes and upper-cased, so
+    # "aapl " and "AAPL" are one symbol. Case-sensitive keeps the case given.
+    SYMBOL_CASE_SENSITIVE: bool = False
+
+    # Compliance: blocked symbols can't be viewed or traded, and a non-empty
+    # allowlist limits access to its symbols. JSON lists, e.g. '["AAPL"]'.
+    SYMBOL_ALLOWLIST: List[str] = []
+    SYMBOL_BLOCKLIST: List[str] = []
+
+    @validator("SYMBOL_ALLOWLIST", "SYMBOL_BLOCKLIST", pre=True)
+    def normalize_symbol_lists(
+        cls, v: Union[str, List[str]], values: Dict[str, Any]
+    ) -> List[str]:
+        if isinstance(v, str):
+            v = v.split(",")
+        symbols = [symbol.strip() for symbol in v if symbol.strip()]
+        if values.get("SYMBOL_CASE_SENSITIVE"):
+            return symbols
+        return [symbol.upper() for symbol in symbols]
+
+    # Reuse of raw provider GET responses when they send no Cache-Control
+    # max-age of their own (0 = only cache what the provider says to)
+    PROVIDER_HTTP_CACHE_SECONDS: float = 2.0
+    PROVIDER_HTTP_CACHE_MAX_ENTRIES: int = 1024
+
+    # Total seconds one API request may spend on provider calls; once spent,
+    # batch endpoints return what they have with partial: true (0 = no limit)
+    PROVIDER_REQUEST_BUDGET: float = 3.0
+
+    # Demo mode: synthetic live prices, demo portfolios and alerts, and no
+    # provider key, database or SECRET_KEY needed. Writes are undone on reset.
+    DEMO_MODE: bool = False
+    DEMO_SEED: int = 42  # Same seed, same price paths
+    DEMO_TICK_SECONDS: float = 1.0  # One random-walk step per tick
+    DEMO_RESET_INTERVAL_MINUTES: float = 60.0
+    DEMO_ALERT_INTERVAL_SECONDS: float = 30.0
+
+    # How often stored quotes, position values and alerts follow the provider
+    QUOTE_REFRESH_INTERVAL_SECONDS: float = 5.0
+
+    # How long single-stock lookups reuse a stock they've read or fetched
+    # (0 = always read the store). Expired entries are evicted on this interval.
+    QUOTE_CACHE_TTL: float = 30.0
+
+    # How often portfolio snapshots are taken. Each portfolio keeps one per
+    # day, so more frequent runs only refresh today's.
+    SNAPSHOT_INTERVAL_MINUTES: float = 60.0
+
+    # A WebSocket client silent this long is sent a ping; silent for another
+    # interval after that and it's disconnected
+    WS_PING_INTERVAL_SECONDS: float = 30.0
+    WS_MAX_SYMBOLS_PER_CONNECTION: int = 50
+    # How often subscribers are sent each symbol's full stock, on top of ticks
+    # (0 = ticks only)
+    WS_STOCK_PUSH_INTERVAL_SECONDS: float = 5.0
+
+    # Encode int64 fields (e.g. volume) as JSON strings unless the client's
+    # X-Int64-As-String header says otherwise
+    INT64_AS_STRING: bool = False
+
+    # Live price streaming
+    STREAM_POLL_INTERVAL_SECONDS: float = 5.0  # Providers without a push feed
+    STREAM_RECONNECT_INITIAL_BACKOFF_SECONDS: float = 1.0
+    STREAM_RECONNECT_MAX_BACKOFF_SECONDS: float = 30.0
+
+    # Longest history a single request may ask for (the "MAX" range clamps
+    # here). Ten years with their leap days, so every range token fits.
+    MAX_HISTORY_DAYS: int = 3653
+
+    # Annual risk-free rate used by Sharpe ratios, and the rate cash earns
+    RISK_FREE_RATE: float = 0.0
+
+    # Index fund that uninvested cash is compared against
+    BENCHMARK_SYMBOL: str = "SPY"
+
+    # Risk-parity allocation limits
+    RISK_PARITY_MAX_SYMBOLS: int = 20
+    RISK_PARITY_MAX_ITERATIONS: int = 500
+
+    # Transactions sent with an expected_price are rejected when the live price
+    # is further than this fraction from it, unless they give a tolerance
+    PRICE_CHECK_DEFAULT_TOLERANCE: float = 0.01
+
+    # A transaction with the same symbol, side, quantity and price as one
+    # recorded this many seconds before is rejected as a likely double
+    # submission, unless sent with force=true (0 = no check)
+    DUPLICATE_TRANSACTION_WINDOW_SECONDS: int = 10
+
+    # Herfindahl-Hirschman Index above which a portfolio counts as concentrated
+    # (0.25 is the antitrust "highly concentrated" line; 1.0 is one holding)
+    CONCENTRATION_HHI_THRESHOLD: float = 0.25
+
+    # Portfolio "attention" flags
+    ATTENTION_LARGE_MOVE_PERCENT: float = 5.0  # Absolute single-day change
+    ATTENTION_DRIFT_PERCENT: float = 5.0  # Percentage points off target weight
+
+    # Service level objectives. A request matching `route` (glob on the route
+    # template) is good if it doesn't 5xx and finishes within latency_ms;
+    # at least `target` of requests over the window must be good.
+    SLO_DEFINITIONS: List[Dict[str, Any]] = [
+        {
+            "name": "quotes",
+            "route": "/api/v1/market/stocks*",
+            "latency_ms": 200,
+            "target": 0.99,
+            "window_minutes": 1440,
+        },
+    ]
+
+    # Development guardrail against N+1 queries: warn when one request runs
+    # more repository queries than this (unset = off; ignored in production).
+    # Strict mode raises instead, for test runs.
+    QUERY_BUDGET: Optional[int] = None
+    QUERY_BUDGET_STRICT: bool = False
+
+    # Fault injection for resilience testing (ignored when ENVIRONMENT=production)
+    FAULT_INJECTION: bool = False
+
     # Redis (for caching and rate limiting)
     REDIS_URL: str = "redis://localhost:6379"
 
@@ -87,6 +305,40 @@ class Settings(BaseSettings):
     )
     RATE_LIMIT_STRICT_MODE: bool = True  # Extra strict mode for financial applications
 
+    # Requests per API_RATE_LIMIT_WINDOW_SECONDS across the API. Requests with
+    # a valid access token count against their user, so users behind one NAT
+    # don't share a limit; others count against their IP (0 = no limit).
+    API_RATE_LIMIT_WINDOW_SECONDS: int = 60
+    API_RATE_LIMIT_ANONYMOUS: int = 0
+    API_RATE_LIMIT_AUTHENTICATED: int = 0
+
+    # Per-IP token bucket, in process memory, on the provider-backed paths
+    # (relative to API_V1_STR): RATE_LIMIT_RPS requests a second on average,
+    # in bursts of up to RATE_LIMIT_BURST (0 RPS = no limit). IPs idle for
+    # RATE_LIMIT_IDLE_SECONDS are forgotten.
+    RATE_LIMIT_RPS: float = 5.0
+    RATE_LIMIT_BURST: int = 20
+    RATE_LIMIT_IDLE_SECONDS: int = 600
+    RATE_LIMIT_PATHS: List[str] = ["/market"]
+    # Proxies in front of the app that append to X-Forwarded-For. At 0 the
+    # header is ignored, since clients can send anything in it.
+    TRUSTED_PROXY_COUNT: int = 0
+
+    @model_validator(mode="after")
+    def require_secret_key(self) -> "Settings":
+        if not self.SECRET_KEY:
+            if not self.DEMO_MODE:
+                raise ValueError("SECRET_KEY (or JWT_SECRET) must be set")
+            # Demo sessions don't need to survive a restart
+            self.SECRET_KEY = secrets.token_urlsafe(32)
+        return self
+
+    @model_validator(mode="after")
+    def require_tls_pair(self) -> "Settings":
+        if bool(self.TLS_CERT_FILE) != bool(self.TLS_KEY_FILE):
+            raise ValueError("TLS_CERT_FILE and TLS_KEY_FILE must be set together")
+        return self
+
     class Config:
         case_sensitive = True
         env_file = ".env"
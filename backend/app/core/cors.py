@@ -0,0 +1,162 @@
+"""
+Cross-origin access for Quant-Dash.
+
+This module provides:
+1. AllowedOrigins, matching a request's Origin against CORS_ALLOWED_ORIGINS:
+   exact origins such as https://app.example.com, and wildcards such as
+   https://*.example.com (any subdomain, not example.com itself) or
+   *.example.com (either http or https)
+2. Middleware adding the CORS headers to simple requests and answering
+   preflights, with the headers the browser asked for allowed and the answer
+   cached for CORS_MAX_AGE_SECONDS
+
+With CORS_ALLOWED_ORIGINS set, a matching Origin is echoed back with
+credentials allowed and Vary: Origin, and any other origin gets no CORS
+headers at all; its preflights are refused with a 400
+http.origin_not_allowed error body. Unset (or "*"), every origin may call
+the API, but without credentials (browsers refuse credentials with a
+wildcard origin anyway).
+"""
+
+import re
+from typing import List, Optional, Pattern
+
+from app.core import errors
+from starlette.responses import JSONResponse
+
+# One or more subdomain labels in place of a leading "*."
+_SUBDOMAINS = r"[a-z0-9-]+(?:\.[a-z0-9-]+)*"
+
+
+def origin_pattern(allowed: str) -> Pattern:
+    """
+    A regex matching the origins an allowed origin or wildcard stands for.
+
+    Raises:
+        ValueError: If a "*" is anywhere but the first label of the host
+    """
+    scheme, separator, host = allowed.lower().rpartition("://")
+    wildcard = host.startswith("*.")
+    if "*" in (host[2:] if wildcard else host) or "*" in scheme:
+        raise ValueError(f"{allowed}: only a leading *. wildcard is supported")
+    scheme_pattern = re.escape(scheme) if separator else "https?"
+    host_pattern = re.escape(host[2:] if wildcard else host)
+    if wildcard:
+        host_pattern = _SUBDOMAINS + r"\." + host_pattern
+    return re.compile(f"{scheme_pattern}://{host_pattern}")
+
+
+class AllowedOrigins:
+    """The origins CORS_ALLOWED_ORIGINS lets call the API; None for any."""
+
+    def __init__(self, origins: Optional[List[str]]):
+        self.any = origins is None or "*" in origins
+        self._exact = set()
+        self._patterns = []
+        for origin in origins or []:
+            if origin == "*":
+                continue
+            if "*" in origin:
+                self._patterns.append(origin_pattern(origin))
+            else:
+                self._exact.add(origin.lower())
+
+    def allow_origin(self, origin: str) -> Optional[str]:
+        """
+        The Access-Control-Allow-Origin value for a request from origin.
+
+        Returns:
+            "*" when any origin is allowed, origin itself when it's listed or
+            matches a wildcard, and None when it isn't allowed
+        """
+        if self.any:
+            return "*"
+        lowered = origin.lower()
+        if lowered in self._exact:
+            return origin
+        if any(pattern.fullmatch(lowered) for pattern in self._patterns):
+            return origin
+        return None
+
+
+def _header(headers, name: bytes) -> Optional[str]:
+    for key, value in headers:
+        if key.lower() == name:
+            return value.decode("latin-1")
+    return None
+
+
+def _add_vary_origin(headers: list) -> list:
+    """headers with Origin added to Vary, keeping any other Vary values."""
+    for i, (key, value) in enumerate(headers):
+        if key.lower() == b"vary":
+            if b"origin" not in value.lower():
+                headers[i] = (key, value + b", Origin")
+            return headers
+    headers.append((b"vary", b"Origin"))
+    return headers
+
+
+class CORSMiddleware:
+    """Adds CORS headers for allowed origins and answers their preflights."""
+
+    def __init__(self, app, allowed_origins: Optional[List[str]], max_age: int):
+        self.app = app
+        self.origins = AllowedOrigins(allowed_origins)
+        self.max_age = max_age  # Seconds browsers may cache a preflight
+
+    async def __call__(self, scope, receive, send):
+        if scope["type"] != "http":
+            return await self.app(scope, receive, send)
+        headers = scope.get("headers", [])
+        origin = _header(headers, b"origin")
+        if origin is None:
+            return await self.app(scope, receive, send)
+
+        allowed = self.origins.allow_origin(origin)
+        requested_method = _header(headers, b"access-control-request-method")
+        if scope["method"] == "OPTIONS" and requested_method is not None:
+            return await self._preflight(scope, receive, send, allowed)
+
+        async def send_with_cors(message):
+            if message["type"] == "http.response.start":
+                message["headers"] = self._cors_headers(
+                    list(message.get("headers", [])), allowed
+                )
+            await send(message)
+
+        await self.app(scope, receive, send_with_cors)
+
+    def _cors_headers(self, headers: list, allowed: Optional[str]) -> list:
+        if allowed == "*":
+            return headers + [(b"access-control-allow-origin", b"*")]
+        # The answer depends on the Origin, so caches must key on it
+        headers = _add_vary_origin(headers)
+        if allowed is None:
+            return headers
+        return headers + [
+            (b"access-control-allow-origin", allowed.encode("latin-1")),
+            (b"access-control-allow-credentials", b"true"),
+        ]
+
+    async def _preflight(self, scope, receive, send, allowed: Optional[str]):
+        request_headers = scope.get("headers", [])
+        if allowed is None:
+            error = errors.origin_not_allowed(_header(request_headers, b"origin"))
+            response = JSONResponse(
+                errors.error_body(error), error.status_code, headers={"Vary": "Origin"}
+            )
+            return await response(scope, receive, send)
+
+        requested_method = _header(request_headers, b"access-control-request-method")
+        headers = self._cors_headers([], allowed) + [
+            (b"access-control-allow-methods", requested_method.encode("latin-1")),
+            (b"access-control-max-age", str(self.max_age).encode()),
+        ]
+        requested_headers = _header(request_headers, b"access-control-request-headers")
+        if requested_headers:
+            headers.append(
+                (b"access-control-allow-headers", requested_headers.encode("latin-1"))
+            )
+        await send({"type": "http.response.start", "status": 204, "headers": headers})
+        await send({"type": "http.response.body", "body": b""})
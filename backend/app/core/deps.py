@@ -5,7 +5,7 @@ This module provides:
 1. Token extraction and validation dependencies
 2. User authentication dependencies
 3. Role-based access control dependencies
-4. Rate limiting dependencies
+4. Rate limiting dependencies, per IP or per signed-in user
 
 Why dependencies:
 - Clean separation of concerns
@@ -16,56 +16,30 @@ Why dependencies:
 
 import logging
 from datetime import datetime, timedelta
-from typing import Optional
+from typing import Optional, Tuple
 
 import redis
+from app.core import errors
+from app.core.clientip import client_ip
 from app.core.config import settings
 from app.core.security import security
 from app.models.auth import UserRole, UserStatus
 from app.services.user import UserService
-from fastapi import Depends, HTTPException, Request, status
+from fastapi import Depends, Request
 from fastapi.security import HTTPAuthorizationCredentials, HTTPBearer
 
 # Configure logger for security events
 logger = logging.getLogger(__name__)
 
-# Security scheme for OpenAPI documentation
-security_scheme = HTTPBearer()
-
-
-class AuthenticationError(HTTPException):
-    """Custom authentication error with proper status codes."""
-
-    def __init__(self, detail: str):
-        super().__init__(
-            status_code=status.HTTP_401_UNAUTHORIZED,
-            detail=detail,
-            headers={"WWW-Authenticate": "Bearer"},
-        )
-
-
-class AuthorizationError(HTTPException):
-    """Custom authorization error for insufficient permissions."""
-
-    def __init__(self, detail: str = "Insufficient permissions"):
-        super().__init__(
-            status_code=status.HTTP_403_FORBIDDEN,
-            detail=detail,
-        )
-
-
-class RateLimitError(HTTPException):
-    """Rate limit exceeded error."""
-
-    def __init__(self, detail: str = "Rate limit exceeded"):
-        super().__init__(
-            status_code=status.HTTP_429_TOO_MANY_REQUESTS,
-            detail=detail,
-        )
+# Security scheme for OpenAPI documentation. Missing tokens are reported by
+# get_current_user_token so they get the auth.token_missing code.
+security_scheme = HTTPBearer(auto_error=False)
+# Same scheme for endpoints that also serve anonymous requests
+optional_security_scheme = HTTPBearer(auto_error=False)
 
 
 async def get_current_user_token(
-    credentials: HTTPAuthorizationCredentials = Depends(security_scheme),
+    credentials: Optional[HTTPAuthorizationCredentials] = Depends(security_scheme),
 ) -> str:
     """
     Extract and validate Bearer token from Authorization header.
@@ -73,10 +47,10 @@ async def get_current_user_token(
     First step in our authentication chain.
     """
     if not credentials:
-        raise AuthenticationError("Missing authentication token")
+        raise errors.token_missing()
 
     if credentials.scheme.lower() != "bearer":
-        raise AuthenticationError("Invalid authentication scheme")
+        raise errors.token_invalid("Invalid authentication scheme")
 
     return credentials.credentials
 
@@ -88,16 +62,34 @@ async def get_current_user_id(token: str = Depends(get_current_user_token)) -> i
     payload = security.verify_token(token, "access")
 
     if payload is None:
-        raise AuthenticationError("Invalid or expired token")
+        if security.is_token_expired(token):
+            raise errors.token_expired()
+        raise errors.token_invalid()
 
     user_id = payload.get("sub")
     if user_id is None:
-        raise AuthenticationError("Invalid token payload")
+        raise errors.token_invalid("Invalid token payload")
 
     try:
         return int(user_id)
     except ValueError:
-        raise AuthenticationError("Invalid user identifier in token")
+        raise errors.token_invalid("Invalid user identifier in token")
+
+
+async def get_optional_user_id(
+    credentials: Optional[HTTPAuthorizationCredentials] = Depends(
+        optional_security_scheme
+    ),
+) -> Optional[int]:
+    """
+    Resolve the user ID when a Bearer token is present.
+
+    Anonymous requests get None; a token that is present but invalid is
+    still rejected so clients notice expired sessions.
+    """
+    if credentials is None:
+        return None
+    return await get_current_user_id(await get_current_user_token(credentials))
 
 
 async def get_current_user(
@@ -111,16 +103,16 @@ async def get_current_user(
     user = await user_service.get_user_by_id(user_id)
 
     if user is None:
-        raise AuthenticationError("User not found")
+        raise errors.user_not_found()
 
     if user.get("status") == UserStatus.SUSPENDED:
-        raise AuthenticationError("Account suspended")
+        raise errors.account_suspended()
 
     if user.get("status") == UserStatus.LOCKED:
-        raise AuthenticationError("Account locked")
+        raise errors.account_locked()
 
     if user.get("status") == UserStatus.PENDING_VERIFICATION:
-        raise AuthenticationError("Email verification required")
+        raise errors.email_unverified()
 
     return user
 
@@ -132,7 +124,7 @@ async def get_verified_user(current_user: dict = Depends(get_current_user)) -> d
     Some endpoints require verified email addresses.
     """
     if not current_user.get("is_email_verified"):
-        raise AuthenticationError("Email verification required")
+        raise errors.email_unverified()
 
     return current_user
 
@@ -160,7 +152,7 @@ def require_role(required_role: UserRole):
         required_level = role_hierarchy.get(required_role, 0)
 
         if user_level < required_level:
-            raise AuthorizationError(f"Role '{required_role.value}' or higher required")
+            raise errors.forbidden(f"Role '{required_role.value}' or higher required")
 
         return current_user
 
@@ -216,7 +208,7 @@ class RateLimiter:
             True if request is allowed, False if rate limit exceeded
 
         Raises:
-            HTTPException: If Redis is unavailable and fail_open_on_error is False
+            AppError: If Redis is unavailable and fail_open_on_error is False
         """
         if not self.redis_client:
             if self.fail_open_on_error:
@@ -231,7 +223,7 @@ class RateLimiter:
                     "Rejecting request for security (fail closed). "
                     "This indicates a critical infrastructure issue that must be resolved immediately."
                 )
-                raise RateLimitError(
+                raise errors.rate_limiter_unavailable(
                     "Rate limiting service unavailable. Request rejected for security."
                 )
 
@@ -279,7 +271,7 @@ class RateLimiter:
                     limit,
                     window_seconds,
                 )
-                raise RateLimitError(
+                raise errors.rate_limiter_unavailable(
                     "Rate limiting service error. Request rejected for security."
                 )
 
@@ -345,15 +337,14 @@ async def login_rate_limit(
 
     Prevents brute force attacks: 5 attempts per IP per 15 minutes.
     """
-    client_ip = request.client.host
-    key = f"login_attempts:{client_ip}"
+    key = f"login_attempts:{client_ip(request)}"
 
     is_allowed = await rate_limiter.check_rate_limit(
         key, 5, 900
     )  # 5 attempts in 15 minutes
 
     if not is_allowed:
-        raise RateLimitError("Too many login attempts. Please try again later.")
+        raise errors.rate_limited("Too many login attempts. Please try again later.")
 
 
 async def registration_rate_limit(
@@ -364,12 +355,54 @@ async def registration_rate_limit(
 
     Prevents spam registrations: 3 attempts per IP per hour.
     """
-    client_ip = request.client.host
-    key = f"registration_attempts:{client_ip}"
+    key = f"registration_attempts:{client_ip(request)}"
 
     is_allowed = await rate_limiter.check_rate_limit(
         key, 3, 3600
     )  # 3 attempts in 1 hour
 
     if not is_allowed:
-        raise RateLimitError("Too many registration attempts. Please try again later.")
+        raise errors.rate_limited(
+            "Too many registration attempts. Please try again later."
+        )
+
+
+def rate_limit_identity(request: Request, token: Optional[str]) -> Tuple[str, int]:
+    """
+    The key and limit a request counts against: its user's, with the
+    authenticated limit, when it has a valid access token; otherwise its IP's.
+
+    An invalid token falls back to the IP; the route's own auth rejects it.
+    """
+    if token:
+        payload = security.verify_token(token, "access")
+        if payload is not None and payload.get("sub") is not None:
+            return (
+                f"api_requests:user:{payload['sub']}",
+                settings.API_RATE_LIMIT_AUTHENTICATED,
+            )
+    return f"api_requests:ip:{client_ip(request)}", settings.API_RATE_LIMIT_ANONYMOUS
+
+
+async def api_rate_limit(
+    request: Request,
+    credentials: Optional[HTTPAuthorizationCredentials] = Depends(
+        optional_security_scheme
+    ),
+):
+    """
+    Rate limit for the API as a whole, per user or per IP.
+
+    Fails open: general traffic keeps flowing when Redis is down.
+    """
+    token = credentials.credentials if credentials else None
+    key, limit = rate_limit_identity(request, token)
+    if limit <= 0:
+        return
+
+    rate_limiter = RateLimiter(redis_client=get_redis_client(), fail_open_on_error=True)
+    is_allowed = await rate_limiter.check_rate_limit(
+        key, limit, settings.API_RATE_LIMIT_WINDOW_SECONDS
+    )
+    if not is_allowed:
+        raise errors.rate_limited("Too many requests. Please try again later.")
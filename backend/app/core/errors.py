@@ -0,0 +1,681 @@
+"""
+Application error catalog for Quant-Dash.
+
+This module provides:
+1. The single source-of-truth table of error codes, statuses and descriptions
+2. AppError, raised by services/endpoints with a stable code
+3. Constructors for every error condition
+4. Mapping framework, validation, provider and database exceptions to codes
+5. Exception handlers that render all errors as ErrorResponse bodies,
+   carrying the request ID so a failure can be found in the logs
+
+Bodies never carry an exception's own text unless it was written for
+clients: database errors in particular are logged and answered with the
+catalog description, as they can quote SQL and stored values.
+
+Codes are part of the public API: clients match on them instead of on
+messages, so renaming one is a breaking change (test_errors.py locks them).
+"""
+
+import logging
+from typing import Any, Callable, Dict, List, NamedTuple, Optional
+
+from app.core.config import settings
+from app.core.logging import current_request_id
+
+logger = logging.getLogger(__name__)
+
+
+class ErrorSpec(NamedTuple):
+    code: str
+    status: int
+    description: str
+
+
+ERROR_SPECS: List[ErrorSpec] = [
+    # Authentication and authorization
+    ErrorSpec("auth.token_missing", 401, "No bearer token was supplied"),
+    ErrorSpec(
+        "auth.token_invalid", 401, "The token is malformed or failed verification"
+    ),
+    ErrorSpec("auth.token_expired", 401, "The token has expired"),
+    ErrorSpec("auth.invalid_credentials", 401, "Email or password is incorrect"),
+    ErrorSpec(
+        "auth.login_rejected", 401, "Login was refused, e.g. the account is locked"
+    ),
+    ErrorSpec("auth.refresh_rejected", 401, "The refresh token can't be used"),
+    ErrorSpec("auth.user_not_found", 401, "The token's user no longer exists"),
+    ErrorSpec("auth.account_suspended", 401, "The account is suspended"),
+    ErrorSpec("auth.account_locked", 401, "The account is locked"),
+    ErrorSpec("auth.email_unverified", 401, "Email verification is required"),
+    ErrorSpec("auth.forbidden", 403, "The user's role doesn't allow this"),
+    ErrorSpec("auth.registration_rejected", 400, "Registration data was rejected"),
+    ErrorSpec(
+        "auth.verification_token_invalid",
+        400,
+        "The email verification token is invalid or expired",
+    ),
+    ErrorSpec(
+        "auth.reset_token_invalid",
+        400,
+        "The password reset token is invalid or expired",
+    ),
+    ErrorSpec("auth.password_rejected", 400, "The new password was rejected"),
+    # Rate limiting
+    ErrorSpec("rate_limit.exceeded", 429, "Too many requests"),
+    ErrorSpec(
+        "rate_limit.unavailable",
+        429,
+        "Rate limiting is unavailable, so the request was refused",
+    ),
+    # Request validation
+    ErrorSpec("validation.field_invalid", 400, "A request field is invalid"),
+    ErrorSpec("validation.body_malformed", 400, "The request body isn't valid JSON"),
+    ErrorSpec(
+        "validation.body_too_large", 413, "The body is over MAX_REQUEST_BODY_BYTES"
+    ),
+    ErrorSpec(
+        "validation.field_unknown", 400, "The request body has fields it doesn't take"
+    ),
+    ErrorSpec(
+        "validation.param_invalid", 400, "A path or query parameter's value is invalid"
+    ),
+    ErrorSpec(
+        "validation.history_range_invalid",
+        400,
+        "The history range is unknown or longer than MAX_HISTORY_DAYS",
+    ),
+    ErrorSpec(
+        "validation.param_conflict",
+        400,
+        "Query parameters conflict or are incomplete together",
+    ),
+    # Portfolio
+    ErrorSpec("portfolio.not_found", 404, "The portfolio doesn't exist"),
+    ErrorSpec("position.not_found", 404, "The position doesn't exist in the portfolio"),
+    ErrorSpec("position.adjustment_invalid", 400, "The position adjustment is invalid"),
+    ErrorSpec(
+        "position.symbol_held",
+        409,
+        "Another position in the portfolio already holds the symbol",
+    ),
+    ErrorSpec(
+        "position.import_invalid",
+        422,
+        "Rows of the imported file are invalid, so nothing was imported",
+    ),
+    ErrorSpec(
+        "transaction.invalid", 400, "The transaction conflicts with the ledger"
+    ),
+    ErrorSpec(
+        "transaction.duplicate",
+        409,
+        "A matching transaction was just recorded; resend with force=true",
+    ),
+    ErrorSpec(
+        "transaction.price_moved",
+        409,
+        "The live price is outside the tolerance of the expected price",
+    ),
+    # Analytics
+    ErrorSpec(
+        "analytics.invalid_request", 400, "The analysis can't run on the available data"
+    ),
+    # Alerts
+    ErrorSpec("alert.not_found", 404, "The user has no alert with that id"),
+    # Watchlists
+    ErrorSpec(
+        "watchlist.name_taken", 409, "The user already has a watchlist with that name"
+    ),
+    ErrorSpec("watchlist.not_found", 404, "The user has no watchlist with that id"),
+    ErrorSpec(
+        "watchlist.order_invalid",
+        400,
+        "The order doesn't list each of the user's watchlists exactly once",
+    ),
+    # Market data
+    ErrorSpec("stock.not_found", 404, "No stock with that symbol"),
+    ErrorSpec("stock.symbol_invalid", 400, "The symbol isn't 1-10 letters"),
+    ErrorSpec(
+        "stock.restricted", 403, "Compliance rules don't allow access to the symbol"
+    ),
+    ErrorSpec(
+        "provider.rate_limited",
+        429,
+        "The market data provider is rate limiting requests",
+    ),
+    ErrorSpec("provider.unavailable", 502, "The market data provider request failed"),
+    ErrorSpec(
+        "provider.not_supported", 501, "The market data provider doesn't offer this"
+    ),
+    # Administration
+    ErrorSpec("fault.injected", 503, "Failure injected by a fault rule"),
+    # Database
+    ErrorSpec("database.conflict", 409, "The change conflicts with stored data"),
+    ErrorSpec("database.unavailable", 503, "The database couldn't be reached"),
+    # Framework and fallbacks
+    ErrorSpec("http.not_found", 404, "No route matches the request path"),
+    ErrorSpec("http.method_not_allowed", 405, "The route doesn't accept that method"),
+    ErrorSpec("http.error", 400, "Other HTTP error; the status code varies"),
+    ErrorSpec(
+        "http.origin_not_allowed",
+        400,
+        "A CORS preflight came from an origin CORS_ALLOWED_ORIGINS doesn't list",
+    ),
+    ErrorSpec("http.timeout", 503, "No response within SERVER_WRITE_TIMEOUT_SECONDS"),
+    ErrorSpec("internal.error", 500, "Unexpected server error"),
+]
+
+ERROR_CATALOG: Dict[str, ErrorSpec] = {spec.code: spec for spec in ERROR_SPECS}
+
+
+class AppError(Exception):
+    """An error with a stable catalog code, rendered as an ErrorResponse."""
+
+    def __init__(
+        self,
+        code: str,
+        message: Optional[str] = None,
+        headers: Optional[Dict[str, str]] = None,
+        context: Optional[Dict[str, Any]] = None,
+    ):
+        spec = ERROR_CATALOG[code]
+        self.code = code
+        self.status_code = spec.status
+        self.message = message or spec.description
+        self.headers = headers
+        self.context = context
+        super().__init__(self.message)
+
+
+# Constructors for every catalog code. Prefer these over AppError(code) so
+# call sites stay greppable and messages consistent.
+ERROR_CONSTRUCTORS: List[Callable[..., AppError]] = []
+
+
+def _constructor(fn: Callable[..., AppError]) -> Callable[..., AppError]:
+    ERROR_CONSTRUCTORS.append(fn)
+    return fn
+
+
+_BEARER = {"WWW-Authenticate": "Bearer"}
+
+
+@_constructor
+def token_missing() -> AppError:
+    return AppError("auth.token_missing", "Missing authentication token", _BEARER)
+
+
+@_constructor
+def token_invalid(message: str = "Invalid or expired token") -> AppError:
+    return AppError("auth.token_invalid", message, _BEARER)
+
+
+@_constructor
+def token_expired() -> AppError:
+    return AppError("auth.token_expired", "Token has expired", _BEARER)
+
+
+@_constructor
+def invalid_credentials() -> AppError:
+    return AppError("auth.invalid_credentials", "Invalid email or password")
+
+
+@_constructor
+def login_rejected(message: str) -> AppError:
+    return AppError("auth.login_rejected", message)
+
+
+@_constructor
+def refresh_rejected(message: str) -> AppError:
+    return AppError("auth.refresh_rejected", message)
+
+
+@_constructor
+def user_not_found() -> AppError:
+    return AppError("auth.user_not_found", "User not found", _BEARER)
+
+
+@_constructor
+def account_suspended() -> AppError:
+    return AppError("auth.account_suspended", "Account suspended", _BEARER)
+
+
+@_constructor
+def account_locked() -> AppError:
+    return AppError("auth.account_locked", "Account locked", _BEARER)
+
+
+@_constructor
+def email_unverified() -> AppError:
+    return AppError("auth.email_unverified", "Email verification required", _BEARER)
+
+
+@_constructor
+def forbidden(message: str = "Insufficient permissions") -> AppError:
+    return AppError("auth.forbidden", message)
+
+
+@_constructor
+def registration_rejected(message: str) -> AppError:
+    return AppError("auth.registration_rejected", message)
+
+
+@_constructor
+def verification_token_invalid() -> AppError:
+    return AppError(
+        "auth.verification_token_invalid", "Invalid or expired verification token"
+    )
+
+
+@_constructor
+def reset_token_invalid() -> AppError:
+    return AppError("auth.reset_token_invalid", "Invalid or expired reset token")
+
+
+@_constructor
+def password_rejected(message: str) -> AppError:
+    return AppError("auth.password_rejected", message)
+
+
+@_constructor
+def rate_limited(
+    message: str = "Rate limit exceeded", retry_after: Optional[int] = None
+) -> AppError:
+    headers = {"Retry-After": str(retry_after)} if retry_after is not None else None
+    return AppError("rate_limit.exceeded", message, headers=headers)
+
+
+@_constructor
+def rate_limiter_unavailable(message: str) -> AppError:
+    return AppError("rate_limit.unavailable", message)
+
+
+@_constructor
+def field_invalid(
+    message: str, fields: Optional[List[Dict[str, str]]] = None
+) -> AppError:
+    context = {"fields": fields} if fields else None
+    return AppError("validation.field_invalid", message, context=context)
+
+
+@_constructor
+def param_invalid(
+    message: str, fields: Optional[List[Dict[str, str]]] = None
+) -> AppError:
+    context = {"fields": fields} if fields else None
+    return AppError("validation.param_invalid", message, context=context)
+
+
+@_constructor
+def history_range_invalid(message: str) -> AppError:
+    return AppError("validation.history_range_invalid", message)
+
+
+@_constructor
+def body_malformed(message: str = "Request body is not valid JSON") -> AppError:
+    return AppError("validation.body_malformed", message)
+
+
+@_constructor
+def body_too_large(max_bytes: int) -> AppError:
+    return AppError(
+        "validation.body_too_large",
+        f"Request body is larger than {max_bytes} bytes",
+        context={"max_bytes": max_bytes},
+    )
+
+
+@_constructor
+def field_unknown(fields: List[str]) -> AppError:
+    return AppError(
+        "validation.field_unknown",
+        f"Unknown field(s): {', '.join(fields)}",
+        context={"fields": [{"field": f, "message": "Unknown field"} for f in fields]},
+    )
+
+
+@_constructor
+def param_conflict(message: str) -> AppError:
+    return AppError("validation.param_conflict", message)
+
+
+@_constructor
+def portfolio_not_found() -> AppError:
+    return AppError("portfolio.not_found", "Portfolio not found")
+
+
+@_constructor
+def position_not_found(portfolio_id: int, position_id: int) -> AppError:
+    return AppError(
+        "position.not_found",
+        f"Position {position_id} not found in portfolio {portfolio_id}",
+    )
+
+
+@_constructor
+def position_adjustment_invalid(message: str) -> AppError:
+    return AppError("position.adjustment_invalid", message)
+
+
+@_constructor
+def position_symbol_held(symbol: str, position_id: int) -> AppError:
+    return AppError(
+        "position.symbol_held",
+        f"Position {position_id} already holds {symbol}; update that one instead",
+        context={"position_id": position_id},
+    )
+
+
+@_constructor
+def position_import_invalid(rows: List[Dict[str, Any]]) -> AppError:
+    return AppError(
+        "position.import_invalid",
+        f"{len(rows)} invalid row(s); nothing was imported",
+        context={"errors": rows},
+    )
+
+
+@_constructor
+def transaction_invalid(message: str) -> AppError:
+    return AppError("transaction.invalid", message)
+
+
+@_constructor
+def transaction_duplicate(
+    transaction_id: int, window: int, existing: Optional[Dict[str, Any]] = None
+) -> AppError:
+    return AppError(
+        "transaction.duplicate",
+        f"Transaction {transaction_id} with the same symbol, side, quantity and "
+        f"price was recorded in the last {window}s; send force=true to record "
+        "this one too",
+        context={"transaction": existing},
+    )
+
+
+@_constructor
+def price_moved(
+    symbol: str, expected_price: float, current_price: float
+) -> AppError:
+    return AppError(
+        "transaction.price_moved",
+        f"{symbol} is at {current_price}, outside the tolerance of the expected "
+        f"{expected_price}; confirm again at the current price",
+        context={"current_price": current_price, "expected_price": expected_price},
+    )
+
+
+@_constructor
+def analytics_invalid_request(message: str) -> AppError:
+    return AppError("analytics.invalid_request", message)
+
+
+@_constructor
+def alert_not_found(alert_id: int) -> AppError:
+    return AppError("alert.not_found", f"Alert {alert_id} not found")
+
+
+@_constructor
+def watchlist_name_taken(name: str) -> AppError:
+    return AppError(
+        "watchlist.name_taken", f"A watchlist named {name!r} already exists"
+    )
+
+
+@_constructor
+def watchlist_not_found(watchlist_id: int) -> AppError:
+    return AppError("watchlist.not_found", f"Watchlist {watchlist_id} not found")
+
+
+@_constructor
+def watchlist_order_invalid(message: str) -> AppError:
+    return AppError("watchlist.order_invalid", message)
+
+
+@_constructor
+def stock_not_found(symbol: str) -> AppError:
+    return AppError("stock.not_found", f"Stock with symbol '{symbol}' not found")
+
+
+@_constructor
+def symbol_invalid(message: str) -> AppError:
+    return AppError("stock.symbol_invalid", message)
+
+
+@_constructor
+def symbol_restricted(symbol: str) -> AppError:
+    return AppError("stock.restricted", f"Symbol {symbol} is restricted")
+
+
+@_constructor
+def provider_rate_limited(provider: str) -> AppError:
+    return AppError("provider.rate_limited", f"{provider} is rate limiting requests")
+
+
+@_constructor
+def provider_unavailable(provider: str) -> AppError:
+    return AppError("provider.unavailable", f"{provider} request failed")
+
+
+@_constructor
+def provider_not_supported(message: str) -> AppError:
+    return AppError("provider.not_supported", message)
+
+
+@_constructor
+def fault_injected(message: str) -> AppError:
+    return AppError("fault.injected", message)
+
+
+@_constructor
+def database_conflict() -> AppError:
+    return AppError(
+        "database.conflict",
+        "The change conflicts with stored data, e.g. a duplicate or a "
+        "missing referenced row",
+    )
+
+
+@_constructor
+def database_unavailable() -> AppError:
+    return AppError("database.unavailable", "The database is unavailable; try again")
+
+
+@_constructor
+def route_not_found() -> AppError:
+    return AppError("http.not_found", "Not Found")
+
+
+@_constructor
+def method_not_allowed() -> AppError:
+    return AppError("http.method_not_allowed", "Method Not Allowed")
+
+
+@_constructor
+def http_error(status_code: int, message: str) -> AppError:
+    error = AppError("http.error", message)
+    error.status_code = status_code
+    return error
+
+
+@_constructor
+def origin_not_allowed(origin: str) -> AppError:
+    return AppError("http.origin_not_allowed", f"Origin {origin} isn't allowed")
+
+
+@_constructor
+def request_timeout() -> AppError:
+    return AppError("http.timeout", "The request took too long")
+
+
+@_constructor
+def internal_error(message: str = "Unexpected server error") -> AppError:
+    return AppError("internal.error", message)
+
+
+def from_http_status(status_code: int, message: str) -> AppError:
+    """Map framework HTTP errors (unknown routes, wrong methods, ...) to codes."""
+    if status_code == 404:
+        return route_not_found()
+    if status_code == 405:
+        return method_not_allowed()
+    if status_code == 413:
+        return body_too_large(settings.MAX_REQUEST_BODY_BYTES)
+    if status_code >= 500:
+        return internal_error()
+    return http_error(status_code, message)
+
+
+def validation_fields(validation_errors: List[Dict[str, Any]]) -> List[Dict[str, str]]:
+    """One {field, message} per failure, the field a dotted path like body.quantity."""
+    return [
+        {"field": ".".join(str(p) for p in e["loc"]), "message": e["msg"]}
+        for e in validation_errors
+    ]
+
+
+def from_validation_errors(validation_errors: List[Dict[str, Any]]) -> AppError:
+    """
+    Map request validation failures to codes; unparsable JSON is a 400.
+
+    Invalid fields are listed in context.fields so clients can show each
+    message next to its input. Fields a body doesn't take are a 400 of their
+    own, as they're usually a typo or a client built for another version,
+    and so are path and query parameters that don't parse, such as a
+    position id that isn't a number.
+    """
+    if any(e.get("type") == "json_invalid" for e in validation_errors):
+        return body_malformed()
+    unknown = [e for e in validation_errors if e.get("type") == "extra_forbidden"]
+    if unknown:
+        return field_unknown([field["field"] for field in validation_fields(unknown)])
+    if validation_errors and all(
+        e["loc"][0] in ("path", "query") for e in validation_errors
+    ):
+        return param_invalid(
+            "Request parameters are invalid", validation_fields(validation_errors)
+        )
+    return field_invalid(
+        "Request validation failed", validation_fields(validation_errors)
+    )
+
+
+def error_body(error: AppError, detail: Optional[str] = None) -> Dict[str, Any]:
+    """The ErrorResponse body for an AppError."""
+    body = {
+        "error": True,
+        "code": error.code,
+        "message": error.message,
+        "detail": detail,
+    }
+    if error.context is not None:
+        body["context"] = error.context
+    request_id = current_request_id()
+    if request_id is not None:
+        body["request_id"] = request_id
+    return body
+
+
+def get_error_catalog() -> List[Dict[str, Any]]:
+    """The catalog as served by GET /api/v1/errors, sorted by code."""
+    return [
+        {"code": spec.code, "status": spec.status, "description": spec.description}
+        for spec in sorted(ERROR_CATALOG.values())
+    ]
+
+
+def provider_error(exc: BaseException) -> AppError:
+    """The catalog error for a failed market data provider call."""
+    from app.data.alphavantage import AlphaVantageError, AlphaVantageRateLimitError
+    from app.data.finnhub import FinnhubError, FinnhubRateLimitError
+
+    if isinstance(exc, FinnhubError):
+        # Wrapper methods re-raise, so look for the 429 along the chain
+        cause: Optional[BaseException] = exc
+        while cause is not None:
+            if isinstance(cause, FinnhubRateLimitError):
+                return provider_rate_limited("Finnhub")
+            cause = cause.__context__
+        return provider_unavailable("Finnhub")
+    if isinstance(exc, AlphaVantageRateLimitError):
+        return provider_rate_limited("Alpha Vantage")
+    if isinstance(exc, AlphaVantageError):
+        return provider_unavailable("Alpha Vantage")
+    # Includes ProviderBudgetExhausted: the request ran out of provider time
+    return provider_unavailable("Market data provider")
+
+
+def database_error(exc: BaseException) -> AppError:
+    """
+    The catalog error for a failed database call, without the driver's
+    message (it's logged instead).
+
+    Constraint violations are a 409, connection failures and timeouts
+    (including a repository timeout injected in staging) a 503, anything
+    else a 500.
+    """
+    from sqlalchemy import exc as sa_exc
+
+    logger.error("Database error: %s", exc)
+    if isinstance(exc, sa_exc.IntegrityError):
+        return database_conflict()
+    if isinstance(
+        exc,
+        (
+            sa_exc.OperationalError,
+            sa_exc.DisconnectionError,
+            sa_exc.TimeoutError,
+            TimeoutError,
+        ),
+    ):
+        return database_unavailable()
+    return internal_error()
+
+
+def install_error_handlers(app) -> None:
+    """Render every error the API raises as an ErrorResponse with a code."""
+    from app.core.faults import InjectedDatabaseTimeout
+    from app.core.providerbudget import ProviderBudgetExhausted
+    from app.data.alphavantage import AlphaVantageError
+    from app.data.finnhub import FinnhubError
+    from fastapi.exceptions import RequestValidationError
+    from fastapi.responses import JSONResponse
+    from sqlalchemy.exc import SQLAlchemyError
+    from starlette.exceptions import HTTPException as StarletteHTTPException
+
+    def respond(error: AppError, detail: Optional[str] = None) -> JSONResponse:
+        return JSONResponse(
+            status_code=error.status_code,
+            content=error_body(error, detail),
+            headers=error.headers,
+        )
+
+    @app.exception_handler(AppError)
+    async def handle_app_error(request, exc: AppError):
+        return respond(exc)
+
+    @app.exception_handler(StarletteHTTPException)
+    async def handle_http_exception(request, exc: StarletteHTTPException):
+        return respond(from_http_status(exc.status_code, str(exc.detail)))
+
+    @app.exception_handler(RequestValidationError)
+    async def handle_validation_error(request, exc: RequestValidationError):
+        detail = "; ".join(
+            f"{field['field']}: {field['message']}"
+            for field in validation_fields(exc.errors())
+        )
+        return respond(from_validation_errors(exc.errors()), detail)
+
+    @app.exception_handler(FinnhubError)
+    @app.exception_handler(AlphaVantageError)
+    @app.exception_handler(ProviderBudgetExhausted)
+    async def handle_provider_error(request, exc: Exception):
+        # Only single-call endpoints get here; batches report per-item errors
+        logger.warning("Provider error on %s: %s", request.url.path, exc)
+        return respond(provider_error(exc))
+
+    @app.exception_handler(SQLAlchemyError)
+    @app.exception_handler(InjectedDatabaseTimeout)
+    async def handle_database_error(request, exc: Exception):
+        return respond(database_error(exc))
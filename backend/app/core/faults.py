@@ -0,0 +1,229 @@
+"""
+Fault injection for resilience testing in staging.
+
+This module provides:
+1. Fault rules matched by route prefix or dependency name
+2. Injected latency, generic errors, provider rate limits and DB timeouts
+3. A proxy that adds fault points to providers/repositories, and wrapping
+   of the services' repositories under fixed dependency names
+4. Middleware that applies route rules to incoming requests
+
+Fault injection is only available when FAULT_INJECTION=true and the
+environment isn't production; otherwise every entry point is inert.
+"""
+
+import asyncio
+import functools
+import inspect
+import logging
+import random
+import time
+from enum import Enum
+from typing import Any, Dict, List, Optional
+
+from app.core.config import settings
+from app.core.errors import error_body, fault_injected
+from app.models.schemas import RequestBody
+from pydantic import Field
+from starlette.middleware.base import BaseHTTPMiddleware
+from starlette.requests import Request
+from starlette.responses import JSONResponse
+
+logger = logging.getLogger(__name__)
+
+
+class FaultTarget(str, Enum):
+    ROUTE = "route"
+    DEPENDENCY = "dependency"
+
+
+class FaultKind(str, Enum):
+    ERROR = "error"
+    PROVIDER_RATE_LIMIT = "provider_rate_limit"
+    DB_TIMEOUT = "db_timeout"
+
+
+class FaultRule(RequestBody):
+    target: FaultTarget
+    match: str = Field(
+        ..., min_length=1, description="Route prefix or dependency name to match"
+    )
+    latency_ms: int = Field(
+        0, ge=0, le=60000, description="Delay added before each matching call"
+    )
+    error_rate: float = Field(
+        0.0, ge=0.0, le=1.0, description="Probability of failing a matching call"
+    )
+    kind: FaultKind = FaultKind.ERROR
+    status_code: int = Field(
+        503, ge=400, le=599, description="Status returned by failing route rules"
+    )
+
+
+class InjectedFault(Exception):
+    """Generic failure raised by a fault rule."""
+
+    def __init__(self, message: str, rule: Optional[FaultRule] = None):
+        super().__init__(message)
+        self.rule = rule
+
+
+class InjectedRateLimit(InjectedFault):
+    """Simulates an upstream provider answering 429."""
+
+
+class InjectedDatabaseTimeout(InjectedFault, TimeoutError):
+    """Simulates a database call timing out."""
+
+
+_FAULT_EXCEPTIONS = {
+    FaultKind.ERROR: InjectedFault,
+    FaultKind.PROVIDER_RATE_LIMIT: InjectedRateLimit,
+    FaultKind.DB_TIMEOUT: InjectedDatabaseTimeout,
+}
+
+
+def fault_injection_available() -> bool:
+    """Fault injection must be opted into and is never allowed in production."""
+    return settings.FAULT_INJECTION and settings.ENVIRONMENT.lower() != "production"
+
+
+class FaultInjector:
+    """Holds the active rules and applies them at fault points."""
+
+    def __init__(
+        self, enabled: Optional[bool] = None, rng: Optional[random.Random] = None
+    ):
+        self.enabled = fault_injection_available() if enabled is None else enabled
+        self.rng = rng or random.Random()
+        self._rules: List[FaultRule] = []
+
+    def set_rules(self, rules: List[FaultRule]) -> None:
+        if not self.enabled:
+            raise RuntimeError("Fault injection is disabled")
+        self._rules = list(rules)
+        logger.warning("Fault injection rules active: %d", len(self._rules))
+
+    def list_rules(self) -> List[FaultRule]:
+        return list(self._rules)
+
+    def clear(self) -> None:
+        self._rules = []
+
+    def _matching(self, target: FaultTarget, name: str) -> List[FaultRule]:
+        if not self.enabled:
+            return []
+        rules = [r for r in self._rules if r.target == target]
+        if target == FaultTarget.ROUTE:
+            return [r for r in rules if name.startswith(r.match)]
+        return [r for r in rules if r.match == name]
+
+    def _maybe_fail(self, rule: FaultRule, target: FaultTarget, name: str) -> None:
+        if rule.error_rate and self.rng.random() < rule.error_rate:
+            raise _FAULT_EXCEPTIONS[rule.kind](
+                f"Injected {rule.kind.value} for {target.value} '{name}'", rule
+            )
+
+    async def apply(self, target: FaultTarget, name: str) -> Optional[FaultRule]:
+        """
+        Apply matching rules: sleep for their latency, then maybe fail.
+
+        Raises:
+            InjectedFault: (or a subclass) when a rule's error roll hits
+        """
+        for rule in self._matching(target, name):
+            if rule.latency_ms:
+                await asyncio.sleep(rule.latency_ms / 1000)
+            self._maybe_fail(rule, target, name)
+        return None
+
+    def apply_sync(self, target: FaultTarget, name: str) -> None:
+        """
+        Apply matching rules to a blocking call, such as a repository's.
+
+        The latency blocks like a slow query on a synchronous driver would.
+
+        Raises:
+            InjectedFault: (or a subclass) when a rule's error roll hits
+        """
+        for rule in self._matching(target, name):
+            if rule.latency_ms:
+                time.sleep(rule.latency_ms / 1000)
+            self._maybe_fail(rule, target, name)
+
+
+class FaultInjectingProxy:
+    """
+    Wraps a provider or repository so each method call is a fault point.
+
+    Provider methods are async and repository methods blocking; both are
+    wrapped. Async generators such as a streaming provider's feed are passed
+    through. The dependency name is what DEPENDENCY rules match on.
+    """
+
+    def __init__(self, target: Any, name: str, injector: "FaultInjector"):
+        self._target = target
+        self._name = name
+        self._injector = injector
+
+    def __getattr__(self, attr: str) -> Any:
+        value = getattr(self._target, attr)
+        if asyncio.iscoroutinefunction(value):
+
+            @functools.wraps(value)
+            async def with_faults(*args, **kwargs):
+                await self._injector.apply(FaultTarget.DEPENDENCY, self._name)
+                return await value(*args, **kwargs)
+
+            return with_faults
+        if not inspect.ismethod(value) or inspect.isasyncgenfunction(value):
+            return value
+
+        @functools.wraps(value)
+        def with_sync_faults(*args, **kwargs):
+            self._injector.apply_sync(FaultTarget.DEPENDENCY, self._name)
+            return value(*args, **kwargs)
+
+        return with_sync_faults
+
+
+def inject_repository_faults(services: Dict[str, Any], injector: FaultInjector) -> None:
+    """
+    Make each service's repositories fault points, named after the attribute
+    holding them (e.g. "stocks", "portfolios").
+
+    Args:
+        services: Services by the repository attributes to wrap, e.g.
+            {"stocks": market_service}
+    """
+    for name, service in services.items():
+        repository = getattr(service, name)
+        if not isinstance(repository, FaultInjectingProxy):
+            setattr(service, name, FaultInjectingProxy(repository, name, injector))
+
+
+class FaultInjectionMiddleware(BaseHTTPMiddleware):
+    """Applies ROUTE rules before requests reach their handlers."""
+
+    def __init__(self, app, injector: "FaultInjector"):
+        super().__init__(app)
+        self.injector = injector
+
+    async def dispatch(self, request: Request, call_next):
+        # Never fault the endpoints used to manage the faults themselves
+        if not request.url.path.startswith(f"{settings.API_V1_STR}/admin/faults"):
+            try:
+                await self.injector.apply(FaultTarget.ROUTE, request.url.path)
+            except InjectedFault as e:
+                return JSONResponse(
+                    status_code=e.rule.status_code if e.rule else 503,
+                    content=error_body(fault_injected(str(e))),
+                )
+        return await call_next(request)
+
+
+fault_injector = FaultInjector()
+
+
+def get_fault_injector() -> FaultInjector:
+    return fault_injector
@@ -0,0 +1,147 @@
+"""
+Per-IP rate limit for the endpoints backed by the market data provider.
+
+This module provides:
+1. A token bucket per client IP, refilled at RATE_LIMIT_RPS tokens a second
+   up to RATE_LIMIT_BURST
+2. Middleware answering 429 rate_limit.exceeded, with Retry-After, on the
+   paths under RATE_LIMIT_PATHS once an IP's bucket is empty
+3. A sweep, run as a job, forgetting IPs idle for RATE_LIMIT_IDLE_SECONDS
+
+Unlike deps.api_rate_limit the buckets live in process memory, so they work
+without Redis and cost nothing per request, but each worker limits on its
+own. Behind TRUSTED_PROXY_COUNT proxies the client IP is read from
+X-Forwarded-For; without a proxy the header is whatever the client sent, so
+it's ignored.
+
+Signed-in callers are limited here too, by address like everyone else.
+api_rate_limit counts them per user, but it's off by default and lets
+requests through while Redis is down, and anyone can sign up for a token;
+these buckets are what keeps the provider's quota from running out either
+way. Both limits read the address through clientip.client_ip.
+"""
+
+import math
+import time
+from typing import Callable, Dict, List, Sequence
+
+from app.core import errors
+from app.core.clientip import client_ip
+from app.core.config import settings
+from starlette.middleware.base import BaseHTTPMiddleware
+from starlette.requests import Request
+from starlette.responses import JSONResponse
+
+
+class TokenBucket:
+    """Tokens for one IP: full to start, one spent per request."""
+
+    def __init__(self, tokens: float, updated: float):
+        self.tokens = tokens
+        self.updated = updated
+
+
+class IPRateLimiter:
+    """Token buckets keyed by client IP (rate 0 = no limit)."""
+
+    def __init__(
+        self,
+        rate: float,
+        burst: int,
+        idle_seconds: float,
+        clock: Callable[[], float] = time.monotonic,
+    ):
+        self.rate = rate
+        self.burst = max(1, burst)
+        self.idle_seconds = idle_seconds
+        self.clock = clock
+        self.buckets: Dict[str, TokenBucket] = {}
+
+    def acquire(self, ip: str) -> float:
+        """
+        Spend one of ip's tokens.
+
+        Returns:
+            0 if the request may go ahead, else the seconds until a token
+            is available
+        """
+        if self.rate <= 0:
+            return 0.0
+        now = self.clock()
+        bucket = self.buckets.get(ip)
+        if bucket is None:
+            bucket = self.buckets[ip] = TokenBucket(self.burst, now)
+        else:
+            refilled = bucket.tokens + (now - bucket.updated) * self.rate
+            bucket.tokens = min(self.burst, refilled)
+            bucket.updated = now
+        if bucket.tokens >= 1:
+            bucket.tokens -= 1
+            return 0.0
+        return (1 - bucket.tokens) / self.rate
+
+    async def sweep(self) -> int:
+        """
+        Drop the buckets of IPs idle for idle_seconds; run periodically.
+
+        A bucket idle for burst / rate seconds is full again, so forgetting
+        it changes nothing for the IP's next request.
+        """
+        cutoff = self.clock() - self.idle_seconds
+        idle = [ip for ip, bucket in self.buckets.items() if bucket.updated <= cutoff]
+        for ip in idle:
+            del self.buckets[ip]
+        return len(idle)
+
+
+class IPRateLimitMiddleware(BaseHTTPMiddleware):
+    """Applies an IPRateLimiter to the requests under the given paths."""
+
+    def __init__(
+        self,
+        app,
+        limiter: IPRateLimiter,
+        paths: Sequence[str],
+        trusted_proxies: int = 0,
+    ):
+        super().__init__(app)
+        self.limiter = limiter
+        self.paths = [path.rstrip("/") for path in paths]
+        self.trusted_proxies = trusted_proxies
+
+    def _limited(self, path: str) -> bool:
+        return any(path == p or path.startswith(p + "/") for p in self.paths)
+
+    async def dispatch(self, request: Request, call_next):
+        if not self._limited(request.url.path):
+            return await call_next(request)
+
+        wait = self.limiter.acquire(client_ip(request, self.trusted_proxies))
+        if wait > 0:
+            error = errors.rate_limited(
+                "Too many requests from this address. Please slow down.",
+                retry_after=math.ceil(wait),
+            )
+            return JSONResponse(
+                errors.error_body(error), error.status_code, headers=error.headers
+            )
+        return await call_next(request)
+
+
+def rate_limit_paths(prefix: str) -> List[str]:
+    """RATE_LIMIT_PATHS, which are relative to the API prefix, made absolute."""
+    return [
+        prefix.rstrip("/") + "/" + path.strip("/") for path in settings.RATE_LIMIT_PATHS
+    ]
+
+
+def ip_rate_limit_enabled() -> bool:
+    return settings.RATE_LIMIT_RPS > 0 and bool(settings.RATE_LIMIT_PATHS)
+
+
+# Limiter instance
+ip_rate_limiter = IPRateLimiter(
+    settings.RATE_LIMIT_RPS,
+    settings.RATE_LIMIT_BURST,
+    settings.RATE_LIMIT_IDLE_SECONDS,
+)
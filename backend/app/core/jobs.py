@@ -0,0 +1,82 @@
+"""
+Periodic background jobs for Quant-Dash.
+
+This module provides:
+1. PeriodicJob, which runs an async callable on a fixed interval
+2. JobScheduler, which starts and stops registered jobs with the app
+
+A job that raises is logged and retried on its next run; it never stops
+the loop. Each run waits one interval first, so jobs registered at startup
+don't all fire at once.
+"""
+
+import asyncio
+import logging
+from typing import Awaitable, Callable, Dict, List, Optional
+
+logger = logging.getLogger(__name__)
+
+JobFunc = Callable[[], Awaitable[object]]
+
+
+class PeriodicJob:
+    """Runs an async callable every `interval` seconds."""
+
+    def __init__(self, name: str, interval: float, func: JobFunc):
+        self.name = name
+        self.interval = interval
+        self.func = func
+        self.runs = 0
+        self.failures = 0
+
+    async def run_once(self) -> None:
+        try:
+            await self.func()
+        except asyncio.CancelledError:
+            raise
+        except Exception:
+            self.failures += 1
+            logger.exception("Job %s failed", self.name)
+        self.runs += 1
+
+    async def run(self) -> None:
+        """Background task: run the job forever."""
+        logger.info("Starting job %s every %ss", self.name, self.interval)
+        while True:
+            await asyncio.sleep(self.interval)
+            await self.run_once()
+
+
+class JobScheduler:
+    """
+    Registry of periodic jobs started together at application startup.
+    """
+
+    def __init__(self):
+        self._jobs: Dict[str, PeriodicJob] = {}
+        self._tasks: List["asyncio.Task[None]"] = []
+
+    def register(self, name: str, interval: float, func: JobFunc) -> PeriodicJob:
+        """Register a job, replacing any job with the same name."""
+        job = PeriodicJob(name, interval, func)
+        self._jobs[name] = job
+        return job
+
+    def get(self, name: str) -> Optional[PeriodicJob]:
+        return self._jobs.get(name)
+
+    def start(self) -> None:
+        """Start a task for every registered job."""
+        for job in self._jobs.values():
+            self._tasks.append(asyncio.create_task(job.run()))
+
+    async def stop(self) -> None:
+        """Cancel all running jobs and wait for them to finish."""
+        for task in self._tasks:
+            task.cancel()
+        await asyncio.gather(*self._tasks, return_exceptions=True)
+        self._tasks.clear()
+
+
+# Scheduler instance
+job_scheduler = JobScheduler()
@@ -0,0 +1,79 @@
+"""
+JSON error bodies for every API response.
+
+Errors raised inside routes already go through the handlers in errors.py,
+but some responses never reach them: any middleware or framework code
+that answers with plain text. This module
+provides middleware that rewrites such responses under the API prefix
+into the standard ErrorResponse, keeping their status and headers.
+
+Responses outside the API prefix (the root page, static files) and
+responses that are already JSON are left as they are.
+"""
+
+from http import HTTPStatus
+
+from app.core import errors
+from app.core.requestlog import REQUEST_ID_HEADER
+from starlette.middleware.base import BaseHTTPMiddleware
+from starlette.requests import Request
+from starlette.responses import JSONResponse
+
+# Replaced along with the body
+_BODY_HEADERS = ("content-length", "content-type")
+
+
+def is_api_path(path: str, prefix: str) -> bool:
+    return path == prefix or path.startswith(prefix.rstrip("/") + "/")
+
+
+def is_json(content_type: str) -> bool:
+    """application/json, or a +json type such as application/problem+json."""
+    media_type = content_type.split(";")[0].strip().lower()
+    return media_type == "application/json" or media_type.endswith("+json")
+
+
+def _status_phrase(status_code: int) -> str:
+    try:
+        return HTTPStatus(status_code).phrase
+    except ValueError:
+        return "Error"
+
+
+class JSONErrorMiddleware(BaseHTTPMiddleware):
+    """Turns non-JSON error responses on API routes into ErrorResponse bodies."""
+
+    def __init__(self, app, prefix: str):
+        super().__init__(app)
+        self.prefix = prefix
+
+    async def dispatch(self, request: Request, call_next):
+        response = await call_next(request)
+        if response.status_code < 400 or not is_api_path(
+            request.url.path, self.prefix
+        ):
+            return response
+        if is_json(response.headers.get("content-type", "")):
+            return response
+
+        content = b""
+        async for chunk in response.body_iterator:
+            content += chunk if isinstance(chunk, bytes) else chunk.encode("utf-8")
+        message = content.decode("utf-8", "replace").strip()
+        error = errors.from_http_status(
+            response.status_code, message or _status_phrase(response.status_code)
+        )
+
+        body = errors.error_body(error)
+        # Responses from outside the request log carry no ID
+        request_id = response.headers.get(REQUEST_ID_HEADER)
+        if request_id is not None:
+            body["request_id"] = request_id
+        headers = {
+            name: value
+            for name, value in response.headers.items()
+            if name.lower() not in _BODY_HEADERS
+        }
+        return JSONResponse(
+            status_code=response.status_code, content=body, headers=headers
+        )
@@ -4,17 +4,64 @@ Logging configuration for Quant-Dash backend.
 This module provides:
 1. Structured logging configuration
 2. Different log levels for different environments
-3. Proper log formatting for production debugging
+3. Proper log formatting for production debugging, as text or JSON lines
 4. Security-aware logging (no sensitive data)
+5. The current request's ID on every record, for correlating a request's logs
 """
 
+import json
 import logging
 import logging.config
 import os
-from typing import Any, Dict
+from contextvars import ContextVar
+from datetime import datetime, timezone
+from typing import Any, Dict, Optional
 
 from app.core.config import settings
 
+# Set by RequestLogMiddleware for the duration of each request
+request_id_var: ContextVar[Optional[str]] = ContextVar("request_id", default=None)
+
+# Attributes every LogRecord has; anything else came in through `extra`
+_RECORD_ATTRS = set(vars(logging.makeLogRecord({}))) | {"message", "asctime"}
+
+
+def current_request_id() -> Optional[str]:
+    """The ID of the request being handled, or None outside a request."""
+    return request_id_var.get()
+
+
+class RequestIdFilter(logging.Filter):
+    """Stamps records with the current request's ID ("-" outside requests)."""
+
+    def filter(self, record: logging.LogRecord) -> bool:
+        record.request_id = current_request_id() or "-"
+        return True
+
+
+class JsonFormatter(logging.Formatter):
+    """One JSON object per record, with any `extra` fields as keys."""
+
+    def format(self, record: logging.LogRecord) -> str:
+        entry = {
+            "time": datetime.fromtimestamp(record.created, timezone.utc).isoformat(),
+            "level": record.levelname,
+            "logger": record.name,
+            "message": record.getMessage(),
+        }
+        entry.update(
+            (key, value)
+            for key, value in vars(record).items()
+            if key not in _RECORD_ATTRS
+        )
+        if record.exc_info:
+            entry["exception"] = self.formatException(record.exc_info)
+        return json.dumps(entry, default=str)
+
+
+def log_level() -> str:
+    return (settings.LOG_LEVEL or ("DEBUG" if settings.DEBUG else "INFO")).upper()
+
 
 def get_logging_config() -> Dict[str, Any]:
     """
@@ -26,27 +73,34 @@ def get_logging_config() -> Dict[str, Any]:
     return {
         "version": 1,
         "disable_existing_loggers": False,
+        "filters": {"request_id": {"()": RequestIdFilter}},
         "formatters": {
             "default": {
-                "format": "%(asctime)s - %(name)s - %(levelname)s - %(message)s",
+                "format": "%(asctime)s - %(name)s - %(levelname)s - [%(request_id)s] - %(message)s",
                 "datefmt": "%Y-%m-%d %H:%M:%S",
             },
             "detailed": {
-                "format": "%(asctime)s - %(name)s - %(levelname)s - %(funcName)s:%(lineno)d - %(message)s",
+                "format": "%(asctime)s - %(name)s - %(levelname)s - [%(request_id)s] - %(funcName)s:%(lineno)d - %(message)s",
                 "datefmt": "%Y-%m-%d %H:%M:%S",
             },
+            "json": {"()": JsonFormatter},
         },
         "handlers": {
             "console": {
                 "class": "logging.StreamHandler",
-                "level": "DEBUG" if settings.DEBUG else "INFO",
-                "formatter": "detailed" if settings.DEBUG else "default",
+                "level": log_level(),
+                "formatter": (
+                    "json"
+                    if settings.LOG_FORMAT == "json"
+                    else "detailed" if settings.DEBUG else "default"
+                ),
+                "filters": ["request_id"],
                 "stream": "ext://sys.stdout",
             },
         },
         "loggers": {
             "app": {
-                "level": "DEBUG" if settings.DEBUG else "INFO",
+                "level": log_level(),
                 "handlers": ["console"],
                 "propagate": False,
             },
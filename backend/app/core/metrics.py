@@ -0,0 +1,290 @@
+"""
+Request metrics for Quant-Dash.
+
+This module provides:
+1. Middleware that times every request, feeds the SLO tracker and counts
+   requests by method, route template and status
+2. Counters and histograms, plus gauges collected on demand, rendered in
+   the Prometheus text format
+3. A proxy counting market data provider calls and their errors
+4. A small server for /metrics on its own port (METRICS_PORT), so scrapes
+   don't go through the public, CORS-open API
+
+Gauges are callbacks rather than stored values so they're always computed
+from the current state when scraped. Query durations are recorded by
+querybudget.counts_as_query, which already wraps every repository call.
+"""
+
+import asyncio
+import functools
+import logging
+import time
+from typing import Any, Callable, Dict, List, Optional, Sequence, Tuple
+
+from app.core.slo import SLOTracker
+from starlette.middleware.base import BaseHTTPMiddleware
+from starlette.requests import Request
+
+logger = logging.getLogger(__name__)
+
+Labels = Tuple[Tuple[str, str], ...]  # Sorted (name, value) pairs
+
+GaugeSamples = Callable[[], List[Tuple[Dict[str, str], float]]]
+
+_gauges: Dict[str, Tuple[str, GaugeSamples]] = {}
+
+# Seconds; the Prometheus client defaults, which suit HTTP and query latency
+DEFAULT_BUCKETS = (0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1.0, 2.5, 5.0, 10.0)
+
+# Route label for requests no route matched, so scans of random paths
+# don't create a series each
+UNMATCHED_ROUTE = "unmatched"
+
+
+def _labels(labels: Dict[str, str]) -> Labels:
+    return tuple(sorted((k, str(v)) for k, v in labels.items()))
+
+
+def _escape(value: str) -> str:
+    return value.replace("\\", r"\\").replace('"', r"\"").replace("\n", r"\n")
+
+
+def _sample(name: str, labels: Labels, value: float) -> str:
+    if not labels:
+        return f"{name} {value}"
+    label_text = ",".join(f'{k}="{_escape(v)}"' for k, v in labels)
+    return f"{name}{{{label_text}}} {value}"
+
+
+class Counter:
+    """A monotonically increasing count per label set."""
+
+    def __init__(self, name: str, help_text: str):
+        self.name = name
+        self.help_text = help_text
+        self.values: Dict[Labels, float] = {}
+
+    def inc(self, labels: Dict[str, str], amount: float = 1.0) -> None:
+        key = _labels(labels)
+        self.values[key] = self.values.get(key, 0.0) + amount
+
+    def value(self, labels: Dict[str, str]) -> float:
+        return self.values.get(_labels(labels), 0.0)
+
+    def render(self) -> List[str]:
+        lines = [f"# HELP {self.name} {self.help_text}", f"# TYPE {self.name} counter"]
+        for labels, value in sorted(self.values.items()):
+            lines.append(_sample(self.name, labels, value))
+        return lines
+
+
+class Histogram:
+    """Observations counted into cumulative buckets per label set."""
+
+    def __init__(
+        self, name: str, help_text: str, buckets: Sequence[float] = DEFAULT_BUCKETS
+    ):
+        self.name = name
+        self.help_text = help_text
+        self.buckets = tuple(sorted(buckets))
+        # Per label set: a count per bucket (the last is +Inf), and the sum
+        self.values: Dict[Labels, Tuple[List[int], float]] = {}
+
+    def observe(self, labels: Dict[str, str], value: float) -> None:
+        key = _labels(labels)
+        counts, total = self.values.get(key, ([0] * (len(self.buckets) + 1), 0.0))
+        for i, bound in enumerate(self.buckets):
+            if value <= bound:
+                counts[i] += 1
+        counts[-1] += 1
+        self.values[key] = (counts, total + value)
+
+    def count(self, labels: Dict[str, str]) -> int:
+        counts, _ = self.values.get(_labels(labels), ([0], 0.0))
+        return counts[-1]
+
+    def render(self) -> List[str]:
+        lines = [
+            f"# HELP {self.name} {self.help_text}",
+            f"# TYPE {self.name} histogram",
+        ]
+        for labels, (counts, total) in sorted(self.values.items()):
+            bounds = [f"{bound:g}" for bound in self.buckets] + ["+Inf"]
+            for bound, count in zip(bounds, counts):
+                bucket_labels = tuple(sorted(labels + (("le", bound),)))
+                lines.append(_sample(f"{self.name}_bucket", bucket_labels, count))
+            lines.append(_sample(f"{self.name}_sum", labels, total))
+            lines.append(_sample(f"{self.name}_count", labels, counts[-1]))
+        return lines
+
+
+_metrics: Dict[str, Any] = {}
+
+
+def counter(name: str, help_text: str) -> Counter:
+    """The counter registered under name, registering it on first use."""
+    if name not in _metrics:
+        _metrics[name] = Counter(name, help_text)
+    return _metrics[name]
+
+
+def histogram(
+    name: str, help_text: str, buckets: Sequence[float] = DEFAULT_BUCKETS
+) -> Histogram:
+    """The histogram registered under name, registering it on first use."""
+    if name not in _metrics:
+        _metrics[name] = Histogram(name, help_text, buckets)
+    return _metrics[name]
+
+
+http_requests = counter(
+    "http_requests_total", "HTTP requests by method, route template and status"
+)
+http_request_duration = histogram(
+    "http_request_duration_seconds", "HTTP request latency by method and route"
+)
+db_query_duration = histogram(
+    "db_query_duration_seconds", "Repository query latency by query"
+)
+provider_calls = counter(
+    "market_data_provider_calls_total", "Market data provider calls by method"
+)
+provider_errors = counter(
+    "market_data_provider_errors_total",
+    "Market data provider calls that raised, by method",
+)
+
+
+def register_gauge(name: str, help_text: str, collect: GaugeSamples) -> None:
+    """Register a gauge whose (labels, value) samples are collected on scrape."""
+    _gauges[name] = (help_text, collect)
+
+
+def render_metrics() -> str:
+    """Every registered metric in the Prometheus text exposition format."""
+    lines = []
+    for name, (help_text, collect) in sorted(_gauges.items()):
+        lines.append(f"# HELP {name} {help_text}")
+        lines.append(f"# TYPE {name} gauge")
+        for labels, value in collect():
+            lines.append(_sample(name, _labels(labels), value))
+    for name, metric in sorted(_metrics.items()):
+        lines.extend(metric.render())
+    return "\n".join(lines) + "\n"
+
+
+def register_slo_gauges(tracker: SLOTracker) -> None:
+    """Expose error budget and burn rate per SLO for alerting."""
+    register_gauge(
+        "slo_error_budget_remaining",
+        "Share of the SLO error budget left in the window (1 = untouched)",
+        lambda: [
+            ({"slo": s.name}, s.error_budget_remaining) for s in tracker.report()
+        ],
+    )
+    register_gauge(
+        "slo_burn_rate",
+        "Error budget burn rate over the SLO window (1 = on budget)",
+        lambda: [({"slo": s.name}, s.burn_rate) for s in tracker.report()],
+    )
+
+
+class MetricsMiddleware(BaseHTTPMiddleware):
+    """Times requests, records them against matching SLOs and counts them."""
+
+    def __init__(self, app, tracker: SLOTracker, timer: Callable[[], float] = None):
+        super().__init__(app)
+        self.tracker = tracker
+        self.timer = timer or time.perf_counter
+
+    async def dispatch(self, request: Request, call_next):
+        started = self.timer()
+        status_code = 500
+        try:
+            response = await call_next(request)
+            status_code = response.status_code
+            return response
+        finally:
+            elapsed = self.timer() - started
+            # Match on the route template so /stocks/{symbol} is one route
+            template = getattr(request.scope.get("route"), "path", None)
+            self.tracker.record(
+                template or request.url.path, elapsed * 1000, status_code
+            )
+
+            route = template or UNMATCHED_ROUTE
+            http_requests.inc(
+                {"method": request.method, "route": route, "status": str(status_code)}
+            )
+            http_request_duration.observe(
+                {"method": request.method, "route": route}, elapsed
+            )
+
+
+class InstrumentedProvider:
+    """
+    Wraps a market data provider so each async method call is counted, and
+    counted again as an error when it raises.
+    """
+
+    def __init__(self, target: Any, name: str):
+        self._target = target
+        self._name = name
+
+    def __getattr__(self, attr: str) -> Any:
+        value = getattr(self._target, attr)
+        if not asyncio.iscoroutinefunction(value):
+            return value
+        labels = {"provider": self._name, "method": attr}
+
+        @functools.wraps(value)
+        async def counted(*args, **kwargs):
+            provider_calls.inc(labels)
+            try:
+                return await value(*args, **kwargs)
+            except Exception:
+                provider_errors.inc(labels)
+                raise
+
+        return counted
+
+
+async def _answer_scrape(reader, writer) -> None:
+    try:
+        request_line = await asyncio.wait_for(reader.readline(), 5)
+        while (await asyncio.wait_for(reader.readline(), 5)) not in (b"\r\n", b""):
+            pass  # Headers aren't needed
+        parts = request_line.decode("latin-1").split()
+        if len(parts) >= 2 and parts[0] == "GET" and parts[1] == "/metrics":
+            status, body = "200 OK", render_metrics().encode()
+        else:
+            status, body = "404 Not Found", b"Not Found\n"
+        writer.write(
+            f"HTTP/1.1 {status}\r\n"
+            "Content-Type: text/plain; version=0.0.4; charset=utf-8\r\n"
+            f"Content-Length: {len(body)}\r\n"
+            "Connection: close\r\n\r\n".encode() + body
+        )
+        await writer.drain()
+    except (asyncio.TimeoutError, ConnectionError):
+        pass
+    finally:
+        writer.close()
+
+
+async def start_metrics_server(
+    host: str, port: int
+) -> Optional[asyncio.AbstractServer]:
+    """
+    Serve GET /metrics alone on host:port, one request per connection.
+
+    Returns:
+        The server, or None if the port couldn't be bound (logged)
+    """
+    try:
+        server = await asyncio.start_server(_answer_scrape, host, port)
+    except OSError as e:
+        logger.error("Metrics server couldn't listen on %s:%s: %s", host, port, e)
+        return None
+    logger.info("Serving /metrics on %s:%s", host, port)
+    return server
@@ -0,0 +1,111 @@
+"""
+Per-request time budget for market data provider calls.
+
+This module provides:
+1. A provider-time budget held in the request context
+2. A wrapper provider clients run their upstream calls through
+3. Middleware that opens a budget per request
+
+Per-call timeouts alone let a request that fans out to many provider calls
+take many times the timeout. The budget is a deadline for the whole request:
+once it has passed, clients refuse further calls with ProviderBudgetExhausted,
+and a call in flight is cut off when the deadline arrives. Batch endpoints
+catch the error and return what they already have, flagged as partial.
+Outside a request (e.g. background jobs) there's no budget and calls run as
+usual.
+"""
+
+import asyncio
+import contextlib
+import time
+from contextvars import ContextVar
+from typing import Awaitable, Callable, Iterator, Optional, TypeVar
+
+from app.core.config import settings
+from starlette.middleware.base import BaseHTTPMiddleware
+from starlette.requests import Request
+
+T = TypeVar("T")
+
+
+class ProviderBudgetExhausted(Exception):
+    """The request has used up its provider time budget."""
+
+
+class ProviderBudget:
+    """A deadline for all of one request's provider calls."""
+
+    def __init__(self, seconds: float, clock: Callable[[], float] = time.monotonic):
+        self.seconds = seconds
+        self.clock = clock
+        self.deadline = clock() + seconds
+        self.refused = 0
+
+    def remaining(self) -> float:
+        return max(0.0, self.deadline - self.clock())
+
+    @property
+    def exhausted(self) -> bool:
+        return self.remaining() <= 0
+
+
+_current_budget: ContextVar[Optional[ProviderBudget]] = ContextVar(
+    "provider_budget", default=None
+)
+
+
+@contextlib.contextmanager
+def provider_budget(
+    seconds: float, clock: Callable[[], float] = time.monotonic
+) -> Iterator[ProviderBudget]:
+    """Bound the provider calls made inside the block to seconds in total."""
+    budget = ProviderBudget(seconds, clock)
+    token = _current_budget.set(budget)
+    try:
+        yield budget
+    finally:
+        _current_budget.reset(token)
+
+
+async def within_budget(call: Awaitable[T]) -> T:
+    """
+    Await a provider call, limited to the current request's remaining budget.
+
+    Raises:
+        ProviderBudgetExhausted: If the budget was used up before the call,
+            or ran out while it was in flight
+    """
+    budget = _current_budget.get()
+    if budget is None:
+        return await call
+    remaining = budget.remaining()
+    if remaining <= 0:
+        if asyncio.iscoroutine(call):
+            call.close()
+        budget.refused += 1
+        raise ProviderBudgetExhausted(
+            f"Provider time budget of {budget.seconds:g}s used up"
+        )
+    try:
+        return await asyncio.wait_for(call, remaining)
+    except asyncio.TimeoutError:
+        budget.refused += 1
+        raise ProviderBudgetExhausted(
+            f"Provider time budget of {budget.seconds:g}s ran out mid-call"
+        )
+
+
+class ProviderBudgetMiddleware(BaseHTTPMiddleware):
+    """Gives every request its own provider time budget."""
+
+    def __init__(self, app, seconds: float):
+        super().__init__(app)
+        self.seconds = seconds
+
+    async def dispatch(self, request: Request, call_next):
+        with provider_budget(self.seconds):
+            return await call_next(request)
+
+
+def provider_budget_enabled() -> bool:
+    return bool(settings.PROVIDER_REQUEST_BUDGET)
@@ -0,0 +1,145 @@
+"""
+Per-request query budget for catching N+1 patterns during development.
+
+This module provides:
+1. A query counter held in the request context
+2. A decorator marking repository methods that each cost one query
+3. Middleware that opens a budget per request and reports overruns
+
+Going over the budget logs a warning naming the queries, or raises
+QueryBudgetExceeded in strict mode so tests fail on the regression. Only
+active when QUERY_BUDGET is set and the environment isn't production;
+outside a budget, counting is a no-op.
+"""
+
+import contextlib
+import functools
+import logging
+import time
+from collections import Counter
+from contextvars import ContextVar
+from typing import Iterator, Optional
+
+from app.core.config import settings
+from app.core.metrics import db_query_duration
+from starlette.middleware.base import BaseHTTPMiddleware
+from starlette.requests import Request
+
+logger = logging.getLogger(__name__)
+
+
+class QueryBudgetExceeded(AssertionError):
+    """A request issued more queries than its budget allows (strict mode)."""
+
+
+class QueryBudget:
+    """Counts the queries issued within one request."""
+
+    def __init__(self, limit: int, strict: bool = False, label: str = "request"):
+        self.limit = limit
+        self.strict = strict
+        self.label = label
+        self.queries: Counter = Counter()
+        self.warned = False
+
+    @property
+    def count(self) -> int:
+        return sum(self.queries.values())
+
+    @property
+    def exceeded(self) -> bool:
+        return self.count > self.limit
+
+    def _summary(self) -> str:
+        return ", ".join(f"{name} x{n}" for name, n in self.queries.most_common())
+
+    def record(self, name: str) -> None:
+        self.queries[name] += 1
+        if self.count <= self.limit:
+            return
+        message = (
+            f"{self.label} issued {self.count} queries, over its budget of "
+            f"{self.limit}: {self._summary()}"
+        )
+        if self.strict:
+            raise QueryBudgetExceeded(message)
+        if not self.warned:
+            # Once per request; the final count is logged when it finishes
+            self.warned = True
+            logger.warning(message)
+
+
+_current_budget: ContextVar[Optional[QueryBudget]] = ContextVar(
+    "query_budget", default=None
+)
+
+
+def query_budget_enabled() -> bool:
+    """Budgets are a development guardrail, never enforced in production."""
+    return (
+        settings.QUERY_BUDGET is not None
+        and settings.ENVIRONMENT.lower() != "production"
+    )
+
+
+@contextlib.contextmanager
+def query_budget(
+    limit: int, strict: bool = False, label: str = "request"
+) -> Iterator[QueryBudget]:
+    """Count queries made inside the block against a budget."""
+    budget = QueryBudget(limit, strict, label)
+    token = _current_budget.set(budget)
+    try:
+        yield budget
+    finally:
+        _current_budget.reset(token)
+
+
+def count_query(name: str) -> None:
+    """Charge one query to the current request's budget, if there is one."""
+    budget = _current_budget.get()
+    if budget is not None:
+        budget.record(name)
+
+
+def counts_as_query(method):
+    """
+    Mark an async repository method as issuing one query per call.
+
+    Every call is also timed into the db_query_duration_seconds histogram,
+    budget or not.
+    """
+    name = method.__qualname__
+
+    @functools.wraps(method)
+    async def wrapper(*args, **kwargs):
+        count_query(name)
+        started = time.perf_counter()
+        try:
+            return await method(*args, **kwargs)
+        finally:
+            db_query_duration.observe({"query": name}, time.perf_counter() - started)
+
+    return wrapper
+
+
+class QueryBudgetMiddleware(BaseHTTPMiddleware):
+    """Gives every request its own query budget."""
+
+    def __init__(self, app, limit: int, strict: bool = False):
+        super().__init__(app)
+        self.limit = limit
+        self.strict = strict
+
+    async def dispatch(self, request: Request, call_next):
+        label = f"{request.method} {request.url.path}"
+        with query_budget(self.limit, self.strict, label) as budget:
+            response = await call_next(request)
+        if budget.exceeded:
+            logger.warning(
+                "%s finished with %d queries (budget %d)",
+                label,
+                budget.count,
+                budget.limit,
+            )
+        return response
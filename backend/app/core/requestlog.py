@@ -0,0 +1,73 @@
+"""
+Request logging for Quant-Dash.
+
+This module provides:
+1. A generated ID per request, echoed in X-Request-ID and stamped on every
+   log record written while the request is handled
+2. One structured log line per request: method, path, status, response
+   size and duration
+3. Recovery from unhandled exceptions: they're logged with the request ID
+   and answered with a 500 internal.error body instead of a dropped
+   connection
+"""
+
+import logging
+import time
+import uuid
+from typing import Callable
+
+from app.core import errors
+from app.core.logging import request_id_var
+from starlette.middleware.base import BaseHTTPMiddleware
+from starlette.requests import Request
+from starlette.responses import JSONResponse
+
+logger = logging.getLogger(__name__)
+
+REQUEST_ID_HEADER = "X-Request-ID"
+
+
+class RequestLogMiddleware(BaseHTTPMiddleware):
+    """Assigns request IDs, logs every request and turns crashes into 500s."""
+
+    def __init__(self, app, timer: Callable[[], float] = None):
+        super().__init__(app)
+        self.timer = timer or time.perf_counter
+
+    async def dispatch(self, request: Request, call_next):
+        request_id = uuid.uuid4().hex
+        token = request_id_var.set(request_id)
+        started = self.timer()
+        try:
+            try:
+                response = await call_next(request)
+            except Exception:
+                logger.exception(
+                    "Unhandled error on %s %s", request.method, request.url.path
+                )
+                error = errors.internal_error()
+                response = JSONResponse(
+                    status_code=error.status_code, content=errors.error_body(error)
+                )
+            response.headers[REQUEST_ID_HEADER] = request_id
+
+            duration_ms = (self.timer() - started) * 1000
+            size = response.headers.get("content-length")
+            logger.info(
+                "%s %s %s %sB %.1fms",
+                request.method,
+                request.url.path,
+                response.status_code,
+                size if size is not None else "-",
+                duration_ms,
+                extra={
+                    "method": request.method,
+                    "path": request.url.path,
+                    "status": response.status_code,
+                    "size": int(size) if size is not None else None,
+                    "duration_ms": round(duration_ms, 1),
+                },
+            )
+            return response
+        finally:
+            request_id_var.reset(token)
@@ -153,6 +153,28 @@ class SecurityManager:
             # Catch-all for unexpected errors
             return None
 
+    @staticmethod
+    def is_token_expired(token: str) -> bool:
+        """
+        Check whether a token's only problem might be its expiry.
+
+        Used after verify_token fails, so clients can be told to refresh
+        rather than log in again. The signature is still checked.
+        """
+        try:
+            jwt.decode(
+                token,
+                settings.SECRET_KEY,
+                algorithms=[settings.JWT_ALGORITHM],
+                audience=settings.JWT_AUDIENCE,
+                issuer=settings.JWT_ISSUER,
+            )
+        except jwt.ExpiredSignatureError:
+            return True
+        except Exception:
+            return False
+        return False
+
     @staticmethod
     def hash_password(password: str) -> str:
         """
@@ -0,0 +1,130 @@
+"""
+Service level objective tracking for Quant-Dash.
+
+This module provides:
+1. SLO definitions (route pattern, latency target, success-rate target, window)
+2. A per-SLO sliding window of per-minute buckets held in a ring buffer
+3. Compliance, error budget remaining and burn rate for each SLO
+
+A request is "good" when it didn't fail with a 5xx and finished within the
+SLO's latency target. The error budget is the share of bad requests the
+target allows: with a 99% target, 1% of requests may be bad. A burn rate of
+1.0 spends the budget exactly over the window; above 1.0 it runs out early.
+"""
+
+import fnmatch
+import time
+from typing import Callable, Dict, List, Optional, Tuple
+
+from app.core.config import settings
+from pydantic import BaseModel, Field
+
+
+class SLODefinition(BaseModel):
+    name: str
+    route: str = Field(..., description="Glob matched against the route template")
+    latency_ms: float = Field(..., gt=0, description="Slowest a good request may be")
+    target: float = Field(
+        ..., gt=0, lt=1, description="Required share of good requests"
+    )
+    window_minutes: int = Field(1440, ge=1, le=60 * 24 * 30)
+
+
+class SLOStatus(BaseModel):
+    name: str
+    route: str
+    latency_ms: float
+    target: float
+    window_minutes: int
+    total: int
+    good: int
+    compliance: Optional[float] = Field(None, description="None without traffic")
+    error_budget_remaining: float = Field(..., description="1.0 = untouched")
+    burn_rate: float
+
+
+class _Bucket:
+    __slots__ = ("minute", "total", "good")
+
+    def __init__(self):
+        self.minute = -1
+        self.total = 0
+        self.good = 0
+
+
+class SLOWindow:
+    """Rolling good/total counts over the last N minutes, one bucket each."""
+
+    def __init__(self, definition: SLODefinition):
+        self.definition = definition
+        self._buckets = [_Bucket() for _ in range(definition.window_minutes)]
+
+    def record(self, minute: int, good: bool) -> None:
+        bucket = self._buckets[minute % len(self._buckets)]
+        if bucket.minute != minute:
+            # Bucket last held a minute that has left the window
+            bucket.minute, bucket.total, bucket.good = minute, 0, 0
+        bucket.total += 1
+        bucket.good += int(good)
+
+    def counts(self, minute: int) -> Tuple[int, int]:
+        oldest = minute - len(self._buckets) + 1
+        total = good = 0
+        for bucket in self._buckets:
+            if oldest <= bucket.minute <= minute:
+                total += bucket.total
+                good += bucket.good
+        return total, good
+
+    def status(self, minute: int) -> SLOStatus:
+        definition = self.definition
+        total, good = self.counts(minute)
+        allowed_bad = 1 - definition.target
+        bad_ratio = (total - good) / total if total else 0.0
+        burn_rate = bad_ratio / allowed_bad
+        return SLOStatus(
+            **definition.model_dump(),
+            total=total,
+            good=good,
+            compliance=round(good / total, 6) if total else None,
+            error_budget_remaining=round(1 - burn_rate, 6),
+            burn_rate=round(burn_rate, 6),
+        )
+
+
+class SLOTracker:
+    """Routes request outcomes to the windows of the SLOs they match."""
+
+    def __init__(
+        self,
+        definitions: List[SLODefinition],
+        clock: Callable[[], float] = time.time,
+    ):
+        self.clock = clock
+        self._windows: Dict[str, SLOWindow] = {
+            d.name: SLOWindow(d) for d in definitions
+        }
+
+    def _minute(self) -> int:
+        return int(self.clock() // 60)
+
+    def record(self, route: str, latency_ms: float, status_code: int) -> None:
+        """Record one finished request against every SLO whose route matches."""
+        minute = self._minute()
+        for window in self._windows.values():
+            definition = window.definition
+            if fnmatch.fnmatchcase(route, definition.route):
+                good = status_code < 500 and latency_ms <= definition.latency_ms
+                window.record(minute, good)
+
+    def report(self) -> List[SLOStatus]:
+        """Current status of every SLO, in definition order."""
+        minute = self._minute()
+        return [window.status(minute) for window in self._windows.values()]
+
+
+slo_tracker = SLOTracker([SLODefinition(**d) for d in settings.SLO_DEFINITIONS])
+
+
+def get_slo_tracker() -> SLOTracker:
+    return slo_tracker
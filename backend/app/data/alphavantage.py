@@ -0,0 +1,187 @@
+"""
+Alpha Vantage API service for market data.
+
+This service provides:
+1. Latest quotes (GLOBAL_QUOTE), normalized to the fields stocks store
+2. Daily closing prices (TIME_SERIES_DAILY)
+3. Typed errors for rate limiting and failed requests
+
+Alpha Vantage reports rate limiting with a 200 response carrying a "Note"
+or "Information" message instead of data, so those are turned into
+AlphaVantageRateLimitError like a 429 is. Every call has its own timeout
+and also stays within the request's provider time budget.
+
+Alpha Vantage API Documentation: https://www.alphavantage.co/documentation/
+"""
+
+import json
+import logging
+from datetime import date, datetime, time, timezone
+from typing import Any, Dict, List, Optional
+
+import aiohttp
+from app.core.config import settings
+from app.core.providerbudget import within_budget
+from app.data.provider_base import MarketProvider, ProviderNotSupportedError
+
+logger = logging.getLogger(__name__)
+
+# Daily series come in a compact (latest 100 days) or a full form
+COMPACT_DAYS = 100
+
+_RATE_LIMIT_KEYS = ("Note", "Information")
+
+
+class AlphaVantageError(Exception):
+    """Custom exception for Alpha Vantage API errors."""
+
+
+class AlphaVantageRateLimitError(AlphaVantageError):
+    """Raised when Alpha Vantage refuses a request for rate limiting."""
+
+
+def _number(value: Optional[str]) -> Optional[float]:
+    if value in (None, ""):
+        return None
+    return float(str(value).rstrip("%"))
+
+
+class AlphaVantageService(MarketProvider):
+    """
+    Service for interacting with the Alpha Vantage REST API.
+    """
+
+    BASE_URL = "https://www.alphavantage.co/query"
+
+    def __init__(self, api_key: str = None, timeout_seconds: float = 10.0):
+        """
+        Initialize Alpha Vantage service.
+
+        Args:
+            api_key: Alpha Vantage API key. If None, will use
+                settings.ALPHA_VANTAGE_API_KEY
+            timeout_seconds: Limit for each request, connecting included
+        """
+        self.api_key = api_key or settings.ALPHA_VANTAGE_API_KEY
+        if not self.api_key:
+            raise AlphaVantageError("Alpha Vantage API key is required")
+        self.timeout_seconds = timeout_seconds
+        self.session: Optional[aiohttp.ClientSession] = None
+
+    async def __aenter__(self):
+        """Async context manager entry."""
+        self.session = self._new_session()
+        return self
+
+    async def __aexit__(self, exc_type, exc_val, exc_tb):
+        """Async context manager exit."""
+        if self.session:
+            await self.session.close()
+            self.session = None
+
+    def _new_session(self) -> aiohttp.ClientSession:
+        timeout = aiohttp.ClientTimeout(total=self.timeout_seconds)
+        return aiohttp.ClientSession(timeout=timeout)
+
+    async def _make_request(self, params: Dict[str, Any]) -> Dict[str, Any]:
+        """
+        Call an Alpha Vantage function.
+
+        Raises:
+            AlphaVantageRateLimitError: If Alpha Vantage is rate limiting
+            AlphaVantageError: If the request fails or returns an error
+            ProviderBudgetExhausted: If the request's provider time budget is
+                used up
+        """
+        if not self.session:
+            self.session = self._new_session()
+
+        try:
+            data = await within_budget(self._get({**params, "apikey": self.api_key}))
+        except aiohttp.ClientError as e:
+            raise AlphaVantageError(f"Network error: {str(e)}")
+        except json.JSONDecodeError as e:
+            raise AlphaVantageError(f"Invalid JSON response: {str(e)}")
+
+        for key in _RATE_LIMIT_KEYS:
+            if key in data:
+                raise AlphaVantageRateLimitError(data[key])
+        if "Error Message" in data:
+            raise AlphaVantageError(data["Error Message"])
+        return data
+
+    async def _get(self, params: Dict[str, Any]) -> Dict[str, Any]:
+        async with self.session.get(self.BASE_URL, params=params) as response:
+            if response.status == 200:
+                return await response.json()
+            if response.status == 429:
+                raise AlphaVantageRateLimitError(
+                    "Rate limit exceeded. Please wait before making more requests."
+                )
+            error_text = await response.text()
+            raise AlphaVantageError(
+                f"API request failed with status {response.status}: {error_text}"
+            )
+
+    async def get_quote(self, symbol: str) -> Dict[str, Any]:
+        """
+        Get the latest quote for a stock symbol.
+
+        Returns:
+            {"symbol", "price", "change", "change_percent", "volume",
+            "previous_close", "latest_trading_day"}; price is None for
+            symbols Alpha Vantage doesn't know
+        """
+        symbol = symbol.upper()
+        data = await self._make_request({"function": "GLOBAL_QUOTE", "symbol": symbol})
+        quote = data.get("Global Quote") or {}
+        volume = _number(quote.get("06. volume"))
+        return {
+            "symbol": symbol,
+            "price": _number(quote.get("05. price")),
+            "change": _number(quote.get("09. change")),
+            "change_percent": _number(quote.get("10. change percent")),
+            "volume": int(volume) if volume is not None else None,
+            "previous_close": _number(quote.get("08. previous close")),
+            "latest_trading_day": quote.get("07. latest trading day"),
+        }
+
+    async def get_daily_closes(
+        self, symbol: str, days: int = COMPACT_DAYS
+    ) -> Dict[date, float]:
+        """Closing prices for about the last `days` trading days, by day."""
+        data = await self._make_request(
+            {
+                "function": "TIME_SERIES_DAILY",
+                "symbol": symbol.upper(),
+                "outputsize": "compact" if days <= COMPACT_DAYS else "full",
+            }
+        )
+        series = data.get("Time Series (Daily)") or {}
+        closes = {
+            date.fromisoformat(day): float(values["4. close"])
+            for day, values in series.items()
+        }
+        return dict(sorted(closes.items())[-days:])
+
+    async def get_history(
+        self, symbol: str, interval: str = "1d", limit: int = 100
+    ) -> List[Dict]:
+        """
+        The last `limit` daily closes as {"t": epoch seconds, "c": close}.
+
+        Raises:
+            ProviderNotSupportedError: For intervals other than "1d"
+        """
+        if interval != "1d":
+            raise ProviderNotSupportedError(
+                f"Alpha Vantage history is daily only, not {interval}"
+            )
+        closes = await self.get_daily_closes(symbol, limit)
+        return [
+            {
+                "t": int(datetime.combine(day, time(), timezone.utc).timestamp()),
+                "c": close,
+            }
+            for day, close in closes.items()
+        ]
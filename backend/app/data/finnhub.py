@@ -6,6 +6,8 @@ This service provides:
 2. Stock price data
 3. WebSocket streaming capabilities
 4. Rate limiting and error handling
+5. Short-lived caching of successful REST responses
+6. Keeping REST calls within the request's provider time budget
 
 Finnhub API Documentation: https://finnhub.io/docs/api
 """
@@ -14,12 +16,19 @@ import asyncio
 import json
 import logging
 from datetime import datetime
-from typing import Any, AsyncIterator, Dict, List, Optional
+from typing import Any, AsyncIterator, Dict, List, Optional, Tuple
 
 import aiohttp
 import websockets
 from app.core.config import settings
-from app.data.provider_base import MarketProvider
+from app.core.providerbudget import ProviderBudgetExhausted, within_budget
+from app.data.http_cache import ResponseCache, cache_key
+from app.data.provider_base import (
+    Level1Provider,
+    MarketProvider,
+    RawQuoteProvider,
+    StreamingProvider,
+)
 
 logger = logging.getLogger(__name__)
 
@@ -30,7 +39,13 @@ class FinnhubError(Exception):
     pass
 
 
-class FinnhubService(MarketProvider):
+class FinnhubRateLimitError(FinnhubError):
+    """Finnhub answered 429 Too Many Requests."""
+
+
+class FinnhubService(
+    MarketProvider, StreamingProvider, Level1Provider, RawQuoteProvider
+):
     """
     Service for interacting with Finnhub API.
 
@@ -40,17 +55,24 @@ class FinnhubService(MarketProvider):
     BASE_URL = "https://finnhub.io/api/v1"
     WS_URL = "wss://ws.finnhub.io"
 
-    def __init__(self, api_key: str = None):
+    def __init__(self, api_key: str = None, cache: Optional[ResponseCache] = None):
         """
         Initialize Finnhub service.
 
         Args:
             api_key: Finnhub API key. If None, will use settings.FINNHUB_API_KEY
+            cache: Response cache. If None, one is built from the
+                PROVIDER_HTTP_CACHE_* settings
         """
         self.api_key = api_key or settings.FINNHUB_API_KEY
         if not self.api_key:
             raise FinnhubError("Finnhub API key is required")
 
+        self.cache = cache or ResponseCache(
+            settings.PROVIDER_HTTP_CACHE_SECONDS,
+            settings.PROVIDER_HTTP_CACHE_MAX_ENTRIES,
+        )
+        self.session: Optional[aiohttp.ClientSession] = None
         self.ws_connection: Optional[websockets.WebSocketClientProtocol] = None
 
     async def __aenter__(self):
@@ -66,6 +88,16 @@ class FinnhubService(MarketProvider):
         if self.ws_connection:
             await self.ws_connection.close()
 
+    async def connect(self):
+        """(Re)connect the upstream WebSocket, dropping any stale connection."""
+        if self.ws_connection:
+            try:
+                await self.ws_connection.close()
+            except Exception:
+                pass
+            self.ws_connection = None
+        await self.connect_websocket()
+
     async def subscribe(self, symbols: List[str]):
         """Subscribe to real-time updates for a list of symbols."""
         if not self.ws_connection:
@@ -159,20 +191,27 @@ class FinnhubService(MarketProvider):
             raise FinnhubError(f"WebSocket stream error: {e}")
 
     async def _make_request(
-        self, endpoint: str, params: Dict[str, Any] = None
+        self, endpoint: str, params: Dict[str, Any] = None, use_cache: bool = True
     ) -> Dict[str, Any]:
         """
         Make HTTP request to Finnhub API.
 
+        Successful responses are cached by URL and served from the cache
+        while fresh; errors are never cached. Cache hits don't count against
+        the request's provider time budget.
+
         Args:
             endpoint: API endpoint (without base URL)
             params: Query parameters
+            use_cache: False to neither read nor fill the response cache
 
         Returns:
             JSON response as dictionary
 
         Raises:
             FinnhubError: If API request fails
+            ProviderBudgetExhausted: If the request's provider time budget is
+                used up
         """
         if not self.session:
             timeout = aiohttp.ClientTimeout(total=30, connect=10)
@@ -180,29 +219,42 @@ class FinnhubService(MarketProvider):
 
         url = f"{self.BASE_URL}{endpoint}"
         params = params or {}
+        key = cache_key(url, params)
+        cached = self.cache.get(key) if use_cache else None
+        if cached is not None:
+            logger.debug(f"Serving {key} from the response cache")
+            return cached
         params["token"] = self.api_key
 
         try:
-            async with self.session.get(url, params=params) as response:
-                if response.status == 200:
-                    data = await response.json()
-                    return data
-                elif response.status == 429:
-                    raise FinnhubError(
-                        "Rate limit exceeded. Please wait before making more requests."
-                    )
-                elif response.status == 401:
-                    raise FinnhubError("Invalid API key")
-                else:
-                    error_text = await response.text()
-                    raise FinnhubError(
-                        f"API request failed with status {response.status}: {error_text}"
-                    )
-
+            data, cache_control = await within_budget(self._get(url, params))
         except aiohttp.ClientError as e:
             raise FinnhubError(f"Network error: {str(e)}")
         except json.JSONDecodeError as e:
             raise FinnhubError(f"Invalid JSON response: {str(e)}")
+        if use_cache:
+            self.cache.put(key, data, cache_control)
+        return data
+
+    async def _get(
+        self, url: str, params: Dict[str, Any]
+    ) -> Tuple[Dict[str, Any], Optional[str]]:
+        """The JSON body and Cache-Control header of a successful GET."""
+        async with self.session.get(url, params=params) as response:
+            if response.status == 200:
+                data = await response.json()
+                return data, response.headers.get("Cache-Control")
+            elif response.status == 429:
+                raise FinnhubRateLimitError(
+                    "Rate limit exceeded. Please wait before making more requests."
+                )
+            elif response.status == 401:
+                raise FinnhubError("Invalid API key")
+            else:
+                error_text = await response.text()
+                raise FinnhubError(
+                    f"API request failed with status {response.status}: {error_text}"
+                )
 
     async def get_countries(self) -> List[Dict[str, str]]:
         """
@@ -281,10 +333,49 @@ class FinnhubService(MarketProvider):
             )
             return data
 
+        except ProviderBudgetExhausted:
+            raise
         except Exception as e:
             logger.error(f"Failed to fetch quote for {symbol}: {str(e)}")
             raise FinnhubError(f"Failed to fetch quote: {str(e)}")
 
+    async def get_raw_quote(self, symbol: str) -> Dict[str, Any]:
+        """
+        Fetch a quote live, bypassing the response cache, exactly as Finnhub
+        returned it.
+
+        Raises:
+            FinnhubRateLimitError: If Finnhub is rate limiting
+            FinnhubError: If the request fails
+        """
+        logger.info(f"Fetching raw quote for symbol: {symbol}")
+        return await self._make_request("/quote", {"symbol": symbol}, use_cache=False)
+
+    async def get_level1(self, symbol: str) -> Dict[str, Any]:
+        """
+        Get the current best bid/ask for a stock symbol.
+
+        Args:
+            symbol: Stock symbol (e.g., "AAPL")
+
+        Returns:
+            Normalized level-1 quote (bid, ask, bid_size, ask_size, timestamp)
+        """
+        try:
+            logger.info(f"Fetching bid/ask for symbol: {symbol}")
+            data = await self._make_request("/stock/bidask", {"symbol": symbol})
+            return {
+                "bid": data["b"],
+                "ask": data["a"],
+                "bid_size": int(data.get("bs", 0)),
+                "ask_size": int(data.get("as", 0)),
+                "timestamp": data.get("t"),
+            }
+
+        except Exception as e:
+            logger.error(f"Failed to fetch bid/ask for {symbol}: {str(e)}")
+            raise FinnhubError(f"Failed to fetch bid/ask: {str(e)}")
+
     async def get_candles(
         self, symbol: str, resolution: str, from_timestamp: int, to_timestamp: int
     ) -> Dict[str, Any]:
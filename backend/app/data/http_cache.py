@@ -0,0 +1,102 @@
+"""
+Short-lived cache of raw provider HTTP responses.
+
+This module provides:
+1. Cache keys built from the request URL and query parameters
+2. Cache-Control parsing (max-age, no-store, no-cache, private)
+3. A bounded in-memory response cache with per-entry expiry
+
+Providers check the cache before calling upstream and store successful GET
+responses, so different code paths asking for the same endpoint within the
+TTL share one upstream call. The provider's Cache-Control wins when present;
+otherwise entries live for the configured default TTL.
+"""
+
+import copy
+import re
+import time
+from collections import OrderedDict
+from typing import Any, Callable, Dict, Optional, Tuple
+from urllib.parse import urlencode
+
+# Query parameters that identify the caller rather than the resource
+CREDENTIAL_PARAMS = ("token", "apikey", "api_key")
+
+_MAX_AGE = re.compile(r"max-age\s*=\s*\"?(\d+)\"?")
+_NO_CACHE_DIRECTIVES = ("no-store", "no-cache", "private")
+
+
+def cache_key(url: str, params: Optional[Dict[str, Any]] = None) -> str:
+    """The URL with its query parameters sorted and credentials left out."""
+    query = sorted(
+        (name, str(value))
+        for name, value in (params or {}).items()
+        if name.lower() not in CREDENTIAL_PARAMS
+    )
+    return f"{url}?{urlencode(query)}" if query else url
+
+
+def cache_ttl(cache_control: Optional[str], default_ttl: float) -> float:
+    """
+    Seconds a response may be reused for, given its Cache-Control header.
+
+    Returns:
+        max-age when given, 0 when the response mustn't be reused, and
+        default_ttl when there's no header or it says nothing about reuse
+    """
+    if not cache_control:
+        return default_ttl
+    directives = cache_control.lower()
+    if any(directive in directives for directive in _NO_CACHE_DIRECTIVES):
+        return 0.0
+    match = _MAX_AGE.search(directives)
+    if match:
+        return float(match.group(1))
+    return default_ttl
+
+
+class ResponseCache:
+    """
+    Bounded cache of decoded response bodies, keyed by URL.
+
+    Bodies are copied in and out, so callers may mutate what they get back.
+    """
+
+    def __init__(
+        self,
+        default_ttl: float,
+        max_entries: int = 1024,
+        clock: Callable[[], float] = time.monotonic,
+    ):
+        self.default_ttl = default_ttl
+        self.max_entries = max_entries
+        self.clock = clock
+        self.hits = 0
+        self.misses = 0
+        self._entries: "OrderedDict[str, Tuple[float, Any]]" = OrderedDict()
+
+    def get(self, key: str) -> Optional[Any]:
+        """A copy of the cached body, or None if absent or expired."""
+        entry = self._entries.get(key)
+        if entry is not None and entry[0] <= self.clock():
+            del self._entries[key]
+            entry = None
+        if entry is None:
+            self.misses += 1
+            return None
+        self.hits += 1
+        self._entries.move_to_end(key)
+        return copy.deepcopy(entry[1])
+
+    def put(self, key: str, body: Any, cache_control: Optional[str] = None) -> None:
+        """Store a successful response body unless its headers forbid reuse."""
+        ttl = cache_ttl(cache_control, self.default_ttl)
+        if ttl <= 0 or self.max_entries <= 0:
+            return
+        self._entries[key] = (self.clock() + ttl, copy.deepcopy(body))
+        self._entries.move_to_end(key)
+        while len(self._entries) > self.max_entries:
+            self._entries.popitem(last=False)
+
+    def clear(self) -> None:
+        self._entries.clear()
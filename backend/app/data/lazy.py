@@ -0,0 +1,92 @@
+"""
+Lazily constructed market data providers.
+
+Provider clients need an API key and an HTTP session, and some open network
+connections. LazyProvider puts off building one until its first call, so
+the app starts quickly and still starts when a provider is down or
+misconfigured. Concurrent first calls share a single construction.
+
+A failed construction is raised to the calls waiting on it (and logged),
+and the next call tries again, so a provider that comes back later is
+picked up without a restart.
+"""
+
+import asyncio
+import inspect
+import logging
+from typing import Any, Awaitable, Callable, Optional
+
+logger = logging.getLogger(__name__)
+
+
+class LazyProvider:
+    """
+    Stands in for a provider of provider_class until factory builds it.
+
+    Only the class's async methods are forwarded, so the supports_* checks
+    in provider_base see the same capabilities as on the provider itself.
+    """
+
+    def __init__(self, provider_class: type, factory: Callable[[], Awaitable[Any]]):
+        self._class = provider_class
+        self._factory = factory
+        self._provider: Optional[Any] = None
+        self._lock = asyncio.Lock()
+
+    @property
+    def initialized(self) -> bool:
+        return self._provider is not None
+
+    async def get(self) -> Any:
+        """The provider, built on the first call."""
+        if self._provider is None:
+            async with self._lock:
+                if self._provider is None:
+                    try:
+                        self._provider = await self._factory()
+                    except Exception as e:
+                        logger.error("Couldn't start %s: %s", self._class.__name__, e)
+                        raise
+        return self._provider
+
+    async def close(self) -> None:
+        """Exit the provider's context if it was ever built."""
+        if self._provider is not None:
+            await self._provider.__aexit__(None, None, None)
+            self._provider = None
+
+    def __getattr__(self, attr: str) -> Any:
+        value = getattr(self._class, attr)
+
+        if inspect.isasyncgenfunction(value):
+
+            async def forward_stream(*args, **kwargs):
+                provider = await self.get()
+                async for item in getattr(provider, attr)(*args, **kwargs):
+                    yield item
+
+            return forward_stream
+
+        if asyncio.iscoroutinefunction(value):
+
+            async def forward(*args, **kwargs):
+                provider = await self.get()
+                return await getattr(provider, attr)(*args, **kwargs)
+
+            return forward
+
+        raise AttributeError(f"{attr} isn't forwarded to a lazy provider")
+
+
+def lazy_client(provider_class: type) -> LazyProvider:
+    """
+    A LazyProvider for an HTTP client class, built with no arguments (it
+    reads its key from the settings) and entered as an async context.
+    """
+
+    async def create():
+        provider = provider_class()
+        await provider.__aenter__()
+        return provider
+
+    return LazyProvider(provider_class, create)
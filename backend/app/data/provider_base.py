@@ -2,16 +2,42 @@
 Base classes and protocols for market data providers.
 """
 
-from typing import AsyncIterator, Dict, List, Protocol
+from datetime import date
+from typing import AsyncIterator, Dict, List, Optional, Protocol, runtime_checkable
+
+
+class ProviderNotSupportedError(Exception):
+    """The provider doesn't offer the requested kind of data."""
 
 
 class MarketProvider(Protocol):
     """
     Protocol for a market data provider.
 
-    A provider can stream live data and fetch historical data.
+    Every provider can fetch quotes and historical data over request/response.
+    """
+
+    async def get_quote(self, symbol: str) -> Dict:
+        """Fetch the latest quote for a symbol."""
+        ...
+
+    async def get_history(self, symbol: str, interval: str, limit: int) -> List[Dict]:
+        """Fetch historical data for a symbol."""
+        ...
+
+
+@runtime_checkable
+class StreamingProvider(Protocol):
+    """
+    Protocol for a provider with an upstream push feed (e.g. a WebSocket).
+
+    Providers that implement it replace polling for subscribed symbols.
     """
 
+    async def connect(self):
+        """(Re)establish the upstream connection."""
+        ...
+
     async def subscribe(self, symbols: List[str]):
         """Subscribe to real-time updates for a list of symbols."""
         ...
@@ -20,10 +46,6 @@ class MarketProvider(Protocol):
         """Unsubscribe from real-time updates for a list of symbols."""
         ...
 
-    async def get_history(self, symbol: str, interval: str, limit: int) -> List[Dict]:
-        """Fetch historical data for a symbol."""
-        ...
-
     def stream(self) -> AsyncIterator[Dict]:
         """
         Yields real-time market data messages.
@@ -32,3 +54,71 @@ class MarketProvider(Protocol):
         {"type": "tick", "symbol": "AAPL", "price": 150.0, "ts": 1678886400}
         """
         ...
+
+
+@runtime_checkable
+class Level1Provider(Protocol):
+    """
+    Protocol for a provider with level-1 quote data (best bid/ask).
+    """
+
+    async def get_level1(self, symbol: str) -> Dict:
+        """
+        Fetch the current best bid and ask for a symbol.
+
+        Returns:
+            {"bid": float, "ask": float, "bid_size": int, "ask_size": int,
+             "timestamp": int | None}
+        """
+        ...
+
+
+@runtime_checkable
+class RawQuoteProvider(Protocol):
+    """
+    Protocol for a provider that can return a quote exactly as received.
+
+    Used for debugging, so implementations must skip any caching.
+    """
+
+    async def get_raw_quote(self, symbol: str) -> Dict:
+        """Fetch a quote live and return the provider's JSON untouched."""
+        ...
+
+
+@runtime_checkable
+class DailyCloseProvider(Protocol):
+    """
+    Protocol for a provider that can backfill daily closing prices.
+    """
+
+    async def get_daily_closes(self, symbol: str, days: int) -> Dict[date, float]:
+        """Fetch about the last `days` daily closes, keyed by day."""
+        ...
+
+
+def quote_price(quote: Dict) -> Optional[float]:
+    """The last price in a quote from any provider, or None if it has none."""
+    # Normalized quotes use "price"; raw Finnhub quotes use "c"
+    price = quote.get("price", quote.get("c"))
+    return float(price) if price is not None else None
+
+
+def supports_streaming(provider: object) -> bool:
+    """Whether a provider exposes an upstream push feed."""
+    return isinstance(provider, StreamingProvider)
+
+
+def supports_daily_closes(provider: object) -> bool:
+    """Whether a provider can backfill daily closing prices."""
+    return isinstance(provider, DailyCloseProvider)
+
+
+def supports_level1(provider: object) -> bool:
+    """Whether a provider can fetch best bid/ask quotes."""
+    return isinstance(provider, Level1Provider)
+
+
+def supports_raw_quotes(provider: object) -> bool:
+    """Whether a provider can return uncached, unmodified quotes."""
+    return isinstance(provider, RawQuoteProvider)
@@ -0,0 +1,147 @@
+"""
+Synthetic market data provider for demo mode.
+
+Prices follow a seeded, mean-reverting random walk anchored to each
+symbol's starting price. The walk advances one step per tick of wall-clock
+time and every step's shock is derived from (seed, symbol, step) alone, so
+two providers with the same seed and clock quote identical prices no matter
+how often they're polled.
+
+Outside US market hours the walk keeps moving at a tenth of its regular
+volatility, like thin extended-hours trading, so a demo is never frozen.
+"""
+
+import math
+import random
+import time
+from collections import deque
+from datetime import date, datetime, timedelta, timezone
+from typing import Callable, Deque, Dict, List, Optional, Tuple
+
+from app.utils.market_calendar import (
+    MARKET_TZ,
+    REGULAR_CLOSE,
+    REGULAR_OPEN,
+    trading_days,
+)
+
+SESSION_SECONDS = 6.5 * 60 * 60
+
+DAILY_VOLATILITY = 0.015  # Regular-session stdev of the daily log return
+OFF_HOURS_FACTOR = 0.1
+HISTORY_POINTS = 1000
+
+
+def is_market_open(at: datetime) -> bool:
+    """Whether US equities trade at this moment (weekdays 9:30-16:00 ET)."""
+    local = at.astimezone(MARKET_TZ)
+    return local.weekday() < 5 and REGULAR_OPEN <= local.time() < REGULAR_CLOSE
+
+
+class SyntheticProvider:
+    """
+    Market provider generating plausible quotes without any API key.
+
+    Symbols without an anchor price get a stable pseudo-random one, so
+    searches for any ticker still return a quote.
+    """
+
+    def __init__(
+        self,
+        anchors: Dict[str, float],
+        seed: int = 0,
+        tick_seconds: float = 1.0,
+        clock: Callable[[], float] = time.time,
+    ):
+        self.seed = seed
+        self.tick_seconds = tick_seconds
+        self.clock = clock
+        # Per-tick volatility that adds up to DAILY_VOLATILITY over a session,
+        # with mean reversion pulling back towards the anchor over about a day
+        ticks_per_session = SESSION_SECONDS / tick_seconds
+        self.volatility = DAILY_VOLATILITY / math.sqrt(ticks_per_session)
+        self.reversion = 1 / ticks_per_session
+        self.reset(anchors)
+
+    def reset(self, anchors: Dict[str, float]) -> None:
+        """Restart every walk at its anchor price from now."""
+        self.anchors = {symbol.upper(): price for symbol, price in anchors.items()}
+        self.started_at = self.clock()
+        self._walks: Dict[str, Tuple[int, float]] = {}  # symbol -> (step, log dev)
+        self._history: Dict[str, Deque[Tuple[float, float]]] = {}
+
+    def _anchor(self, symbol: str) -> float:
+        if symbol not in self.anchors:
+            rng = random.Random(f"{self.seed}:{symbol}")
+            self.anchors[symbol] = round(rng.uniform(20, 500), 2)
+        return self.anchors[symbol]
+
+    def _step_at(self, now: float) -> int:
+        return max(0, int((now - self.started_at) // self.tick_seconds))
+
+    def _price(self, symbol: str) -> Tuple[float, float]:
+        """Advance the symbol's walk to the current step; (timestamp, price)."""
+        symbol = symbol.upper()
+        anchor = self._anchor(symbol)
+        step, deviation = self._walks.get(symbol, (0, 0.0))
+        target = self._step_at(self.clock())
+        history = self._history.setdefault(symbol, deque(maxlen=HISTORY_POINTS))
+
+        for n in range(step + 1, target + 1):
+            at = self.started_at + n * self.tick_seconds
+            volatility = self.volatility
+            if not is_market_open(datetime.fromtimestamp(at, timezone.utc)):
+                volatility *= OFF_HOURS_FACTOR
+            shock = random.Random(f"{self.seed}:{symbol}:{n}").gauss(0, 1)
+            deviation = deviation * (1 - self.reversion) + volatility * shock
+            if target - n < HISTORY_POINTS:
+                history.append((at, round(anchor * math.exp(deviation), 2)))
+
+        self._walks[symbol] = (target, deviation)
+        return (
+            self.started_at + target * self.tick_seconds,
+            round(anchor * math.exp(deviation), 2),
+        )
+
+    async def get_quote(self, symbol: str) -> Dict:
+        at, price = self._price(symbol)
+        return {"symbol": symbol.upper(), "price": price, "ts": int(at * 1000)}
+
+    async def get_level1(self, symbol: str) -> Dict:
+        at, price = self._price(symbol)
+        half_spread = max(0.01, round(price * 0.0002, 2)) / 2
+        rng = random.Random(f"{self.seed}:{symbol.upper()}:size:{int(at)}")
+        return {
+            "bid": round(price - half_spread, 4),
+            "ask": round(price + half_spread, 4),
+            "bid_size": rng.randint(1, 20) * 100,
+            "ask_size": rng.randint(1, 20) * 100,
+            "timestamp": int(at),
+        }
+
+    async def get_history(
+        self, symbol: str, interval: Optional[str] = None, limit: int = 100
+    ) -> List[Dict]:
+        """The most recent walk points, one per tick; interval is ignored."""
+        self._price(symbol)
+        points = list(self._history.get(symbol.upper(), []))[-limit:]
+        return [{"t": int(at), "c": price} for at, price in points]
+
+    async def get_daily_closes(self, symbol: str, days: int) -> Dict[date, float]:
+        """
+        Closes for the last `days` trading days up to today, by day.
+
+        Walks back from the anchor price with a shock seeded by (seed, symbol,
+        day), so a day's close doesn't depend on how many days are asked for.
+        """
+        symbol = symbol.upper()
+        anchor = self._anchor(symbol)
+        today = datetime.fromtimestamp(self.clock(), timezone.utc).date()
+        # About 5 in 7 calendar days are trading days, less holidays
+        since = today - timedelta(days=days * 7 // 5 + 10)
+        closes, deviation = {}, 0.0
+        for day in reversed(trading_days(since, today)[-days:]):
+            closes[day] = round(anchor * math.exp(deviation), 2)
+            shock = random.Random(f"{self.seed}:{symbol}:{day}").gauss(0, 1)
+            deviation -= DAILY_VOLATILITY * shock
+        return dict(sorted(closes.items()))
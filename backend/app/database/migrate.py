@@ -0,0 +1,158 @@
+"""
+Versioned schema migrations for Quant-Dash.
+
+This module provides:
+1. Loading the numbered SQL files in app/database/migrations, e.g.
+   0002_stock_sector.sql is version 2, named stock_sector
+2. A schema_migrations table recording the versions applied and when
+3. migrate(), which applies the pending versions in order, each in its own
+   transaction together with its schema_migrations row
+
+Migrations only go up: to change the schema, add a file with the next
+number rather than editing an applied one. A failing migration is rolled
+back and stops the run, so later versions never apply on top of it; running
+again retries from there. Statements in a file end with a semicolon at the
+end of a line.
+
+The app applies the pending migrations at startup when DATABASE_REQUIRED
+is set; `python -m app.database.migrate` applies them by hand.
+"""
+
+import logging
+import re
+from pathlib import Path
+from typing import Any, List, NamedTuple, Optional
+
+logger = logging.getLogger(__name__)
+
+MIGRATIONS_DIR = Path(__file__).parent / "migrations"
+
+_FILENAME = re.compile(r"^(\d+)_(\w+)\.sql$")
+
+_CREATE_TABLE = """
+CREATE TABLE IF NOT EXISTS schema_migrations (
+    version INTEGER PRIMARY KEY,
+    name TEXT NOT NULL,
+    applied_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+)
+"""
+
+
+class Migration(NamedTuple):
+    version: int
+    name: str
+    sql: str
+
+
+class MigrationError(Exception):
+    """A migration failed; it was rolled back and later ones weren't run."""
+
+    def __init__(self, migration: Migration, cause: Exception):
+        self.migration = migration
+        super().__init__(
+            f"Migration {migration.version} ({migration.name}) failed: {cause}"
+        )
+
+
+def load_migrations(directory: Path = MIGRATIONS_DIR) -> List[Migration]:
+    """
+    The migrations in directory, lowest version first.
+
+    Raises:
+        ValueError: If a .sql file isn't named <version>_<name>.sql or two
+            files share a version
+    """
+    migrations = {}
+    for path in sorted(directory.glob("*.sql")):
+        match = _FILENAME.match(path.name)
+        if match is None:
+            raise ValueError(f"{path.name} isn't named <version>_<name>.sql")
+        version = int(match.group(1))
+        if version in migrations:
+            raise ValueError(
+                f"{path.name} reuses version {version} of "
+                f"{migrations[version].name}"
+            )
+        migrations[version] = Migration(version, match.group(2), path.read_text())
+    return [migrations[version] for version in sorted(migrations)]
+
+
+def split_statements(sql: str) -> List[str]:
+    """The statements in a migration, without comment lines."""
+    statements, lines = [], []
+    for line in sql.splitlines():
+        if line.strip().startswith("--"):
+            continue
+        lines.append(line)
+        if line.rstrip().endswith(";"):
+            statements.append("\n".join(lines).strip().rstrip(";"))
+            lines = []
+    rest = "\n".join(lines).strip()
+    if rest:
+        statements.append(rest)
+    return [statement for statement in statements if statement]
+
+
+def applied_versions(engine: Any) -> List[int]:
+    """Versions recorded in schema_migrations, creating it if needed."""
+    with engine.begin() as connection:
+        connection.exec_driver_sql(_CREATE_TABLE)
+        rows = connection.exec_driver_sql(
+            "SELECT version FROM schema_migrations ORDER BY version"
+        ).fetchall()
+    return [row[0] for row in rows]
+
+
+def migrate(
+    engine: Any = None, migrations: Optional[List[Migration]] = None
+) -> List[int]:
+    """
+    Apply the migrations not yet recorded in schema_migrations.
+
+    Args:
+        engine: Defaults to the shared engine from app.database.session
+        migrations: Defaults to the files in MIGRATIONS_DIR
+
+    Returns:
+        The versions applied, in order; empty when up to date
+
+    Raises:
+        MigrationError: If a migration failed (after rolling it back)
+    """
+    if engine is None:
+        from app.database.session import get_engine
+
+        engine = get_engine()
+    if migrations is None:
+        migrations = load_migrations()
+
+    done = set(applied_versions(engine))
+    applied = []
+    for migration in migrations:
+        if migration.version in done:
+            continue
+        try:
+            with engine.begin() as connection:
+                for statement in split_statements(migration.sql):
+                    connection.exec_driver_sql(statement)
+                # Version and name come from a validated file name
+                connection.exec_driver_sql(
+                    "INSERT INTO schema_migrations (version, name) "
+                    f"VALUES ({migration.version}, '{migration.name}')"
+                )
+        except Exception as e:
+            logger.error("Migration %s failed: %s", migration.version, e)
+            raise MigrationError(migration, e) from e
+        logger.info("Applied migration %s (%s)", migration.version, migration.name)
+        applied.append(migration.version)
+
+    if not applied:
+        logger.info("Database schema is up to date")
+    return applied
+
+
+if __name__ == "__main__":
+    from app.core.logging import setup_logging
+
+    setup_logging()
+    migrate()
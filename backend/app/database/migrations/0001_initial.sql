@@ -0,0 +1,84 @@
+-- Tables behind the repositories in app/repositories/base.py
+
+CREATE TABLE stocks (
+    id SERIAL PRIMARY KEY,
+    symbol VARCHAR(16) NOT NULL UNIQUE,
+    name TEXT NOT NULL,
+    price DOUBLE PRECISION NOT NULL,
+    change DOUBLE PRECISION NOT NULL,
+    change_percent DOUBLE PRECISION NOT NULL,
+    volume BIGINT NOT NULL,
+    market_cap TEXT,
+    pe_ratio DOUBLE PRECISION,
+    dividend_yield DOUBLE PRECISION,
+    updated_at TIMESTAMP NOT NULL
+);
+
+CREATE TABLE daily_closes (
+    symbol VARCHAR(16) NOT NULL,
+    day DATE NOT NULL,
+    close DOUBLE PRECISION NOT NULL,
+    PRIMARY KEY (symbol, day)
+);
+
+-- One OHLC bar per symbol and trading day
+CREATE TABLE market_data (
+    id SERIAL PRIMARY KEY,
+    symbol VARCHAR(16) NOT NULL,
+    date TIMESTAMP NOT NULL,
+    open_price DOUBLE PRECISION NOT NULL,
+    high_price DOUBLE PRECISION NOT NULL,
+    low_price DOUBLE PRECISION NOT NULL,
+    close_price DOUBLE PRECISION NOT NULL,
+    volume BIGINT NOT NULL,
+    created_at TIMESTAMP NOT NULL
+);
+
+CREATE UNIQUE INDEX market_data_symbol_day ON market_data (symbol, CAST(date AS DATE));
+
+CREATE TABLE portfolios (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX portfolios_user_id ON portfolios (user_id);
+
+CREATE TABLE positions (
+    id SERIAL PRIMARY KEY,
+    portfolio_id INTEGER NOT NULL REFERENCES portfolios (id) ON DELETE CASCADE,
+    stock_symbol VARCHAR(16) NOT NULL,
+    quantity INTEGER NOT NULL CHECK (quantity > 0),
+    average_price DOUBLE PRECISION NOT NULL,
+    current_value DOUBLE PRECISION NOT NULL,
+    target_weight DOUBLE PRECISION CHECK (target_weight BETWEEN 0 AND 1),
+    notes TEXT,
+    tags TEXT[] NOT NULL DEFAULT '{}'
+);
+
+CREATE INDEX positions_portfolio_id ON positions (portfolio_id);
+
+CREATE TABLE transactions (
+    id SERIAL PRIMARY KEY,
+    portfolio_id INTEGER NOT NULL REFERENCES portfolios (id) ON DELETE CASCADE,
+    symbol VARCHAR(16) NOT NULL,
+    side VARCHAR(4) NOT NULL CHECK (side IN ('buy', 'sell')),
+    quantity DOUBLE PRECISION NOT NULL CHECK (quantity > 0),
+    price DOUBLE PRECISION NOT NULL,
+    fees DOUBLE PRECISION NOT NULL DEFAULT 0,
+    executed_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX transactions_portfolio_executed ON transactions (portfolio_id, executed_at);
+
+CREATE TABLE corporate_actions (
+    id SERIAL PRIMARY KEY,
+    symbol VARCHAR(16) NOT NULL,
+    type VARCHAR(16) NOT NULL CHECK (type IN ('split', 'dividend')),
+    ex_date DATE NOT NULL,
+    split_ratio DOUBLE PRECISION,
+    dividend_per_share DOUBLE PRECISION
+);
+
+CREATE INDEX corporate_actions_ex_date ON corporate_actions (ex_date);
@@ -0,0 +1,11 @@
+-- One snapshot per portfolio and day; retaking it the same day replaces it
+
+CREATE TABLE portfolio_snapshots (
+    portfolio_id INTEGER NOT NULL REFERENCES portfolios (id) ON DELETE CASCADE,
+    date DATE NOT NULL,
+    total_value DOUBLE PRECISION NOT NULL,
+    total_gain DOUBLE PRECISION NOT NULL DEFAULT 0,
+    holdings JSONB NOT NULL DEFAULT '[]',
+    taken_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (portfolio_id, date)
+);
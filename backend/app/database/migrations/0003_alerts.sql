@@ -0,0 +1,33 @@
+-- Price alert rules; a one-shot alert is inactive from its trigger until
+-- it's acknowledged
+
+CREATE TABLE alerts (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL,
+    symbol VARCHAR(16) NOT NULL,
+    condition VARCHAR(16) NOT NULL
+        CHECK (condition IN ('above', 'below', 'pct_change')),
+    threshold DOUBLE PRECISION NOT NULL CHECK (threshold > 0),
+    active BOOLEAN NOT NULL DEFAULT TRUE,
+    triggered_at TIMESTAMP,
+    triggered_price DOUBLE PRECISION,
+    acknowledged_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX alerts_active_symbol ON alerts (symbol) WHERE active;
+
+-- One row per firing; kept when the alert is deleted
+CREATE TABLE alert_events (
+    id SERIAL PRIMARY KEY,
+    alert_id INTEGER NOT NULL,
+    user_id INTEGER NOT NULL,
+    symbol VARCHAR(16) NOT NULL,
+    condition VARCHAR(16) NOT NULL,
+    threshold DOUBLE PRECISION NOT NULL,
+    price DOUBLE PRECISION NOT NULL,
+    previous_close DOUBLE PRECISION,
+    triggered_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX alert_events_user_time ON alert_events (user_id, triggered_at);
@@ -0,0 +1,4 @@
+-- Gain on shares sold through recorded transactions; positions only hold
+-- the shares still open
+
+ALTER TABLE portfolios ADD COLUMN realized_gain DOUBLE PRECISION NOT NULL DEFAULT 0;
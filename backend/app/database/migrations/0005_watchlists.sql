@@ -0,0 +1,20 @@
+-- Watchlists, shown to their owner in sort_order; names are unique per user
+-- so a retried create finds the first attempt's watchlist
+
+CREATE TABLE watchlists (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL,
+    name VARCHAR(100) NOT NULL,
+    sort_order INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMP NOT NULL,
+    UNIQUE (user_id, name)
+);
+
+CREATE INDEX watchlists_user_order ON watchlists (user_id, sort_order);
+
+CREATE TABLE watchlist_items (
+    watchlist_id INTEGER NOT NULL REFERENCES watchlists (id) ON DELETE CASCADE,
+    symbol VARCHAR(16) NOT NULL,
+    added_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (watchlist_id, symbol)
+);
@@ -0,0 +1,6 @@
+-- Symbol search matches case-insensitively on symbol and name prefixes.
+-- text_pattern_ops lets LIKE 'x%' use the index under any collation
+
+CREATE INDEX stocks_lower_symbol ON stocks (lower(symbol) text_pattern_ops);
+
+CREATE INDEX stocks_lower_name ON stocks (lower(name) text_pattern_ops);
@@ -0,0 +1,82 @@
+"""
+Database connection pool for Quant-Dash.
+
+This module provides:
+1. The pool settings read from DB_MAX_OPEN_CONNS, DB_MAX_IDLE_CONNS and
+   DB_CONN_MAX_LIFETIME_SECONDS
+2. A shared SQLAlchemy engine, created on first use
+3. A ping with a timeout, for the readiness check
+
+The engine is only built when something asks for it, so the app starts
+(and serves from memory) without a reachable database.
+"""
+
+import asyncio
+from typing import Any, Dict, Optional
+
+from app.core.config import settings
+
+_engine: Optional[Any] = None
+
+
+def pool_options() -> Dict[str, Any]:
+    """
+    create_engine arguments for the configured limits.
+
+    SQLAlchemy keeps pool_size connections open and opens up to
+    max_overflow more under load, closing those when they're returned.
+    """
+    return {
+        "pool_size": settings.DB_MAX_IDLE_CONNS,
+        "max_overflow": settings.DB_MAX_OPEN_CONNS - settings.DB_MAX_IDLE_CONNS,
+        "pool_recycle": settings.DB_CONN_MAX_LIFETIME_SECONDS,
+        "pool_pre_ping": True,  # Drop connections the server closed
+    }
+
+
+def get_engine() -> Any:
+    """The shared engine, connecting lazily to SQLALCHEMY_DATABASE_URI."""
+    global _engine
+    if _engine is None:
+        from sqlalchemy import create_engine
+
+        _engine = create_engine(
+            settings.SQLALCHEMY_DATABASE_URI,
+            connect_args={
+                "connect_timeout": max(1, int(settings.READINESS_TIMEOUT_SECONDS))
+            },
+            **pool_options(),
+        )
+    return _engine
+
+
+def _select_one() -> None:
+    from sqlalchemy import text
+
+    with get_engine().connect() as connection:
+        connection.execute(text("SELECT 1"))
+
+
+async def ping(timeout: float) -> Optional[str]:
+    """
+    Run SELECT 1 on a pooled connection.
+
+    Returns:
+        None if the database answered within timeout seconds, otherwise
+        why it didn't
+    """
+    try:
+        await asyncio.wait_for(asyncio.to_thread(_select_one), timeout)
+    except asyncio.TimeoutError:
+        return f"No answer within {timeout}s"
+    except Exception as e:
+        return str(e) or type(e).__name__
+    return None
+
+
+def dispose_engine() -> None:
+    """Close the pooled connections, e.g. at shutdown."""
+    global _engine
+    if _engine is not None:
+        _engine.dispose()
+        _engine = None
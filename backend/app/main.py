@@ -1,12 +1,63 @@
 import asyncio
+import logging
 from typing import Any, Dict
 
 from app.api.v1 import api_router
+from app.api.v1.endpoints.health import readiness_check
+from app.core.bodylimit import BodySizeLimitMiddleware
+from app.core.caching import CacheControlMiddleware
 from app.core.config import settings
+from app.core.cors import CORSMiddleware
+from app.core.errors import install_error_handlers
+from app.core.faults import (
+    FaultInjectingProxy,
+    FaultInjectionMiddleware,
+    fault_injection_available,
+    fault_injector,
+    inject_repository_faults,
+)
+from app.core.iplimit import (
+    IPRateLimitMiddleware,
+    ip_rate_limit_enabled,
+    ip_rate_limiter,
+    rate_limit_paths,
+)
+from app.core.jobs import job_scheduler
+from app.core.jsonerrors import JSONErrorMiddleware
+from app.core.logging import setup_logging
+from app.core.metrics import (
+    InstrumentedProvider,
+    MetricsMiddleware,
+    register_gauge,
+    register_slo_gauges,
+    render_metrics,
+    start_metrics_server,
+)
+from app.core.providerbudget import ProviderBudgetMiddleware, provider_budget_enabled
+from app.core.querybudget import QueryBudgetMiddleware, query_budget_enabled
+from app.core.requestlog import RequestLogMiddleware
+from app.core.slo import slo_tracker
+from app.data.alphavantage import AlphaVantageService
 from app.data.finnhub import FinnhubService
+from app.data.lazy import lazy_client
+from app.data.synthetic import SyntheticProvider
+from app.database.migrate import migrate
+from app.database.session import dispose_engine
+from app.models.schemas import ReadinessResponse
+from app.services.alerts import alert_service
+from app.services.corporate_actions import corporate_action_service
+from app.services.demo import DemoService, register_demo_jobs
+from app.services.market import market_service
+from app.services.portfolio import portfolio_service
+from app.services.refresher import QuoteRefresher
+from app.services.snapshots import snapshot_service
+from app.ws.feed import create_feed
 from app.ws.hub import ConnectionManager
-from fastapi import FastAPI, WebSocket, WebSocketDisconnect
-from fastapi.middleware.cors import CORSMiddleware
+from fastapi import FastAPI, Response, WebSocket
+from fastapi.responses import PlainTextResponse
+
+setup_logging()
+logger = logging.getLogger(__name__)
 
 app = FastAPI(
     title="Quant-Dash API",
@@ -15,6 +66,9 @@ app = FastAPI(
     openapi_url=f"{settings.API_V1_STR}/openapi.json",
 )
 
+install_error_handlers(app)
+register_slo_gauges(slo_tracker)
+
 # Application state
 state: Dict[str, Any] = {}
 
@@ -22,56 +76,187 @@ state: Dict[str, Any] = {}
 @app.on_event("startup")
 async def startup_event():
     """Handles application startup events."""
-    finnhub_provider = FinnhubService()
-    await finnhub_provider.__aenter__()  # Manually enter the context
+    if settings.DATABASE_REQUIRED:
+        # A failing migration stops startup rather than serving on an old schema
+        await asyncio.to_thread(migrate)
 
-    connection_manager = ConnectionManager(finnhub_provider)
+    if fault_injection_available():
+        inject_repository_faults(
+            {
+                "stocks": market_service,
+                "market_data": market_service,
+                "portfolios": portfolio_service,
+                "actions": corporate_action_service,
+            },
+            fault_injector,
+        )
 
-    state["finnhub_provider"] = finnhub_provider
+    if settings.DEMO_MODE:
+        provider = SyntheticProvider(
+            {}, seed=settings.DEMO_SEED, tick_seconds=settings.DEMO_TICK_SECONDS
+        )
+        demo = DemoService(provider, market_service, portfolio_service, alert_service)
+        await demo.reset()
+        register_demo_jobs(job_scheduler, demo)
+        provider_name = "synthetic"
+        logger.info("Demo mode: serving synthetic market data")
+    elif settings.MARKET_DATA_PROVIDER == "synthetic":
+        stocks = await market_service.get_stocks()
+        anchors = {stock.symbol: stock.price for stock in stocks}
+        provider = SyntheticProvider(
+            anchors, seed=settings.DEMO_SEED, tick_seconds=settings.DEMO_TICK_SECONDS
+        )
+        provider_name = "synthetic"
+        logger.info("Serving synthetic market data; no provider key is used")
+    elif settings.MARKET_DATA_PROVIDER == "alphavantage":
+        # Built on first use, so a provider that's down doesn't block startup
+        provider = lazy_client(AlphaVantageService)
+        state["http_provider"] = provider
+        provider_name = "alphavantage"
+    else:
+        provider = lazy_client(FinnhubService)
+        state["http_provider"] = provider
+        provider_name = "finnhub"
+
+    feed_provider = provider
+    if fault_injection_available():
+        feed_provider = FaultInjectingProxy(provider, provider_name, fault_injector)
+    # Outermost, so injected faults count as provider errors
+    feed_provider = InstrumentedProvider(feed_provider, provider_name)
+
+    market_service.set_provider(feed_provider, provider_name)
+    connection_manager = ConnectionManager(
+        create_feed(feed_provider), market=market_service
+    )
     state["connection_manager"] = connection_manager
+    register_gauge(
+        "websocket_connections",
+        "Open market stream WebSocket connections",
+        lambda: [({}, len(connection_manager.active_connections))],
+    )
+
+    if provider_name != "finnhub" or settings.FINNHUB_API_KEY:
+        refresher = QuoteRefresher(
+            feed_provider, market_service, portfolio_service, alert_service
+        )
+        job_scheduler.register(
+            "quote_refresh",
+            settings.QUOTE_REFRESH_INTERVAL_SECONDS,
+            refresher.refresh_once,
+        )
+
+    if settings.QUOTE_CACHE_TTL > 0:
+        job_scheduler.register(
+            "quote_cache_eviction",
+            settings.QUOTE_CACHE_TTL,
+            market_service.evict_expired_quotes,
+        )
+
+    if settings.WS_STOCK_PUSH_INTERVAL_SECONDS > 0:
+        job_scheduler.register(
+            "stream_stock_push",
+            settings.WS_STOCK_PUSH_INTERVAL_SECONDS,
+            connection_manager.push_stocks,
+        )
+
+    job_scheduler.register(
+        "portfolio_snapshots",
+        settings.SNAPSHOT_INTERVAL_MINUTES * 60,
+        snapshot_service.take_all,
+    )
+
+    if ip_rate_limit_enabled():
+        job_scheduler.register(
+            "rate_limit_sweep",
+            settings.RATE_LIMIT_IDLE_SECONDS,
+            ip_rate_limiter.sweep,
+        )
 
     asyncio.create_task(connection_manager.broadcast_ticks())
+    job_scheduler.start()
+    if settings.METRICS_PORT:
+        state["metrics_server"] = await start_metrics_server(
+            settings.BIND_HOST, settings.METRICS_PORT
+        )
     print("Application startup complete.")
 
 
 @app.on_event("shutdown")
 async def shutdown_event():
     """Handles application shutdown events."""
-    if "finnhub_provider" in state:
-        await state["finnhub_provider"].__aexit__(None, None, None)
+    await job_scheduler.stop()
+    if state.get("metrics_server") is not None:
+        state["metrics_server"].close()
+    if "http_provider" in state:
+        await state["http_provider"].close()
+    dispose_engine()
     print("Application shutdown complete.")
 
 
-# Set all CORS enabled origins
-if settings.BACKEND_CORS_ORIGINS:
+# Fault injection for resilience testing; never installed in production
+if fault_injection_available():
+    app.add_middleware(FaultInjectionMiddleware, injector=fault_injector)
+
+# Per-request query budget for catching N+1 patterns in development
+if query_budget_enabled():
+    app.add_middleware(
+        QueryBudgetMiddleware,
+        limit=settings.QUERY_BUDGET,
+        strict=settings.QUERY_BUDGET_STRICT,
+    )
+
+# Total provider time per request, so fan-out endpoints have bounded latency
+if provider_budget_enabled():
+    app.add_middleware(
+        ProviderBudgetMiddleware, seconds=settings.PROVIDER_REQUEST_BUDGET
+    )
+
+# 429s for IPs calling the provider-backed endpoints faster than RATE_LIMIT_RPS
+if ip_rate_limit_enabled():
     app.add_middleware(
-        CORSMiddleware,
-        allow_origins=[str(origin) for origin in settings.BACKEND_CORS_ORIGINS],
-        allow_credentials=True,
-        allow_methods=["*"],
-        allow_headers=["*"],
+        IPRateLimitMiddleware,
+        limiter=ip_rate_limiter,
+        paths=rate_limit_paths(settings.API_V1_STR),
+        trusted_proxies=settings.TRUSTED_PROXY_COUNT,
     )
 
+# Cache-Control headers declared per route
+app.add_middleware(CacheControlMiddleware)
+
+# Request timing for SLO tracking
+app.add_middleware(MetricsMiddleware, tracker=slo_tracker)
+
+# 413s for bodies over MAX_REQUEST_BODY_BYTES
+app.add_middleware(BodySizeLimitMiddleware, max_bytes=settings.MAX_REQUEST_BODY_BYTES)
+
+# Request IDs, one log line per request, and 500s for unhandled errors
+app.add_middleware(RequestLogMiddleware)
+
+# CORS for CORS_ALLOWED_ORIGINS, or any origin without credentials
+app.add_middleware(
+    CORSMiddleware,
+    allowed_origins=settings.CORS_ALLOWED_ORIGINS,
+    max_age=settings.CORS_MAX_AGE_SECONDS,
+)
+
+# ErrorResponse bodies for plain-text API errors from middleware or the framework
+app.add_middleware(JSONErrorMiddleware, prefix=settings.API_V1_STR)
+
 app.include_router(api_router, prefix=settings.API_V1_STR)
 
 
 @app.websocket("/ws")
+@app.websocket(f"{settings.API_V1_STR}/market/stream")
 async def websocket_endpoint(websocket: WebSocket):
-    """Main WebSocket endpoint for real-time data."""
-    connection_manager = state["connection_manager"]
-    await connection_manager.connect(websocket)
-    try:
-        while True:
-            data = await websocket.receive_text()
-            await connection_manager.handle_message(websocket, data)
-    except WebSocketDisconnect:
-        # Client disconnected, which is an expected event.
-        pass
-    except Exception as e:
-        # Log other unexpected errors for debugging.
-        print(f"WebSocket error for client {websocket.client}: {e}")
-    finally:
-        await connection_manager.disconnect(websocket)
+    """
+    WebSocket endpoint for real-time data.
+
+    Send {"symbols": ["AAPL", "GOOGL"]} or {"action": "subscribe", ...} (or
+    "unsubscribe") to choose which symbols are pushed, up to
+    WS_MAX_SYMBOLS_PER_CONNECTION. Subscribers get price ticks as they arrive
+    and each symbol's stock every WS_STOCK_PUSH_INTERVAL_SECONDS.
+    """
+    await state["connection_manager"].serve(websocket)
 
 
 @app.get("/")
@@ -86,3 +271,19 @@ async def health_check():
         "message": "Quant-Dash Backend API is running",
         "version": "1.0.0",
     }
+
+
+@app.get("/health/ready", response_model=ReadinessResponse)
+async def health_ready(response: Response):
+    """Readiness: 503 while something needed to serve traffic is down."""
+    return await readiness_check(response)
+
+
+async def metrics():
+    """Prometheus scrape endpoint."""
+    return render_metrics()
+
+
+# On the API port unless METRICS_PORT gives it a listener of its own
+if not settings.METRICS_PORT:
+    app.add_api_route("/metrics", metrics, response_class=PlainTextResponse)
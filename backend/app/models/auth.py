@@ -16,8 +16,9 @@ Design decisions:
 import re
 from datetime import datetime
 from enum import Enum
-from typing import List, Optional
+from typing import Optional
 
+from app.models.schemas import RequestBody
 from pydantic import BaseModel, EmailStr, Field, model_validator, validator
 
 
@@ -51,7 +52,7 @@ class UserStatus(str, Enum):
 
 
 # Authentication Schemas
-class UserLogin(BaseModel):
+class UserLogin(RequestBody):
     """
     Login request schema with validation.
 
@@ -62,7 +63,7 @@ class UserLogin(BaseModel):
     password: str = Field(..., min_length=8, max_length=128)
 
 
-class UserRegister(BaseModel):
+class UserRegister(RequestBody):
     """
     User registration schema with strong password validation.
     Fields:
@@ -170,11 +171,11 @@ class UserProfile(BaseModel):
         from_attributes = True
 
 
-class PasswordReset(BaseModel):
+class PasswordReset(RequestBody):
     email: EmailStr
 
 
-class PasswordResetConfirm(BaseModel):
+class PasswordResetConfirm(RequestBody):
 
     token: str = Field(..., description="Password reset token")
     new_password: str = Field(..., min_length=8, max_length=128)
@@ -192,23 +193,11 @@ class PasswordResetConfirm(BaseModel):
         return self
 
 
-class EmailVerification(BaseModel):
+class EmailVerification(RequestBody):
     token: str = Field(..., description="Email verification token")
 
 
-class RefreshTokenRequest(BaseModel):
+class RefreshTokenRequest(RequestBody):
 
     refresh_token: str = Field(..., description="Refresh token")
 
-
-# Error Response Schemas
-class AuthErrorResponse(BaseModel):
-    error: str
-    message: str
-    details: Optional[dict] = None
-
-
-class ValidationErrorResponse(BaseModel):
-    error: str = "validation_error"
-    message: str
-    details: List[dict]
@@ -1,7 +1,22 @@
-from datetime import datetime
-from typing import List, Optional
+from datetime import date, datetime
+from enum import Enum
+from typing import Any, Dict, List, Optional
 
-from pydantic import BaseModel, Field
+from pydantic import BaseModel, Field, field_validator, model_validator
+from app.utils.cointegration import SIGNIFICANCE_LEVELS
+from app.utils.symbols import normalize_symbol
+from app.utils.timestamps import naive_utc
+
+
+class RequestBody(BaseModel):
+    """
+    Base for request bodies: fields the model doesn't declare are rejected
+    (400 validation.field_unknown) instead of silently dropped, so a typo
+    like "quantiy" doesn't quietly fall back to a default.
+    """
+
+    class Config:
+        extra = "forbid"
 
 
 # Stock Models
@@ -14,6 +29,9 @@ class StockBase(BaseModel):
     volume: int = Field(..., description="Trading volume")
     market_cap: Optional[str] = Field(None, description="Market capitalization")
     pe_ratio: Optional[float] = Field(None, description="Price-to-earnings ratio")
+    dividend_yield: Optional[float] = Field(
+        None, ge=0, description="Trailing annual dividend / price (0.01 = 1%)"
+    )
 
 
 class Stock(StockBase):
@@ -24,10 +42,185 @@ class Stock(StockBase):
         from_attributes = True
 
 
-class StockCreate(StockBase):
+class ProviderDebug(BaseModel):
+    """A live provider quote as received, next to the stock parsed from it."""
+
+    symbol: str
+    provider: str
+    fetched_at: datetime
+    raw: Dict[str, Any] = Field(..., description="The provider's JSON, untouched")
+    price: Optional[float] = Field(None, description="Price read from the quote")
+    stock: Optional[Stock] = Field(None, description="None for untracked symbols")
+
+
+class StockPage(BaseModel):
+    items: List[Stock]
+    total: int = Field(..., description="Matching stocks across all pages")
+    page: int
+    page_size: int
+
+
+class LiveQuote(BaseModel):
+    symbol: str
+    price: float
+
+
+class BatchItemStatus(BaseModel):
+    """Outcome of one item of a batch request."""
+
+    item: str = Field(..., description="The item as requested, e.g. a symbol")
+    status: int = Field(..., description="HTTP status the item would get alone")
+    code: Optional[str] = Field(None, description="Error code, for failed items")
+    message: Optional[str] = None
+
+
+class QuoteBatch(BaseModel):
+    quotes: List[LiveQuote] = Field(default_factory=list)
+    stocks: Dict[str, Stock] = Field(
+        default_factory=dict,
+        description="Each stock by symbol, in the order requested; not when live",
+    )
+    missing: List[str] = Field(
+        default_factory=list, description="Symbols without a quote"
+    )
+    not_found: List[str] = Field(
+        default_factory=list,
+        description="The missing symbols with no stock; the rest are worth retrying",
+    )
+    partial: bool = Field(
+        False,
+        description="The provider time budget ran out before every symbol "
+        "was fetched",
+    )
+    results: List[BatchItemStatus] = Field(
+        default_factory=list, description="Outcome per requested symbol, in order"
+    )
+
+
+class QuoteBatchRequest(RequestBody):
+    symbols: List[str] = Field(
+        ..., description="Up to 100, or 50 live; repeats are ignored"
+    )
+    live: bool = Field(False, description="Only fresh quotes from the provider")
+
+
+# Market Data Models
+class MarketDataBase(BaseModel):
+    symbol: str = Field(..., description="Stock symbol")
+    date: datetime = Field(..., description="Trading date")
+    open_price: float = Field(..., description="Opening price")
+    high_price: float = Field(..., description="Highest price")
+    low_price: float = Field(..., description="Lowest price")
+    close_price: float = Field(..., description="Closing price")
+    volume: int = Field(..., description="Trading volume")
+
+
+class MarketData(MarketDataBase):
+    id: int
+    created_at: datetime
+
+    class Config:
+        from_attributes = True
+
+
+class MarketDataCreate(MarketDataBase):
     pass
 
 
+class PricePoint(BaseModel):
+    date: date
+    close: float
+
+
+class StockHistory(BaseModel):
+    symbol: str
+    start: date
+    end: date
+    closes: List[PricePoint] = Field(
+        default_factory=list, description="Stored daily closes, oldest first"
+    )
+    bars: List[MarketData] = Field(
+        default_factory=list, description="Stored daily OHLC bars, oldest first"
+    )
+
+
+class IndicatorType(str, Enum):
+    SMA = "sma"
+    EMA = "ema"
+    RSI = "rsi"
+
+
+class IndicatorPoint(BaseModel):
+    date: date
+    close: float
+    values: Dict[IndicatorType, Optional[float]] = Field(
+        default_factory=dict,
+        description="Each requested indicator, None during its warm-up",
+    )
+
+
+class IndicatorSeries(BaseModel):
+    symbol: str
+    types: List[IndicatorType]
+    period: int
+    start: date
+    end: date
+    points: List[IndicatorPoint] = Field(
+        default_factory=list, description="One per stored close, oldest first"
+    )
+
+
+class DataCoverage(BaseModel):
+    """The stored daily history for a symbol and the trading days it's missing."""
+
+    symbol: str
+    granularity: str = Field("1d", description="Spacing of the stored rows")
+    start: Optional[date] = Field(None, description="First stored day")
+    end: Optional[date] = Field(None, description="Last stored day")
+    rows: int
+    gaps: List[date] = Field(
+        default_factory=list,
+        description="Trading days between start and end with no row",
+    )
+
+
+class StockCreate(StockBase):
+    price: float = Field(..., gt=0, description="Current stock price")
+    change_percent: float = Field(
+        0.0, description="Ignored; recomputed from price and change"
+    )
+    volume: int = Field(..., ge=0, description="Trading volume")
+
+    class Config:
+        extra = "forbid"  # A request body; see RequestBody
+
+
+class SearchResult(BaseModel):
+    symbol: str
+    name: str
+    price: float
+    change_percent: float
+    match_type: str = Field(
+        ..., description="exact, prefix, substring or fuzzy (trigram similarity)"
+    )
+    score: float = Field(..., description="Ranking score, higher is better")
+    highlights: Dict[str, List[List[int]]] = Field(
+        default_factory=dict,
+        description="Matched [start, end) character ranges keyed by field",
+    )
+
+
+class Level1Quote(BaseModel):
+    symbol: str
+    bid: float
+    ask: float
+    bid_size: int
+    ask_size: int
+    spread: float = Field(..., description="Ask minus bid")
+    spread_percent: float = Field(..., description="Spread as % of the midpoint")
+    timestamp: Optional[int] = Field(None, description="Quote time, epoch ms")
+
+
 class StockUpdate(BaseModel):
     price: Optional[float] = None
     change: Optional[float] = None
@@ -38,10 +231,33 @@ class StockUpdate(BaseModel):
 
 
 # Portfolio Models
-class PositionBase(BaseModel):
-    stock_symbol: str = Field(..., description="Stock symbol")
-    quantity: int = Field(..., description="Number of shares")
-    average_price: float = Field(..., description="Average purchase price")
+class PositionBase(RequestBody):
+    stock_symbol: str = Field(..., min_length=1, description="Stock symbol")
+    quantity: int = Field(..., gt=0, description="Number of shares")
+    average_price: float = Field(..., ge=0, description="Average purchase price")
+    target_weight: Optional[float] = Field(
+        None, ge=0, le=1, description="Target share of portfolio value (0-1)"
+    )
+    notes: Optional[str] = Field(
+        None, max_length=2000, description="Rationale for holding the position"
+    )
+    tags: List[str] = Field(
+        default_factory=list, description="Labels for filtering, e.g. long-term"
+    )
+
+    @field_validator("tags")
+    def clean_tags(cls, tags: List[str]) -> List[str]:
+        return normalize_tags(tags)
+
+
+def normalize_tags(tags: Optional[List[str]]) -> List[str]:
+    """Lowercase and strip tags, dropping blanks and duplicates (order kept)."""
+    normalized: List[str] = []
+    for tag in tags or []:
+        tag = tag.strip().lower()
+        if tag and tag not in normalized:
+            normalized.append(tag)
+    return normalized
 
 
 class Position(PositionBase):
@@ -52,12 +268,276 @@ class Position(PositionBase):
 
     class Config:
         from_attributes = True
+        extra = "ignore"  # A response, not a body
 
 
 class PositionCreate(PositionBase):
     portfolio_id: int
 
 
+class PositionUpdate(RequestBody):
+    """
+    Partial position update; only the fields sent are changed.
+
+    A null target_weight or notes clears it and a null tags clears the tags;
+    quantity and average_price can be left out but not nulled.
+    """
+
+    quantity: Optional[int] = Field(None, gt=0)
+    average_price: Optional[float] = Field(None, ge=0)
+    target_weight: Optional[float] = Field(None, ge=0, le=1)
+    notes: Optional[str] = Field(None, max_length=2000)
+    tags: Optional[List[str]] = None
+
+    @field_validator("quantity", "average_price")
+    def not_null(cls, value):
+        if value is None:
+            raise ValueError("Leave the field out to keep it; it can't be null")
+        return value
+
+    @field_validator("tags")
+    def clean_tags(cls, tags: Optional[List[str]]) -> List[str]:
+        return normalize_tags(tags)
+
+
+class PositionAdjustment(RequestBody):
+    """
+    Manual correction of a position after a corporate action.
+
+    Exactly one of split_ratio or average_price must be given.
+    """
+
+    split_ratio: Optional[float] = Field(
+        None, gt=0, description="New shares per old share (2.0 for a 2-for-1 split)"
+    )
+    average_price: Optional[float] = Field(
+        None, gt=0, description="Explicit replacement average price"
+    )
+    reason: Optional[str] = Field(
+        None, max_length=255, description="Note recorded in the audit log"
+    )
+
+    @model_validator(mode="after")
+    def exactly_one_adjustment(self) -> "PositionAdjustment":
+        """Ensure the request is either a split or an explicit override."""
+        if (self.split_ratio is None) == (self.average_price is None):
+            raise ValueError("Provide exactly one of split_ratio or average_price")
+        return self
+
+
+class CorporateActionType(str, Enum):
+    SPLIT = "split"
+    DIVIDEND = "dividend"
+
+
+class CorporateActionCreate(BaseModel):
+    """
+    A split or cash dividend on a symbol.
+
+    Splits give split_ratio and dividends give dividend_per_share.
+    """
+
+    symbol: str
+    type: CorporateActionType
+    ex_date: date
+    split_ratio: Optional[float] = Field(
+        None, gt=0, description="New shares per old share (2.0 for a 2-for-1 split)"
+    )
+    dividend_per_share: Optional[float] = Field(None, gt=0)
+
+    @field_validator("symbol")
+    def clean_symbol(cls, symbol: str) -> str:
+        return normalize_symbol(symbol)
+
+    @model_validator(mode="after")
+    def amount_matches_type(self) -> "CorporateActionCreate":
+        """Ensure a split has only a ratio and a dividend only an amount."""
+        is_split = self.type == CorporateActionType.SPLIT
+        if is_split != (self.split_ratio is not None):
+            raise ValueError("split_ratio is required for splits, and only for them")
+        if is_split == (self.dividend_per_share is not None):
+            raise ValueError(
+                "dividend_per_share is required for dividends, and only for them"
+            )
+        return self
+
+
+class CorporateAction(CorporateActionCreate):
+    id: int
+
+
+class HeldCorporateAction(CorporateAction):
+    quantity: float = Field(..., description="Shares held going into the ex-date")
+    dividend_amount: Optional[float] = Field(
+        None, description="quantity * dividend_per_share, for dividends"
+    )
+
+
+class PortfolioCorporateActions(BaseModel):
+    portfolio_id: int
+    start: date
+    end: date
+    actions: List[HeldCorporateAction] = Field(
+        default_factory=list, description="By ex-date"
+    )
+
+
+# Transaction Models
+class TradeSide(str, Enum):
+    BUY = "buy"
+    SELL = "sell"
+
+
+class TransactionCreate(RequestBody):
+    symbol: str = Field(..., description="Stock symbol")
+    side: TradeSide
+    quantity: float = Field(..., gt=0)
+    price: float = Field(..., gt=0, description="Execution price per share")
+    fees: float = Field(0.0, ge=0, description="Commission and other costs")
+    executed_at: datetime = Field(..., description="Stored in UTC, without a zone")
+
+    @field_validator("executed_at")
+    def in_naive_utc(cls, executed_at: datetime) -> datetime:
+        # Aware and naive times in one ledger couldn't be sorted together
+        return naive_utc(executed_at)
+
+
+class TransactionRequest(TransactionCreate):
+    """
+    A transaction with an optional guard against acting on a stale price.
+
+    With expected_price set, the transaction is rejected (409) when the live
+    price is further than tolerance (a fraction of expected_price) from it.
+    """
+
+    expected_price: Optional[float] = Field(
+        None, gt=0, description="Price the user saw when confirming"
+    )
+    tolerance: Optional[float] = Field(
+        None,
+        ge=0,
+        le=1,
+        description="Allowed deviation, e.g. 0.01 for 1%; defaults to "
+        "PRICE_CHECK_DEFAULT_TOLERANCE",
+    )
+
+    @model_validator(mode="after")
+    def tolerance_needs_expected_price(self) -> "TransactionRequest":
+        if self.tolerance is not None and self.expected_price is None:
+            raise ValueError("tolerance requires expected_price")
+        return self
+
+
+class Transaction(TransactionCreate):
+    id: int
+    portfolio_id: int
+
+    class Config:
+        extra = "ignore"  # A response, not a body
+
+
+class ClosedTrade(BaseModel):
+    """A sell matched against the buys it closed, oldest lots first."""
+
+    symbol: str
+    quantity: float
+    opened_at: datetime = Field(..., description="Execution of the oldest lot")
+    closed_at: datetime
+    cost_basis: float = Field(..., description="Including buy fees")
+    proceeds: float = Field(..., description="Net of sell fees")
+    profit: float
+
+
+class TradeStats(BaseModel):
+    portfolio_id: int
+    closed_trades: int
+    wins: int
+    losses: int
+    win_rate: Optional[float] = Field(None, description="Profitable / closed trades")
+    average_win: Optional[float] = None
+    average_loss: Optional[float] = Field(None, description="Negative amount")
+    profit_factor: Optional[float] = Field(
+        None, description="Gross profit / gross loss; None without losses"
+    )
+
+
+class HoldingTerm(str, Enum):
+    SHORT = "short_term"
+    LONG = "long_term"  # Held more than a year
+
+
+class RealizedLot(BaseModel):
+    """Shares of one buy lot a suggested sell would close."""
+
+    opened_at: datetime
+    quantity: float
+    cost_basis: float = Field(..., description="Including buy fees")
+    proceeds: float = Field(..., description="At the current price, before fees")
+    gain: float
+    term: HoldingTerm
+
+
+class RebalanceTrade(BaseModel):
+    """Whole shares to buy or sell to bring a position to its target weight."""
+
+    symbol: str
+    side: TradeSide
+    quantity: int
+    price: float = Field(..., description="Current value per share")
+    value: float = Field(..., description="quantity * price")
+    current_weight: float
+    target_weight: float
+    lots: List[RealizedLot] = Field(
+        default_factory=list, description="Lots a sell closes, oldest first (FIFO)"
+    )
+    unmatched_quantity: float = Field(
+        0.0,
+        description="Shares sold beyond the ledger's open lots, costed at the "
+        "position's average price",
+    )
+    realized_gain: Optional[float] = Field(None, description="Sells only")
+
+
+class RebalanceTaxImpact(BaseModel):
+    """Gains the suggested sells would realize, net of losses, by term."""
+
+    short_term_gain: float
+    long_term_gain: float
+    unclassified_gain: float = Field(
+        ..., description="On unmatched shares, whose holding period isn't known"
+    )
+    total_gain: float
+
+
+class RebalancePlan(BaseModel):
+    portfolio_id: int
+    as_of: date = Field(..., description="Sale date the terms are classified at")
+    total_value: float
+    trades: List[RebalanceTrade]
+    tax: RebalanceTaxImpact
+
+
+class PositionHoldingPeriod(BaseModel):
+    """How long the shares of a position still open have been held."""
+
+    symbol: str
+    quantity: float = Field(..., description="Shares in the ledger's open lots")
+    value: float = Field(..., description="Of those shares, at the current price")
+    average_days: float = Field(..., description="Weighted by the shares per lot")
+    oldest_lot_at: datetime
+
+
+class HoldingPeriod(BaseModel):
+    portfolio_id: int
+    as_of: datetime
+    average_days: Optional[float] = Field(
+        None, description="Weighted by each lot's value; None with no open lots"
+    )
+    positions: List[PositionHoldingPeriod] = Field(
+        ..., description="Longest held first"
+    )
+
+
 class PortfolioBase(BaseModel):
     total_value: float = Field(..., description="Total portfolio value")
     total_gain: float = Field(..., description="Total gain/loss")
@@ -68,61 +548,580 @@ class Portfolio(PortfolioBase):
     user_id: int
     created_at: datetime
     updated_at: datetime
+    unrealized_gain: float = Field(0.0, description="Gain on the shares held")
+    realized_gain: float = Field(
+        0.0, description="Gain on shares sold through recorded transactions"
+    )
     positions: List[Position] = []
 
     class Config:
         from_attributes = True
 
 
+class PositionCheckStatus(str, Enum):
+    MATCHES = "matches"
+    DIFFERS = "differs"
+    UNTRACKED = "untracked"  # Not accounted for by the ledger; left alone
+
+
+class PositionCheck(BaseModel):
+    """A position next to what the ledger adds up to in its symbol."""
+
+    symbol: str
+    quantity: int = Field(..., description="0 without a position")
+    average_price: Optional[float] = Field(None, description="None without one")
+    ledger_quantity: Optional[int] = Field(None, description="None if untracked")
+    ledger_average_price: Optional[float] = None
+    status: PositionCheckStatus
+
+
+class PositionRebuild(BaseModel):
+    portfolio_id: int
+    applied: bool = Field(..., description="False for a dry run")
+    consistent: bool = Field(
+        ..., description="Every tracked position and the realized gain match"
+    )
+    realized_gain: float = Field(..., description="The portfolio's, before")
+    ledger_realized_gain: float = Field(
+        ..., description="Over the symbols the ledger accounts for"
+    )
+    positions: List[PositionCheck] = Field(
+        ..., description="By symbol, as they were before any rebuild"
+    )
+
+
 class PortfolioCreate(BaseModel):
     user_id: int
 
 
-# User Models
-class UserBase(BaseModel):
-    username: str = Field(..., min_length=3, max_length=50)
-    email: str = Field(..., description="User email address")
+class AttentionReason(BaseModel):
+    code: str = Field(..., description="large_move, drift or alert_triggered")
+    message: str
 
 
-class User(UserBase):
+class AttentionPosition(BaseModel):
+    position: Position
+    reasons: List[AttentionReason]
+
+
+class PositionYield(BaseModel):
+    stock_symbol: str
+    current_value: float
+    dividend_yield: Optional[float] = Field(
+        None, description="None if the fundamentals have no yield"
+    )
+    weight: float = Field(..., description="Share of portfolio value (0-1)")
+
+
+class PortfolioYield(BaseModel):
+    portfolio_id: int
+    total_value: float
+    weighted_yield: float = Field(
+        ..., description="Value-weighted dividend yield (0.01 = 1%)"
+    )
+    positions: List[PositionYield]
+    missing_yield: List[str] = Field(
+        ..., description="Symbols without a yield, counted as 0"
+    )
+
+
+class PortfolioPE(BaseModel):
+    portfolio_id: int
+    weighted_pe: Optional[float] = Field(
+        ..., description="Value-weighted PE of the included holdings; None if none"
+    )
+    included_value: float = Field(..., description="Value of holdings with a PE")
+    excluded_count: int = Field(..., description="Holdings with a negative/no PE")
+    excluded: List[str] = Field(..., description="Symbols left out")
+
+
+class PortfolioConcentration(BaseModel):
+    portfolio_id: int
+    positions: int
+    hhi: Optional[float] = Field(
+        None, description="Sum of squared weights; 1/N when equal-weighted"
+    )
+    normalized_hhi: Optional[float] = Field(
+        None, description="HHI rescaled to 0 (equal weights) .. 1 (one holding)"
+    )
+    effective_positions: Optional[float] = Field(None, description="1 / HHI")
+    threshold: float
+    concentrated: bool = Field(..., description="HHI above the threshold")
+
+
+class CompositionHolding(BaseModel):
+    symbol: str
+    quantity: float = Field(..., description="Shares held at the end of the day")
+    close: Optional[float] = Field(
+        None, description="Latest close on or before the day; None if there's none"
+    )
+    value: Optional[float] = None
+    weight: Optional[float] = Field(None, description="Fraction of the total value")
+
+
+class PortfolioComposition(BaseModel):
+    portfolio_id: int
+    as_of: date
+    holdings: List[CompositionHolding] = Field(
+        default_factory=list, description="Empty before the first transaction"
+    )
+    total_value: float = Field(..., description="Sum over holdings with a close")
+    missing_prices: List[str] = Field(
+        default_factory=list, description="Holdings with no close to value them at"
+    )
+
+
+class CashBalanceUpdate(RequestBody):
+    balance: float = Field(..., ge=0, description="Uninvested cash")
+    as_of: Optional[date] = Field(None, description="Defaults to today")
+
+
+class CashBalance(BaseModel):
+    portfolio_id: int
+    balance: float
+    as_of: date
+
+
+class BetaPoint(BaseModel):
+    date: date  # Last day of the window
+    beta: Optional[float] = Field(None, description="None if the benchmark was flat")
+
+
+class RollingBeta(BaseModel):
+    """Beta against the benchmark over each `window` daily returns, sliding by a day."""
+
+    portfolio_id: int
+    benchmark: str
+    window: int
+    points: List[BetaPoint]
+
+
+class CashDrag(BaseModel):
+    """
+    What uninvested cash cost relative to holding the benchmark instead.
+
+    drag is the benchmark-invested value of the cash (deposits and
+    withdrawals invested on the day) minus the cash with its interest at
+    RISK_FREE_RATE, at the end of the period.
+    """
+
+    portfolio_id: int
+    benchmark: str
+    start: date
+    end: date
+    average_cash_balance: float
+    benchmark_return: float
+    cash_return: float
+    drag: float
+
+
+class SnapshotHolding(BaseModel):
+    stock_symbol: str
+    quantity: int
+    value: float
+
+
+class PortfolioSnapshot(BaseModel):
+    """A portfolio's value and holdings as of the end of a day."""
+
+    portfolio_id: int
+    date: date
+    total_value: float
+    total_gain: float = 0.0
+    holdings: List[SnapshotHolding] = Field(default_factory=list)
+    taken_at: datetime = Field(..., description="When it was last (re)taken")
+
+
+class Granularity(str, Enum):
+    DAILY = "daily"
+    WEEKLY = "weekly"
+    MONTHLY = "monthly"
+
+
+class PerformancePoint(BaseModel):
+    date: date
+    total_value: float
+    total_gain: float
+
+
+class PerformanceHistory(BaseModel):
+    """A portfolio's snapshot values over a period, for charting."""
+
+    portfolio_id: int
+    start: date
+    end: date
+    granularity: Granularity
+    points: List[PerformancePoint] = Field(
+        default_factory=list,
+        description="Days without a snapshot are left out; weekly and monthly "
+        "points are the last snapshot of each week or month",
+    )
+    period_return: Optional[float] = Field(
+        None, description="Last point over first, minus 1; None under 2 points"
+    )
+    max_drawdown: Optional[float] = Field(
+        None, description="Largest peak-to-trough fall between points, e.g. 0.2"
+    )
+
+
+class PeriodMetrics(BaseModel):
+    start: date
+    end: date
+    observations: int = Field(..., description="Days valued in the period")
+    total_return: float
+    volatility: float = Field(..., description="Annualized")
+    sharpe: Optional[float] = Field(None, description="None without volatility")
+    cagr: float = Field(..., description="Compound annual growth rate")
+    max_drawdown: float = Field(..., description="Largest peak-to-trough fall")
+    ulcer_index: float = Field(
+        ..., description="RMS of percent drawdowns from the running peak"
+    )
+    calmar: float = Field(
+        ..., description="CAGR over max drawdown; 0 when there's no drawdown"
+    )
+    beta: Optional[float] = Field(
+        None, description="Against the benchmark; None without enough benchmark prices"
+    )
+    treynor: Optional[float] = Field(
+        None, description="CAGR over the risk-free rate per unit of beta"
+    )
+
+
+class PeriodDeltas(BaseModel):
+    total_return: float
+    volatility: float
+    sharpe: Optional[float] = None
+
+
+class PeriodComparison(BaseModel):
+    portfolio_id: int
+    a: PeriodMetrics
+    b: PeriodMetrics
+    deltas: PeriodDeltas = Field(..., description="Period a minus period b")
+
+
+# Export Models
+class ExportFormat(str, Enum):
+    CSV = "csv"
+    XLSX = "xlsx"
+
+
+class ImportMode(str, Enum):
+    MERGE = "merge"
+    REPLACE = "replace"
+
+
+class ImportRowError(BaseModel):
+    row: int = Field(..., description="Line in the file; the header is row 1")
+    reason: str
+
+
+class PositionImportResult(BaseModel):
+    portfolio_id: int
+    mode: ImportMode
+    rows: int = Field(..., description="Data rows read from the file")
+    created: int
+    updated: int = Field(..., description="Held symbols given a new quantity and price")
+    removed: int = Field(..., description="Positions deleted first in replace mode")
+
+
+# Analytics Models
+class ContributionInterval(str, Enum):
+    WEEKLY = "weekly"
+    BIWEEKLY = "biweekly"
+    MONTHLY = "monthly"
+    QUARTERLY = "quarterly"
+
+
+class CostModel(RequestBody):
+    """Trading costs charged on each fill; every component defaults to 0."""
+
+    commission_per_share: float = Field(0.0, ge=0, description="Flat, per share")
+    fee_bps: float = Field(0.0, ge=0, description="Basis points of notional")
+    slippage_bps: float = Field(
+        0.0, ge=0, description="Expected price impact, in basis points of notional"
+    )
+
+
+class CostEstimateRequest(RequestBody):
+    price: float = Field(..., gt=0, description="Quoted price per share")
+    quantity: float = Field(..., gt=0)
+    costs: CostModel = Field(default_factory=CostModel)
+
+
+class TradeCost(BaseModel):
+    notional: float = Field(..., description="Price times quantity, before costs")
+    commission: float
+    fee: float
+    slippage: float
+    total: float
+    cost_bps: float = Field(..., description="Total in basis points of notional")
+
+
+class DCARequest(RequestBody):
+    """
+    Dollar-cost averaging backfill request.
+
+    Exactly one of symbol or weights must be given.
+    """
+
+    symbol: Optional[str] = Field(None, description="Single symbol to buy")
+    weights: Optional[Dict[str, float]] = Field(
+        None, description="Allocation by symbol, summing to 1"
+    )
+    contribution: float = Field(..., gt=0, description="Amount invested per interval")
+    interval: ContributionInterval = ContributionInterval.MONTHLY
+    start_date: date
+    end_date: Optional[date] = Field(None, description="Defaults to latest price")
+    lump_sum: float = Field(0.0, ge=0, description="Extra amount invested at start")
+    costs: CostModel = Field(
+        default_factory=CostModel, description="Charged on every purchase"
+    )
+
+    @model_validator(mode="after")
+    def symbol_or_weights(self) -> "DCARequest":
+        """Ensure one allocation is given and the weights are a full allocation."""
+        if (self.symbol is None) == (self.weights is None):
+            raise ValueError("Provide exactly one of symbol or weights")
+        if self.weights is not None:
+            if not self.weights or any(w <= 0 for w in self.weights.values()):
+                raise ValueError("Weights must be positive")
+            if abs(sum(self.weights.values()) - 1) > 1e-6:
+                raise ValueError("Weights must sum to 1")
+        if self.end_date is not None and self.end_date < self.start_date:
+            raise ValueError("end_date must not be before start_date")
+        return self
+
+    def allocation(self) -> Dict[str, float]:
+        if self.symbol is not None:
+            return {normalize_symbol(self.symbol): 1.0}
+        return {normalize_symbol(symbol): w for symbol, w in self.weights.items()}
+
+
+class EquityPoint(BaseModel):
+    date: date
+    contributed: float
+    value: float
+
+
+class LumpSumComparison(BaseModel):
+    invested: float = Field(..., description="Total DCA contributions, at start")
+    ending_value: float
+    total_return: float
+
+
+class DCAResult(BaseModel):
+    equity_curve: List[EquityPoint]
+    total_contributed: float
+    ending_value: float
+    shares: Dict[str, float] = Field(..., description="Shares accumulated by symbol")
+    total_costs: float = Field(..., description="Commission, fees and slippage")
+    total_return: float
+    money_weighted_return: float = Field(..., description="Annualized (XIRR)")
+    lump_sum: LumpSumComparison
+
+
+class RiskParityRequest(RequestBody):
+    """
+    Risk-parity allocation request.
+
+    The lookback is either a range token or start/end dates, not both;
+    with neither it defaults to 1Y.
+    """
+
+    symbols: List[str] = Field(..., min_length=2)
+    range_token: Optional[str] = Field(
+        None, alias="range", description="1M, 3M, 6M, 1Y, ... or MAX"
+    )
+    start_date: Optional[date] = None
+    end_date: Optional[date] = Field(None, description="Defaults to today")
+
+    class Config:
+        populate_by_name = True
+
+
+class RiskParityWeight(BaseModel):
+    symbol: str
+    weight: float
+    volatility: float = Field(..., description="Annualized, from daily returns")
+    risk_contribution: float = Field(..., description="Share of portfolio risk")
+
+
+class RiskParityResult(BaseModel):
+    weights: List[RiskParityWeight]
+    observations: int = Field(..., description="Daily returns used")
+    iterations: int
+    converged: bool
+
+
+class CointegrationRequest(RequestBody):
+    """
+    Engle-Granger test of whether a pair's spread is mean-reverting.
+
+    symbol_a is regressed on symbol_b. The lookback is either a range token
+    or start/end dates, not both; with neither it defaults to 1Y.
+    """
+
+    symbol_a: str = Field(..., description="Dependent leg, e.g. KO")
+    symbol_b: str = Field(..., description="Independent leg, e.g. PEP")
+    range_token: Optional[str] = Field(
+        None, alias="range", description="1M, 3M, 6M, 1Y, ... or MAX"
+    )
+    start_date: Optional[date] = None
+    end_date: Optional[date] = Field(None, description="Defaults to today")
+    significance: float = Field(0.05, description="0.01, 0.05 or 0.1")
+    lags: int = Field(
+        1, ge=0, le=10, description="Lagged differences in the ADF regression"
+    )
+
+    class Config:
+        populate_by_name = True
+
+    @field_validator("symbol_a", "symbol_b")
+    def clean_symbol(cls, symbol: str) -> str:
+        return normalize_symbol(symbol)
+
+    @field_validator("significance")
+    def known_significance(cls, significance: float) -> float:
+        if significance not in SIGNIFICANCE_LEVELS:
+            levels = ", ".join(f"{level:g}" for level in SIGNIFICANCE_LEVELS)
+            raise ValueError(f"Significance must be one of {levels}")
+        return significance
+
+
+class CointegrationResult(BaseModel):
+    symbol_a: str
+    symbol_b: str
+    start: date
+    end: date
+    observations: int = Field(..., description="Days both symbols have a close")
+    hedge_ratio: float = Field(
+        ..., description="Shares of symbol_b per share of symbol_a in the spread"
+    )
+    intercept: float
+    adf_statistic: float = Field(
+        ..., description="Engle-Granger statistic; more negative is stronger"
+    )
+    critical_value: float = Field(..., description="At the requested significance")
+    significance: float
+    lags: int
+    cointegrated: bool = Field(
+        ..., description="Whether adf_statistic is below critical_value"
+    )
+
+
+# Alert Models
+class AlertCondition(str, Enum):
+    ABOVE = "above"
+    BELOW = "below"
+    PCT_CHANGE = "pct_change"  # Moves at least threshold % from the previous close
+
+
+class PriceAlertCreate(RequestBody):
+    symbol: str = Field(..., description="Stock symbol")
+    condition: AlertCondition
+    threshold: float = Field(
+        ...,
+        gt=0,
+        description="Price that triggers the alert; for pct_change, the percent "
+        "move from the previous close, either way",
+    )
+
+
+class PriceAlert(PriceAlertCreate):
     id: int
+    user_id: int
+    active: bool = True
+    triggered_at: Optional[datetime] = None
+    triggered_price: Optional[float] = None
+    acknowledged_at: Optional[datetime] = Field(
+        None, description="When the latest trigger was handled"
+    )
     created_at: datetime
-    updated_at: datetime
 
     class Config:
         from_attributes = True
+        extra = "ignore"  # A response, not a body
 
 
-class UserCreate(UserBase):
-    password: str = Field(..., min_length=8)
+class AlertEvent(BaseModel):
+    """One firing of an alert, kept after the alert is re-armed or deleted."""
 
+    id: int
+    alert_id: int
+    user_id: int
+    symbol: str
+    condition: AlertCondition
+    threshold: float
+    price: float = Field(..., description="Price that triggered the alert")
+    previous_close: Optional[float] = Field(
+        None, description="Close the move was measured from, for pct_change"
+    )
+    triggered_at: datetime
 
-class UserUpdate(BaseModel):
-    username: Optional[str] = Field(None, min_length=3, max_length=50)
-    email: Optional[str] = None
 
+# Watchlist Models
+class WatchlistCreate(RequestBody):
+    name: str = Field(..., min_length=1, max_length=100)
+    symbols: List[str] = Field(default_factory=list, description="Stock symbols")
 
-# Market Data Models
-class MarketDataBase(BaseModel):
-    symbol: str = Field(..., description="Stock symbol")
-    date: datetime = Field(..., description="Trading date")
-    open_price: float = Field(..., description="Opening price")
-    high_price: float = Field(..., description="Highest price")
-    low_price: float = Field(..., description="Lowest price")
-    close_price: float = Field(..., description="Closing price")
-    volume: int = Field(..., description="Trading volume")
+    @field_validator("name")
+    def strip_name(cls, name: str) -> str:
+        name = name.strip()
+        if not name:
+            raise ValueError("Name must not be blank")
+        return name
 
 
-class MarketData(MarketDataBase):
+class WatchlistItem(BaseModel):
+    symbol: str
+    added_at: datetime
+    price: Optional[float] = Field(None, description="None if the stock isn't stored")
+    change_percent: Optional[float] = None
+
+
+class WatchlistOrder(RequestBody):
+    ids: List[int] = Field(
+        ..., description="Every one of the user's watchlist ids, in display order"
+    )
+
+
+class Watchlist(WatchlistCreate):
     id: int
+    user_id: int
+    sort_order: int = Field(0, description="Display position, from 0")
     created_at: datetime
+    items: List[WatchlistItem] = Field(
+        default_factory=list, description="The symbols with their current prices"
+    )
 
     class Config:
         from_attributes = True
+        extra = "ignore"  # A response, not a body
 
 
-class MarketDataCreate(MarketDataBase):
-    pass
+# User Models
+class UserBase(BaseModel):
+    username: str = Field(..., min_length=3, max_length=50)
+    email: str = Field(..., description="User email address")
+
+
+class User(UserBase):
+    id: int
+    created_at: datetime
+    updated_at: datetime
+
+    class Config:
+        from_attributes = True
+
+
+class UserCreate(UserBase):
+    password: str = Field(..., min_length=8)
+
+
+class UserUpdate(BaseModel):
+    username: Optional[str] = Field(None, min_length=3, max_length=50)
+    email: Optional[str] = None
 
 
 # Response Models
@@ -132,7 +1131,29 @@ class HealthResponse(BaseModel):
     version: str = "1.0.0"
 
 
+class ReadinessResponse(BaseModel):
+    status: str = Field(..., description="ready, or unavailable with a 503")
+    checks: Dict[str, str] = Field(
+        default_factory=dict, description="Each dependency checked: ok or the failure"
+    )
+
+
+class ErrorCatalogEntry(BaseModel):
+    code: str
+    status: int = Field(..., description="HTTP status returned with this code")
+    description: str
+
+
 class ErrorResponse(BaseModel):
     error: bool = True
+    code: str = Field(..., description="Stable code from GET /api/v1/errors")
     message: str
     detail: Optional[str] = None
+    context: Optional[Dict[str, Any]] = Field(
+        None,
+        description="Machine-readable specifics, e.g. the current price, or"
+        " fields: [{field, message}] for invalid request fields",
+    )
+    request_id: Optional[str] = Field(
+        None, description="Matches X-Request-ID and the request's log lines"
+    )
@@ -0,0 +1,121 @@
+"""
+Storage interfaces for the services.
+
+Services read and write rows through these protocols instead of owning
+their storage, so they can be tested against in-memory repositories
+(app.repositories.memory) and later backed by the database. Rows are
+plain dicts with the fields of the matching schema; a repository hands out
+copies, so changes only stick once they're upserted.
+"""
+
+from datetime import date
+from typing import Any, Dict, List, Optional, Protocol
+
+Row = Dict[str, Any]
+
+
+class StockRepository(Protocol):
+    """Stock catalog rows, keyed by symbol."""
+
+    def get(self, symbol: str) -> Optional[Row]:
+        ...
+
+    def list(self) -> List[Row]:
+        """Every stock, in the order they were first stored."""
+        ...
+
+    def upsert(self, row: Row) -> Row:
+        """
+        Insert or replace the row for row["symbol"], assigning a new one an id;
+        in SQL, INSERT ... ON CONFLICT (symbol) DO UPDATE.
+        """
+        ...
+
+    def delete(self, symbol: str) -> bool:
+        """Remove a stock; False if there was none."""
+        ...
+
+    def clear(self) -> None:
+        ...
+
+
+class MarketDataRepository(Protocol):
+    """Daily closes and OHLC bars per symbol."""
+
+    def get_closes(self, symbol: str) -> Dict[date, float]:
+        ...
+
+    def upsert_closes(self, symbol: str, closes: Dict[date, float]) -> None:
+        """Store closes, replacing the days already stored."""
+        ...
+
+    def get_bars(self, symbol: str) -> Dict[date, Row]:
+        ...
+
+    def upsert_bar(self, row: Row) -> Row:
+        """
+        Insert or replace the bar for row["symbol"] on row["date"]'s day,
+        keeping a replaced bar's id and created_at.
+        """
+        ...
+
+    def delete(self, symbol: str) -> bool:
+        """Remove a symbol's closes and bars; False if there were none."""
+        ...
+
+    def clear(self) -> None:
+        ...
+
+
+class PortfolioRepository(Protocol):
+    """Portfolio rows and their position rows, keyed by id."""
+
+    def get(self, portfolio_id: int) -> Optional[Row]:
+        ...
+
+    def list(self, user_id: Optional[int] = None) -> List[Row]:
+        """Portfolios by id, optionally only one user's."""
+        ...
+
+    def upsert(self, row: Row) -> Row:
+        """Insert or replace a portfolio, assigning one without an id the next."""
+        ...
+
+    def delete(self, portfolio_id: int) -> bool:
+        """Remove a portfolio and its positions; False if there was none."""
+        ...
+
+    def get_position(self, position_id: int) -> Optional[Row]:
+        ...
+
+    def list_positions(self, portfolio_id: Optional[int] = None) -> List[Row]:
+        """Positions by id, optionally only one portfolio's."""
+        ...
+
+    def upsert_position(self, row: Row) -> Row:
+        """Insert or replace a position, assigning one without an id the next."""
+        ...
+
+    def delete_position(self, position_id: int) -> bool:
+        ...
+
+    def clear(self) -> None:
+        ...
+
+
+class CorporateActionRepository(Protocol):
+    """Splits and dividends, one row per symbol, type and ex-date."""
+
+    def add(self, row: Row) -> Row:
+        """Insert an action, assigning it the next id."""
+        ...
+
+    def list(self, symbols: Optional[List[str]], start: date, end: date) -> List[Row]:
+        """
+        Actions with an ex-date between start and end (inclusive), by ex-date,
+        optionally only for some symbols.
+        """
+        ...
+
+    def clear(self) -> None:
+        ...
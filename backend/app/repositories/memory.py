@@ -0,0 +1,150 @@
+"""
+In-memory repositories, used until the services are backed by the database
+and by tests.
+"""
+
+from datetime import date, datetime
+from typing import Dict, List, Optional
+
+from app.repositories.base import Row
+
+
+class InMemoryStockRepository:
+    def __init__(self):
+        self.clear()
+
+    def clear(self) -> None:
+        self._rows: Dict[str, Row] = {}  # symbol -> stock_data
+        self._next_id = 1
+
+    def get(self, symbol: str) -> Optional[Row]:
+        row = self._rows.get(symbol)
+        return dict(row) if row else None
+
+    def list(self) -> List[Row]:
+        return [dict(row) for row in self._rows.values()]
+
+    def upsert(self, row: Row) -> Row:
+        row = dict(row)
+        if "id" not in row:
+            existing = self._rows.get(row["symbol"])
+            row["id"] = existing["id"] if existing else self._next_id
+            self._next_id = max(self._next_id, row["id"] + 1)
+        self._rows[row["symbol"]] = row
+        return dict(row)
+
+    def delete(self, symbol: str) -> bool:
+        return self._rows.pop(symbol, None) is not None
+
+
+class InMemoryMarketDataRepository:
+    def __init__(self):
+        self.clear()
+
+    def clear(self) -> None:
+        self._closes: Dict[str, Dict[date, float]] = {}  # symbol -> day -> close
+        self._bars: Dict[str, Dict[date, Row]] = {}  # symbol -> day -> bar
+        self._next_bar_id = 1
+
+    def get_closes(self, symbol: str) -> Dict[date, float]:
+        return dict(self._closes.get(symbol, {}))
+
+    def upsert_closes(self, symbol: str, closes: Dict[date, float]) -> None:
+        self._closes.setdefault(symbol, {}).update(closes)
+
+    def get_bars(self, symbol: str) -> Dict[date, Row]:
+        return {day: dict(bar) for day, bar in self._bars.get(symbol, {}).items()}
+
+    def upsert_bar(self, row: Row) -> Row:
+        bars = self._bars.setdefault(row["symbol"], {})
+        day = row["date"].date()
+        existing = bars.get(day)
+        if existing:
+            keys = {"id": existing["id"], "created_at": existing["created_at"]}
+        else:
+            keys = {"id": self._next_bar_id, "created_at": datetime.utcnow()}
+            self._next_bar_id += 1
+        bars[day] = {**row, **keys}
+        return dict(bars[day])
+
+    def delete(self, symbol: str) -> bool:
+        had_closes = self._closes.pop(symbol, None) is not None
+        had_bars = self._bars.pop(symbol, None) is not None
+        return had_closes or had_bars
+
+
+class InMemoryPortfolioRepository:
+    def __init__(self):
+        self.clear()
+
+    def clear(self) -> None:
+        self._portfolios: Dict[int, Row] = {}  # id -> portfolio_data
+        self._positions: Dict[int, Row] = {}  # id -> position_data
+        self._next_position_id = 1
+
+    def get(self, portfolio_id: int) -> Optional[Row]:
+        row = self._portfolios.get(portfolio_id)
+        return dict(row) if row else None
+
+    def list(self, user_id: Optional[int] = None) -> List[Row]:
+        return [
+            dict(row)
+            for _, row in sorted(self._portfolios.items())
+            if user_id is None or row["user_id"] == user_id
+        ]
+
+    def upsert(self, row: Row) -> Row:
+        row = {"id": max(self._portfolios, default=0) + 1, **row}
+        self._portfolios[row["id"]] = row
+        return dict(row)
+
+    def delete(self, portfolio_id: int) -> bool:
+        if self._portfolios.pop(portfolio_id, None) is None:
+            return False
+        for position in self.list_positions(portfolio_id):
+            del self._positions[position["id"]]
+        return True
+
+    def get_position(self, position_id: int) -> Optional[Row]:
+        row = self._positions.get(position_id)
+        return dict(row) if row else None
+
+    def list_positions(self, portfolio_id: Optional[int] = None) -> List[Row]:
+        return [
+            dict(row)
+            for _, row in sorted(self._positions.items())
+            if portfolio_id is None or row["portfolio_id"] == portfolio_id
+        ]
+
+    def upsert_position(self, row: Row) -> Row:
+        # Ids aren't reused after a delete, like a database sequence
+        row = {"id": self._next_position_id, **row}
+        self._next_position_id = max(self._next_position_id, row["id"] + 1)
+        self._positions[row["id"]] = row
+        return dict(row)
+
+    def delete_position(self, position_id: int) -> bool:
+        return self._positions.pop(position_id, None) is not None
+
+
+class InMemoryCorporateActionRepository:
+    def __init__(self):
+        self.clear()
+
+    def clear(self) -> None:
+        self._actions: Dict[int, Row] = {}  # id -> action_data
+
+    def add(self, row: Row) -> Row:
+        row = {**row, "id": max(self._actions, default=0) + 1}
+        self._actions[row["id"]] = row
+        return dict(row)
+
+    def list(self, symbols: Optional[List[str]], start: date, end: date) -> List[Row]:
+        rows = [
+            row
+            for row in self._actions.values()
+            if start <= row["ex_date"] <= end
+            and (symbols is None or row["symbol"] in symbols)
+        ]
+        rows.sort(key=lambda r: (r["ex_date"], r["id"]))
+        return [dict(row) for row in rows]
@@ -0,0 +1,163 @@
+"""
+Server entry point for Quant-Dash: `python -m app.serve`.
+
+This module handles:
+1. Serving plain HTTP/1.1 through uvicorn, the default
+2. Serving HTTPS through Hypercorn when TLS_CERT_FILE/TLS_KEY_FILE are set,
+   negotiating HTTP/2 over ALPN and falling back to HTTP/1.1
+3. Serving cleartext HTTP/2 (h2c) through Hypercorn when HTTP2_CLEARTEXT is
+   set, for clients using prior knowledge or an h2c Upgrade, e.g. behind a
+   TLS-terminating proxy
+4. Server timeouts and graceful shutdown: on SIGINT/SIGTERM the listener
+   closes, in-flight requests get SHUTDOWN_GRACE_SECONDS to finish, and
+   then the app's shutdown handlers run. If requests had to be cut off, the
+   process exits non-zero.
+
+uvicorn doesn't speak HTTP/2, so Hypercorn is only used when it's needed.
+"""
+
+import asyncio
+import logging
+import signal
+import sys
+from typing import Optional
+
+from app.core import errors
+from app.core.config import settings
+
+logger = logging.getLogger(__name__)
+
+
+def http2_enabled() -> bool:
+    return bool(settings.TLS_CERT_FILE) or settings.HTTP2_CLEARTEXT
+
+
+def hypercorn_config(host: Optional[str] = None, port: Optional[int] = None):
+    """Hypercorn settings for TLS with h2 over ALPN, or for h2c."""
+    from hypercorn.config import Config
+
+    config = Config()
+    config.bind = [f"{host or settings.BIND_HOST}:{port or settings.BIND_PORT}"]
+    config.accesslog = "-"
+    if settings.TLS_CERT_FILE:
+        config.certfile = settings.TLS_CERT_FILE
+        config.keyfile = settings.TLS_KEY_FILE
+        config.alpn_protocols = ["h2", "http/1.1"]
+    # Without TLS, Hypercorn accepts h2c (prior knowledge and Upgrade)
+    # next to HTTP/1.1
+    config.read_timeout = settings.SERVER_READ_TIMEOUT_SECONDS or None
+    config.keep_alive_timeout = settings.SERVER_IDLE_TIMEOUT_SECONDS
+    config.graceful_timeout = settings.SHUTDOWN_GRACE_SECONDS
+    return config
+
+
+def with_write_timeout(app, seconds: float):
+    """
+    Wrap an ASGI app so HTTP requests are cut off after `seconds` (0 = never).
+
+    A request still running then is cancelled. If nothing was sent yet the
+    client gets a 503 http.timeout; otherwise the connection is dropped
+    mid-response. WebSockets and lifespan events aren't limited.
+    """
+    if not seconds:
+        return app
+
+    async def timed_app(scope, receive, send):
+        if scope["type"] != "http":
+            return await app(scope, receive, send)
+        started = False
+
+        async def tracked_send(message):
+            nonlocal started
+            started = started or message["type"] == "http.response.start"
+            await send(message)
+
+        try:
+            await asyncio.wait_for(app(scope, receive, tracked_send), seconds)
+        except asyncio.TimeoutError:
+            logger.warning(
+                "%s %s exceeded the %ss write timeout",
+                scope["method"],
+                scope["path"],
+                seconds,
+            )
+            if started:
+                raise
+            from starlette.responses import JSONResponse
+
+            error = errors.request_timeout()
+            response = JSONResponse(errors.error_body(error), error.status_code)
+            await response(scope, receive, send)
+
+    return timed_app
+
+
+class InFlightRequests:
+    """
+    ASGI wrapper counting HTTP requests cancelled before they finished.
+
+    The servers only cancel requests once the shutdown grace period runs out,
+    so a non-zero count after serving means writes may have been cut off.
+    """
+
+    def __init__(self, app):
+        self.app = app
+        self.cut_off = 0
+
+    async def __call__(self, scope, receive, send):
+        try:
+            await self.app(scope, receive, send)
+        except asyncio.CancelledError:
+            if scope["type"] == "http":
+                self.cut_off += 1
+            raise
+
+
+async def shutdown_signal() -> None:
+    """Return on the first SIGINT or SIGTERM, when Hypercorn should drain."""
+    received = asyncio.Event()
+    loop = asyncio.get_running_loop()
+    for signum in (signal.SIGINT, signal.SIGTERM):
+        loop.add_signal_handler(signum, received.set)
+    await received.wait()
+    logger.info("Shutting down; draining in-flight requests")
+
+
+def main() -> None:
+    from app.main import app
+
+    served = InFlightRequests(
+        with_write_timeout(app, settings.SERVER_WRITE_TIMEOUT_SECONDS)
+    )
+    if http2_enabled():
+        from hypercorn.asyncio import serve
+
+        config = hypercorn_config()
+        protocols = (
+            "https (h2, http/1.1)" if settings.TLS_CERT_FILE else "h2c, http/1.1"
+        )
+        logger.info("Serving %s on %s", protocols, config.bind[0])
+        asyncio.run(serve(served, config, shutdown_trigger=shutdown_signal))
+    else:
+        import uvicorn
+
+        # uvicorn handles SIGINT/SIGTERM itself, draining the same way
+        uvicorn.run(
+            served,
+            host=settings.BIND_HOST,
+            port=settings.BIND_PORT,
+            timeout_keep_alive=settings.SERVER_IDLE_TIMEOUT_SECONDS,
+            timeout_graceful_shutdown=settings.SHUTDOWN_GRACE_SECONDS,
+        )
+
+    if served.cut_off:
+        logger.error(
+            "Shutdown grace period of %ss ran out; %d request(s) were cut off",
+            settings.SHUTDOWN_GRACE_SECONDS,
+            served.cut_off,
+        )
+        sys.exit(1)
+
+
+if __name__ == "__main__":
+    main()
@@ -0,0 +1,245 @@
+"""
+Price alert service layer for Quant-Dash.
+
+This module handles:
+1. Alert storage per user
+2. Evaluating all active alerts against one shared map of latest prices
+3. Recording an event each time an alert fires, for clients to poll
+4. Looking up triggered alerts
+5. Acknowledging triggered alerts once they're handled
+
+pct_change alerts compare the latest price with the previous day's close,
+not the previous tick, so a steady drift still fires once it adds up.
+
+For development/testing, this uses an in-memory store. In production, this
+would interact with a real database ORM.
+"""
+
+from datetime import datetime
+from typing import Any, Callable, Dict, List, Optional
+
+from app.core.querybudget import counts_as_query
+from app.models.schemas import (
+    AlertCondition,
+    AlertEvent,
+    PriceAlert,
+    PriceAlertCreate,
+)
+from app.utils.symbols import check_symbol, normalize_symbol
+
+
+def is_hit(
+    condition: AlertCondition,
+    threshold: float,
+    price: float,
+    previous_close: Optional[float] = None,
+) -> Optional[bool]:
+    """
+    Whether price meets an alert's condition.
+
+    Returns:
+        None if it can't be told: a pct_change alert without a previous close
+    """
+    if condition == AlertCondition.ABOVE:
+        return price >= threshold
+    if condition == AlertCondition.BELOW:
+        return price <= threshold
+    if not previous_close or previous_close <= 0:
+        return None
+    return abs(price / previous_close - 1) * 100 >= threshold
+
+
+class AlertService:
+    """
+    Service for handling price alerts
+    """
+
+    def __init__(self, clock: Callable[[], datetime] = datetime.utcnow):
+        self.clock = clock
+        self.reset()
+
+    def reset(self) -> None:
+        """Drop all alerts and their events."""
+        self._alerts: Dict[int, Dict[str, Any]] = {}  # id -> alert_data
+        self._next_alert_id = 1
+        self._events: List[Dict[str, Any]] = []  # Oldest first
+        self._next_event_id = 1
+
+    async def create_alert(self, user_id: int, alert: PriceAlertCreate) -> PriceAlert:
+        """
+        Create an active alert for a user
+
+        Raises:
+            SymbolRestrictedError: If compliance rules exclude the symbol
+        """
+        record = {
+            **alert.model_dump(),
+            "symbol": check_symbol(alert.symbol),
+            "id": self._next_alert_id,
+            "user_id": user_id,
+            "active": True,
+            "triggered_at": None,
+            "triggered_price": None,
+            "acknowledged_at": None,
+            "created_at": self.clock(),
+        }
+        self._alerts[record["id"]] = record
+        self._next_alert_id += 1
+        return PriceAlert(**record)
+
+    async def delete_alert(self, user_id: int, alert_id: int) -> bool:
+        """
+        Delete one of a user's alerts; its events are kept.
+
+        Returns:
+            False if the user has no alert with that id
+        """
+        record = self._alerts.get(alert_id)
+        if record is None or record["user_id"] != user_id:
+            return False
+        del self._alerts[alert_id]
+        return True
+
+    @counts_as_query
+    async def get_alerts(self, user_id: int) -> List[PriceAlert]:
+        """Get all alerts belonging to a user"""
+        return [
+            PriceAlert(**record)
+            for record in self._alerts.values()
+            if record["user_id"] == user_id
+        ]
+
+    async def get_triggered_alerts(
+        self, user_id: int, symbol: Optional[str] = None
+    ) -> List[PriceAlert]:
+        """Get a user's triggered alerts, optionally for a single symbol"""
+        return [
+            alert
+            for alert in await self.get_alerts(user_id)
+            if alert.triggered_at is not None
+            and (symbol is None or alert.symbol == normalize_symbol(symbol))
+        ]
+
+    async def get_unacknowledged_alerts(self, user_id: int) -> List[PriceAlert]:
+        """A user's triggered alerts not yet acknowledged, latest trigger first."""
+        alerts = [
+            alert
+            for alert in await self.get_triggered_alerts(user_id)
+            if alert.acknowledged_at is None
+        ]
+        return sorted(alerts, key=lambda alert: alert.triggered_at, reverse=True)
+
+    async def acknowledge(self, user_id: int, alert_id: int) -> Optional[PriceAlert]:
+        """
+        Mark a triggered alert as handled and re-arm it.
+
+        The alert drops out of the unacknowledged list and watches its
+        threshold again; the next trigger clears the acknowledgment.
+        Acknowledging an alert that hasn't triggered changes nothing.
+
+        Returns:
+            The alert, or None if the user has no alert with that id
+        """
+        record = self._alerts.get(alert_id)
+        if record is None or record["user_id"] != user_id:
+            return None
+        if record["triggered_at"] is not None and record["acknowledged_at"] is None:
+            record["acknowledged_at"] = self.clock()
+            record["active"] = True
+        return PriceAlert(**record)
+
+    async def active_symbols(
+        self, condition: Optional[AlertCondition] = None
+    ) -> List[str]:
+        """
+        Distinct symbols with at least one active alert, optionally only
+        alerts with condition, sorted.
+        """
+        return sorted(
+            {
+                record["symbol"]
+                for record in self._alerts.values()
+                if record["active"]
+                and (condition is None or record["condition"] == condition)
+            }
+        )
+
+    async def evaluate_prices(
+        self,
+        prices: Dict[str, float],
+        previous_closes: Optional[Dict[str, float]] = None,
+    ) -> List[PriceAlert]:
+        """
+        Check every active alert against the latest price of its symbol.
+
+        Alerts fire once: a triggered alert is deactivated so it doesn't
+        fire again on the next tick, until it's acknowledged. A new trigger
+        clears any earlier acknowledgment and records an event. Alerts on
+        symbols missing from prices, and pct_change alerts on symbols
+        missing from previous_closes, are left alone.
+
+        Returns:
+            The alerts triggered by these prices
+        """
+        previous_closes = previous_closes or {}
+        triggered = []
+        for record in self._alerts.values():
+            price = prices.get(record["symbol"])
+            if not record["active"] or price is None:
+                continue
+            previous_close = None
+            if record["condition"] == AlertCondition.PCT_CHANGE:
+                previous_close = previous_closes.get(record["symbol"])
+            if is_hit(record["condition"], record["threshold"], price, previous_close):
+                record["active"] = False
+                record["triggered_at"] = self.clock()
+                record["triggered_price"] = price
+                record["acknowledged_at"] = None
+                self._record_event(record, previous_close)
+                triggered.append(PriceAlert(**record))
+        return triggered
+
+    async def evaluate(self, symbol: str, price: float) -> List[PriceAlert]:
+        """Check the active alerts on one symbol against its latest price."""
+        return await self.evaluate_prices({normalize_symbol(symbol): price})
+
+    def _record_event(
+        self, record: Dict[str, Any], previous_close: Optional[float]
+    ) -> None:
+        self._events.append(
+            {
+                "id": self._next_event_id,
+                "alert_id": record["id"],
+                "user_id": record["user_id"],
+                "symbol": record["symbol"],
+                "condition": record["condition"],
+                "threshold": record["threshold"],
+                "price": record["triggered_price"],
+                "previous_close": previous_close,
+                "triggered_at": record["triggered_at"],
+            }
+        )
+        self._next_event_id += 1
+
+    @counts_as_query
+    async def get_events(
+        self, user_id: int, since: Optional[datetime] = None
+    ) -> List[AlertEvent]:
+        """
+        A user's alert events after since (all of them if None), oldest
+        first, so a client can poll with the time of the last one it saw.
+        """
+        return [
+            AlertEvent(**event)
+            for event in self._events
+            if event["user_id"] == user_id
+            and (since is None or event["triggered_at"] > since)
+        ]
+
+
+# Service instance
+alert_service = AlertService()
+
+
+def get_alert_service() -> AlertService:
+    return alert_service
@@ -0,0 +1,539 @@
+"""
+Portfolio analytics service for Quant-Dash.
+
+This module handles:
+1. Dollar-cost averaging backfills ("what if I had invested $500/month?")
+2. Money-weighted returns (XIRR) for irregular cash flows
+3. Lump-sum comparisons for the same total investment
+4. Risk-parity weights, where every asset contributes equally to risk
+5. Concentration of position weights (Herfindahl-Hirschman Index)
+6. The Calmar ratio of growth to drawdown
+7. Estimated trading costs: per-share commission, fees and slippage
+8. Engle-Granger cointegration of a pair, for pairs trading
+
+Purchases buy fractional shares at the close of the first trading day on or
+after each contribution date, so a holiday on the 1st rolls forward. Costs
+come out of the amount invested, so fewer shares are bought.
+"""
+
+import bisect
+import math
+from datetime import date, timedelta
+from typing import Dict, List, Optional, Sequence, Tuple
+
+from app.core.config import settings
+from app.models.schemas import (
+    CointegrationResult,
+    ContributionInterval,
+    CostModel,
+    DCARequest,
+    DCAResult,
+    EquityPoint,
+    LumpSumComparison,
+    RiskParityResult,
+    RiskParityWeight,
+    TradeCost,
+)
+from app.services.market import MarketService, market_service
+from app.utils.cointegration import CointegrationTest, engle_granger
+from app.utils.returns import TRADING_DAYS_PER_YEAR
+from app.utils.symbols import normalize_symbol
+
+PriceSeries = List[Tuple[date, float]]
+
+BPS = 10_000  # Basis points in 1
+
+_INTERVAL_DAYS = {
+    ContributionInterval.WEEKLY: 7,
+    ContributionInterval.BIWEEKLY: 14,
+}
+
+
+def contribution_dates(
+    start: date,
+    end: date,
+    interval: ContributionInterval = ContributionInterval.MONTHLY,
+) -> List[date]:
+    """
+    The start date, then each contribution date after it up to end.
+
+    Weekly and biweekly contributions fall every 7 or 14 days from start;
+    monthly and quarterly ones on the 1st of every month or every third month.
+    """
+    dates = [start]
+    if interval in _INTERVAL_DAYS:
+        step = timedelta(days=_INTERVAL_DAYS[interval])
+        while dates[-1] + step <= end:
+            dates.append(dates[-1] + step)
+        return dates
+
+    months = 3 if interval == ContributionInterval.QUARTERLY else 1
+    year, month = start.year, start.month
+    while True:
+        month += months
+        year, month = year + (month - 1) // 12, (month - 1) % 12 + 1
+        day = date(year, month, 1)
+        if day > end:
+            return dates
+        dates.append(day)
+
+
+def next_available(prices: PriceSeries, day: date) -> Optional[Tuple[date, float]]:
+    """The first (date, close) on or after day, or None past the data."""
+    index = bisect.bisect_left(prices, (day,))
+    return prices[index] if index < len(prices) else None
+
+
+def last_available(prices: PriceSeries, day: date) -> Optional[Tuple[date, float]]:
+    """The last (date, close) on or before day."""
+    index = bisect.bisect_right(prices, (day, float("inf")))
+    return prices[index - 1] if index else None
+
+
+def xirr(cash_flows: Sequence[Tuple[date, float]]) -> float:
+    """
+    Annualized money-weighted return of dated cash flows.
+
+    Investments are negative, withdrawals/ending value positive. Solved by
+    bisection, which is slower than Newton's method but can't diverge.
+    """
+    first = min(day for day, _ in cash_flows)
+    flows = [((day - first).days / 365.0, amount) for day, amount in cash_flows]
+    if all(years == 0 for years, _ in flows):
+        return 0.0
+
+    def npv(rate: float) -> float:
+        return sum(amount / (1 + rate) ** years for years, amount in flows)
+
+    # Short horizons annualize to huge rates, so widen the bracket as needed
+    low, high = -0.9999, 1.0
+    while npv(low) * npv(high) > 0 and high < 1e9:
+        high *= 10
+    if npv(low) * npv(high) > 0:
+        raise ValueError("Cash flows have no money-weighted return")
+    for _ in range(200):
+        mid = (low + high) / 2
+        if npv(low) * npv(mid) <= 0:
+            high = mid
+        else:
+            low = mid
+    return (low + high) / 2
+
+
+def estimate_cost(price: float, quantity: float, model: CostModel) -> TradeCost:
+    """
+    What trading quantity shares at price costs on top of the notional.
+
+    Commission is charged per share; fees and slippage are basis points of
+    the notional.
+    """
+    notional = price * quantity
+    commission = model.commission_per_share * quantity
+    fee = notional * model.fee_bps / BPS
+    slippage = notional * model.slippage_bps / BPS
+    total = commission + fee + slippage
+    return TradeCost(
+        notional=round(notional, 2),
+        commission=round(commission, 2),
+        fee=round(fee, 2),
+        slippage=round(slippage, 2),
+        total=round(total, 2),
+        cost_bps=round(total / notional * BPS, 4) if notional else 0.0,
+    )
+
+
+def affordable_shares(amount: float, price: float, model: CostModel) -> float:
+    """Shares that amount buys at price once the model's costs are paid."""
+    per_share = price * (1 + (model.fee_bps + model.slippage_bps) / BPS)
+    return amount / (per_share + model.commission_per_share)
+
+
+def simulate_dca(
+    prices: Dict[str, PriceSeries],
+    weights: Dict[str, float],
+    contribution: float,
+    start: date,
+    end: Optional[date] = None,
+    lump_sum: float = 0.0,
+    interval: ContributionInterval = ContributionInterval.MONTHLY,
+    costs: Optional[CostModel] = None,
+) -> DCAResult:
+    """
+    Simulate periodic purchases into a fixed allocation.
+
+    Args:
+        prices: Daily closes per symbol, sorted by date
+        weights: Allocation by symbol, summing to 1
+        contribution: Amount invested on each contribution date
+        start: First contribution date
+        end: Last date to value the holdings on (defaults to the latest close)
+        lump_sum: Extra amount invested alongside the first contribution
+        interval: How often to contribute
+        costs: Trading costs charged on every purchase, none by default
+
+    Raises:
+        ValueError: If a symbol has no prices from the start date on, or no
+            purchase falls before the end date
+    """
+    for symbol in weights:
+        if next_available(prices.get(symbol, []), start) is None:
+            raise ValueError(f"No price history for {symbol} from {start}")
+    if end is None:
+        end = min(prices[symbol][-1][0] for symbol in weights)
+
+    costs = costs or CostModel()
+    shares = {symbol: 0.0 for symbol in weights}
+    contributed = spent_on_costs = 0.0
+    cash_flows: List[Tuple[date, float]] = []
+    curve: List[EquityPoint] = []
+
+    for i, day in enumerate(contribution_dates(start, end, interval)):
+        fills = {symbol: next_available(prices[symbol], day) for symbol in weights}
+        if any(fill is None or fill[0] > end for fill in fills.values()):
+            break  # No trading day left before the end of the data
+
+        amount = contribution + (lump_sum if i == 0 else 0.0)
+        for symbol, weight in weights.items():
+            price = fills[symbol][1]
+            bought = affordable_shares(amount * weight, price, costs)
+            shares[symbol] += bought
+            spent_on_costs += amount * weight - bought * price
+        contributed += amount
+
+        trade_day = min(fill[0] for fill in fills.values())
+        cash_flows.append((trade_day, -amount))
+        value = sum(shares[s] * fills[s][1] for s in weights)
+        curve.append(
+            EquityPoint(
+                date=trade_day, contributed=round(contributed, 2), value=round(value, 2)
+            )
+        )
+
+    if not curve:
+        raise ValueError(f"No trading days between {start} and {end}")
+
+    closes = {symbol: last_available(prices[symbol], end) for symbol in weights}
+    ending_value = sum(shares[s] * closes[s][1] for s in weights)
+    final_day = max(close[0] for close in closes.values())
+    if curve[-1].date != final_day:
+        curve.append(
+            EquityPoint(
+                date=final_day,
+                contributed=round(contributed, 2),
+                value=round(ending_value, 2),
+            )
+        )
+    cash_flows.append((final_day, ending_value))
+
+    # Same total invested all at once at the first fill
+    first_fills = {symbol: next_available(prices[symbol], start) for symbol in weights}
+    lump_value = sum(
+        affordable_shares(contributed * weight, first_fills[s][1], costs)
+        * closes[s][1]
+        for s, weight in weights.items()
+    )
+
+    return DCAResult(
+        equity_curve=curve,
+        total_contributed=round(contributed, 2),
+        ending_value=round(ending_value, 2),
+        shares={symbol: round(n, 6) for symbol, n in shares.items()},
+        total_costs=round(spent_on_costs, 2),
+        total_return=round(ending_value / contributed - 1, 6),
+        money_weighted_return=round(xirr(cash_flows), 6),
+        lump_sum=LumpSumComparison(
+            invested=round(contributed, 2),
+            ending_value=round(lump_value, 2),
+            total_return=round(lump_value / contributed - 1, 6),
+        ),
+    )
+
+
+Matrix = List[List[float]]
+
+
+def aligned_returns(prices: Dict[str, PriceSeries]) -> Dict[str, List[float]]:
+    """Simple daily returns per symbol, over the days every symbol has a close."""
+    days = sorted(set.intersection(*({d for d, _ in s} for s in prices.values())))
+    closes = {symbol: dict(series) for symbol, series in prices.items()}
+    return {
+        symbol: [by_day[b] / by_day[a] - 1 for a, b in zip(days, days[1:])]
+        for symbol, by_day in closes.items()
+    }
+
+
+def covariance_matrix(returns: Sequence[Sequence[float]]) -> Matrix:
+    """Sample covariance of equally long return series."""
+    n = len(returns[0])
+    means = [sum(series) / n for series in returns]
+    return [
+        [
+            sum((x - mean_a) * (y - mean_b) for x, y in zip(a, b)) / (n - 1)
+            for b, mean_b in zip(returns, means)
+        ]
+        for a, mean_a in zip(returns, means)
+    ]
+
+
+def risk_contributions(cov: Matrix, weights: Sequence[float]) -> List[float]:
+    """Each asset's share w_i * (cov w)_i of the portfolio variance."""
+    marginal = [sum(c * w for c, w in zip(row, weights)) for row in cov]
+    return [w * m for w, m in zip(weights, marginal)]
+
+
+def risk_parity_weights(
+    cov: Matrix,
+    max_iterations: int = settings.RISK_PARITY_MAX_ITERATIONS,
+    tolerance: float = 1e-8,
+) -> Tuple[List[float], int, bool]:
+    """
+    Solve for weights whose risk contributions are all equal.
+
+    Starts from inverse-volatility weights and repeatedly scales each weight
+    by sqrt(target / contribution), which shrinks over-contributors and grows
+    under-contributors, until every contribution is within tolerance (as a
+    share of portfolio variance) of the equal target.
+
+    Returns:
+        (weights summing to 1, iterations used, whether it converged)
+
+    Raises:
+        ValueError: If an asset has no variance
+    """
+    if any(cov[i][i] <= 0 for i in range(len(cov))):
+        raise ValueError("Every asset needs non-zero return variance")
+
+    weights = [1 / math.sqrt(cov[i][i]) for i in range(len(cov))]
+    weights = [w / sum(weights) for w in weights]
+    for iteration in range(max_iterations + 1):
+        contributions = risk_contributions(cov, weights)
+        variance = sum(contributions)
+        target = variance / len(weights)
+        if max(abs(c - target) for c in contributions) <= tolerance * variance:
+            return weights, iteration, True
+        if iteration == max_iterations:
+            break
+        weights = [
+            w * math.sqrt(target / max(c, target * 1e-6))
+            for w, c in zip(weights, contributions)
+        ]
+        weights = [w / sum(weights) for w in weights]
+    return weights, max_iterations, False
+
+
+def compute_risk_parity(
+    prices: Dict[str, PriceSeries],
+    max_iterations: int = settings.RISK_PARITY_MAX_ITERATIONS,
+) -> RiskParityResult:
+    """
+    Risk-parity allocation from daily closes.
+
+    Raises:
+        ValueError: With fewer than two overlapping returns or a flat price
+    """
+    returns = aligned_returns(prices)
+    symbols = list(returns)
+    observations = len(returns[symbols[0]])
+    if observations < 2:
+        raise ValueError("Need at least 3 common trading days of prices")
+
+    cov = covariance_matrix([returns[s] for s in symbols])
+    weights, iterations, converged = risk_parity_weights(cov, max_iterations)
+    contributions = risk_contributions(cov, weights)
+    variance = sum(contributions)
+    return RiskParityResult(
+        weights=[
+            RiskParityWeight(
+                symbol=symbol,
+                weight=round(weights[i], 6),
+                volatility=round(math.sqrt(cov[i][i] * TRADING_DAYS_PER_YEAR), 6),
+                risk_contribution=round(contributions[i] / variance, 6),
+            )
+            for i, symbol in enumerate(symbols)
+        ],
+        observations=observations,
+        iterations=iterations,
+        converged=converged,
+    )
+
+
+def compute_cointegration(
+    closes_a: PriceSeries,
+    closes_b: PriceSeries,
+    significance: float = 0.05,
+    lags: int = 1,
+) -> Tuple[int, CointegrationTest]:
+    """
+    Engle-Granger test of a on b over the days both have a close.
+
+    Returns:
+        (aligned closes used, the test)
+
+    Raises:
+        ValueError: If too few days line up, or a series is flat
+    """
+    by_day = dict(closes_b)
+    aligned = [(close, by_day[day]) for day, close in closes_a if day in by_day]
+    test = engle_granger(
+        [a for a, _ in aligned], [b for _, b in aligned], significance, lags
+    )
+    return len(aligned), test
+
+
+def hhi(weights: Sequence[float]) -> float:
+    """
+    Herfindahl-Hirschman Index: the sum of squared weights.
+
+    Weights are fractions summing to 1, so N equal weights give 1/N and a
+    single holding gives 1.
+
+    Raises:
+        ValueError: With no weights
+    """
+    if not weights:
+        raise ValueError("HHI needs at least one weight")
+    return sum(weight * weight for weight in weights)
+
+
+def normalized_hhi(weights: Sequence[float]) -> float:
+    """HHI rescaled from [1/N, 1] to [0, 1]; a single holding is 1."""
+    n = len(weights)
+    if n == 1:
+        return 1.0
+    return (hhi(weights) - 1 / n) / (1 - 1 / n)
+
+
+def calmar_ratio(cagr: float, max_drawdown: float) -> float:
+    """
+    CAGR over the absolute maximum drawdown.
+
+    A series that never fell has no drawdown to measure against, so it's 0
+    rather than infinite.
+    """
+    if not max_drawdown:
+        return 0.0
+    return cagr / abs(max_drawdown)
+
+
+def treynor_ratio(portfolio_return: float, risk_free_rate: float, beta: float) -> float:
+    """
+    Return in excess of the risk-free rate per unit of beta: like Sharpe, but
+    against market risk rather than total volatility.
+
+    Raises:
+        ValueError: If beta is zero
+    """
+    if beta == 0:
+        raise ValueError("The Treynor ratio is undefined for a beta of zero")
+    return (portfolio_return - risk_free_rate) / beta
+
+
+class AnalyticsService:
+    """
+    Service for historical what-if analytics
+    """
+
+    def __init__(self, market: MarketService):
+        self.market = market
+
+    async def simulate_dca(self, request: DCARequest) -> DCAResult:
+        """
+        Backfill a dollar-cost averaging strategy from stored closes.
+
+        Raises:
+            ValueError: If price history is missing for a symbol
+        """
+        weights = request.allocation()
+        prices = {
+            symbol: await self.market.get_closes(
+                symbol, request.start_date, request.end_date
+            )
+            for symbol in weights
+        }
+        return simulate_dca(
+            prices,
+            weights,
+            request.contribution,
+            request.start_date,
+            request.end_date,
+            request.lump_sum,
+            request.interval,
+            request.costs,
+        )
+
+    async def risk_parity(
+        self, symbols: List[str], start: date, end: Optional[date] = None
+    ) -> RiskParityResult:
+        """
+        Risk-parity weights from stored closes between start and end.
+
+        Raises:
+            ValueError: For too few/many symbols or missing price history
+        """
+        symbols = list(dict.fromkeys(normalize_symbol(symbol) for symbol in symbols))
+        if len(symbols) < 2:
+            raise ValueError("Risk parity needs at least 2 distinct symbols")
+        if len(symbols) > settings.RISK_PARITY_MAX_SYMBOLS:
+            raise ValueError(
+                f"At most {settings.RISK_PARITY_MAX_SYMBOLS} symbols are allowed"
+            )
+
+        prices = {}
+        for symbol in symbols:
+            prices[symbol] = await self.market.get_closes(symbol, start, end)
+            if not prices[symbol]:
+                raise ValueError(f"No price history for {symbol} from {start}")
+        return compute_risk_parity(prices)
+
+
+    async def cointegration(
+        self,
+        symbol_a: str,
+        symbol_b: str,
+        start: date,
+        end: Optional[date] = None,
+        significance: float = 0.05,
+        lags: int = 1,
+    ) -> CointegrationResult:
+        """
+        Engle-Granger test of a pair from stored closes between start and end.
+
+        Raises:
+            ValueError: For the same symbol twice, missing price history or
+                too few days on which both symbols have a close
+        """
+        symbol_a, symbol_b = normalize_symbol(symbol_a), normalize_symbol(symbol_b)
+        if symbol_a == symbol_b:
+            raise ValueError("Cointegration needs two different symbols")
+        end = end or date.today()
+
+        closes = {}
+        for symbol in (symbol_a, symbol_b):
+            closes[symbol] = await self.market.get_closes(symbol, start, end)
+            if not closes[symbol]:
+                raise ValueError(f"No price history for {symbol} from {start}")
+        observations, test = compute_cointegration(
+            closes[symbol_a], closes[symbol_b], significance, lags
+        )
+        return CointegrationResult(
+            symbol_a=symbol_a,
+            symbol_b=symbol_b,
+            start=start,
+            end=end,
+            observations=observations,
+            hedge_ratio=round(test.hedge_ratio, 6),
+            intercept=round(test.intercept, 6),
+            adf_statistic=round(test.adf_statistic, 4),
+            critical_value=round(test.critical_value, 4),
+            significance=significance,
+            lags=lags,
+            cointegrated=test.cointegrated,
+        )
+
+
+# Service instance
+analytics_service = AnalyticsService(market_service)
+
+
+def get_analytics_service() -> AnalyticsService:
+    return analytics_service
@@ -0,0 +1,109 @@
+"""
+Position attention service for Quant-Dash.
+
+This module flags positions that need a look from the user:
+1. Large single-day moves in the underlying stock
+2. Drift from the position's target weight
+3. Triggered price alerts on the position's symbol
+
+It only aggregates the portfolio, market and alert services so the
+dashboard widget needs a single call.
+"""
+
+from typing import List, Optional
+
+from app.core.config import settings
+from app.models.schemas import AttentionPosition, AttentionReason
+from app.services.alerts import AlertService, alert_service
+from app.services.market import MarketService, market_service
+from app.services.portfolio import PortfolioService, portfolio_service
+
+
+class AttentionService:
+    """
+    Service for finding positions that meet any attention condition
+    """
+
+    def __init__(
+        self,
+        portfolios: PortfolioService,
+        market: MarketService,
+        alerts: AlertService,
+        large_move_percent: float = settings.ATTENTION_LARGE_MOVE_PERCENT,
+        drift_percent: float = settings.ATTENTION_DRIFT_PERCENT,
+    ):
+        self.portfolios = portfolios
+        self.market = market
+        self.alerts = alerts
+        self.large_move_percent = large_move_percent
+        self.drift_percent = drift_percent
+
+    async def get_attention(
+        self, portfolio_id: int
+    ) -> Optional[List[AttentionPosition]]:
+        """
+        List the positions in a portfolio that meet at least one condition.
+
+        Returns:
+            Flagged positions with their reasons, in position order, or None
+            if the portfolio doesn't exist
+        """
+        portfolio = await self.portfolios.get_portfolio_by_id(portfolio_id)
+        if portfolio is None:
+            return None
+
+        flagged = []
+        for position in portfolio.positions:
+            reasons: List[AttentionReason] = []
+
+            stock = await self.market.get_stock_by_symbol(position.stock_symbol)
+            if stock and abs(stock.change_percent) > self.large_move_percent:
+                reasons.append(
+                    AttentionReason(
+                        code="large_move",
+                        message=(
+                            f"{stock.symbol} moved {stock.change_percent:+.2f}% today"
+                        ),
+                    )
+                )
+
+            if position.target_weight is not None and portfolio.total_value > 0:
+                weight = position.current_value / portfolio.total_value
+                drift = (weight - position.target_weight) * 100
+                if abs(drift) > self.drift_percent:
+                    reasons.append(
+                        AttentionReason(
+                            code="drift",
+                            message=(
+                                f"Weight {weight * 100:.1f}% is {drift:+.1f} points "
+                                f"from target {position.target_weight * 100:.1f}%"
+                            ),
+                        )
+                    )
+
+            triggered = await self.alerts.get_triggered_alerts(
+                portfolio.user_id, position.stock_symbol
+            )
+            for alert in triggered:
+                reasons.append(
+                    AttentionReason(
+                        code="alert_triggered",
+                        message=(
+                            f"Alert {alert.id}: price {alert.condition.value} "
+                            f"{alert.threshold:g} triggered at "
+                            f"{alert.triggered_price:g}"
+                        ),
+                    )
+                )
+
+            if reasons:
+                flagged.append(AttentionPosition(position=position, reasons=reasons))
+        return flagged
+
+
+# Service instance
+attention_service = AttentionService(portfolio_service, market_service, alert_service)
+
+
+def get_attention_service() -> AttentionService:
+    return attention_service
@@ -0,0 +1,117 @@
+"""
+Historical portfolio composition for Quant-Dash.
+
+This module handles:
+1. Replaying a portfolio's ledger up to a day to find what it held then
+2. Valuing those holdings at that day's closes, with their weights
+
+Only the ledger counts here, not the positions as they are now, so a
+portfolio had nothing before its first transaction. A holding whose symbol
+has no close that day is valued at the latest close in the week before it,
+and listed as missing if there's none.
+"""
+
+from datetime import date, timedelta
+from typing import Dict, List, Optional, Sequence
+
+from app.models.schemas import (
+    CompositionHolding,
+    PortfolioComposition,
+    TradeSide,
+    Transaction,
+)
+from app.services.ledger import QUANTITY_EPSILON, LedgerService, ledger_service
+from app.services.market import MarketService, market_service
+from app.services.portfolio import PortfolioService, portfolio_service
+
+# How far back a holding's close may be from the day it's valued on
+CLOSE_LOOKBACK = timedelta(days=7)
+
+
+def holdings_as_of(
+    transactions: Sequence[Transaction], as_of: date
+) -> Dict[str, float]:
+    """Shares of each symbol held at the end of as_of, leaving out closed ones."""
+    held: Dict[str, float] = {}
+    for transaction in transactions:
+        if transaction.executed_at.date() > as_of:
+            continue
+        sign = 1 if transaction.side == TradeSide.BUY else -1
+        held[transaction.symbol] = (
+            held.get(transaction.symbol, 0.0) + sign * transaction.quantity
+        )
+    return {
+        symbol: quantity
+        for symbol, quantity in sorted(held.items())
+        if quantity > QUANTITY_EPSILON
+    }
+
+
+class CompositionService:
+    """
+    Service for what a portfolio held on past days
+    """
+
+    def __init__(
+        self,
+        portfolios: PortfolioService,
+        ledger: LedgerService,
+        market: MarketService,
+    ):
+        self.portfolios = portfolios
+        self.ledger = ledger
+        self.market = market
+
+    async def get_composition(
+        self, portfolio_id: int, as_of: date
+    ) -> Optional[PortfolioComposition]:
+        """
+        The portfolio's holdings at the end of as_of, valued at its closes.
+
+        Returns:
+            The composition, or None if the portfolio doesn't exist
+        """
+        if await self.portfolios.get_portfolio_by_id(portfolio_id) is None:
+            return None
+
+        transactions = await self.ledger.get_transactions(portfolio_id)
+        holdings: List[CompositionHolding] = []
+        missing: List[str] = []
+        for symbol, quantity in holdings_as_of(transactions, as_of).items():
+            closes = await self.market.get_closes(symbol, as_of - CLOSE_LOOKBACK, as_of)
+            if not closes:
+                missing.append(symbol)
+                holdings.append(CompositionHolding(symbol=symbol, quantity=quantity))
+                continue
+            close = closes[-1][1]
+            holdings.append(
+                CompositionHolding(
+                    symbol=symbol,
+                    quantity=quantity,
+                    close=close,
+                    value=round(quantity * close, 2),
+                )
+            )
+
+        total_value = sum(h.value for h in holdings if h.value is not None)
+        if total_value:
+            for holding in holdings:
+                if holding.value is not None:
+                    holding.weight = round(holding.value / total_value, 6)
+        return PortfolioComposition(
+            portfolio_id=portfolio_id,
+            as_of=as_of,
+            holdings=holdings,
+            total_value=round(total_value, 2),
+            missing_prices=missing,
+        )
+
+
+# Service instance
+composition_service = CompositionService(
+    portfolio_service, ledger_service, market_service
+)
+
+
+def get_composition_service() -> CompositionService:
+    return composition_service
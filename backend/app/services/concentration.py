@@ -0,0 +1,70 @@
+"""
+Portfolio concentration service for Quant-Dash.
+
+This module handles:
+1. Position weights by current value
+2. The Herfindahl-Hirschman Index (HHI) of those weights, raw and normalized
+3. Flagging portfolios whose HHI is above CONCENTRATION_HHI_THRESHOLD
+"""
+
+from typing import Optional
+
+from app.core.config import settings
+from app.models.schemas import PortfolioConcentration
+from app.services.analytics import hhi, normalized_hhi
+from app.services.portfolio import PortfolioService, portfolio_service
+
+
+class ConcentrationService:
+    """
+    Service for how concentrated a portfolio's holdings are
+    """
+
+    def __init__(self, portfolios: PortfolioService):
+        self.portfolios = portfolios
+
+    async def get_concentration(
+        self, portfolio_id: int
+    ) -> Optional[PortfolioConcentration]:
+        """
+        HHI of the portfolio's position weights.
+
+        Positions without value carry no weight; an empty portfolio has no HHI.
+
+        Returns:
+            The concentration, or None if the portfolio doesn't exist
+        """
+        portfolio = await self.portfolios.get_portfolio_by_id(portfolio_id)
+        if portfolio is None:
+            return None
+
+        threshold = settings.CONCENTRATION_HHI_THRESHOLD
+        values = [p.current_value for p in portfolio.positions if p.current_value > 0]
+        total = sum(values)
+        if not total:
+            return PortfolioConcentration(
+                portfolio_id=portfolio_id,
+                positions=0,
+                threshold=threshold,
+                concentrated=False,
+            )
+
+        weights = [value / total for value in values]
+        index = hhi(weights)
+        return PortfolioConcentration(
+            portfolio_id=portfolio_id,
+            positions=len(weights),
+            hhi=round(index, 6),
+            normalized_hhi=round(normalized_hhi(weights), 6),
+            effective_positions=round(1 / index, 4),
+            threshold=threshold,
+            concentrated=index > threshold,
+        )
+
+
+# Service instance
+concentration_service = ConcentrationService(portfolio_service)
+
+
+def get_concentration_service() -> ConcentrationService:
+    return concentration_service
@@ -0,0 +1,103 @@
+"""
+Corporate actions service for Quant-Dash.
+
+This module handles:
+1. Recording splits and dividends per symbol
+2. Listing the ones that affected a portfolio's holdings in a period
+
+A holding counts for an action if it was held at the end of the day before
+the ex-date, as that's who gets the dividend or the extra shares. Symbols
+with ledger transactions are replayed from the ledger; positions entered
+without any are taken as held throughout, at their current quantity.
+"""
+
+from datetime import date, timedelta
+from typing import Dict, List, Optional
+
+from app.models.schemas import (
+    CorporateAction,
+    CorporateActionCreate,
+    CorporateActionType,
+    HeldCorporateAction,
+    PortfolioCorporateActions,
+)
+from app.repositories.base import CorporateActionRepository
+from app.repositories.memory import InMemoryCorporateActionRepository
+from app.services.composition import holdings_as_of
+from app.services.ledger import LedgerService, ledger_service
+from app.services.portfolio import PortfolioService, portfolio_service
+
+
+class CorporateActionService:
+    """
+    Service for splits and dividends on held symbols
+    """
+
+    def __init__(
+        self,
+        portfolios: PortfolioService,
+        ledger: LedgerService,
+        actions: Optional[CorporateActionRepository] = None,
+    ):
+        self.portfolios = portfolios
+        self.ledger = ledger
+        self.actions = actions or InMemoryCorporateActionRepository()
+
+    async def record_action(self, action: CorporateActionCreate) -> CorporateAction:
+        return CorporateAction(**self.actions.add(action.model_dump()))
+
+    async def get_portfolio_actions(
+        self, portfolio_id: int, start: date, end: date
+    ) -> Optional[PortfolioCorporateActions]:
+        """
+        Actions with an ex-date between start and end on symbols the portfolio
+        held going into that ex-date.
+
+        Returns:
+            The actions by ex-date, or None if the portfolio doesn't exist
+        """
+        portfolio = await self.portfolios.get_portfolio_by_id(portfolio_id)
+        if portfolio is None:
+            return None
+
+        transactions = await self.ledger.get_transactions(portfolio_id)
+        traded = {transaction.symbol for transaction in transactions}
+        untracked: Dict[str, float] = {
+            position.stock_symbol: position.quantity
+            for position in portfolio.positions
+            if position.stock_symbol not in traded
+        }
+        symbols = sorted(traded | set(untracked))
+
+        held_actions: List[HeldCorporateAction] = []
+        for row in self.actions.list(symbols, start, end):
+            action = CorporateAction(**row)
+            if action.symbol in untracked:
+                quantity = untracked[action.symbol]
+            else:
+                held = holdings_as_of(transactions, action.ex_date - timedelta(days=1))
+                quantity = held.get(action.symbol, 0.0)
+            if not quantity:
+                continue
+            dividend_amount = None
+            if action.type == CorporateActionType.DIVIDEND:
+                dividend_amount = round(quantity * action.dividend_per_share, 2)
+            held_actions.append(
+                HeldCorporateAction(
+                    **action.model_dump(),
+                    quantity=quantity,
+                    dividend_amount=dividend_amount,
+                )
+            )
+
+        return PortfolioCorporateActions(
+            portfolio_id=portfolio_id, start=start, end=end, actions=held_actions
+        )
+
+
+# Service instance
+corporate_action_service = CorporateActionService(portfolio_service, ledger_service)
+
+
+def get_corporate_action_service() -> CorporateActionService:
+    return corporate_action_service
@@ -0,0 +1,121 @@
+"""
+Demo mode for Quant-Dash.
+
+With DEMO_MODE=true the API needs no provider key or database:
+1. The synthetic provider replaces Finnhub and drives the live feed
+2. The demo user gets pre-built portfolios on top of the sample one
+3. Alerts are planted periodically so the refresher triggers them
+4. The whole dataset resets on an interval, undoing visitors' writes
+
+Everything else runs through the regular services, so the dashboard
+exercises the same paths it does against a real provider.
+"""
+
+import logging
+import random
+
+from app.core.config import settings
+from app.core.jobs import JobScheduler
+from app.data.synthetic import SyntheticProvider
+from app.models.schemas import AlertCondition, PriceAlertCreate
+from app.services.alerts import AlertService
+from app.services.market import MarketService
+from app.services.portfolio import PortfolioService
+
+logger = logging.getLogger(__name__)
+
+# The dashboard's default user, which owns the sample portfolio
+DEMO_USER_ID = 1
+
+# Extra demo portfolios: symbol, quantity, average price, target weight
+DEMO_PORTFOLIOS = [
+    [
+        ("MSFT", 40, 280.00, 0.50),
+        ("AAPL", 80, 135.00, 0.50),
+    ],
+    [
+        ("GOOGL", 3, 2500.00, None),
+        ("AAPL", 25, 160.00, None),
+    ],
+]
+
+
+class DemoService:
+    """
+    Seeds, animates and resets the demo dataset
+    """
+
+    def __init__(
+        self,
+        provider: SyntheticProvider,
+        market: MarketService,
+        portfolios: PortfolioService,
+        alerts: AlertService,
+        seed: int = settings.DEMO_SEED,
+    ):
+        self.provider = provider
+        self.market = market
+        self.portfolios = portfolios
+        self.alerts = alerts
+        self.seed = seed
+        self.resets = 0
+        self.alerts_planted = 0
+
+    async def reset(self) -> None:
+        """Restore the seeded dataset and restart prices at their anchors."""
+        self.market.reset()
+        self.portfolios.reset()
+        self.alerts.reset()
+        for holdings in DEMO_PORTFOLIOS:
+            portfolio_id = self.portfolios.create_portfolio(DEMO_USER_ID)
+            for symbol, quantity, average_price, target_weight in holdings:
+                stock = await self.market.get_stock_by_symbol(symbol)
+                await self.portfolios.create_position(
+                    {
+                        "portfolio_id": portfolio_id,
+                        "stock_symbol": symbol,
+                        "quantity": quantity,
+                        "average_price": average_price,
+                        "current_value": round(quantity * stock.price, 2),
+                        "target_weight": target_weight,
+                    }
+                )
+        self.provider.reset(
+            {stock.symbol: stock.price for stock in await self.market.get_stocks()}
+        )
+        self.resets += 1
+        logger.info("Demo dataset reset (%d)", self.resets)
+
+    async def plant_alert(self) -> None:
+        """
+        Create an alert for the demo user that the next refresh triggers.
+
+        The threshold sits just on the already-crossed side of the current
+        price, so it fires through the regular alert evaluation.
+        """
+        rng = random.Random(f"{self.seed}:alert:{self.alerts_planted}")
+        stocks = await self.market.get_stocks()
+        stock = rng.choice(stocks)
+        if rng.random() < 0.5:
+            condition, threshold = AlertCondition.ABOVE, stock.price * 0.999
+        else:
+            condition, threshold = AlertCondition.BELOW, stock.price * 1.001
+        await self.alerts.create_alert(
+            DEMO_USER_ID,
+            PriceAlertCreate(
+                symbol=stock.symbol,
+                condition=condition,
+                threshold=round(threshold, 2),
+            ),
+        )
+        self.alerts_planted += 1
+
+
+def register_demo_jobs(scheduler: JobScheduler, demo: DemoService) -> None:
+    """Schedule the dataset reset and alert planting."""
+    scheduler.register(
+        "demo_reset", settings.DEMO_RESET_INTERVAL_MINUTES * 60, demo.reset
+    )
+    scheduler.register(
+        "demo_alerts", settings.DEMO_ALERT_INTERVAL_SECONDS, demo.plant_alert
+    )
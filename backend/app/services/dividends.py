@@ -0,0 +1,78 @@
+"""
+Dividend income service for Quant-Dash.
+
+This module handles:
+1. The value-weighted dividend yield of a portfolio
+2. Reporting which positions have no yield in the fundamentals
+
+Positions without a yield (non-payers, or symbols the fundamentals don't
+cover) count as 0 so the result is the yield of the portfolio as held.
+"""
+
+from typing import List, Optional
+
+from app.models.schemas import PortfolioYield, PositionYield
+from app.services.market import MarketService, market_service
+from app.services.portfolio import PortfolioService, portfolio_service
+
+
+class DividendService:
+    """
+    Service for portfolio dividend calculations
+    """
+
+    def __init__(self, portfolios: PortfolioService, market: MarketService):
+        self.portfolios = portfolios
+        self.market = market
+
+    async def get_portfolio_yield(self, portfolio_id: int) -> Optional[PortfolioYield]:
+        """
+        Compute sum(position value * symbol yield) / total value.
+
+        Returns:
+            The weighted yield with a per-position breakdown, or None if the
+            portfolio doesn't exist
+        """
+        portfolio = await self.portfolios.get_portfolio_by_id(portfolio_id)
+        if portfolio is None:
+            return None
+
+        total_value = portfolio.total_value
+        income = 0.0
+        positions: List[PositionYield] = []
+        missing: List[str] = []
+        for position in portfolio.positions:
+            stock = await self.market.get_stock_by_symbol(position.stock_symbol)
+            dividend_yield = stock.dividend_yield if stock else None
+            if dividend_yield is None:
+                missing.append(position.stock_symbol)
+            else:
+                income += position.current_value * dividend_yield
+            positions.append(
+                PositionYield(
+                    stock_symbol=position.stock_symbol,
+                    current_value=position.current_value,
+                    dividend_yield=dividend_yield,
+                    weight=(
+                        round(position.current_value / total_value, 6)
+                        if total_value
+                        else 0.0
+                    ),
+                )
+            )
+
+        return PortfolioYield(
+            portfolio_id=portfolio_id,
+            total_value=total_value,
+            weighted_yield=round(income / total_value, 6) if total_value else 0.0,
+            positions=positions,
+            missing_yield=missing,
+        )
+
+
+# Service instance
+dividend_service = DividendService(portfolio_service, market_service)
+
+
+def get_dividend_service() -> DividendService:
+    return dividend_service
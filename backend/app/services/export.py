@@ -0,0 +1,191 @@
+"""
+Portfolio export service for Quant-Dash.
+
+This module handles:
+1. A CSV of a portfolio's positions
+2. An XLSX workbook with positions, transactions and performance sheets
+3. A CSV of a stock's daily history
+
+Workbook cells are typed: quantities, prices and values are numbers and
+execution times and days are dates, so spreadsheets can sum and sort them
+without converting text.
+"""
+
+import csv
+import io
+from datetime import date, datetime
+from typing import Any, Dict, List, Optional, Sequence, Tuple
+
+from app.models.schemas import ExportFormat, Portfolio, StockHistory
+from app.services.ledger import LedgerService, ledger_service
+from app.services.performance import PerformanceService, performance_service
+from app.services.portfolio import PortfolioService, portfolio_service
+from app.utils.timestamps import naive_utc
+
+MEDIA_TYPES = {
+    ExportFormat.CSV: "text/csv",
+    ExportFormat.XLSX: (
+        "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+    ),
+}
+
+POSITION_COLUMNS = [
+    "Symbol",
+    "Quantity",
+    "Average price",
+    "Current value",
+    "Total gain",
+    "Target weight",
+    "Tags",
+]
+TRANSACTION_COLUMNS = ["Executed at", "Symbol", "Side", "Quantity", "Price", "Fees"]
+PERFORMANCE_COLUMNS = ["Date", "Value", "Daily return"]
+HISTORY_COLUMNS = ["Date", "Open", "High", "Low", "Close", "Volume"]
+
+Sheet = List[List[Any]]
+
+
+def _cell(value: Any) -> Any:
+    # XLSX has no time zones; store aware times as UTC
+    if isinstance(value, datetime):
+        return naive_utc(value)
+    return value
+
+
+def to_csv(rows: Sheet) -> bytes:
+    buffer = io.StringIO()
+    csv.writer(buffer).writerows(rows)
+    return buffer.getvalue().encode("utf-8")
+
+
+def to_xlsx(sheets: Dict[str, Sheet]) -> bytes:
+    """A workbook with one sheet per entry, in order, header row first."""
+    from openpyxl import Workbook
+
+    workbook = Workbook(write_only=True)
+    for title, rows in sheets.items():
+        sheet = workbook.create_sheet(title)
+        for row in rows:
+            sheet.append([_cell(value) for value in row])
+    buffer = io.BytesIO()
+    workbook.save(buffer)
+    return buffer.getvalue()
+
+
+def _with_returns(history: Sequence[Tuple[date, float]]) -> Sheet:
+    """Daily values with the return since the previous row (None for the first)."""
+    rows: Sheet = []
+    previous = None
+    for day, value in history:
+        daily_return = round(value / previous - 1, 6) if previous else None
+        rows.append([day, round(value, 2), daily_return])
+        previous = value
+    return rows
+
+
+def _positions_sheet(portfolio: Portfolio) -> Sheet:
+    return [POSITION_COLUMNS] + [
+        [
+            p.stock_symbol,
+            p.quantity,
+            p.average_price,
+            p.current_value,
+            p.total_gain,
+            p.target_weight,
+            ", ".join(p.tags),
+        ]
+        for p in portfolio.positions
+    ]
+
+
+def history_csv(history: StockHistory) -> bytes:
+    """
+    One row per stored close, oldest first. Open, high, low and volume are
+    blank on days with a close but no OHLC bar.
+    """
+    bars = {bar.date.date(): bar for bar in history.bars}
+    rows: Sheet = [HISTORY_COLUMNS]
+    for point in history.closes:
+        bar = bars.get(point.date)
+        if bar is None:
+            rows.append([point.date, "", "", "", point.close, ""])
+            continue
+        rows.append(
+            [
+                point.date,
+                bar.open_price,
+                bar.high_price,
+                bar.low_price,
+                point.close,
+                bar.volume,
+            ]
+        )
+    return to_csv(rows)
+
+
+class ExportService:
+    """
+    Service for exporting portfolio history
+    """
+
+    def __init__(
+        self,
+        portfolios: PortfolioService,
+        ledger: LedgerService,
+        performance: PerformanceService,
+    ):
+        self.portfolios = portfolios
+        self.ledger = ledger
+        self.performance = performance
+
+    async def export(
+        self,
+        portfolio_id: int,
+        export_format: ExportFormat,
+        start: date,
+        end: date,
+    ) -> Optional[bytes]:
+        """
+        Export a portfolio in the given format.
+
+        A CSV holds the positions only; a workbook adds the transactions and
+        the daily value between start and end.
+
+        Returns:
+            The file's contents, or None if the portfolio doesn't exist
+        """
+        portfolio = await self.portfolios.get_portfolio_by_id(portfolio_id)
+        if portfolio is None:
+            return None
+
+        positions = _positions_sheet(portfolio)
+        if export_format == ExportFormat.CSV:
+            return to_csv(positions)
+
+        transactions: Sheet = [TRANSACTION_COLUMNS] + [
+            [t.executed_at, t.symbol, t.side.value, t.quantity, t.price, t.fees]
+            for t in await self.ledger.get_transactions(portfolio_id)
+        ]
+        history = await self.performance.daily_values(portfolio_id, start, end)
+        return to_xlsx(
+            {
+                "Positions": positions,
+                "Transactions": transactions,
+                "Performance": [PERFORMANCE_COLUMNS] + _with_returns(history),
+            }
+        )
+
+    async def positions_csv(self, portfolio_id: int) -> Optional[bytes]:
+        """The positions CSV alone, or None if the portfolio doesn't exist."""
+        portfolio = await self.portfolios.get_portfolio_by_id(portfolio_id)
+        if portfolio is None:
+            return None
+        return to_csv(_positions_sheet(portfolio))
+
+
+# Service instance
+export_service = ExportService(portfolio_service, ledger_service, performance_service)
+
+
+def get_export_service() -> ExportService:
+    return export_service
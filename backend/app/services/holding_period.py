@@ -0,0 +1,102 @@
+"""
+Holding periods for Quant-Dash.
+
+This module handles:
+1. Replaying the ledger to find the lots still open, sells closing the
+   oldest first (FIFO)
+2. Each position's average holding period, weighted by the shares per lot
+3. The portfolio's average holding period, weighted by each lot's value
+
+Lots are valued at their position's current price, so a long-held small
+position counts for less than a recent large one. Symbols the ledger holds
+without a position (positions are maintained separately from the ledger)
+are valued at the lots' own cost.
+"""
+
+from datetime import datetime
+from typing import Callable, Optional
+
+from app.models.schemas import HoldingPeriod, PositionHoldingPeriod
+from app.services.ledger import LedgerService, ledger_service
+from app.services.portfolio import PortfolioService, portfolio_service
+from app.services.trades import open_lots
+from app.utils.timestamps import naive_utc
+
+SECONDS_PER_DAY = 86400
+
+
+def held_days(opened_at: datetime, as_of: datetime) -> float:
+    """Days from opened_at to as_of; lots executed later count as 0."""
+    elapsed = naive_utc(as_of) - naive_utc(opened_at)
+    return max(0.0, elapsed.total_seconds() / SECONDS_PER_DAY)
+
+
+class HoldingPeriodService:
+    """
+    Service for how long a portfolio's open positions have been held
+    """
+
+    def __init__(
+        self,
+        portfolios: PortfolioService,
+        ledger: LedgerService,
+        clock: Callable[[], datetime] = datetime.utcnow,
+    ):
+        self.portfolios = portfolios
+        self.ledger = ledger
+        self.clock = clock
+
+    async def get_holding_period(self, portfolio_id: int) -> Optional[HoldingPeriod]:
+        """
+        Returns:
+            The average holding period overall and per position, or None if
+            the portfolio doesn't exist
+        """
+        portfolio = await self.portfolios.get_portfolio_by_id(portfolio_id)
+        if portfolio is None:
+            return None
+        lots = open_lots(await self.ledger.get_transactions(portfolio_id))
+        as_of = naive_utc(self.clock())
+        prices = {
+            position.stock_symbol: position.current_value / position.quantity
+            for position in portfolio.positions
+            if position.quantity > 0
+        }
+
+        positions = []
+        total_value = weighted_days = 0.0
+        for symbol, symbol_lots in lots.items():
+            quantity = value = share_days = 0.0
+            for lot in symbol_lots:
+                days = held_days(lot.opened_at, as_of)
+                lot_value = lot.quantity * prices.get(symbol, lot.unit_cost)
+                quantity += lot.quantity
+                value += lot_value
+                share_days += lot.quantity * days
+                weighted_days += lot_value * days
+            total_value += value
+            positions.append(
+                PositionHoldingPeriod(
+                    symbol=symbol,
+                    quantity=round(quantity, 6),
+                    value=round(value, 2),
+                    average_days=round(share_days / quantity, 2),
+                    oldest_lot_at=symbol_lots[0].opened_at,
+                )
+            )
+
+        positions.sort(key=lambda position: position.average_days, reverse=True)
+        return HoldingPeriod(
+            portfolio_id=portfolio_id,
+            as_of=as_of,
+            average_days=round(weighted_days / total_value, 2) if total_value else None,
+            positions=positions,
+        )
+
+
+# Service instance
+holding_period_service = HoldingPeriodService(portfolio_service, ledger_service)
+
+
+def get_holding_period_service() -> HoldingPeriodService:
+    return holding_period_service
@@ -0,0 +1,241 @@
+"""
+Transaction ledger for Quant-Dash.
+
+This module handles:
+1. Recording buy and sell executions per portfolio
+2. Rejecting sells of more shares than were held
+3. Rejecting transactions whose live price moved away from what the user saw
+4. Rejecting likely resubmissions of a transaction just recorded
+5. Updating the portfolio's position with each transaction recorded
+6. Listing a portfolio's transactions in execution order
+
+The ledger is the history of executions. Positions can still be entered
+directly too, and a sell from one is checked against the shares it holds
+rather than the ledger's; checking positions against the ledger
+(PortfolioService.rebuild_positions) shows where the two disagree. For
+development/testing, this uses an in-memory store. In production, this
+would interact with a real database ORM.
+"""
+
+from datetime import date, datetime, timedelta
+from typing import Any, Callable, Dict, List, Optional
+
+from app.core.config import settings
+from app.core.querybudget import counts_as_query
+from app.models.schemas import TradeSide, Transaction, TransactionCreate
+from app.services.market import MarketService, market_service
+from app.services.portfolio import PortfolioService, portfolio_service
+from app.utils.symbols import check_symbol, normalize_symbol
+
+# Shares left over after rounding are treated as none
+QUANTITY_EPSILON = 1e-9
+
+
+class PriceMovedError(ValueError):
+    """The live price is outside the tolerance of the expected price."""
+
+    def __init__(
+        self,
+        symbol: str,
+        expected_price: float,
+        current_price: float,
+        tolerance: float,
+    ):
+        self.symbol = symbol
+        self.expected_price = expected_price
+        self.current_price = current_price
+        self.tolerance = tolerance
+        super().__init__(
+            f"{symbol} is at {current_price}, more than {tolerance:.2%} from the "
+            f"expected {expected_price}"
+        )
+
+
+class PriceCheckError(Exception):
+    """The live price to check the transaction against couldn't be fetched."""
+
+    def __init__(self, symbol: str, cause: Exception):
+        self.symbol = symbol
+        self.cause = cause
+        super().__init__(f"Couldn't fetch the live price of {symbol}: {cause}")
+
+
+class DuplicateTransactionError(ValueError):
+    """A matching transaction was recorded moments ago, e.g. a double-click."""
+
+    def __init__(self, existing: Transaction, window: int):
+        self.existing = existing
+        self.window = window
+        super().__init__(
+            f"Transaction {existing.id} with the same symbol, side, quantity and "
+            f"price was recorded within the last {window}s"
+        )
+
+
+class LedgerService:
+    """
+    Service for recording and listing transactions
+    """
+
+    def __init__(
+        self,
+        market: Optional[MarketService] = None,
+        clock: Callable[[], datetime] = datetime.utcnow,
+        portfolios: Optional[PortfolioService] = None,
+    ):
+        self.market = market
+        self.clock = clock
+        self.portfolios = portfolios
+        self.reset()
+
+    def reset(self) -> None:
+        """Drop all transactions."""
+        self._transactions: Dict[int, Dict[str, Any]] = {}  # id -> transaction
+        self._next_transaction_id = 1
+
+    async def _check_price(
+        self, symbol: str, expected_price: float, tolerance: Optional[float]
+    ) -> None:
+        if tolerance is None:
+            tolerance = settings.PRICE_CHECK_DEFAULT_TOLERANCE
+        current_price = None
+        if self.market is not None:
+            try:
+                current_price = await self.market.get_live_price(symbol)
+            except Exception as e:
+                raise PriceCheckError(symbol, e) from e
+        if current_price is None:
+            raise ValueError(f"No live price for {symbol} to check against")
+        if abs(current_price - expected_price) > tolerance * expected_price:
+            raise PriceMovedError(symbol, expected_price, current_price, tolerance)
+
+    def _recent_duplicate(
+        self, portfolio_id: int, symbol: str, transaction: TransactionCreate
+    ) -> Optional[Transaction]:
+        """The latest matching transaction recorded within the window, if any."""
+        window = settings.DUPLICATE_TRANSACTION_WINDOW_SECONDS
+        if window <= 0:
+            return None
+        since = self.clock() - timedelta(seconds=window)
+        matches = [
+            record
+            for record in self._transactions.values()
+            if record["portfolio_id"] == portfolio_id
+            and record["symbol"] == symbol
+            and record["side"] == transaction.side
+            and record["quantity"] == transaction.quantity
+            and record["price"] == transaction.price
+            and record["recorded_at"] >= since
+        ]
+        if not matches:
+            return None
+        return Transaction(**max(matches, key=lambda r: r["recorded_at"]))
+
+    async def record(
+        self,
+        portfolio_id: int,
+        transaction: TransactionCreate,
+        expected_price: Optional[float] = None,
+        tolerance: Optional[float] = None,
+        force: bool = False,
+    ) -> Transaction:
+        """
+        Record an execution.
+
+        With expected_price, the live price must be within tolerance (a
+        fraction of expected_price, PRICE_CHECK_DEFAULT_TOLERANCE by default)
+        of it, so a user never confirms against a stale quote. Unless forced,
+        a transaction matching one recorded in the last
+        DUPLICATE_TRANSACTION_WINDOW_SECONDS (same symbol, side, quantity and
+        price) is taken for an accidental resubmission and rejected.
+
+        With a portfolio service, the transaction is applied to the
+        portfolio's position in the symbol too, and only recorded if that
+        succeeds, as one database transaction would.
+
+        Raises:
+            SymbolRestrictedError: If the symbol is blocked or not allowlisted
+            DuplicateTransactionError: If it looks like a resubmission
+            PriceMovedError: If the live price is outside the tolerance
+            ValueError: If a sell is for more shares than were held at the time,
+                counting sells already recorded after it, or, for a position
+                the ledger doesn't account for, than the position holds; if
+                the position would be left with a fractional share count; or
+                if there's no live price to check expected_price against
+        """
+        symbol = check_symbol(transaction.symbol)
+        if not force:
+            duplicate = self._recent_duplicate(portfolio_id, symbol, transaction)
+            if duplicate is not None:
+                raise DuplicateTransactionError(
+                    duplicate, settings.DUPLICATE_TRANSACTION_WINDOW_SECONDS
+                )
+        if expected_price is not None:
+            await self._check_price(symbol, expected_price, tolerance)
+        history = await self.get_transactions(portfolio_id, symbol)
+        if transaction.side == TradeSide.SELL and (
+            self.portfolios is None
+            or self.portfolios.tracks_ledger(portfolio_id, symbol, history)
+        ):
+            # Sells already recorded after this one must stay covered too.
+            # Shares entered directly are checked by apply_transaction instead
+            held = 0.0
+            for record in sorted(history + [transaction], key=lambda r: r.executed_at):
+                if record.side == TradeSide.BUY:
+                    held += record.quantity
+                else:
+                    held -= record.quantity
+                if held < -QUANTITY_EPSILON:
+                    raise ValueError(
+                        f"Selling {transaction.quantity:g} {symbol} at "
+                        f"{transaction.executed_at.isoformat()} would sell more "
+                        "shares than were held"
+                    )
+        if self.portfolios is not None:
+            await self.portfolios.apply_transaction(
+                portfolio_id,
+                transaction.model_copy(update={"symbol": symbol}),
+                history,
+            )
+
+        record = {
+            **transaction.model_dump(),
+            "symbol": symbol,
+            "id": self._next_transaction_id,
+            "portfolio_id": portfolio_id,
+            "recorded_at": self.clock(),
+        }
+        self._transactions[record["id"]] = record
+        self._next_transaction_id += 1
+        return Transaction(**record)
+
+    @counts_as_query
+    async def get_transactions(
+        self,
+        portfolio_id: int,
+        symbol: Optional[str] = None,
+        start: Optional[date] = None,
+        end: Optional[date] = None,
+    ) -> List[Transaction]:
+        """
+        A portfolio's transactions, oldest execution first, optionally only
+        those in a symbol or executed between start and end (inclusive).
+        """
+        records = [
+            record
+            for record in self._transactions.values()
+            if record["portfolio_id"] == portfolio_id
+            and (symbol is None or record["symbol"] == normalize_symbol(symbol))
+            and (start is None or record["executed_at"].date() >= start)
+            and (end is None or record["executed_at"].date() <= end)
+        ]
+        records.sort(key=lambda r: (r["executed_at"], r["id"]))
+        return [Transaction(**record) for record in records]
+
+
+# Service instance
+ledger_service = LedgerService(market_service, portfolios=portfolio_service)
+
+
+def get_ledger_service() -> LedgerService:
+    return ledger_service
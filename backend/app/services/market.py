@@ -1,60 +1,711 @@
-from typing import List, Optional
-from app.models.schemas import Stock, Portfolio, Position
+"""
+Market data service layer for Quant-Dash.
+
+Stocks, closes and bars are kept in repositories (in memory unless others
+are passed in), seeded with sample quotes. In production, these would be
+backed by the database and the market data providers.
+
+Single-stock lookups go through a short-lived cache in front of the stock
+repository; writing a stock drops its cached copy.
+
+Quotes from the provider and manual seeding both store stocks through the
+same upsert, keyed by symbol: it recomputes change_percent from the price
+and change, and always moves updated_at forward.
+"""
+
+import asyncio
+import logging
+from datetime import date, datetime, timedelta
+from typing import Any, Dict, Iterable, List, Optional, Sequence, Tuple
+
+from app.core import errors
+from app.core.config import settings
+from app.core.providerbudget import ProviderBudgetExhausted
+from app.core.querybudget import counts_as_query
+from app.data.provider_base import (
+    MarketProvider,
+    ProviderNotSupportedError,
+    quote_price,
+    supports_daily_closes,
+    supports_level1,
+    supports_raw_quotes,
+)
+from app.models.schemas import (
+    DataCoverage,
+    IndicatorPoint,
+    IndicatorSeries,
+    IndicatorType,
+    Level1Quote,
+    LiveQuote,
+    MarketData,
+    MarketDataCreate,
+    PricePoint,
+    ProviderDebug,
+    QuoteBatch,
+    Stock,
+    StockCreate,
+    StockHistory,
+)
+from app.repositories.base import MarketDataRepository, StockRepository
+from app.repositories.memory import (
+    InMemoryMarketDataRepository,
+    InMemoryStockRepository,
+)
+from app.utils import indicators
+from app.utils.market_calendar import trading_days
+from app.utils.multistatus import MultiStatus
+from app.utils.symbols import normalize_symbol, symbol_allowed
+from app.utils.ttlcache import TTLCache
+
+logger = logging.getLogger(__name__)
+
+# Quote requests in flight at once for a batch
+QUOTE_BATCH_CONCURRENCY = 5
+
+# Stock fields list_stocks can sort by
+STOCK_SORT_FIELDS = ("symbol", "price", "change_percent", "volume")
+
+INDICATORS = {
+    IndicatorType.SMA: indicators.sma,
+    IndicatorType.EMA: indicators.ema,
+    IndicatorType.RSI: indicators.rsi,
+}
+
+
+def change_percent(price: float, change: float) -> float:
+    """The day's change as a percent of the previous close, price - change."""
+    previous_close = price - change
+    return round(change / previous_close * 100, 2) if previous_close else 0.0
 
 
 class MarketService:
     """
     Service for handling market data operations
     """
-    
+
+    def __init__(
+        self,
+        provider: Optional[MarketProvider] = None,
+        provider_name: str = "",
+        stocks: Optional[StockRepository] = None,
+        market_data: Optional[MarketDataRepository] = None,
+        quote_cache: Optional[TTLCache] = None,
+    ):
+        self.provider = provider
+        self.provider_name = provider_name
+        self.stocks = stocks or InMemoryStockRepository()
+        self.market_data = market_data or InMemoryMarketDataRepository()
+        self.quote_cache = quote_cache or TTLCache()
+        self.reset()
+
+    def reset(self) -> None:
+        """Drop all stored data and restore the sample quotes."""
+        self.stocks.clear()
+        self.market_data.clear()
+        self.quote_cache.clear()
+        self._seed_sample_data()
+
+    async def evict_expired_quotes(self) -> int:
+        """Drop expired cached stocks; run periodically so the cache stays small."""
+        return self.quote_cache.evict_expired()
+
+    def set_provider(
+        self, provider: Optional[MarketProvider], provider_name: str = ""
+    ) -> None:
+        """Attach the live data provider once it's connected at startup."""
+        self.provider = provider
+        self.provider_name = provider_name
+
+    def _seed_sample_data(self) -> None:
+        """Load the sample quotes used by the dashboard during development."""
+        updated_at = datetime(2025, 8, 5, 10, 30, 0)
+        for symbol, name, price, change, change_percent, volume, cap, pe, dy in [
+            ("AAPL", "Apple Inc.", 150.25, 2.15, 1.45, 50000000, "2.4T", 28.5, 0.0061),
+            (
+                "GOOGL",
+                "Alphabet Inc.",
+                2750.80,
+                -12.45,
+                -0.45,
+                1200000,
+                "1.8T",
+                25.2,
+                None,
+            ),
+            (
+                "MSFT",
+                "Microsoft Corporation",
+                310.50,
+                5.25,
+                1.72,
+                25000000,
+                "2.3T",
+                32.1,
+                0.0087,
+            ),
+        ]:
+            self._put_stock(
+                {
+                    "symbol": symbol,
+                    "name": name,
+                    "price": price,
+                    "change": change,
+                    "change_percent": change_percent,
+                    "volume": volume,
+                    "market_cap": cap,
+                    "pe_ratio": pe,
+                    "dividend_yield": dy,
+                    "updated_at": updated_at,
+                }
+            )
+
+    def _put_stock(self, stock_data: Dict[str, Any]) -> Dict[str, Any]:
+        """Insert or replace a stock row keyed by symbol."""
+        record = {**(self.stocks.get(stock_data["symbol"]) or {}), **stock_data}
+        record.setdefault("updated_at", datetime.utcnow())
+        # The next lookup reads the new row rather than a cached one
+        self.quote_cache.delete(record["symbol"])
+        return self.stocks.upsert(record)
+
+    def _upsert_stock(self, stock_data: Dict[str, Any]) -> Dict[str, Any]:
+        """
+        Insert or update a stock row keyed by symbol, recomputing its
+        change_percent. An update's updated_at is always later than the
+        stored one, even when the given time (or the clock) isn't.
+        """
+        existing = self.stocks.get(stock_data["symbol"])
+        record = {**(existing or {}), **stock_data}
+        record["change_percent"] = change_percent(record["price"], record["change"])
+        updated_at = stock_data.get("updated_at") or datetime.utcnow()
+        if existing is not None and updated_at <= existing["updated_at"]:
+            updated_at = existing["updated_at"] + timedelta(microseconds=1)
+        record["updated_at"] = updated_at
+        return self._put_stock(record)
+
+    async def upsert_stock(self, stock: StockCreate) -> Tuple[Stock, bool]:
+        """
+        Store a stock, replacing the fields given for one already tracked.
+
+        Returns:
+            The stored stock, and whether it's new
+        """
+        symbol = normalize_symbol(stock.symbol)
+        is_new = self.stocks.get(symbol) is None
+        record = self._upsert_stock({**stock.model_dump(), "symbol": symbol})
+        return Stock(**record), is_new
+
+    def _quoted(self, record: Dict[str, Any], price: float) -> Dict[str, Any]:
+        """A stock record's fields after a live quote at price."""
+        change = price - (record["price"] - record["change"])
+        return {
+            "symbol": record["symbol"],
+            "price": price,
+            "change": round(change, 2),
+            "change_percent": change_percent(price, change),
+            "updated_at": datetime.utcnow(),
+        }
+
+    def apply_quote(self, symbol: str, price: float) -> Optional[Stock]:
+        """
+        Update a stock's price from a live quote.
+
+        The day's change is measured against the previous close implied by
+        the stored price and change. Unknown symbols are ignored.
+        """
+        record = self.stocks.get(normalize_symbol(symbol))
+        if record is None:
+            return None
+        record = self._upsert_stock(self._quoted(record, price))
+        return Stock(**record)
+
+    async def debug_quote(self, symbol: str) -> ProviderDebug:
+        """
+        Fetch a quote live and show it both raw and parsed, storing nothing.
+
+        Skips the provider's response cache when it has one. The parsed stock
+        is what apply_quote would store; None if the symbol isn't tracked or
+        the quote has no price.
+
+        Raises:
+            ProviderNotSupportedError: If no provider is connected
+        """
+        if self.provider is None:
+            raise ProviderNotSupportedError("No market data provider is connected")
+
+        symbol = normalize_symbol(symbol)
+        if supports_raw_quotes(self.provider):
+            raw = await self.provider.get_raw_quote(symbol)
+        else:
+            raw = await self.provider.get_quote(symbol)
+
+        price = quote_price(raw)
+        record = self.stocks.get(symbol)
+        stock = None
+        if record is not None and price is not None:
+            stock = Stock(**{**record, **self._quoted(record, price)})
+        return ProviderDebug(
+            symbol=symbol,
+            provider=self.provider_name,
+            fetched_at=datetime.utcnow(),
+            raw=raw,
+            price=price,
+            stock=stock,
+        )
+
+    async def get_live_price(self, symbol: str) -> Optional[float]:
+        """
+        The current price, quoted live when a provider is connected.
+
+        Falls back to the stored price when there's no provider or the quote
+        has no price; None if neither is available.
+        """
+        symbol = normalize_symbol(symbol)
+        if self.provider is not None:
+            price = quote_price(await self.provider.get_quote(symbol))
+            if price is not None:
+                return price
+        record = self.stocks.get(symbol)
+        return record["price"] if record else None
+
+    async def get_live_quotes(
+        self, symbols: Iterable[str], statuses: Optional[MultiStatus] = None
+    ) -> QuoteBatch:
+        """
+        Fetch a live quote for each distinct symbol, in the order given.
+
+        Symbols whose quote fails or has no price are listed as missing. Once
+        the request's provider time budget runs out, the remaining symbols
+        are missing too and the batch is marked partial.
+
+        Args:
+            symbols: Symbols to quote
+            statuses: Outcomes recorded so far, e.g. symbols the caller
+                rejected; each symbol's outcome is added to it
+
+        Raises:
+            ProviderNotSupportedError: If no provider is connected
+        """
+        if self.provider is None:
+            raise ProviderNotSupportedError("No market data provider is connected")
+
+        symbols = list(dict.fromkeys(normalize_symbol(symbol) for symbol in symbols))
+        if statuses is None:
+            statuses = MultiStatus(symbols)
+        limit = asyncio.Semaphore(QUOTE_BATCH_CONCURRENCY)
+
+        async def fetch(symbol: str) -> Optional[float]:
+            async with limit:
+                return quote_price(await self.provider.get_quote(symbol))
+
+        results = await asyncio.gather(
+            *(fetch(symbol) for symbol in symbols), return_exceptions=True
+        )
+        quotes, missing, not_found, partial = [], [], [], False
+        for symbol, result in zip(symbols, results):
+            if isinstance(result, Exception):
+                if isinstance(result, ProviderBudgetExhausted):
+                    partial = True
+                else:
+                    logger.warning("Quote for %s failed: %s", symbol, result)
+                missing.append(symbol)
+                statuses.failed(symbol, errors.provider_error(result))
+            elif result is None:
+                missing.append(symbol)
+                not_found.append(symbol)
+                statuses.failed(symbol, errors.stock_not_found(symbol))
+            else:
+                quotes.append(LiveQuote(symbol=symbol, price=result))
+                statuses.succeeded(symbol)
+        return QuoteBatch(
+            quotes=quotes,
+            missing=missing,
+            not_found=not_found,
+            partial=partial,
+            results=statuses.results,
+        )
+
+    @counts_as_query
     async def get_stocks(self) -> List[Stock]:
         """Get all stocks"""
-        # TODO: Implement actual market data fetching
-        # This could integrate with APIs like Alpha Vantage, IEX Cloud, etc.
-        pass
-    
+        return [Stock(**record) for record in self.stocks.list()]
+
+    def _listed(
+        self,
+        min_price: Optional[float] = None,
+        max_price: Optional[float] = None,
+        symbols: Optional[Sequence[str]] = None,
+    ) -> List[Dict[str, Any]]:
+        """
+        Records of stocks not restricted by compliance rules, by id, priced
+        within the given bounds (inclusive) and, if symbols is given, only
+        those symbols.
+        """
+        wanted = None if symbols is None else set(symbols)
+        return [
+            record
+            for record in self.stocks.list()
+            if symbol_allowed(record["symbol"])
+            and (min_price is None or record["price"] >= min_price)
+            and (max_price is None or record["price"] <= max_price)
+            and (wanted is None or record["symbol"] in wanted)
+        ]
+
+    @counts_as_query
+    async def list_stocks(
+        self,
+        limit: int,
+        offset: int = 0,
+        sort: Optional[Tuple[str, bool]] = None,
+        min_price: Optional[float] = None,
+        max_price: Optional[float] = None,
+        symbols: Optional[Sequence[str]] = None,
+    ) -> List[Stock]:
+        """
+        One page of the stocks clients may see, in catalog order unless sort
+        gives a (field, descending) pair. Ties keep catalog order. Symbols
+        limits the page to those stocks; ones not stored are left out.
+
+        The caller checks the field against STOCK_SORT_FIELDS; in SQL it's
+        picked from that list for ORDER BY, not taken from the request.
+        """
+        records = self._listed(min_price, max_price, symbols)
+        if sort is not None:
+            field, descending = sort
+            records.sort(key=lambda record: record[field], reverse=descending)
+        return [Stock(**record) for record in records[offset : offset + limit]]
+
+    @counts_as_query
+    async def count_stocks(
+        self,
+        min_price: Optional[float] = None,
+        max_price: Optional[float] = None,
+        symbols: Optional[Sequence[str]] = None,
+    ) -> int:
+        """Number of stocks clients may see, for paging through list_stocks."""
+        return len(self._listed(min_price, max_price, symbols))
+
+    @counts_as_query
     async def get_stock_by_symbol(self, symbol: str) -> Optional[Stock]:
         """Get a specific stock by symbol"""
-        # TODO: Implement actual stock data fetching
-        pass
-    
-    async def get_stock_history(self, symbol: str, days: int = 30):
-        """Get historical data for a stock"""
-        # TODO: Implement historical data fetching
-        pass
+        record = self.stocks.get(normalize_symbol(symbol))
+        return Stock(**record) if record else None
 
+    @counts_as_query
+    async def get_stocks_by_symbol(self, symbols: Iterable[str]) -> Dict[str, Stock]:
+        """Stored stocks by symbol in one read; symbols with no stock are left out"""
+        records = (self.stocks.get(normalize_symbol(symbol)) for symbol in symbols)
+        return {record["symbol"]: Stock(**record) for record in records if record}
 
-class PortfolioService:
-    """
-    Service for handling portfolio operations
-    """
-    
-    async def get_portfolio(self, user_id: int) -> Optional[Portfolio]:
-        """Get user's portfolio"""
-        # TODO: Implement database query
-        pass
-    
-    async def create_position(self, position_data: dict) -> Position:
-        """Create a new position"""
-        # TODO: Implement database insert
-        pass
-    
-    async def update_position(self, position_id: int, position_data: dict) -> Position:
-        """Update an existing position"""
-        # TODO: Implement database update
-        pass
-    
-    async def delete_position(self, position_id: int) -> bool:
-        """Delete a position"""
-        # TODO: Implement database delete
-        pass
-    
-    async def calculate_portfolio_performance(self, user_id: int, days: int = 30):
-        """Calculate portfolio performance metrics"""
-        # TODO: Implement performance calculations
-        pass
-
-
-# Service instances
+    @counts_as_query
+    async def get_prices(self, symbols: Iterable[str]) -> Dict[str, float]:
+        """Stored prices by symbol in one read; symbols with no stock are left out"""
+        records = (self.stocks.get(normalize_symbol(symbol)) for symbol in symbols)
+        return {record["symbol"]: record["price"] for record in records if record}
+
+    async def find_stock(self, symbol: str) -> Optional[Stock]:
+        """
+        Get a stock by symbol, fetching it from the provider if it isn't stored.
+
+        A fetched quote is stored, so later lookups don't call the provider,
+        and the stock is cached for QUOTE_CACHE_TTL seconds, so they don't
+        read the store either until it changes.
+
+        Returns:
+            The stock, or None if it isn't stored and the provider has no price
+            for it (or there's no provider)
+        """
+        symbol = normalize_symbol(symbol)
+        stock = self.quote_cache.get(symbol)
+        if stock is None:
+            stock = await self._find_stock(symbol)
+            if stock is not None:
+                self.quote_cache.set(symbol, stock, settings.QUOTE_CACHE_TTL)
+        return stock
+
+    async def _find_stock(self, symbol: str) -> Optional[Stock]:
+        stock = await self.get_stock_by_symbol(symbol)
+        if stock is not None or self.provider is None:
+            return stock
+        return await self._fetch_stock(symbol)
+
+    async def _fetch_stock(self, symbol: str) -> Optional[Stock]:
+        """Store the provider's quote for a symbol; None if it has no price."""
+        quote = await self.provider.get_quote(symbol)
+        price = quote_price(quote)
+        if not price:
+            return None
+        record = self._upsert_stock(
+            {
+                "symbol": symbol,
+                "name": quote.get("name") or symbol,
+                "price": price,
+                "change": float(quote.get("change", quote.get("d")) or 0.0),
+                "volume": int(quote.get("volume") or 0),
+                "updated_at": datetime.utcnow(),
+            }
+        )
+        logger.info("Stored %s from %s", symbol, self.provider_name or "provider")
+        return Stock(**record)
+
+    async def find_stocks(
+        self, symbols: Iterable[str], statuses: Optional[MultiStatus] = None
+    ) -> QuoteBatch:
+        """
+        Get several stocks at once, like find_stock for each distinct symbol,
+        as a quote batch with the stocks by symbol.
+
+        Cached stocks are used as is, the rest are read from the store in one
+        query, and only the symbols still missing go to the provider, at most
+        QUOTE_BATCH_CONCURRENCY at a time. Symbols restricted by compliance
+        rules are reported as not found.
+
+        Args:
+            symbols: Symbols to look up
+            statuses: Outcomes recorded so far (see get_live_quotes)
+        """
+        symbols = list(dict.fromkeys(normalize_symbol(symbol) for symbol in symbols))
+        if statuses is None:
+            statuses = MultiStatus(symbols)
+        allowed = [symbol for symbol in symbols if symbol_allowed(symbol)]
+
+        found: Dict[str, Stock] = {}
+        for symbol in allowed:
+            stock = self.quote_cache.get(symbol)
+            if stock is not None:
+                found[symbol] = stock
+        uncached = [symbol for symbol in allowed if symbol not in found]
+        if uncached:
+            stored = await self.get_stocks_by_symbol(uncached)
+            for symbol, stock in stored.items():
+                self.quote_cache.set(symbol, stock, settings.QUOTE_CACHE_TTL)
+            found.update(stored)
+
+        failures: Dict[str, Exception] = {}
+        unstored = [symbol for symbol in allowed if symbol not in found]
+        if unstored and self.provider is not None:
+            limit = asyncio.Semaphore(QUOTE_BATCH_CONCURRENCY)
+
+            async def fetch(symbol: str) -> Optional[Stock]:
+                async with limit:
+                    return await self._fetch_stock(symbol)
+
+            results = await asyncio.gather(
+                *(fetch(symbol) for symbol in unstored), return_exceptions=True
+            )
+            for symbol, result in zip(unstored, results):
+                if isinstance(result, Exception):
+                    if not isinstance(result, ProviderBudgetExhausted):
+                        logger.warning("Quote for %s failed: %s", symbol, result)
+                    failures[symbol] = result
+                elif result is not None:
+                    self.quote_cache.set(symbol, result, settings.QUOTE_CACHE_TTL)
+                    found[symbol] = result
+
+        stocks, quotes, missing, not_found = {}, [], [], []
+        for symbol in symbols:
+            if symbol in found:
+                stocks[symbol] = found[symbol]
+                quotes.append(LiveQuote(symbol=symbol, price=found[symbol].price))
+                statuses.succeeded(symbol)
+                continue
+            missing.append(symbol)
+            if symbol in failures:
+                statuses.failed(symbol, errors.provider_error(failures[symbol]))
+            else:
+                not_found.append(symbol)
+                statuses.failed(symbol, errors.stock_not_found(symbol))
+        return QuoteBatch(
+            quotes=quotes,
+            stocks=stocks,
+            missing=missing,
+            not_found=not_found,
+            partial=any(
+                isinstance(failure, ProviderBudgetExhausted)
+                for failure in failures.values()
+            ),
+            results=statuses.results,
+        )
+
+    async def get_level1(self, symbol: str) -> Level1Quote:
+        """
+        Get the current best bid/ask for a stock, with the spread.
+
+        Raises:
+            ProviderNotSupportedError: If the provider has no level-1 data
+        """
+        if self.provider is None or not supports_level1(self.provider):
+            raise ProviderNotSupportedError("Level-1 quotes aren't supported")
+
+        symbol = normalize_symbol(symbol)
+        data = await self.provider.get_level1(symbol)
+        bid, ask = float(data["bid"]), float(data["ask"])
+        spread = ask - bid
+        mid = (bid + ask) / 2
+        return Level1Quote(
+            symbol=symbol,
+            bid=bid,
+            ask=ask,
+            bid_size=data["bid_size"],
+            ask_size=data["ask_size"],
+            spread=round(spread, 6),
+            spread_percent=round(spread / mid * 100, 4) if mid else 0.0,
+            timestamp=data.get("timestamp"),
+        )
+
+    def put_closes(self, symbol: str, closes: Dict[date, float]) -> None:
+        """Store daily closing prices for a symbol, replacing existing days."""
+        self.market_data.upsert_closes(normalize_symbol(symbol), closes)
+
+    def put_bars(self, bars: Iterable[MarketDataCreate]) -> None:
+        """Store daily OHLC bars, replacing existing days, and their closes."""
+        for bar in bars:
+            symbol = normalize_symbol(bar.symbol)
+            self.market_data.upsert_bar({**bar.model_dump(), "symbol": symbol})
+            self.put_closes(symbol, {bar.date.date(): bar.close_price})
+
+    @counts_as_query
+    async def get_market_data(
+        self, symbol: str, start: date, end: date
+    ) -> List[MarketData]:
+        """Daily OHLC bars for a symbol between start and end (inclusive), by day."""
+        bars = self.market_data.get_bars(normalize_symbol(symbol))
+        return [
+            MarketData(**bars[day]) for day in sorted(bars) if start <= day <= end
+        ]
+
+    @counts_as_query
+    async def get_closes(
+        self, symbol: str, start: date, end: Optional[date] = None
+    ) -> List[Tuple[date, float]]:
+        """Daily closes for a symbol between start and end (inclusive), by day."""
+        closes = self.market_data.get_closes(normalize_symbol(symbol))
+        return sorted(
+            (day, close)
+            for day, close in closes.items()
+            if day >= start and (end is None or day <= end)
+        )
+
+    async def previous_close(self, symbol: str, today: date) -> Optional[float]:
+        """
+        The close a price on today is measured against: the latest stored
+        daily close before today, or else the one the catalog's day change
+        implies. None if neither is known.
+        """
+        symbol = normalize_symbol(symbol)
+        closes = self.market_data.get_closes(symbol)
+        earlier = [day for day in closes if day < today]
+        if earlier:
+            return closes[max(earlier)]
+        record = self.stocks.get(symbol)
+        if record is None:
+            return None
+        return record["price"] - record["change"]
+
+    @counts_as_query
+    async def get_coverage(self, symbol: str) -> Optional[DataCoverage]:
+        """
+        The range of stored daily closes for a symbol and its gaps.
+
+        Gaps are trading days between the first and last close with no
+        close; weekends and market holidays aren't gaps.
+
+        Returns:
+            The coverage, or None if the symbol is neither tracked nor has
+            any stored closes
+        """
+        symbol = normalize_symbol(symbol)
+        closes = self.market_data.get_closes(symbol)
+        if not closes:
+            if self.stocks.get(symbol) is None:
+                return None
+            return DataCoverage(symbol=symbol, rows=0)
+
+        start, end = min(closes), max(closes)
+        return DataCoverage(
+            symbol=symbol,
+            start=start,
+            end=end,
+            rows=len(closes),
+            gaps=[day for day in trading_days(start, end) if day not in closes],
+        )
+
+    async def get_stock_history(
+        self, symbol: str, start: date, end: date
+    ) -> Optional[StockHistory]:
+        """
+        Stored daily closes for a stock between start and end (inclusive).
+
+        Returns:
+            The history, or None if the symbol is neither tracked nor has any
+            stored closes
+        """
+        symbol = normalize_symbol(symbol)
+        closes = await self.get_closes(symbol, start, end)
+        if not closes and supports_daily_closes(self.provider):
+            # Backfill from the provider and keep what it sends
+            days = (date.today() - start).days + 1
+            fetched = await self.provider.get_daily_closes(symbol, days)
+            if fetched:
+                self.put_closes(symbol, fetched)
+                closes = await self.get_closes(symbol, start, end)
+        if self.stocks.get(symbol) is None and not self.market_data.get_closes(symbol):
+            return None
+        return StockHistory(
+            symbol=symbol,
+            start=start,
+            end=end,
+            closes=[PricePoint(date=day, close=close) for day, close in closes],
+            bars=await self.get_market_data(symbol, start, end),
+        )
+
+    async def get_indicators(
+        self,
+        symbol: str,
+        types: Sequence[IndicatorType],
+        period: int,
+        start: date,
+        end: date,
+    ) -> Optional[IndicatorSeries]:
+        """
+        Indicators over the stock's daily closes between start and end, each
+        computed only if it's in types.
+
+        Returns:
+            The series, or None if the stock is unknown (as for history)
+
+        Raises:
+            ValueError: If period is under indicators.MIN_PERIOD or too long
+        """
+        history = await self.get_stock_history(symbol, start, end)
+        if history is None:
+            return None
+        closes = [point.close for point in history.closes]
+        computed = {}
+        for indicator in types:
+            values = INDICATORS[indicator](closes, period)
+            computed[indicator] = [None if v is None else round(v, 4) for v in values]
+        return IndicatorSeries(
+            symbol=history.symbol,
+            types=list(types),
+            period=period,
+            start=start,
+            end=end,
+            points=[
+                IndicatorPoint(
+                    date=point.date,
+                    close=point.close,
+                    values={
+                        indicator: values[i] for indicator, values in computed.items()
+                    },
+                )
+                for i, point in enumerate(history.closes)
+            ],
+        )
+
+
+# Service instance
 market_service = MarketService()
-portfolio_service = PortfolioService()
+
+
+def get_market_service() -> MarketService:
+    return market_service
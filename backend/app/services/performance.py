@@ -0,0 +1,355 @@
+"""
+Portfolio performance metrics for Quant-Dash.
+
+This module handles:
+1. Valuing a portfolio's holdings over a historical window
+2. Return, volatility, Ulcer Index, Sharpe, Calmar and Treynor ratios of
+   each window
+3. Side-by-side comparison of two windows
+4. Cash drag: what uninvested cash cost against a benchmark
+5. Rolling beta against a benchmark
+
+Days with a daily snapshot use the value it recorded. Days without one are
+reconstructed from the portfolio's current quantities at each day's close
+(there's no trade history yet), on days every holding has a close.
+"""
+
+import bisect
+from datetime import date
+from typing import Dict, List, Optional, Sequence, Tuple
+
+from app.core.config import settings
+from app.models.schemas import (
+    BetaPoint,
+    CashDrag,
+    PeriodComparison,
+    PeriodDeltas,
+    PeriodMetrics,
+    RollingBeta,
+)
+from app.services.analytics import calmar_ratio, treynor_ratio
+from app.services.market import MarketService, market_service
+from app.services.portfolio import PortfolioService, portfolio_service
+from app.services.snapshots import SnapshotService, snapshot_service
+from app.utils.returns import (
+    annualized_volatility,
+    beta,
+    cagr,
+    max_drawdown,
+    period_returns,
+    sharpe_ratio,
+    total_return,
+    ulcer_index,
+)
+from app.utils.symbols import normalize_symbol
+
+
+def period_metrics(
+    start: date,
+    end: date,
+    values: Sequence[float],
+    benchmark_beta: Optional[float] = None,
+) -> PeriodMetrics:
+    """
+    Metrics of one window's value series. The Treynor ratio is only given
+    with a nonzero beta.
+
+    Raises:
+        ValueError: With fewer than two values
+    """
+    if len(values) < 2:
+        raise ValueError(f"Need at least 2 days of prices between {start} and {end}")
+    sharpe = sharpe_ratio(values)
+    growth = cagr(values)
+    drawdown = max_drawdown(values)
+    return PeriodMetrics(
+        start=start,
+        end=end,
+        observations=len(values),
+        total_return=round(total_return(values), 6),
+        volatility=round(annualized_volatility(values), 6),
+        sharpe=round(sharpe, 4) if sharpe is not None else None,
+        cagr=round(growth, 6),
+        max_drawdown=round(drawdown, 6),
+        ulcer_index=round(ulcer_index(values), 4),
+        calmar=round(calmar_ratio(growth, drawdown), 4),
+        beta=round(benchmark_beta, 4) if benchmark_beta is not None else None,
+        treynor=(
+            round(treynor_ratio(growth, settings.RISK_FREE_RATE, benchmark_beta), 4)
+            if benchmark_beta
+            else None
+        ),
+    )
+
+
+def cash_drag_amount(
+    cash_history: Sequence[Tuple[date, float]],
+    closes: Sequence[Tuple[date, float]],
+    risk_free_rate: float,
+) -> Tuple[float, List[float]]:
+    """
+    Benchmark-invested value of the cash minus the cash earning risk_free_rate.
+
+    Both start at the balance on the first close. Each change in the balance
+    is added to both on the day it takes effect, so deposits count as
+    invested from then on and withdrawals come out of the invested value.
+
+    Returns:
+        (drag, the cash balance on each close day)
+    """
+    changes = [day for day, _ in cash_history]
+
+    def balance_on(day: date) -> float:
+        i = bisect.bisect_right(changes, day)
+        return cash_history[i - 1][1] if i else 0.0
+
+    balances = [balance_on(day) for day, _ in closes]
+    invested = cash = balances[0]
+    for i in range(1, len(closes)):
+        (prev_day, prev_close), (day, close) = closes[i - 1], closes[i]
+        invested *= close / prev_close
+        cash *= (1 + risk_free_rate) ** ((day - prev_day).days / 365)
+        invested += balances[i] - balances[i - 1]
+        cash += balances[i] - balances[i - 1]
+    return invested - cash, balances
+
+
+class PerformanceService:
+    """
+    Service for historical portfolio performance
+    """
+
+    def __init__(
+        self,
+        portfolios: PortfolioService,
+        market: MarketService,
+        snapshots: Optional[SnapshotService] = None,
+    ):
+        self.portfolios = portfolios
+        self.market = market
+        self.snapshots = snapshots
+
+    async def portfolio_values(
+        self, portfolio_id: int, start: date, end: date
+    ) -> Optional[List[float]]:
+        """
+        Daily value of the portfolio between start and end.
+
+        Snapshot values take precedence; other days value the current holdings
+        at that day's closes.
+
+        Returns:
+            Values in date order, or None if the portfolio doesn't exist
+        """
+        history = await self.daily_values(portfolio_id, start, end)
+        if history is None:
+            return None
+        return [value for _, value in history]
+
+    async def daily_values(
+        self, portfolio_id: int, start: date, end: date
+    ) -> Optional[List[Tuple[date, float]]]:
+        """
+        Like portfolio_values, but as (day, value) pairs in date order.
+        """
+        values = await self._values_by_day(portfolio_id, start, end)
+        if values is None:
+            return None
+        return sorted(values.items())
+
+    async def _values_by_day(
+        self, portfolio_id: int, start: date, end: date
+    ) -> Optional[Dict[date, float]]:
+        if await self.portfolios.get_portfolio_by_id(portfolio_id) is None:
+            return None
+        positions = await self.portfolios.get_positions(portfolio_id)
+
+        holdings = {}
+        for position in positions:
+            closes = await self.market.get_closes(position.stock_symbol, start, end)
+            holdings[position.stock_symbol] = (position.quantity, dict(closes))
+
+        values: Dict[date, float] = {}
+        if holdings:
+            days = set.intersection(*(set(closes) for _, closes in holdings.values()))
+            for day in days:
+                values[day] = sum(
+                    quantity * closes[day] for quantity, closes in holdings.values()
+                )
+        if self.snapshots is not None:
+            for snapshot in await self.snapshots.get_snapshots(
+                portfolio_id, start, end
+            ):
+                values[snapshot.date] = snapshot.total_value
+        return values
+
+    async def metrics(
+        self, portfolio_id: int, start: date, end: date
+    ) -> Optional[PeriodMetrics]:
+        """
+        Metrics of the portfolio's value between start and end, with beta
+        against the default benchmark on the days it has closes.
+
+        Returns:
+            The metrics, or None if the portfolio doesn't exist
+
+        Raises:
+            ValueError: With fewer than two days of prices
+        """
+        values = await self._values_by_day(portfolio_id, start, end)
+        if values is None:
+            return None
+        benchmark = normalize_symbol(settings.BENCHMARK_SYMBOL)
+        returns, benchmark_returns = self._aligned_returns(
+            values, dict(await self.market.get_closes(benchmark, start, end))
+        )
+        history = [value for _, value in sorted(values.items())]
+        return period_metrics(start, end, history, beta(returns, benchmark_returns))
+
+    async def compare_periods(
+        self,
+        portfolio_id: int,
+        a_start: date,
+        a_end: date,
+        b_start: date,
+        b_end: date,
+    ) -> Optional[PeriodComparison]:
+        """
+        Metrics for periods a and b, and a minus b.
+
+        Returns:
+            The comparison, or None if the portfolio doesn't exist
+
+        Raises:
+            ValueError: If a period has fewer than two days of prices
+        """
+        a_values = await self.portfolio_values(portfolio_id, a_start, a_end)
+        if a_values is None:
+            return None
+        b_values = await self.portfolio_values(portfolio_id, b_start, b_end)
+
+        a = period_metrics(a_start, a_end, a_values)
+        b = period_metrics(b_start, b_end, b_values)
+        return PeriodComparison(
+            portfolio_id=portfolio_id,
+            a=a,
+            b=b,
+            deltas=PeriodDeltas(
+                total_return=round(a.total_return - b.total_return, 6),
+                volatility=round(a.volatility - b.volatility, 6),
+                sharpe=(
+                    round(a.sharpe - b.sharpe, 4)
+                    if a.sharpe is not None and b.sharpe is not None
+                    else None
+                ),
+            ),
+        )
+
+    async def rolling_beta(
+        self,
+        portfolio_id: int,
+        start: date,
+        end: date,
+        window: int,
+        benchmark: Optional[str] = None,
+    ) -> Optional[RollingBeta]:
+        """
+        Beta against the benchmark over every `window` consecutive daily
+        returns, on days with both a portfolio value and a benchmark close.
+
+        Returns:
+            One point per window, dated by its last day, or None if the
+            portfolio doesn't exist
+
+        Raises:
+            ValueError: If there are fewer returns than the window
+        """
+        values = await self._values_by_day(portfolio_id, start, end)
+        if values is None:
+            return None
+        benchmark = normalize_symbol(benchmark or settings.BENCHMARK_SYMBOL)
+        closes = dict(await self.market.get_closes(benchmark, start, end))
+
+        days = sorted(set(values) & set(closes))
+        returns, benchmark_returns = self._aligned_returns(values, closes)
+        if window > len(returns):
+            raise ValueError(
+                f"A {window}-day window needs {window + 1} days with both portfolio "
+                f"values and {benchmark} prices between {start} and {end}; "
+                f"there are {len(days)}"
+            )
+
+        points = []
+        for i in range(window, len(returns) + 1):
+            value = beta(returns[i - window : i], benchmark_returns[i - window : i])
+            points.append(
+                BetaPoint(
+                    date=days[i], beta=round(value, 4) if value is not None else None
+                )
+            )
+        return RollingBeta(
+            portfolio_id=portfolio_id, benchmark=benchmark, window=window, points=points
+        )
+
+    @staticmethod
+    def _aligned_returns(
+        values: Dict[date, float], closes: Dict[date, float]
+    ) -> Tuple[List[float], List[float]]:
+        """Portfolio and benchmark returns between the days both have."""
+        days = sorted(set(values) & set(closes))
+        return (
+            period_returns([values[day] for day in days]),
+            period_returns([closes[day] for day in days]),
+        )
+
+    async def cash_drag(
+        self,
+        portfolio_id: int,
+        start: date,
+        end: date,
+        benchmark: Optional[str] = None,
+    ) -> Optional[CashDrag]:
+        """
+        What the portfolio's cash cost relative to holding the benchmark.
+
+        Returns:
+            The drag, or None if the portfolio doesn't exist
+
+        Raises:
+            ValueError: If the benchmark has fewer than two closes in the period
+        """
+        if await self.portfolios.get_portfolio_by_id(portfolio_id) is None:
+            return None
+        benchmark = normalize_symbol(benchmark or settings.BENCHMARK_SYMBOL)
+        closes = await self.market.get_closes(benchmark, start, end)
+        if len(closes) < 2:
+            raise ValueError(
+                f"Need at least 2 days of {benchmark} prices between {start} and {end}"
+            )
+
+        cash_history = await self.portfolios.get_cash_history(portfolio_id)
+        rate = settings.RISK_FREE_RATE
+        drag, balances = cash_drag_amount(cash_history, closes, rate)
+        first_day, last_day = closes[0][0], closes[-1][0]
+        return CashDrag(
+            portfolio_id=portfolio_id,
+            benchmark=benchmark,
+            start=first_day,
+            end=last_day,
+            average_cash_balance=round(sum(balances) / len(balances), 2),
+            benchmark_return=round(closes[-1][1] / closes[0][1] - 1, 6),
+            cash_return=round(
+                (1 + rate) ** ((last_day - first_day).days / 365) - 1, 6
+            ),
+            drag=round(drag, 2),
+        )
+
+
+# Service instance
+performance_service = PerformanceService(
+    portfolio_service, market_service, snapshot_service
+)
+
+
+def get_performance_service() -> PerformanceService:
+    return performance_service
@@ -0,0 +1,887 @@
+"""
+Portfolio service layer for Quant-Dash.
+
+This module handles:
+1. Portfolio and position storage, valued at current stock prices
+2. Position adjustments after corporate actions
+3. Position notes and tags, and filtering positions by tag
+4. Cash balance history
+5. Importing positions in bulk, all or nothing
+6. Applying ledger transactions to positions at average cost, and checking
+   positions against the ledger, rebuilding the ones that differ
+7. The portfolio audit log
+
+Portfolios and positions are kept in a PortfolioRepository (in memory
+unless another is passed in), seeded with a sample portfolio. In production,
+this would be backed by the database.
+"""
+
+from datetime import date, datetime
+from typing import Any, Dict, List, Optional, Sequence, Tuple
+
+from app.core.querybudget import counts_as_query
+from app.models.schemas import (
+    Portfolio,
+    Position,
+    PositionAdjustment,
+    PositionCheck,
+    PositionCheckStatus,
+    PositionRebuild,
+    TradeSide,
+    Transaction,
+    TransactionCreate,
+    normalize_tags,
+)
+from app.repositories.base import PortfolioRepository
+from app.repositories.memory import InMemoryPortfolioRepository
+from app.services.market import MarketService, market_service
+from app.utils.symbols import check_symbol, normalize_symbol
+
+# Share counts this close to a whole number are taken as whole
+SHARE_EPSILON = 1e-9
+# Average prices are stored to 4 decimal places
+PRICE_EPSILON = 1e-4
+
+
+def apply_execution(
+    quantity: float,
+    average_price: float,
+    transaction: TransactionCreate,
+) -> Tuple[float, float, float]:
+    """
+    A holding after one execution, at average cost.
+
+    Buys fold their price and fees into the average price. Sells leave it as
+    is and realize their proceeds, net of fees, over the average cost.
+
+    Returns:
+        (quantity, average price, realized gain)
+
+    Raises:
+        ValueError: If a sell is for more shares than held
+    """
+    shares, price = transaction.quantity, transaction.price
+    if transaction.side == TradeSide.BUY:
+        total = quantity + shares
+        cost = quantity * average_price + shares * price + transaction.fees
+        return total, cost / total, 0.0
+    if shares > quantity + SHARE_EPSILON:
+        raise ValueError(
+            f"Selling {shares:g} {transaction.symbol} when {quantity:g} are held"
+        )
+    realized = shares * (price - average_price) - transaction.fees
+    return max(quantity - shares, 0.0), average_price, realized
+
+
+def replay_ledger(
+    transactions: Sequence[TransactionCreate],
+) -> Tuple[Dict[str, Tuple[float, float]], float]:
+    """
+    What transactions add up to, replayed in execution order at average cost;
+    transactions executed at the same time keep the order they're given in.
+
+    Returns:
+        (quantity, average price) per symbol traded, closed ones included,
+        and the gain the sells realized
+
+    Raises:
+        ValueError: If a sell is for more shares than were held at the time
+    """
+    holdings: Dict[str, Tuple[float, float]] = {}
+    realized = 0.0
+    for transaction in sorted(transactions, key=lambda t: t.executed_at):
+        held = holdings.get(transaction.symbol, (0.0, 0.0))
+        quantity, average_price, gain = apply_execution(*held, transaction)
+        holdings[transaction.symbol] = (quantity, average_price)
+        realized += gain
+    return holdings, realized
+
+
+def matches_ledger(held: Tuple[float, float], ledger: Tuple[float, float]) -> bool:
+    """Whether a (quantity, average price) holding is what the ledger says."""
+    (quantity, average_price), (ledger_quantity, ledger_average) = held, ledger
+    if abs(quantity - ledger_quantity) > SHARE_EPSILON:
+        return False
+    return quantity == 0 or abs(average_price - ledger_average) <= PRICE_EPSILON
+
+
+def whole_shares(quantity: float, symbol: str) -> int:
+    """
+    Raises:
+        ValueError: If quantity isn't a whole number of shares
+    """
+    if abs(quantity - round(quantity)) > SHARE_EPSILON:
+        raise ValueError(
+            f"Positions hold whole shares; {symbol} would be left with {quantity:g}"
+        )
+    return int(round(quantity))
+
+
+class SymbolHeldError(ValueError):
+    """A position would move to a symbol another position already holds."""
+
+    def __init__(self, symbol: str, position_id: int):
+        self.symbol = symbol
+        self.position_id = position_id
+        super().__init__(f"Position {position_id} already holds {symbol}")
+
+
+class PortfolioService:
+    """
+    Service for handling portfolio operations
+    """
+
+    UPDATABLE_FIELDS = (
+        "stock_symbol",
+        "quantity",
+        "average_price",
+        "target_weight",
+        "notes",
+        "tags",
+    )
+
+    def __init__(
+        self,
+        market: Optional[MarketService] = None,
+        portfolios: Optional[PortfolioRepository] = None,
+    ):
+        self.market = market
+        self.portfolios = portfolios or InMemoryPortfolioRepository()
+        self.reset()
+
+    def reset(self) -> None:
+        """Drop all stored data and restore the sample portfolio."""
+        self.portfolios.clear()
+        self._cash_balances: Dict[int, Dict[date, float]] = {}  # id -> day -> cash
+        self._audit_log: List[Dict[str, Any]] = []
+        self._seed_sample_data()
+
+    def _seed_sample_data(self) -> None:
+        """Load the sample portfolio used by the dashboard during development."""
+        self.portfolios.upsert(
+            {
+                "id": 1,
+                "user_id": 1,
+                "created_at": datetime(2025, 7, 1, 10, 0, 0),
+                "updated_at": datetime(2025, 8, 5, 10, 30, 0),
+            }
+        )
+        for symbol, quantity, average_price, current_value, target_weight in [
+            ("AAPL", 10, 145.00, 1502.50, 0.20),
+            ("GOOGL", 2, 2700.00, 5501.60, 0.60),
+            ("MSFT", 5, 300.00, 1552.50, 0.20),
+        ]:
+            self._insert_position(
+                1, symbol, quantity, average_price, current_value, target_weight
+            )
+
+    def create_portfolio(self, user_id: int) -> int:
+        """Create an empty portfolio for a user and return its id"""
+        now = datetime.utcnow()
+        portfolio = self.portfolios.upsert(
+            {"user_id": user_id, "created_at": now, "updated_at": now}
+        )
+        return portfolio["id"]
+
+    def _touch(self, portfolio_id: int) -> None:
+        portfolio = self.portfolios.get(portfolio_id)
+        portfolio["updated_at"] = datetime.utcnow()
+        self.portfolios.upsert(portfolio)
+
+    def _insert_position(
+        self,
+        portfolio_id: int,
+        symbol: str,
+        quantity: int,
+        average_price: float,
+        current_value: float,
+        target_weight: Optional[float] = None,
+        notes: Optional[str] = None,
+        tags: Optional[List[str]] = None,
+    ) -> Dict[str, Any]:
+        record = {
+            "portfolio_id": portfolio_id,
+            "stock_symbol": symbol,
+            "quantity": quantity,
+            "average_price": average_price,
+            "current_value": current_value,
+            "target_weight": target_weight,
+            "notes": notes,
+            "tags": normalize_tags(tags),
+        }
+        return self.portfolios.upsert_position(record)
+
+    @staticmethod
+    def _to_position(record: Dict[str, Any]) -> Position:
+        cost_basis = record["quantity"] * record["average_price"]
+        total_gain = record["current_value"] - cost_basis
+        return Position(**record, total_gain=round(total_gain, 2))
+
+    @staticmethod
+    def _valued(position: Position, price: float) -> Position:
+        return position.model_copy(
+            update={
+                "current_value": round(position.quantity * price, 2),
+                "total_gain": round(
+                    (price - position.average_price) * position.quantity, 2
+                ),
+            }
+        )
+
+    @counts_as_query
+    async def get_portfolio(self, user_id: int) -> Optional[Portfolio]:
+        """Get user's portfolio"""
+        portfolio = next(iter(self.portfolios.list(user_id)), None)
+        if portfolio is None:
+            return None
+        return await self._build_portfolio(portfolio)
+
+    def ensure_portfolio(self, user_id: int) -> int:
+        """The id of the user's portfolio, creating an empty one if needed"""
+        for portfolio in self.portfolios.list(user_id):
+            return portfolio["id"]
+        return self.create_portfolio(user_id)
+
+    @counts_as_query
+    async def owner_of(self, portfolio_id: int) -> Optional[int]:
+        """The id of the user who owns a portfolio, or None if it doesn't exist"""
+        portfolio = self.portfolios.get(portfolio_id)
+        return portfolio["user_id"] if portfolio else None
+
+    @counts_as_query
+    async def list_portfolio_ids(self) -> List[int]:
+        """Ids of every portfolio, in creation order"""
+        return [portfolio["id"] for portfolio in self.portfolios.list()]
+
+    @counts_as_query
+    async def get_portfolio_by_id(self, portfolio_id: int) -> Optional[Portfolio]:
+        """Get a portfolio by its id"""
+        portfolio = self.portfolios.get(portfolio_id)
+        if portfolio is None:
+            return None
+        return await self._build_portfolio(portfolio)
+
+    async def _build_portfolio(self, portfolio: Dict[str, Any]) -> Portfolio:
+        """
+        A portfolio with totals summed from its positions.
+
+        With a market service, every position is revalued at its stock's
+        stored price, read right after the positions with nothing able to
+        write in between. A position whose stock isn't stored is kept, worth
+        0. Without one, positions keep their last stored value.
+        """
+        positions = await self.get_positions(portfolio["id"])
+        if self.market is not None:
+            prices = await self.market.get_prices(p.stock_symbol for p in positions)
+            positions = [
+                self._valued(position, prices.get(position.stock_symbol, 0.0))
+                for position in positions
+            ]
+        unrealized = sum(p.total_gain for p in positions)
+        realized = portfolio.get("realized_gain", 0.0)
+        return Portfolio(
+            **{
+                **portfolio,
+                "total_value": round(sum(p.current_value for p in positions), 2),
+                "total_gain": round(unrealized + realized, 2),
+                "unrealized_gain": round(unrealized, 2),
+                "realized_gain": round(realized, 2),
+                "positions": positions,
+            }
+        )
+
+    @counts_as_query
+    async def get_positions(
+        self, portfolio_id: int, tag: Optional[str] = None
+    ) -> List[Position]:
+        """Get all positions in a portfolio, optionally only those with a tag"""
+        wanted = normalize_tags([tag]) if tag is not None else []
+        return [
+            self._to_position(record)
+            for record in self.portfolios.list_positions(portfolio_id)
+            if set(wanted) <= set(record["tags"])
+        ]
+
+    async def create_position(self, position_data: dict) -> Position:
+        """
+        Create a new position, valued at cost unless current_value is given.
+
+        Buying a symbol the portfolio already holds adds to that position
+        instead: quantities and values add up and the average price becomes
+        the quantity-weighted average of both.
+
+        Raises:
+            SymbolRestrictedError: If the symbol is blocked or not allowlisted
+        """
+        portfolio_id = position_data["portfolio_id"]
+        symbol = check_symbol(position_data["stock_symbol"])
+        quantity = position_data["quantity"]
+        average_price = position_data["average_price"]
+        current_value = position_data.get(
+            "current_value", round(quantity * average_price, 2)
+        )
+
+        existing = next(
+            (
+                record
+                for record in self.portfolios.list_positions(portfolio_id)
+                if record["stock_symbol"] == symbol
+            ),
+            None,
+        )
+        if existing is not None:
+            return self._merge_position(existing, position_data, current_value)
+
+        record = self._insert_position(
+            portfolio_id,
+            symbol,
+            quantity,
+            average_price,
+            current_value,
+            position_data.get("target_weight"),
+            position_data.get("notes"),
+            position_data.get("tags"),
+        )
+        return self._to_position(record)
+
+    def _merge_position(
+        self, record: Dict[str, Any], position_data: dict, current_value: float
+    ) -> Position:
+        before = {
+            "quantity": record["quantity"],
+            "average_price": record["average_price"],
+        }
+        quantity = record["quantity"] + position_data["quantity"]
+        cost = (
+            record["quantity"] * record["average_price"]
+            + position_data["quantity"] * position_data["average_price"]
+        )
+        record["quantity"] = quantity
+        record["average_price"] = round(cost / quantity, 4) if quantity else 0.0
+        record["current_value"] = round(record["current_value"] + current_value, 2)
+        added_tags = position_data.get("tags") or []
+        record["tags"] = normalize_tags(record["tags"] + added_tags)
+        for field in ("target_weight", "notes"):
+            if record[field] is None:
+                record[field] = position_data.get(field)
+        self.portfolios.upsert_position(record)
+
+        portfolio_id = record["portfolio_id"]
+        self._touch(portfolio_id)
+        self._record_audit(
+            portfolio_id,
+            "position.merged",
+            {
+                "position_id": record["id"],
+                "before": before,
+                "after": {
+                    "quantity": record["quantity"],
+                    "average_price": record["average_price"],
+                },
+            },
+        )
+        return self._to_position(record)
+
+    async def update_position(
+        self, portfolio_id: int, position_id: int, position_data: dict
+    ) -> Optional[Position]:
+        """
+        Update the given fields of a position; fields not in position_data
+        are left alone.
+
+        Returns:
+            The updated position, or None if it doesn't exist in the portfolio
+
+        Raises:
+            SymbolRestrictedError: If moved to a blocked or unlisted symbol
+            SymbolHeldError: If moved to a symbol another position holds, as
+                a portfolio holds each symbol in one position
+        """
+        record = self.portfolios.get_position(position_id)
+        if record is None or record["portfolio_id"] != portfolio_id:
+            return None
+
+        changes = {
+            field: value
+            for field, value in position_data.items()
+            if field in self.UPDATABLE_FIELDS
+        }
+        if "stock_symbol" in changes:
+            changes["stock_symbol"] = check_symbol(changes["stock_symbol"])
+            held = self._position_in(portfolio_id, changes["stock_symbol"])
+            if held is not None and held["id"] != position_id:
+                raise SymbolHeldError(changes["stock_symbol"], held["id"])
+        if "tags" in changes:
+            changes["tags"] = normalize_tags(changes["tags"])
+        record.update(changes)
+        self.portfolios.upsert_position(record)
+
+        self._touch(portfolio_id)
+        self._record_audit(
+            portfolio_id,
+            "position.updated",
+            {"position_id": position_id, "fields": sorted(changes)},
+        )
+        return self._to_position(record)
+
+    async def adjust_position(
+        self, portfolio_id: int, position_id: int, adjustment: PositionAdjustment
+    ) -> Optional[Position]:
+        """
+        Correct a position's quantity/average price after a corporate action.
+
+        A split ratio of 2.0 (2-for-1) doubles the quantity and halves the
+        average price, leaving cost basis unchanged. An explicit average price
+        overrides the recorded one without touching the quantity.
+
+        Returns:
+            The adjusted position, or None if it doesn't exist in the portfolio
+
+        Raises:
+            ValueError: If the split would leave a fractional share count
+        """
+        record = self.portfolios.get_position(position_id)
+        if record is None or record["portfolio_id"] != portfolio_id:
+            return None
+
+        before = {
+            "quantity": record["quantity"],
+            "average_price": record["average_price"],
+        }
+
+        if adjustment.split_ratio is not None:
+            new_quantity = record["quantity"] * adjustment.split_ratio
+            if abs(new_quantity - round(new_quantity)) > 1e-9:
+                raise ValueError(
+                    f"Split ratio {adjustment.split_ratio} would leave a fractional "
+                    f"share count ({new_quantity})"
+                )
+            record["quantity"] = int(round(new_quantity))
+            record["average_price"] = record["average_price"] / adjustment.split_ratio
+        else:
+            record["average_price"] = adjustment.average_price
+        self.portfolios.upsert_position(record)
+
+        self._touch(portfolio_id)
+        self._record_audit(
+            portfolio_id,
+            "position.adjusted",
+            {
+                "position_id": position_id,
+                "symbol": record["stock_symbol"],
+                "split_ratio": adjustment.split_ratio,
+                "reason": adjustment.reason,
+                "before": before,
+                "after": {
+                    "quantity": record["quantity"],
+                    "average_price": record["average_price"],
+                },
+            },
+        )
+        return self._to_position(record)
+
+    async def revalue(self, symbol: str, price: float) -> int:
+        """
+        Mark every position in a symbol to a new price.
+
+        Returns:
+            Number of positions revalued
+        """
+        revalued = 0
+        for record in self.portfolios.list_positions():
+            if record["stock_symbol"] == normalize_symbol(symbol):
+                record["current_value"] = round(record["quantity"] * price, 2)
+                self.portfolios.upsert_position(record)
+                revalued += 1
+        return revalued
+
+    def _position_in(self, portfolio_id: int, symbol: str) -> Optional[Dict[str, Any]]:
+        return next(
+            (
+                record
+                for record in self.portfolios.list_positions(portfolio_id)
+                if record["stock_symbol"] == symbol
+            ),
+            None,
+        )
+
+    def tracks_ledger(
+        self, portfolio_id: int, symbol: str, history: Sequence[Transaction]
+    ) -> bool:
+        """
+        Whether the position in symbol (none counts as empty) holds what the
+        ledger's transactions in it add up to, rather than shares entered
+        directly. A ledger that sells shares it never bought doesn't.
+        """
+        record = self._position_in(portfolio_id, symbol)
+        held = (record["quantity"], record["average_price"]) if record else (0, 0.0)
+        try:
+            holdings = replay_ledger(history)[0]
+        except ValueError:
+            return False
+        return matches_ledger(held, holdings.get(symbol, (0.0, 0.0)))
+
+    async def apply_transaction(
+        self,
+        portfolio_id: int,
+        transaction: TransactionCreate,
+        history: Sequence[Transaction] = (),
+    ) -> Optional[Position]:
+        """
+        Update the position in a transaction's (normalized) symbol.
+
+        history is the ledger's transactions in the symbol so far, oldest
+        first. While the position holds what they add up to, it becomes what
+        they add up to with this transaction, replayed in execution order, so
+        a back-dated sell realizes its gain over the average cost at the time
+        it was executed, as a rebuild would. A position the ledger doesn't
+        account for (shares entered directly) takes the transaction at its
+        current average cost instead.
+
+        Buys create the position if needed, and a position left empty is
+        deleted. Sells add their gain to the portfolio's realized gain.
+        Nothing changes if the transaction is rejected.
+
+        Returns:
+            The position, or None if a sell closed it
+
+        Raises:
+            ValueError: If a sell is for more shares than the position holds,
+                or the position would be left with a fractional share count
+        """
+        symbol = transaction.symbol
+        record = self._position_in(portfolio_id, symbol)
+        held = (record["quantity"], record["average_price"]) if record else (0, 0.0)
+        if self.tracks_ledger(portfolio_id, symbol, history):
+            realized_before = replay_ledger(history)[1]
+            after, realized_after = replay_ledger([*history, transaction])
+            quantity, average_price = after[symbol]
+            realized = realized_after - realized_before
+        else:
+            quantity, average_price, realized = apply_execution(*held, transaction)
+        quantity = whole_shares(quantity, symbol)
+        average_price = round(average_price, 4)
+
+        if record is None:
+            record = self._insert_position(
+                portfolio_id,
+                symbol,
+                quantity,
+                average_price,
+                round(quantity * transaction.price, 2),
+            )
+        elif quantity:
+            price = record["current_value"] / record["quantity"]
+            record["quantity"] = quantity
+            record["average_price"] = average_price
+            record["current_value"] = round(quantity * price, 2)
+            self.portfolios.upsert_position(record)
+        else:
+            self.portfolios.delete_position(record["id"])
+
+        portfolio = self.portfolios.get(portfolio_id)
+        portfolio["realized_gain"] = portfolio.get("realized_gain", 0.0) + realized
+        portfolio["updated_at"] = datetime.utcnow()
+        self.portfolios.upsert(portfolio)
+        self._record_audit(
+            portfolio_id,
+            "position.traded",
+            {
+                "position_id": record["id"],
+                "side": transaction.side.value,
+                "before": {"quantity": held[0], "average_price": held[1]},
+                "after": {"quantity": quantity, "average_price": average_price},
+                "realized_gain": round(realized, 2),
+            },
+        )
+        return self._to_position(record) if quantity else None
+
+    async def rebuild_positions(
+        self,
+        portfolio_id: int,
+        transactions: Sequence[Transaction],
+        apply: bool = False,
+    ) -> Optional[PositionRebuild]:
+        """
+        Check the positions against what the transactions add up to, replayed
+        in execution order at average cost, and with apply, make them agree.
+
+        Only symbols the ledger accounts for are checked: positions without
+        any transactions (entered directly or seeded), or whose transactions
+        sell shares they never bought, are listed as untracked and never
+        changed. Rebuilt positions keep their target weight, notes, tags and
+        price per share. The realized gain becomes the ledger's, unless it
+        sells shares entered directly, whose cost it doesn't know.
+
+        Returns:
+            Each position next to the ledger's holding, as they were before
+            any rebuild, or None if the portfolio doesn't exist
+
+        Raises:
+            ValueError: If a holding ends with a fractional share count;
+                nothing changes
+        """
+        portfolio = self.portfolios.get(portfolio_id)
+        if portfolio is None:
+            return None
+        holdings: Dict[str, Tuple[float, float]] = {}
+        realized = 0.0
+        by_symbol: Dict[str, List[Transaction]] = {}
+        for transaction in transactions:
+            by_symbol.setdefault(transaction.symbol, []).append(transaction)
+        for symbol_transactions in by_symbol.values():
+            try:
+                symbol_holdings, symbol_realized = replay_ledger(symbol_transactions)
+            except ValueError:
+                continue  # Sells shares entered directly
+            holdings.update(symbol_holdings)
+            realized += symbol_realized
+        complete = len(holdings) == len(by_symbol)
+        ledger = {
+            symbol: (whole_shares(quantity, symbol), average_price)
+            for symbol, (quantity, average_price) in holdings.items()
+        }
+        existing = {
+            record["stock_symbol"]: record
+            for record in self.portfolios.list_positions(portfolio_id)
+        }
+
+        checks = []
+        for symbol in sorted(set(existing) | set(ledger)):
+            record = existing.get(symbol)
+            held = (record["quantity"], record["average_price"]) if record else (0, 0.0)
+            if symbol not in ledger:
+                status = PositionCheckStatus.UNTRACKED
+            elif matches_ledger(held, ledger[symbol]):
+                if record is None:
+                    continue  # Closed in the ledger, and no position
+                status = PositionCheckStatus.MATCHES
+            else:
+                status = PositionCheckStatus.DIFFERS
+            ledger_quantity, ledger_average = ledger.get(symbol, (None, None))
+            checks.append(
+                PositionCheck(
+                    symbol=symbol,
+                    quantity=held[0],
+                    average_price=held[1] if record else None,
+                    ledger_quantity=ledger_quantity,
+                    ledger_average_price=(
+                        round(ledger_average, 4) if ledger_quantity else None
+                    ),
+                    status=status,
+                )
+            )
+        recorded_gain = portfolio.get("realized_gain", 0.0)
+        differs = [c for c in checks if c.status == PositionCheckStatus.DIFFERS]
+
+        if apply:
+            for check in differs:
+                self._rebuild_position(
+                    portfolio_id, existing.get(check.symbol), check.symbol, ledger
+                )
+            if complete:
+                portfolio["realized_gain"] = realized
+            portfolio["updated_at"] = datetime.utcnow()
+            self.portfolios.upsert(portfolio)
+            self._record_audit(
+                portfolio_id,
+                "positions.rebuilt",
+                {
+                    "transactions": len(transactions),
+                    "rebuilt": [check.symbol for check in differs],
+                },
+            )
+
+        return PositionRebuild(
+            portfolio_id=portfolio_id,
+            applied=apply,
+            consistent=not differs
+            and (not complete or abs(recorded_gain - realized) < 0.005),
+            realized_gain=round(recorded_gain, 2),
+            ledger_realized_gain=round(realized, 2),
+            positions=checks,
+        )
+
+    def _rebuild_position(
+        self,
+        portfolio_id: int,
+        record: Optional[Dict[str, Any]],
+        symbol: str,
+        ledger: Dict[str, Tuple[int, float]],
+    ) -> None:
+        quantity, average_price = ledger[symbol]
+        if record is None:
+            self._insert_position(
+                portfolio_id,
+                symbol,
+                quantity,
+                round(average_price, 4),
+                round(quantity * average_price, 2),
+            )
+        elif quantity:
+            price = average_price
+            if record["quantity"]:
+                price = record["current_value"] / record["quantity"]
+            record["quantity"] = quantity
+            record["average_price"] = round(average_price, 4)
+            record["current_value"] = round(quantity * price, 2)
+            self.portfolios.upsert_position(record)
+        else:
+            self.portfolios.delete_position(record["id"])
+
+    async def set_cash_balance(
+        self, portfolio_id: int, balance: float, as_of: date
+    ) -> bool:
+        """
+        Record the portfolio's uninvested cash from a day onwards.
+
+        Returns:
+            False if the portfolio doesn't exist
+        """
+        if self.portfolios.get(portfolio_id) is None:
+            return False
+        balances = self._cash_balances.setdefault(portfolio_id, {})
+        before = balances.get(as_of)
+        balances[as_of] = balance
+        self._touch(portfolio_id)
+        self._record_audit(
+            portfolio_id,
+            "cash.updated",
+            {"as_of": as_of.isoformat(), "before": before, "after": balance},
+        )
+        return True
+
+    @counts_as_query
+    async def get_cash_history(self, portfolio_id: int) -> List[Tuple[date, float]]:
+        """
+        Cash balance changes by day, oldest first.
+
+        A balance holds until the next change; before the first one it's 0.
+        """
+        return sorted(self._cash_balances.get(portfolio_id, {}).items())
+
+    async def delete_position(self, portfolio_id: int, position_id: int) -> bool:
+        """
+        Delete a position.
+
+        Returns:
+            False if the position doesn't exist in the portfolio
+        """
+        record = self.portfolios.get_position(position_id)
+        if record is None or record["portfolio_id"] != portfolio_id:
+            return False
+
+        self.portfolios.delete_position(position_id)
+        self._touch(portfolio_id)
+        self._record_audit(
+            portfolio_id,
+            "position.deleted",
+            {"position_id": position_id, "stock_symbol": record["stock_symbol"]},
+        )
+        return True
+
+    async def import_positions(
+        self,
+        portfolio_id: int,
+        rows: Sequence[Tuple[str, int, float]],
+        replace: bool = False,
+    ) -> Tuple[int, int, int]:
+        """
+        Apply (symbol, quantity, average_price) rows as a single change.
+
+        With replace, every existing position is deleted first. Otherwise a
+        symbol already held takes the row's quantity and average price,
+        keeping its price per share, tags and notes, and positions not in the
+        rows are left alone. New positions are valued at cost.
+
+        If any row fails, the positions are restored as they were, as a
+        database transaction would be rolled back.
+
+        Returns:
+            How many positions were created, updated and removed
+
+        Raises:
+            SymbolRestrictedError: If a symbol is blocked or not allowlisted
+        """
+        before = self.portfolios.list_positions(portfolio_id)
+        audit_length = len(self._audit_log)
+        try:
+            return self._apply_import(portfolio_id, rows, replace, before)
+        except Exception:
+            for record in self.portfolios.list_positions(portfolio_id):
+                self.portfolios.delete_position(record["id"])
+            for record in before:
+                self.portfolios.upsert_position(record)
+            del self._audit_log[audit_length:]
+            raise
+
+    def _apply_import(
+        self,
+        portfolio_id: int,
+        rows: Sequence[Tuple[str, int, float]],
+        replace: bool,
+        existing: List[Dict[str, Any]],
+    ) -> Tuple[int, int, int]:
+        held = {record["stock_symbol"]: record for record in existing}
+        removed = 0
+        if replace:
+            for record in existing:
+                self.portfolios.delete_position(record["id"])
+            removed = len(existing)
+            held = {}
+
+        created = updated = 0
+        for symbol, quantity, average_price in rows:
+            symbol = check_symbol(symbol)
+            record = held.get(symbol)
+            if record is None:
+                cost = round(quantity * average_price, 2)
+                self._insert_position(
+                    portfolio_id, symbol, quantity, average_price, cost
+                )
+                created += 1
+                continue
+            price = record["current_value"] / record["quantity"]
+            record["quantity"] = quantity
+            record["average_price"] = average_price
+            record["current_value"] = round(quantity * price, 2)
+            self.portfolios.upsert_position(record)
+            updated += 1
+
+        self._touch(portfolio_id)
+        self._record_audit(
+            portfolio_id,
+            "positions.imported",
+            {
+                "replace": replace,
+                "created": created,
+                "updated": updated,
+                "removed": removed,
+            },
+        )
+        return created, updated, removed
+
+    def _record_audit(
+        self, portfolio_id: int, action: str, details: Dict[str, Any]
+    ) -> None:
+        self._audit_log.append(
+            {
+                "id": len(self._audit_log) + 1,
+                "portfolio_id": portfolio_id,
+                "action": action,
+                "details": details,
+                "created_at": datetime.utcnow(),
+            }
+        )
+
+    async def get_audit_log(self, portfolio_id: int) -> List[Dict[str, Any]]:
+        """Get audit entries for a portfolio, oldest first"""
+        return [e for e in self._audit_log if e["portfolio_id"] == portfolio_id]
+
+
+# Service instance
+portfolio_service = PortfolioService(market_service)
+
+
+def get_portfolio_service() -> PortfolioService:
+    return portfolio_service
@@ -0,0 +1,175 @@
+"""
+Position import service for Quant-Dash.
+
+This module handles:
+1. Reading a CSV of positions (symbol, quantity, average_price)
+2. Checking every row and reporting each bad one with its line number
+3. Applying the file to a portfolio in one change, merged or replacing
+
+A file with any bad row imports nothing, so fixing the reported rows and
+uploading again gives a predictable result. Headers are matched ignoring
+case, with spaces read as underscores, and other columns are ignored, so
+the positions CSV export ("Average price", ...) can be imported back.
+"""
+
+import csv
+import io
+import math
+from typing import Dict, List, Sequence, Tuple
+
+from app.models.schemas import ImportMode, ImportRowError, PositionImportResult
+from app.services.portfolio import PortfolioService, portfolio_service
+from app.utils.symbols import check_symbol, parse_symbol
+
+IMPORT_COLUMNS = ("symbol", "quantity", "average_price")
+
+ImportRow = Tuple[str, int, float]  # symbol, quantity, average_price
+
+
+class PositionImportError(ValueError):
+    """The file has bad rows; errors lists each one."""
+
+    def __init__(self, errors: List[ImportRowError]):
+        self.errors = errors
+        super().__init__(f"{len(errors)} invalid row(s)")
+
+
+def _column_name(cell: str) -> str:
+    return cell.strip().lower().replace(" ", "_")
+
+
+def _number(text: str, column: str) -> float:
+    try:
+        value = float(text.replace(",", ""))  # Allow "1,000"
+    except ValueError:
+        raise ValueError(f"{column} '{text}' isn't a number")
+    if not math.isfinite(value):
+        raise ValueError(f"{column} '{text}' isn't a number")
+    return value
+
+
+def _parse_row(record: Sequence[str], index: Dict[str, int]) -> ImportRow:
+    cells = {
+        column: record[i].strip() if i < len(record) else ""
+        for column, i in index.items()
+    }
+    for column in IMPORT_COLUMNS:
+        if not cells[column]:
+            raise ValueError(f"{column} is empty")
+
+    symbol = check_symbol(parse_symbol(cells["symbol"]))
+    quantity = _number(cells["quantity"], "quantity")
+    if quantity <= 0 or not quantity.is_integer():
+        raise ValueError(f"quantity must be a whole number above 0, got {quantity:g}")
+    average_price = _number(cells["average_price"], "average_price")
+    if average_price < 0:
+        raise ValueError(f"average_price must not be negative, got {average_price:g}")
+    return symbol, int(quantity), average_price
+
+
+def parse_positions_csv(
+    content: bytes,
+) -> Tuple[List[ImportRow], List[ImportRowError]]:
+    """
+    Read the rows of a positions CSV, skipping blank lines.
+
+    Returns:
+        The valid rows in file order, and an error for each invalid one:
+        unreadable values, restricted symbols, or a symbol already on an
+        earlier row. A bad header is a single error on row 1.
+    """
+    try:
+        text = content.decode("utf-8-sig")  # Spreadsheets may add a BOM
+    except UnicodeDecodeError:
+        return [], [ImportRowError(row=1, reason="The file isn't UTF-8 text")]
+
+    reader = csv.reader(io.StringIO(text))
+    try:
+        header = next(reader, None)
+    except csv.Error as e:
+        return [], [ImportRowError(row=1, reason=f"Unreadable CSV: {e}")]
+    if header is None:
+        return [], [ImportRowError(row=1, reason="The file is empty")]
+
+    names = [_column_name(cell) for cell in header]
+    problems = []
+    for column in IMPORT_COLUMNS:
+        count = names.count(column)
+        if count == 0:
+            problems.append(f"missing column {column}")
+        elif count > 1:
+            problems.append(f"column {column} appears {count} times")
+    if problems:
+        return [], [ImportRowError(row=1, reason="; ".join(problems))]
+    index = {column: names.index(column) for column in IMPORT_COLUMNS}
+
+    rows: List[ImportRow] = []
+    errors: List[ImportRowError] = []
+    first_rows: Dict[str, int] = {}  # symbol -> row it first appeared on
+    number = 1
+    try:
+        for number, record in enumerate(reader, start=2):
+            if not any(cell.strip() for cell in record):
+                continue
+            try:
+                row = _parse_row(record, index)
+            except ValueError as e:
+                errors.append(ImportRowError(row=number, reason=str(e)))
+                continue
+            symbol = row[0]
+            if symbol in first_rows:
+                reason = f"{symbol} is already on row {first_rows[symbol]}"
+                errors.append(ImportRowError(row=number, reason=reason))
+                continue
+            first_rows[symbol] = number
+            rows.append(row)
+    except csv.Error as e:
+        errors.append(ImportRowError(row=number + 1, reason=f"Unreadable CSV: {e}"))
+    return rows, errors
+
+
+class PositionImportService:
+    """
+    Service for bulk-loading positions from a file
+    """
+
+    def __init__(self, portfolios: PortfolioService):
+        self.portfolios = portfolios
+
+    async def import_csv(
+        self, portfolio_id: int, content: bytes, mode: ImportMode
+    ) -> PositionImportResult:
+        """
+        Import a positions CSV into a portfolio.
+
+        Merging updates the symbols in the file and keeps the others;
+        replacing deletes every position first.
+
+        Raises:
+            PositionImportError: If any row is invalid, or there are none
+        """
+        rows, errors = parse_positions_csv(content)
+        if not rows and not errors:
+            errors = [ImportRowError(row=2, reason="The file has no positions")]
+        if errors:
+            raise PositionImportError(errors)
+
+        created, updated, removed = await self.portfolios.import_positions(
+            portfolio_id, rows, replace=mode == ImportMode.REPLACE
+        )
+        return PositionImportResult(
+            portfolio_id=portfolio_id,
+            mode=mode,
+            rows=len(rows),
+            created=created,
+            updated=updated,
+            removed=removed,
+        )
+
+
+# Service instance
+position_import_service = PositionImportService(portfolio_service)
+
+
+def get_position_import_service() -> PositionImportService:
+    return position_import_service
@@ -0,0 +1,162 @@
+"""
+Rebalancing suggestions for Quant-Dash, with their tax impact.
+
+This module handles:
+1. Suggesting whole-share trades that bring positions to their target weights
+2. Matching each suggested sell against the ledger's open lots, oldest first
+3. Classifying the gains those sells would realize as short or long term
+
+Positions without a target weight are left alone. Trades are rounded toward
+zero so a position never overshoots its target. Shares a sell needs beyond
+the ledger's lots (positions are maintained separately from the ledger) are
+costed at the position's average price, and reported apart since their
+holding period isn't known.
+"""
+
+from collections import deque
+from datetime import date, datetime
+from typing import Callable, Deque, List, Optional, Tuple
+
+from app.models.schemas import (
+    HoldingTerm,
+    Position,
+    RealizedLot,
+    RebalancePlan,
+    RebalanceTaxImpact,
+    RebalanceTrade,
+    TradeSide,
+)
+from app.services.ledger import QUANTITY_EPSILON, LedgerService, ledger_service
+from app.services.portfolio import PortfolioService, portfolio_service
+from app.services.trades import Lot, open_lots, take_from_lots
+
+
+def holding_term(opened_on: date, sold_on: date) -> HoldingTerm:
+    """Long term when sold after the first anniversary of the purchase."""
+    try:
+        anniversary = opened_on.replace(year=opened_on.year + 1)
+    except ValueError:  # Bought on 29 February
+        anniversary = date(opened_on.year + 1, 2, 28)
+    return HoldingTerm.LONG if sold_on > anniversary else HoldingTerm.SHORT
+
+
+def realize(
+    lots: Deque[Lot], quantity: float, price: float, sold_on: date
+) -> Tuple[List[RealizedLot], float]:
+    """
+    The lots selling quantity shares at price would close, and their gains.
+
+    Returns:
+        The closed lots, oldest first, and the shares they couldn't cover
+    """
+    taken, unmatched = take_from_lots(lots, quantity)
+    realized = []
+    for lot, used in taken:
+        cost_basis = used * lot.unit_cost
+        proceeds = used * price
+        realized.append(
+            RealizedLot(
+                opened_at=lot.opened_at,
+                quantity=used,
+                cost_basis=round(cost_basis, 2),
+                proceeds=round(proceeds, 2),
+                gain=round(proceeds - cost_basis, 2),
+                term=holding_term(lot.opened_at.date(), sold_on),
+            )
+        )
+    return realized, unmatched
+
+
+def suggested_shares(position: Position, total_value: float) -> int:
+    """Shares to buy (positive) or sell (negative) to reach the target weight."""
+    if position.target_weight is None or position.quantity <= 0:
+        return 0
+    price = position.current_value / position.quantity
+    if price <= 0:
+        return 0
+    gap = position.target_weight * total_value - position.current_value
+    return int(gap / price)
+
+
+class RebalanceService:
+    """
+    Service for rebalancing suggestions and the gains they would realize
+    """
+
+    def __init__(
+        self,
+        portfolios: PortfolioService,
+        ledger: LedgerService,
+        clock: Callable[[], datetime] = datetime.utcnow,
+    ):
+        self.portfolios = portfolios
+        self.ledger = ledger
+        self.clock = clock
+
+    async def get_plan(self, portfolio_id: int) -> Optional[RebalancePlan]:
+        """
+        Returns:
+            The suggested trades and their tax impact, or None if the
+            portfolio doesn't exist
+        """
+        portfolio = await self.portfolios.get_portfolio_by_id(portfolio_id)
+        if portfolio is None:
+            return None
+        lots = open_lots(await self.ledger.get_transactions(portfolio_id))
+        sold_on = self.clock().date()
+        total_value = portfolio.total_value
+
+        trades = []
+        gains = {term: 0.0 for term in HoldingTerm}
+        unclassified = 0.0
+        for position in portfolio.positions:
+            shares = suggested_shares(position, total_value)
+            if shares == 0:
+                continue
+            price = position.current_value / position.quantity
+            trade = RebalanceTrade(
+                symbol=position.stock_symbol,
+                side=TradeSide.BUY if shares > 0 else TradeSide.SELL,
+                quantity=abs(shares),
+                price=round(price, 4),
+                value=round(abs(shares) * price, 2),
+                current_weight=round(position.current_value / total_value, 4),
+                target_weight=position.target_weight,
+            )
+            if shares < 0:
+                symbol_lots = lots.get(position.stock_symbol, deque())
+                realized, unmatched = realize(symbol_lots, -shares, price, sold_on)
+                for lot in realized:
+                    gains[lot.term] += lot.gain
+                unmatched_gain = 0.0
+                if unmatched > QUANTITY_EPSILON:
+                    unmatched_gain = unmatched * (price - position.average_price)
+                    unclassified += unmatched_gain
+                trade.lots = realized
+                trade.unmatched_quantity = round(unmatched, 6)
+                trade.realized_gain = round(
+                    sum(lot.gain for lot in realized) + unmatched_gain, 2
+                )
+            trades.append(trade)
+
+        short, long = gains[HoldingTerm.SHORT], gains[HoldingTerm.LONG]
+        return RebalancePlan(
+            portfolio_id=portfolio_id,
+            as_of=sold_on,
+            total_value=total_value,
+            trades=trades,
+            tax=RebalanceTaxImpact(
+                short_term_gain=round(short, 2),
+                long_term_gain=round(long, 2),
+                unclassified_gain=round(unclassified, 2),
+                total_gain=round(short + long + unclassified, 2),
+            ),
+        )
+
+
+# Service instance
+rebalance_service = RebalanceService(portfolio_service, ledger_service)
+
+
+def get_rebalance_service() -> RebalanceService:
+    return rebalance_service
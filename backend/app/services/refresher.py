@@ -0,0 +1,133 @@
+"""
+Quote refresher for Quant-Dash.
+
+This module keeps stored data in step with the live provider:
+1. Updates the stock catalog's price and day change
+2. Marks positions in each symbol to the new price
+3. Evaluates price alerts against it, pct_change alerts against the
+   symbol's previous close
+
+Each cycle fetches one quote per distinct symbol across the catalog and
+active alerts, however many alerts share a symbol, then evaluates every
+alert against that shared price map. Symbols without a price this cycle
+are skipped, leaving their alerts for the next one.
+
+It runs as a periodic job (see app.core.jobs) while a live provider is
+configured, including the synthetic provider in demo mode.
+"""
+
+import asyncio
+import logging
+from datetime import datetime
+from typing import Dict, Iterable, List
+
+from app.data.provider_base import MarketProvider, quote_price
+from app.models.schemas import AlertCondition, PriceAlert
+from app.services.alerts import AlertService
+from app.services.market import MarketService
+from app.services.portfolio import PortfolioService
+from app.utils.symbols import normalize_symbol
+
+logger = logging.getLogger(__name__)
+
+# Quote requests in flight at once during a cycle
+QUOTE_FETCH_CONCURRENCY = 5
+
+
+class QuoteRefresher:
+    """
+    Pulls the latest quote for every catalog symbol and applies it
+    """
+
+    def __init__(
+        self,
+        provider: MarketProvider,
+        market: MarketService,
+        portfolios: PortfolioService,
+        alerts: AlertService,
+    ):
+        self.provider = provider
+        self.market = market
+        self.portfolios = portfolios
+        self.alerts = alerts
+
+    async def refresh_symbol(self, symbol: str) -> List[PriceAlert]:
+        """
+        Refresh one symbol.
+
+        Returns:
+            The alerts the new price triggered
+        """
+        price = quote_price(await self.provider.get_quote(symbol))
+        if price is None:
+            return []
+        await self._apply_price(symbol, price)
+        return await self._evaluate_alerts({normalize_symbol(symbol): price})
+
+    async def fetch_prices(self, symbols: Iterable[str]) -> Dict[str, float]:
+        """
+        Fetch one quote per distinct symbol.
+
+        Symbols whose quote fails or has no price are logged and left out.
+        """
+        symbols = sorted({normalize_symbol(symbol) for symbol in symbols})
+        limit = asyncio.Semaphore(QUOTE_FETCH_CONCURRENCY)
+
+        async def fetch(symbol: str):
+            async with limit:
+                return quote_price(await self.provider.get_quote(symbol))
+
+        results = await asyncio.gather(
+            *(fetch(symbol) for symbol in symbols), return_exceptions=True
+        )
+        prices = {}
+        for symbol, result in zip(symbols, results):
+            if isinstance(result, Exception):
+                logger.warning("Refreshing %s failed: %s", symbol, result)
+            elif result is not None:
+                prices[symbol] = result
+        return prices
+
+    async def refresh_once(self) -> int:
+        """
+        Refresh every catalog symbol and evaluate every active alert; one
+        failed quote doesn't stop the rest.
+
+        Returns:
+            Number of symbols refreshed
+        """
+        symbols = [stock.symbol for stock in await self.market.get_stocks()]
+        symbols += await self.alerts.active_symbols()
+        prices = await self.fetch_prices(symbols)
+        for symbol, price in prices.items():
+            await self._apply_price(symbol, price)
+        await self._evaluate_alerts(prices)
+        return len(prices)
+
+    async def _apply_price(self, symbol: str, price: float) -> None:
+        self.market.apply_quote(symbol, price)
+        await self.portfolios.revalue(symbol, price)
+
+    async def _previous_closes(self, prices: Dict[str, float]) -> Dict[str, float]:
+        """Previous closes of the priced symbols with active pct_change alerts."""
+        today = datetime.utcnow().date()
+        closes = {}
+        for symbol in await self.alerts.active_symbols(AlertCondition.PCT_CHANGE):
+            if symbol not in prices:
+                continue
+            close = await self.market.previous_close(symbol, today)
+            if close is not None:
+                closes[symbol] = close
+        return closes
+
+    async def _evaluate_alerts(self, prices: Dict[str, float]) -> List[PriceAlert]:
+        previous_closes = await self._previous_closes(prices)
+        triggered = await self.alerts.evaluate_prices(prices, previous_closes)
+        for alert in triggered:
+            logger.info(
+                "Alert %s on %s triggered at %s",
+                alert.id,
+                alert.symbol,
+                alert.triggered_price,
+            )
+        return triggered
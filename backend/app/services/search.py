@@ -0,0 +1,229 @@
+"""
+Symbol search service for Quant-Dash.
+
+This module handles:
+1. Exact, prefix and substring matching on symbol and company name
+2. Trigram similarity fallback for typos (e.g. "APPL" -> AAPL)
+3. Ranking by match tier (exact symbol, symbol prefix, name or substring,
+   fuzzy), then within a tier by match quality, market cap and per-user
+   recency
+4. Tracking recently viewed symbols per user
+
+Symbols restricted by the compliance allow/blocklist are never returned.
+
+Search runs over the stock catalog in memory, and recent views are kept in
+process memory only, so they're lost on restart. Trigram similarity follows
+pg_trgm semantics (lowercased words padded with two leading spaces and one
+trailing space) so results would stay the same if search moved into the
+database; there's no pg_trgm index or recent_symbols table yet.
+"""
+
+import math
+import re
+from collections import OrderedDict
+from datetime import datetime
+from typing import Dict, List, Optional, Set, Tuple
+
+from app.models.schemas import SearchResult, Stock
+from app.services.market import MarketService, market_service
+from app.utils.symbols import filter_allowed
+
+# Prefix/substring matching returning fewer hits than this triggers fuzzy search
+FUZZY_FALLBACK_THRESHOLD = 5
+# Minimum trigram similarity for a fuzzy hit (pg_trgm's default)
+SIMILARITY_THRESHOLD = 0.3
+# Recently viewed symbols remembered per user
+RECENT_SYMBOLS_LIMIT = 20
+
+# Match tiers, best first; a result never outranks one from a better tier
+EXACT_TIER = 0
+PREFIX_TIER = 1
+CONTAINS_TIER = 2
+FUZZY_TIER = 3
+
+# Match quality weights, best first
+EXACT_SYMBOL = 1.0
+SYMBOL_PREFIX = 0.8
+NAME_PREFIX = 0.6
+SUBSTRING = 0.5
+FUZZY = 0.4  # Scaled by similarity
+
+MARKET_CAP_WEIGHT = 0.1
+RECENCY_WEIGHT = 0.2
+
+_MARKET_CAP_SUFFIXES = {"K": 1e3, "M": 1e6, "B": 1e9, "T": 1e12}
+_LARGEST_MARKET_CAP = 3e12
+
+
+def trigrams(text: str) -> Set[str]:
+    """Extract pg_trgm style trigrams from text."""
+    grams: Set[str] = set()
+    for word in re.findall(r"[a-z0-9]+", text.lower()):
+        padded = f"  {word} "
+        grams.update(padded[i : i + 3] for i in range(len(padded) - 2))
+    return grams
+
+
+def similarity(a: str, b: str) -> float:
+    """Trigram similarity between two strings in [0, 1]."""
+    grams_a, grams_b = trigrams(a), trigrams(b)
+    if not grams_a or not grams_b:
+        return 0.0
+    return len(grams_a & grams_b) / len(grams_a | grams_b)
+
+
+def parse_market_cap(value: Optional[str]) -> float:
+    """Parse a display market cap such as '2.4T' into a number."""
+    if not value:
+        return 0.0
+    value = value.strip().upper()
+    multiplier = _MARKET_CAP_SUFFIXES.get(value[-1:], 1.0)
+    number = value[:-1] if value[-1:] in _MARKET_CAP_SUFFIXES else value
+    try:
+        return float(number) * multiplier
+    except ValueError:
+        return 0.0
+
+
+def _market_cap_score(stock: Stock) -> float:
+    cap = parse_market_cap(stock.market_cap)
+    if cap <= 1:
+        return 0.0
+    return MARKET_CAP_WEIGHT * min(math.log10(cap) / math.log10(_LARGEST_MARKET_CAP), 1)
+
+
+def _match(query: str, stock: Stock) -> Optional[Tuple[int, float, str, Dict]]:
+    """
+    Score how well a stock matches the query.
+
+    Name prefixes share the contains tier with substrings; their higher
+    quality only orders them within it.
+
+    Returns:
+        (tier, quality, match_type, highlights) or None when there's no direct
+        match
+    """
+    q = query.lower()
+    symbol = stock.symbol.lower()
+    name = stock.name.lower()
+
+    if symbol == q:
+        return EXACT_TIER, EXACT_SYMBOL, "exact", {"symbol": [(0, len(q))]}
+    if symbol.startswith(q):
+        return PREFIX_TIER, SYMBOL_PREFIX, "prefix", {"symbol": [(0, len(q))]}
+    if name.startswith(q):
+        return CONTAINS_TIER, NAME_PREFIX, "prefix", {"name": [(0, len(q))]}
+
+    highlights = {}
+    if q in symbol:
+        start = symbol.index(q)
+        highlights["symbol"] = [(start, start + len(q))]
+    if q in name:
+        start = name.index(q)
+        highlights["name"] = [(start, start + len(q))]
+    if highlights:
+        return CONTAINS_TIER, SUBSTRING, "substring", highlights
+    return None
+
+
+class SearchService:
+    """
+    Service for ranked symbol search and recent-symbol tracking
+    """
+
+    def __init__(self, market: MarketService):
+        self.market = market
+        self._recent: Dict[int, "OrderedDict[str, datetime]"] = {}
+
+    async def record_view(self, user_id: int, symbol: str) -> None:
+        """Remember that a user viewed a stock's detail page."""
+        recent = self._recent.setdefault(user_id, OrderedDict())
+        recent.pop(symbol, None)
+        recent[symbol] = datetime.utcnow()
+        while len(recent) > RECENT_SYMBOLS_LIMIT:
+            recent.popitem(last=False)
+
+    async def get_recent_symbols(self, user_id: int) -> List[str]:
+        """Recently viewed symbols for a user, most recent first."""
+        return list(reversed(self._recent.get(user_id, OrderedDict())))
+
+    def _recency_score(self, user_id: Optional[int], symbol: str) -> float:
+        if user_id is None:
+            return 0.0
+        recent = list(reversed(self._recent.get(user_id, OrderedDict())))
+        if symbol not in recent:
+            return 0.0
+        return RECENCY_WEIGHT * (1 - recent.index(symbol) / RECENT_SYMBOLS_LIMIT)
+
+    async def search(
+        self, query: str, limit: int = 10, user_id: Optional[int] = None
+    ) -> List[SearchResult]:
+        """
+        Search stocks by symbol or name.
+
+        Args:
+            query: Search text (symbol fragment, typo, or company name)
+            limit: Maximum results to return
+            user_id: Authenticated user for the recency boost, if any
+
+        Returns:
+            Results ordered by match tier, then by score, ties broken
+            alphabetically by symbol
+        """
+        query = query.strip()
+        if not query:
+            return []
+
+        stocks = filter_allowed(await self.market.get_stocks(), lambda s: s.symbol)
+        scored: Dict[str, Tuple[int, float, str, Dict, Stock]] = {}
+
+        for stock in stocks:
+            match = _match(query, stock)
+            if match:
+                scored[stock.symbol] = (*match, stock)
+
+        if len(scored) < FUZZY_FALLBACK_THRESHOLD:
+            for stock in stocks:
+                if stock.symbol in scored:
+                    continue
+                best = max(
+                    similarity(query, stock.symbol), similarity(query, stock.name)
+                )
+                if best >= SIMILARITY_THRESHOLD:
+                    scored[stock.symbol] = (
+                        FUZZY_TIER,
+                        FUZZY * best,
+                        "fuzzy",
+                        {},
+                        stock,
+                    )
+
+        ranked = []
+        for symbol, (tier, quality, match_type, highlights, stock) in scored.items():
+            score = quality + _market_cap_score(stock)
+            score += self._recency_score(user_id, symbol)
+            result = SearchResult(
+                symbol=stock.symbol,
+                name=stock.name,
+                price=stock.price,
+                change_percent=stock.change_percent,
+                match_type=match_type,
+                score=round(score, 4),
+                highlights={
+                    field: [list(r) for r in ranges]
+                    for field, ranges in highlights.items()
+                },
+            )
+            ranked.append((tier, result))
+
+        ranked.sort(key=lambda item: (item[0], -item[1].score, item[1].symbol))
+        results = [result for _, result in ranked]
+        return results[:limit]
+
+
+# Service instance
+search_service = SearchService(market_service)
+
+
+def get_search_service() -> SearchService:
+    return search_service
@@ -0,0 +1,170 @@
+"""
+Daily portfolio snapshots for Quant-Dash.
+
+This module handles:
+1. Recording each portfolio's total value, gain and holdings once per day
+2. Reading the recorded values back for performance metrics
+3. The value history for charting, daily or the last snapshot of each week
+   or month
+
+A snapshot is keyed by portfolio and day, so taking one again the same day
+replaces it rather than adding a second: the job is safe to run as often as
+it's scheduled, and the day's last run wins.
+"""
+
+import logging
+from datetime import date, datetime
+from typing import Any, Dict, List, Optional, Tuple
+
+from app.core.querybudget import counts_as_query
+from app.models.schemas import (
+    Granularity,
+    PerformanceHistory,
+    PerformancePoint,
+    PortfolioSnapshot,
+    SnapshotHolding,
+)
+from app.services.portfolio import PortfolioService, portfolio_service
+from app.utils.returns import max_drawdown
+
+logger = logging.getLogger(__name__)
+
+
+def bucket_of(day: date, granularity: Granularity) -> Tuple[int, ...]:
+    """The day itself, its ISO week, or its month."""
+    if granularity == Granularity.WEEKLY:
+        return tuple(day.isocalendar())[:2]
+    if granularity == Granularity.MONTHLY:
+        return (day.year, day.month)
+    return (day.year, day.month, day.day)
+
+
+def last_per_bucket(
+    snapshots: List[PortfolioSnapshot], granularity: Granularity
+) -> List[PortfolioSnapshot]:
+    """The last of each bucket's snapshots, from snapshots in date order."""
+    last: Dict[Tuple[int, ...], PortfolioSnapshot] = {}
+    for snapshot in snapshots:
+        last[bucket_of(snapshot.date, granularity)] = snapshot
+    return list(last.values())
+
+
+class SnapshotService:
+    """
+    Service for the portfolio_snapshots history
+    """
+
+    def __init__(self, portfolios: PortfolioService):
+        self.portfolios = portfolios
+        self.reset()
+
+    def reset(self) -> None:
+        """Drop every snapshot."""
+        # (portfolio_id, day) -> snapshot row
+        self._snapshots: Dict[Tuple[int, date], Dict[str, Any]] = {}
+
+    async def take(
+        self, portfolio_id: int, day: Optional[date] = None
+    ) -> Optional[PortfolioSnapshot]:
+        """
+        Record a portfolio's current value and holdings as of day (today).
+
+        Returns:
+            The snapshot, or None if the portfolio doesn't exist
+        """
+        portfolio = await self.portfolios.get_portfolio_by_id(portfolio_id)
+        if portfolio is None:
+            return None
+        day = day or date.today()
+        row = {
+            "portfolio_id": portfolio_id,
+            "date": day,
+            "total_value": portfolio.total_value,
+            "total_gain": portfolio.total_gain,
+            "holdings": [
+                {
+                    "stock_symbol": p.stock_symbol,
+                    "quantity": p.quantity,
+                    "value": p.current_value,
+                }
+                for p in portfolio.positions
+            ],
+            "taken_at": datetime.utcnow(),
+        }
+        self._snapshots[(portfolio_id, day)] = row
+        return self._to_snapshot(row)
+
+    async def take_all(self, day: Optional[date] = None) -> List[PortfolioSnapshot]:
+        """Snapshot every portfolio; the daily job."""
+        snapshots = []
+        for portfolio_id in await self.portfolios.list_portfolio_ids():
+            snapshot = await self.take(portfolio_id, day)
+            if snapshot is not None:
+                snapshots.append(snapshot)
+        logger.info("Took %d portfolio snapshots", len(snapshots))
+        return snapshots
+
+    @counts_as_query
+    async def get_snapshots(
+        self, portfolio_id: int, start: date, end: date
+    ) -> List[PortfolioSnapshot]:
+        """A portfolio's snapshots between start and end (inclusive), by day."""
+        rows = [
+            row
+            for (pid, day), row in self._snapshots.items()
+            if pid == portfolio_id and start <= day <= end
+        ]
+        return [self._to_snapshot(row) for row in sorted(rows, key=lambda r: r["date"])]
+
+    async def get_history(
+        self,
+        portfolio_id: int,
+        start: date,
+        end: date,
+        granularity: Granularity = Granularity.DAILY,
+    ) -> PerformanceHistory:
+        """
+        The portfolio's snapshot values between start and end, with the
+        return and maximum drawdown over them. Days without a snapshot are
+        left out rather than filled in.
+        """
+        snapshots = last_per_bucket(
+            await self.get_snapshots(portfolio_id, start, end), granularity
+        )
+        values = [snapshot.total_value for snapshot in snapshots]
+        period_return = None
+        if len(values) >= 2 and values[0]:
+            period_return = round(values[-1] / values[0] - 1, 6)
+        return PerformanceHistory(
+            portfolio_id=portfolio_id,
+            start=start,
+            end=end,
+            granularity=granularity,
+            points=[
+                PerformancePoint(
+                    date=snapshot.date,
+                    total_value=snapshot.total_value,
+                    total_gain=snapshot.total_gain,
+                )
+                for snapshot in snapshots
+            ],
+            period_return=period_return,
+            max_drawdown=round(max_drawdown(values), 6) if values else None,
+        )
+
+    @staticmethod
+    def _to_snapshot(row: Dict[str, Any]) -> PortfolioSnapshot:
+        return PortfolioSnapshot(
+            **{
+                **row,
+                "holdings": [SnapshotHolding(**h) for h in row["holdings"]],
+            }
+        )
+
+
+# Service instance
+snapshot_service = SnapshotService(portfolio_service)
+
+
+def get_snapshot_service() -> SnapshotService:
+    return snapshot_service
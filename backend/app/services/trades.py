@@ -0,0 +1,149 @@
+"""
+Closed-trade statistics for Quant-Dash.
+
+This module handles:
+1. Reconstructing closed trades from the ledger by matching sells to buys
+2. The lots still open after those sells, for costing hypothetical sells
+3. Win rate, average win/loss and profit factor over those trades
+
+Sells close the oldest open lots first (FIFO). Each sell is one closed
+trade; buy fees are spread over the lot's shares and sell fees come out of
+the proceeds. Shares still held are open and left out, and so are shares
+sold that the ledger never bought (entered directly as a position), whose
+cost it doesn't know.
+"""
+
+from collections import defaultdict, deque
+from typing import Deque, Dict, List, Optional, Sequence, Tuple
+
+from app.models.schemas import ClosedTrade, TradeSide, TradeStats, Transaction
+from app.services.ledger import QUANTITY_EPSILON, LedgerService, ledger_service
+from app.services.portfolio import PortfolioService, portfolio_service
+
+
+class Lot:
+    """Shares of one buy still open, at their per-share cost."""
+
+    def __init__(self, transaction: Transaction):
+        self.opened_at = transaction.executed_at
+        self.quantity = transaction.quantity
+        self.unit_cost = transaction.price + transaction.fees / transaction.quantity
+
+
+def take_from_lots(
+    open_lots: Deque[Lot], quantity: float
+) -> Tuple[List[Tuple[Lot, float]], float]:
+    """
+    Close quantity shares of the oldest lots first.
+
+    Returns:
+        (lot, shares taken from it) in order, and the shares the lots
+        couldn't cover
+    """
+    taken = []
+    remaining = quantity
+    while remaining > QUANTITY_EPSILON and open_lots:
+        lot = open_lots[0]
+        used = min(lot.quantity, remaining)
+        taken.append((lot, used))
+        lot.quantity -= used
+        remaining -= used
+        if lot.quantity <= QUANTITY_EPSILON:
+            open_lots.popleft()
+    return taken, max(0.0, remaining)
+
+
+def _replay(
+    transactions: Sequence[Transaction],
+) -> Tuple[Dict[str, Deque[Lot]], List[ClosedTrade]]:
+    lots: Dict[str, Deque[Lot]] = defaultdict(deque)
+    trades = []
+    for transaction in sorted(transactions, key=lambda t: (t.executed_at, t.id)):
+        open_lots = lots[transaction.symbol]
+        if transaction.side == TradeSide.BUY:
+            open_lots.append(Lot(transaction))
+            continue
+
+        opened_at = open_lots[0].opened_at if open_lots else None
+        taken, remaining = take_from_lots(open_lots, transaction.quantity)
+        quantity = transaction.quantity - remaining
+        if quantity <= QUANTITY_EPSILON:
+            continue  # Every share sold was entered directly
+
+        cost_basis = sum(used * lot.unit_cost for lot, used in taken)
+        fees = transaction.fees * quantity / transaction.quantity
+        proceeds = quantity * transaction.price - fees
+        trades.append(
+            ClosedTrade(
+                symbol=transaction.symbol,
+                quantity=quantity,
+                opened_at=opened_at,
+                closed_at=transaction.executed_at,
+                cost_basis=round(cost_basis, 2),
+                proceeds=round(proceeds, 2),
+                profit=round(proceeds - cost_basis, 2),
+            )
+        )
+    return lots, trades
+
+
+def closed_trades(transactions: Sequence[Transaction]) -> List[ClosedTrade]:
+    """Match sells against the buys before them, oldest lot first."""
+    return _replay(transactions)[1]
+
+
+def open_lots(transactions: Sequence[Transaction]) -> Dict[str, Deque[Lot]]:
+    """
+    The lots still open per symbol once every sell has closed its own, oldest
+    first.
+    """
+    return {symbol: lots for symbol, lots in _replay(transactions)[0].items() if lots}
+
+
+def _average(values: List[float]) -> Optional[float]:
+    return round(sum(values) / len(values), 2) if values else None
+
+
+def trade_stats(portfolio_id: int, trades: Sequence[ClosedTrade]) -> TradeStats:
+    """Win rate, average win/loss and profit factor; breakeven isn't a win."""
+    wins = [trade.profit for trade in trades if trade.profit > 0]
+    losses = [trade.profit for trade in trades if trade.profit < 0]
+    gross_loss = -sum(losses)
+    return TradeStats(
+        portfolio_id=portfolio_id,
+        closed_trades=len(trades),
+        wins=len(wins),
+        losses=len(losses),
+        win_rate=round(len(wins) / len(trades), 4) if trades else None,
+        average_win=_average(wins),
+        average_loss=_average(losses),
+        profit_factor=round(sum(wins) / gross_loss, 4) if gross_loss else None,
+    )
+
+
+class TradeStatsService:
+    """
+    Service for statistics over a portfolio's closed trades
+    """
+
+    def __init__(self, portfolios: PortfolioService, ledger: LedgerService):
+        self.portfolios = portfolios
+        self.ledger = ledger
+
+    async def get_trade_stats(self, portfolio_id: int) -> Optional[TradeStats]:
+        """
+        Returns:
+            The statistics, or None if the portfolio doesn't exist
+        """
+        if await self.portfolios.get_portfolio_by_id(portfolio_id) is None:
+            return None
+        transactions = await self.ledger.get_transactions(portfolio_id)
+        return trade_stats(portfolio_id, closed_trades(transactions))
+
+
+# Service instance
+trade_stats_service = TradeStatsService(portfolio_service, ledger_service)
+
+
+def get_trade_stats_service() -> TradeStatsService:
+    return trade_stats_service
@@ -0,0 +1,66 @@
+"""
+Portfolio valuation service for Quant-Dash.
+
+This module handles:
+1. The value-weighted PE ratio of a portfolio's holdings
+2. Reporting holdings left out of it
+
+Negative PEs (loss-making companies) and missing PEs would distort an
+average of multiples, so those holdings are excluded and counted instead.
+"""
+
+from typing import List, Optional
+
+from app.models.schemas import PortfolioPE
+from app.services.market import MarketService, market_service
+from app.services.portfolio import PortfolioService, portfolio_service
+
+
+class ValuationService:
+    """
+    Service for portfolio valuation snapshots
+    """
+
+    def __init__(self, portfolios: PortfolioService, market: MarketService):
+        self.portfolios = portfolios
+        self.market = market
+
+    async def get_portfolio_pe(self, portfolio_id: int) -> Optional[PortfolioPE]:
+        """
+        Compute sum(value * PE) / sum(value) over holdings with a usable PE.
+
+        Returns:
+            The weighted PE with the exclusions, or None if the portfolio
+            doesn't exist
+        """
+        portfolio = await self.portfolios.get_portfolio_by_id(portfolio_id)
+        if portfolio is None:
+            return None
+
+        weighted = 0.0
+        included_value = 0.0
+        excluded: List[str] = []
+        for position in portfolio.positions:
+            stock = await self.market.get_stock_by_symbol(position.stock_symbol)
+            pe_ratio = stock.pe_ratio if stock else None
+            if pe_ratio is None or pe_ratio < 0:
+                excluded.append(position.stock_symbol)
+                continue
+            weighted += position.current_value * pe_ratio
+            included_value += position.current_value
+
+        return PortfolioPE(
+            portfolio_id=portfolio_id,
+            weighted_pe=round(weighted / included_value, 4) if included_value else None,
+            included_value=round(included_value, 2),
+            excluded_count=len(excluded),
+            excluded=excluded,
+        )
+
+
+# Service instance
+valuation_service = ValuationService(portfolio_service, market_service)
+
+
+def get_valuation_service() -> ValuationService:
+    return valuation_service
@@ -0,0 +1,215 @@
+"""
+Watchlist service layer for Quant-Dash.
+
+This module handles:
+1. Watchlist storage per user
+2. Idempotent creation: names are unique per user, so a retried create
+   returns the watchlist the first attempt made
+3. Adding and removing symbols, each kept with the time it was added
+4. Listing watchlists in the user's chosen order, with the current price of
+   each symbol
+5. Reordering a user's watchlists all at once
+
+For development/testing, this uses an in-memory store. In production, this
+would interact with a real database ORM, with a unique constraint on
+(user_id, name) and the items in their own table, deleted with the watchlist.
+"""
+
+from datetime import datetime
+from typing import Any, Dict, List, Optional, Tuple
+
+from app.core.querybudget import counts_as_query
+from app.models.schemas import Watchlist, WatchlistCreate, WatchlistItem
+from app.services.market import MarketService, market_service
+from app.utils.symbols import check_symbol, normalize_symbol
+
+
+class WatchlistOrderError(ValueError):
+    """A new order that doesn't list each of the user's watchlists once."""
+
+
+class WatchlistService:
+    """
+    Service for handling watchlists
+    """
+
+    def __init__(self, market: Optional[MarketService] = None):
+        self.market = market
+        self.reset()
+
+    def reset(self) -> None:
+        """Drop all watchlists."""
+        self._watchlists: Dict[int, Dict[str, Any]] = {}  # id -> watchlist_data
+        self._ids_by_name: Dict[Tuple[int, str], int] = {}  # (user_id, name) -> id
+        self._next_watchlist_id = 1
+
+    async def create_watchlist(
+        self, user_id: int, watchlist: WatchlistCreate
+    ) -> Tuple[Watchlist, bool]:
+        """
+        Create a watchlist, unless the user already has one with that name.
+
+        Returns:
+            (the new or existing watchlist, whether it was created); an
+            existing watchlist is returned as it is, whatever symbols were sent
+
+        Raises:
+            SymbolRestrictedError: If a symbol is blocked or not allowlisted
+        """
+        existing = self._ids_by_name.get((user_id, watchlist.name))
+        if existing is not None:
+            return self._to_watchlist(self._watchlists[existing]), False
+
+        created_at = datetime.utcnow()
+        symbols = dict.fromkeys(check_symbol(s) for s in watchlist.symbols)
+        # New watchlists go last
+        sort_order = max((r["sort_order"] for r in self._of(user_id)), default=-1) + 1
+        record = {
+            "id": self._next_watchlist_id,
+            "user_id": user_id,
+            "name": watchlist.name,
+            "sort_order": sort_order,
+            "items": [{"symbol": s, "added_at": created_at} for s in symbols],
+            "created_at": created_at,
+        }
+        self._watchlists[record["id"]] = record
+        self._ids_by_name[(user_id, watchlist.name)] = record["id"]
+        self._next_watchlist_id += 1
+        return self._to_watchlist(record), True
+
+    @counts_as_query
+    async def get_watchlists(self, user_id: int) -> List[Watchlist]:
+        """
+        Get all watchlists belonging to a user in their display order, with
+        the stored price and change of each symbol (None for symbols with no
+        stock)
+        """
+        records = self._of(user_id)
+        stocks = {}
+        if self.market is not None:
+            stocks = await self.market.get_stocks_by_symbol(
+                item["symbol"] for record in records for item in record["items"]
+            )
+
+        watchlists = []
+        for record in records:
+            watchlist = self._to_watchlist(record)
+            for item in watchlist.items:
+                stock = stocks.get(item.symbol)
+                if stock is not None:
+                    item.price = stock.price
+                    item.change_percent = stock.change_percent
+            watchlists.append(watchlist)
+        return watchlists
+
+    async def add_symbol(
+        self, user_id: int, watchlist_id: int, symbol: str
+    ) -> Optional[Tuple[Watchlist, bool]]:
+        """
+        Add a symbol to one of the user's watchlists.
+
+        Returns:
+            (the watchlist, whether the symbol was added); a symbol already on
+            the list is left where it is. None if the user has no such watchlist
+
+        Raises:
+            SymbolRestrictedError: If the symbol is blocked or not allowlisted
+        """
+        record = self._owned(user_id, watchlist_id)
+        if record is None:
+            return None
+
+        symbol = check_symbol(symbol)
+        if any(item["symbol"] == symbol for item in record["items"]):
+            return self._to_watchlist(record), False
+        record["items"].append({"symbol": symbol, "added_at": datetime.utcnow()})
+        return self._to_watchlist(record), True
+
+    async def remove_symbol(
+        self, user_id: int, watchlist_id: int, symbol: str
+    ) -> Optional[Watchlist]:
+        """
+        Remove a symbol from one of the user's watchlists; removing one that
+        isn't on the list changes nothing.
+
+        Returns:
+            The watchlist, or None if the user has no such watchlist
+        """
+        record = self._owned(user_id, watchlist_id)
+        if record is None:
+            return None
+
+        symbol = normalize_symbol(symbol)
+        record["items"] = [i for i in record["items"] if i["symbol"] != symbol]
+        return self._to_watchlist(record)
+
+    async def delete_watchlist(self, user_id: int, watchlist_id: int) -> bool:
+        """
+        Delete one of the user's watchlists along with its symbols.
+
+        Returns:
+            True if deleted, False if the user has no such watchlist
+        """
+        record = self._owned(user_id, watchlist_id)
+        if record is None:
+            return False
+
+        del self._watchlists[watchlist_id]
+        del self._ids_by_name[(user_id, record["name"])]
+        return True
+
+    async def reorder_watchlists(
+        self, user_id: int, watchlist_ids: List[int]
+    ) -> List[Watchlist]:
+        """
+        Put the user's watchlists in the given order, all at once.
+
+        Returns:
+            The watchlists in their new order
+
+        Raises:
+            WatchlistOrderError: If watchlist_ids isn't exactly the user's
+                watchlist ids, each once; nothing is reordered
+        """
+        owned = {record["id"] for record in self._of(user_id)}
+        if len(set(watchlist_ids)) != len(watchlist_ids):
+            raise WatchlistOrderError("Each watchlist can only be listed once")
+        unknown = [i for i in watchlist_ids if i not in owned]
+        if unknown:
+            raise WatchlistOrderError(f"No watchlists with ids {unknown}")
+        missing = sorted(owned - set(watchlist_ids))
+        if missing:
+            raise WatchlistOrderError(f"Watchlists {missing} are missing")
+
+        for sort_order, watchlist_id in enumerate(watchlist_ids):
+            self._watchlists[watchlist_id]["sort_order"] = sort_order
+        return await self.get_watchlists(user_id)
+
+    def _of(self, user_id: int) -> List[Dict[str, Any]]:
+        """A user's watchlist records in display order; ties go oldest first."""
+        records = [r for r in self._watchlists.values() if r["user_id"] == user_id]
+        return sorted(records, key=lambda r: (r["sort_order"], r["id"]))
+
+    def _owned(self, user_id: int, watchlist_id: int) -> Optional[Dict[str, Any]]:
+        # Another user's watchlist is treated as missing, not forbidden
+        record = self._watchlists.get(watchlist_id)
+        if record is None or record["user_id"] != user_id:
+            return None
+        return record
+
+    @staticmethod
+    def _to_watchlist(record: Dict[str, Any]) -> Watchlist:
+        items = [WatchlistItem(**item) for item in record["items"]]
+        return Watchlist(
+            **{k: v for k, v in record.items() if k != "items"},
+            symbols=[item.symbol for item in items],
+            items=items,
+        )
+
+
+# Service instance
+watchlist_service = WatchlistService(market_service)
+
+
+def get_watchlist_service() -> WatchlistService:
+    return watchlist_service
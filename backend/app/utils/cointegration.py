@@ -0,0 +1,181 @@
+"""
+Engle-Granger cointegration test for a pair of price series.
+
+Two series are cointegrated when some combination y - beta * x of them is
+stationary, so their spread keeps returning to its mean. The test:
+1. Regresses y on x by ordinary least squares for the hedge ratio beta
+2. Runs an augmented Dickey-Fuller (ADF) test on the residuals
+3. Compares the ADF statistic with MacKinnon's critical values for
+   residuals of a two-variable regression with a constant
+
+A statistic below the critical value rejects "no cointegration".
+"""
+
+import math
+from typing import Dict, List, NamedTuple, Sequence, Tuple
+
+# Below this many aligned closes the critical values don't apply
+MIN_OBSERVATIONS = 30
+
+# MacKinnon (2010), two variables with a constant: tau = b0 + b1/T + b2/T^2,
+# where T is the number of observations in the ADF regression
+_CRITICAL_VALUE_COEFFICIENTS: Dict[float, Tuple[float, float, float]] = {
+    0.01: (-3.89644, -10.9519, -22.527),
+    0.05: (-3.33613, -6.1101, -6.823),
+    0.10: (-3.04445, -4.2412, -2.720),
+}
+
+SIGNIFICANCE_LEVELS = tuple(sorted(_CRITICAL_VALUE_COEFFICIENTS))
+
+
+class RegressionFit(NamedTuple):
+    intercept: float
+    slope: float
+    residuals: List[float]
+
+
+class CointegrationTest(NamedTuple):
+    hedge_ratio: float
+    intercept: float
+    adf_statistic: float
+    critical_value: float
+    observations: int  # In the ADF regression, after differencing and lags
+    cointegrated: bool
+
+
+def linear_fit(y: Sequence[float], x: Sequence[float]) -> RegressionFit:
+    """
+    Least squares fit of y = intercept + slope * x.
+
+    Raises:
+        ValueError: If the series differ in length or x is constant
+    """
+    if len(y) != len(x):
+        raise ValueError(f"The series have {len(y)} and {len(x)} values")
+    n = len(x)
+    mean_x, mean_y = sum(x) / n, sum(y) / n
+    sxx = sum((a - mean_x) ** 2 for a in x)
+    if sxx == 0:
+        raise ValueError("The independent series is constant")
+    slope = sum((a - mean_x) * (b - mean_y) for a, b in zip(x, y)) / sxx
+    intercept = mean_y - slope * mean_x
+    residuals = [b - intercept - slope * a for a, b in zip(x, y)]
+    return RegressionFit(intercept, slope, residuals)
+
+
+def _solve(matrix: List[List[float]], vector: List[float]) -> List[float]:
+    """Gaussian elimination with partial pivoting; matrix is square."""
+    n = len(vector)
+    rows = [matrix[i][:] + [vector[i]] for i in range(n)]
+    for col in range(n):
+        pivot = max(range(col, n), key=lambda r: abs(rows[r][col]))
+        if abs(rows[pivot][col]) < 1e-12:
+            raise ValueError("The regressors are collinear")
+        rows[col], rows[pivot] = rows[pivot], rows[col]
+        for r in range(col + 1, n):
+            factor = rows[r][col] / rows[col][col]
+            for c in range(col, n + 1):
+                rows[r][c] -= factor * rows[col][c]
+    solution = [0.0] * n
+    for r in range(n - 1, -1, -1):
+        known = sum(rows[r][c] * solution[c] for c in range(r + 1, n))
+        solution[r] = (rows[r][n] - known) / rows[r][r]
+    return solution
+
+
+def _first_t_statistic(regressors: List[List[float]], target: List[float]) -> float:
+    """The t statistic of the first coefficient of a no-constant regression."""
+    n, k = len(target), len(regressors[0])
+    xtx = [
+        [sum(row[i] * row[j] for row in regressors) for j in range(k)]
+        for i in range(k)
+    ]
+    xty = [sum(row[i] * t for row, t in zip(regressors, target)) for i in range(k)]
+    coefficients = _solve(xtx, xty)
+    sse = sum(
+        (t - sum(c * v for c, v in zip(coefficients, row))) ** 2
+        for row, t in zip(regressors, target)
+    )
+    variance = sse / (n - k)
+    # The first diagonal entry of (X'X)^-1, from solving X'X z = e1
+    unit = [1.0] + [0.0] * (k - 1)
+    inverse_first = _solve(xtx, unit)[0]
+    standard_error = math.sqrt(variance * inverse_first)
+    if standard_error == 0:
+        raise ValueError("The series has no variation to test")
+    return coefficients[0] / standard_error
+
+
+def adf_statistic(series: Sequence[float], lags: int = 1) -> Tuple[float, int]:
+    """
+    Augmented Dickey-Fuller statistic for a zero-mean series like residuals.
+
+    Regresses the change d_t on the previous level and `lags` earlier
+    changes, with no constant:
+        d_t = gamma * s_{t-1} + phi_1 * d_{t-1} + ... + e_t
+    and returns gamma's t statistic. The more negative, the stronger the
+    pull back to the mean.
+
+    Returns:
+        (statistic, observations in the regression)
+
+    Raises:
+        ValueError: If there are too few values for the lags, or no variation
+    """
+    if lags < 0:
+        raise ValueError(f"Lags must not be negative, got {lags}")
+    changes = [series[i] - series[i - 1] for i in range(1, len(series))]
+    observations = len(changes) - lags
+    if observations <= lags + 1:
+        raise ValueError(f"{len(series)} values are too few for {lags} lag(s)")
+    regressors = [
+        [series[t]] + [changes[t - i] for i in range(1, lags + 1)]
+        for t in range(lags, len(changes))
+    ]
+    return _first_t_statistic(regressors, changes[lags:]), observations
+
+
+def critical_value(significance: float, observations: int) -> float:
+    """
+    MacKinnon's Engle-Granger critical value at a significance level.
+
+    Raises:
+        ValueError: If significance isn't one of SIGNIFICANCE_LEVELS
+    """
+    if significance not in _CRITICAL_VALUE_COEFFICIENTS:
+        levels = ", ".join(f"{level:g}" for level in SIGNIFICANCE_LEVELS)
+        raise ValueError(f"Significance must be one of {levels}, got {significance}")
+    b0, b1, b2 = _CRITICAL_VALUE_COEFFICIENTS[significance]
+    return b0 + b1 / observations + b2 / observations**2
+
+
+def engle_granger(
+    y: Sequence[float],
+    x: Sequence[float],
+    significance: float = 0.05,
+    lags: int = 1,
+) -> CointegrationTest:
+    """
+    Test whether y and x, aligned by index, are cointegrated.
+
+    Raises:
+        ValueError: With fewer than MIN_OBSERVATIONS values, series of
+            different lengths, a constant series or an unknown significance
+    """
+    if len(y) != len(x):
+        raise ValueError(f"The series have {len(y)} and {len(x)} values")
+    if len(y) < MIN_OBSERVATIONS:
+        raise ValueError(
+            f"Need at least {MIN_OBSERVATIONS} aligned closes, got {len(y)}"
+        )
+    fit = linear_fit(y, x)
+    statistic, observations = adf_statistic(fit.residuals, lags)
+    threshold = critical_value(significance, observations)
+    return CointegrationTest(
+        hedge_ratio=fit.slope,
+        intercept=fit.intercept,
+        adf_statistic=statistic,
+        critical_value=threshold,
+        observations=observations,
+        cointegrated=statistic < threshold,
+    )
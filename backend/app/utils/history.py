@@ -0,0 +1,68 @@
+"""
+History range helpers shared by the history and returns endpoints.
+
+Ranges are given either as a number of days or a token such as "1Y".
+Every range is capped at MAX_HISTORY_DAYS so a single request can't ask for
+decades of data; the "MAX" token means "as much as the cap allows".
+"""
+
+from datetime import date
+from typing import Optional
+
+from app.core.config import settings
+
+RANGE_TOKENS = {
+    "1W": 7,
+    "1M": 30,
+    "3M": 91,
+    "6M": 182,
+    "1Y": 365,
+    "2Y": 730,
+    "5Y": 1826,
+    "10Y": 3652,
+}
+MAX_TOKEN = "MAX"
+
+
+class HistoryRangeError(ValueError):
+    """The requested history range is unknown or exceeds the cap."""
+
+
+def resolve_history_days(
+    days: Optional[int] = None,
+    range_token: Optional[str] = None,
+    max_days: Optional[int] = None,
+) -> int:
+    """
+    Resolve a days count or range token to a number of days within the cap.
+
+    Raises:
+        HistoryRangeError: For unknown tokens, non-positive days, or ranges
+            beyond the cap
+    """
+    cap = max_days if max_days is not None else settings.MAX_HISTORY_DAYS
+    if range_token is not None:
+        token = range_token.strip().upper()
+        if token == MAX_TOKEN:
+            return cap
+        if token not in RANGE_TOKENS:
+            raise HistoryRangeError(
+                f"Unknown range '{range_token}'; use one of "
+                f"{', '.join([*RANGE_TOKENS, MAX_TOKEN])}"
+            )
+        days = RANGE_TOKENS[token]
+    if days is None:
+        raise HistoryRangeError("Provide a number of days or a range")
+    if days < 1:
+        raise HistoryRangeError("History range must be at least 1 day")
+    if days > cap:
+        raise HistoryRangeError(
+            f"Requested {days} days of history; the maximum is {cap} days"
+        )
+    return days
+
+
+def check_date_range(start: date, end: Optional[date] = None) -> None:
+    """Reject a start..end span (end defaults to today) longer than the cap."""
+    end = end or date.today()
+    resolve_history_days((end - start).days + 1)
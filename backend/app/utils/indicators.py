@@ -0,0 +1,89 @@
+"""
+Technical indicators over a daily close series.
+
+Every function returns a list aligned with its input: index i is the
+indicator as of closes[i], and None during the warm-up before there are
+enough closes for it.
+"""
+
+from typing import List, Optional, Sequence
+
+Series = List[Optional[float]]
+
+# A one-close window just repeats the closes
+MIN_PERIOD = 2
+
+
+def _check_period(period: int, available: int, needed: int) -> None:
+    if period < MIN_PERIOD:
+        raise ValueError(f"Period must be at least {MIN_PERIOD}, got {period}")
+    if needed > available:
+        raise ValueError(
+            f"A period of {period} needs {needed} closes; there are {available}"
+        )
+
+
+def sma(closes: Sequence[float], period: int) -> Series:
+    """
+    Simple moving average: the mean of the last `period` closes.
+
+    Raises:
+        ValueError: If period isn't between MIN_PERIOD and the number of closes
+    """
+    _check_period(period, len(closes), period)
+    values: Series = [None] * (period - 1)
+    window = sum(closes[:period])
+    values.append(window / period)
+    for i in range(period, len(closes)):
+        window += closes[i] - closes[i - period]
+        values.append(window / period)
+    return values
+
+
+def ema(closes: Sequence[float], period: int) -> Series:
+    """
+    Exponential moving average with a smoothing factor of 2 / (period + 1),
+    seeded with the simple average of the first `period` closes.
+
+    Raises:
+        ValueError: If period isn't between MIN_PERIOD and the number of closes
+    """
+    _check_period(period, len(closes), period)
+    alpha = 2 / (period + 1)
+    values: Series = [None] * (period - 1)
+    average = sum(closes[:period]) / period
+    values.append(average)
+    for close in closes[period:]:
+        average = alpha * close + (1 - alpha) * average
+        values.append(average)
+    return values
+
+
+def rsi(closes: Sequence[float], period: int) -> Series:
+    """
+    Wilder's relative strength index, 0-100.
+
+    Average gains and losses start as plain means over the first `period`
+    changes (so period + 1 closes) and are then smoothed by 1 / period. With
+    no losses it's 100.
+
+    Raises:
+        ValueError: If period is below MIN_PERIOD or there are too few closes
+    """
+    _check_period(period, len(closes), period + 1)
+    changes = [closes[i] - closes[i - 1] for i in range(1, len(closes))]
+    average_gain = sum(max(c, 0.0) for c in changes[:period]) / period
+    average_loss = sum(max(-c, 0.0) for c in changes[:period]) / period
+
+    def index() -> float:
+        if not average_loss:
+            return 100.0
+        return 100 - 100 / (1 + average_gain / average_loss)
+
+    values: Series = [None] * period
+    values.append(index())
+    for change in changes[period:]:
+        average_gain = (average_gain * (period - 1) + max(change, 0.0)) / period
+        average_loss = (average_loss * (period - 1) + max(-change, 0.0)) / period
+        values.append(index())
+    return values
@@ -0,0 +1,81 @@
+"""
+JSON encoding options for API responses.
+
+JavaScript numbers lose precision above 2**53 - 1, so clients can ask for
+int64 fields such as trading volume to be encoded as strings instead:
+
+    X-Int64-As-String: true   ->   {"volume": "12345678901"}
+
+Numbers stay numeric by default; INT64_AS_STRING flips the default for
+deployments whose clients all want strings.
+"""
+
+from typing import Any, Iterable, Optional
+
+from app.core.caching import vary_on
+from app.core.config import settings
+from fastapi import Header, Request
+from fastapi.encoders import jsonable_encoder
+from fastapi.responses import JSONResponse
+
+# Fields that hold int64 values and may exceed the JS safe integer range
+INT64_FIELDS = frozenset({"volume"})
+
+_TRUE = {"1", "true", "yes", "on"}
+_FALSE = {"0", "false", "no", "off"}
+
+
+def stringify_int64_fields(data: Any, fields: Iterable[str] = INT64_FIELDS) -> Any:
+    """Recursively encode integer values of the given fields as strings."""
+    fields = frozenset(fields)
+    if isinstance(data, dict):
+        encoded = {}
+        for key, value in data.items():
+            if key in fields and isinstance(value, int) and not isinstance(value, bool):
+                encoded[key] = str(value)
+            else:
+                encoded[key] = stringify_int64_fields(value, fields)
+        return encoded
+    if isinstance(data, list):
+        return [stringify_int64_fields(item, fields) for item in data]
+    return data
+
+
+def parse_int64_preference(value: Optional[str]) -> bool:
+    """Interpret the X-Int64-As-String header, falling back to the config."""
+    if value is not None:
+        value = value.strip().lower()
+        if value in _TRUE:
+            return True
+        if value in _FALSE:
+            return False
+    return settings.INT64_AS_STRING
+
+
+async def int64_as_string(
+    request: Request,
+    x_int64_as_string: Optional[str] = Header(None),
+) -> bool:
+    """
+    Dependency resolving whether this response should use string int64s.
+
+    The encoding follows the header, so cached responses vary on it.
+    """
+    vary_on(request, "X-Int64-As-String")
+    return parse_int64_preference(x_int64_as_string)
+
+
+def encode_response(content: Any, as_string: bool, status_code: int = 200) -> Any:
+    """
+    Return content unchanged, or as a JSONResponse with string int64 fields.
+
+    Endpoints keep their response_model for documentation; only the
+    string-encoded form bypasses it, so it takes the status_code the endpoint
+    would otherwise have set on its response.
+    """
+    if not as_string:
+        return content
+    return JSONResponse(
+        content=stringify_int64_fields(jsonable_encoder(content)),
+        status_code=status_code,
+    )
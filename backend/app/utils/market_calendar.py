@@ -0,0 +1,119 @@
+"""
+US equity market calendar.
+
+Trading days are weekdays that aren't NYSE full-day holidays. Holidays are
+derived from their rules rather than a table, so any year works: fixed-date
+holidays falling on a Saturday are observed the Friday before and on a
+Sunday the Monday after, except New Year's Day, which isn't moved back into
+the previous year. Early closes count as full trading days.
+
+The regular session runs 9:30-16:00 New York time on trading days; pre-
+and post-market trading falls outside it.
+"""
+
+from datetime import date, datetime, time, timedelta, timezone
+from functools import lru_cache
+from typing import Callable, FrozenSet, Iterable, List, TypeVar
+from zoneinfo import ZoneInfo, ZoneInfoNotFoundError
+
+try:
+    MARKET_TZ = ZoneInfo("America/New_York")
+except ZoneInfoNotFoundError:  # No tz database, e.g. slim images
+    MARKET_TZ = timezone(timedelta(hours=-5))
+
+REGULAR_OPEN = time(9, 30)
+REGULAR_CLOSE = time(16, 0)
+
+JUNETEENTH_FIRST_YEAR = 2022
+
+T = TypeVar("T")
+
+
+def _nth_weekday(year: int, month: int, weekday: int, n: int) -> date:
+    """The n-th given weekday (Monday is 0) of a month."""
+    first = date(year, month, 1)
+    return first + timedelta(days=(weekday - first.weekday()) % 7 + 7 * (n - 1))
+
+
+def _last_weekday(year: int, month: int, weekday: int) -> date:
+    """The last given weekday (Monday is 0) of a month."""
+    following = date(year + month // 12, month % 12 + 1, 1)
+    last = following - timedelta(days=1)
+    return last - timedelta(days=(last.weekday() - weekday) % 7)
+
+
+def _observed(day: date) -> date:
+    if day.weekday() == 5:
+        return day - timedelta(days=1)
+    if day.weekday() == 6:
+        return day + timedelta(days=1)
+    return day
+
+
+def _easter(year: int) -> date:
+    """Western Easter Sunday (anonymous Gregorian algorithm)."""
+    a = year % 19
+    b, c = divmod(year, 100)
+    d, e = divmod(b, 4)
+    f = (b + 8) // 25
+    g = (b - f + 1) // 3
+    h = (19 * a + b - d - g + 15) % 30
+    i, k = divmod(c, 4)
+    l = (32 + 2 * e + 2 * i - h - k) % 7  # noqa: E741
+    m = (a + 11 * h + 22 * l) // 451
+    month, day = divmod(h + l - 7 * m + 114, 31)
+    return date(year, month, day + 1)
+
+
+@lru_cache(maxsize=64)
+def market_holidays(year: int) -> FrozenSet[date]:
+    """Weekdays in a year on which the market is closed all day."""
+    new_year = date(year, 1, 1)
+    holidays = {
+        new_year + timedelta(days=1) if new_year.weekday() == 6 else new_year,
+        _nth_weekday(year, 1, 0, 3),  # Martin Luther King Jr. Day
+        _nth_weekday(year, 2, 0, 3),  # Washington's Birthday
+        _easter(year) - timedelta(days=2),  # Good Friday
+        _last_weekday(year, 5, 0),  # Memorial Day
+        _observed(date(year, 7, 4)),
+        _nth_weekday(year, 9, 0, 1),  # Labor Day
+        _nth_weekday(year, 11, 3, 4),  # Thanksgiving
+        _observed(date(year, 12, 25)),
+    }
+    if year >= JUNETEENTH_FIRST_YEAR:
+        holidays.add(_observed(date(year, 6, 19)))
+    return frozenset(day for day in holidays if day.weekday() < 5)
+
+
+def is_trading_day(day: date) -> bool:
+    """Whether the market is open on a day."""
+    return day.weekday() < 5 and day not in market_holidays(day.year)
+
+
+def trading_days(start: date, end: date) -> List[date]:
+    """Trading days from start to end (inclusive), in order."""
+    days = []
+    day = start
+    while day <= end:
+        if is_trading_day(day):
+            days.append(day)
+        day += timedelta(days=1)
+    return days
+
+
+def in_regular_session(at: datetime) -> bool:
+    """
+    Whether a moment falls in a trading day's regular session, open
+    inclusive and close exclusive. Naive datetimes are taken as UTC.
+    """
+    if at.tzinfo is None:
+        at = at.replace(tzinfo=timezone.utc)
+    local = at.astimezone(MARKET_TZ)
+    return REGULAR_OPEN <= local.time() < REGULAR_CLOSE and is_trading_day(local.date())
+
+
+def regular_session_only(
+    points: Iterable[T], moment: Callable[[T], datetime]
+) -> List[T]:
+    """The points whose moment is in a regular session, dropping extended hours."""
+    return [point for point in points if in_regular_session(moment(point))]
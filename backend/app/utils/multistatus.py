@@ -0,0 +1,63 @@
+"""
+Per-item outcomes for batch endpoints.
+
+A batch reports how each item went instead of failing as a whole because of
+one bad item. The response is:
+1. 200 when every item succeeded
+2. 207 Multi-Status when only some did
+3. The items' own status when all failed the same way, else 207
+"""
+
+from typing import Dict, Iterable, List, Optional
+
+from app.core.errors import AppError
+from app.models.schemas import BatchItemStatus
+
+MULTI_STATUS = 207
+
+
+class MultiStatus:
+    """
+    Collects the outcome of each item of a batch, in request order.
+
+    Items are listed up front so outcomes recorded out of order (e.g. from
+    concurrent fetches) still come back in the order they were requested.
+    """
+
+    def __init__(self, items: Iterable[str] = ()):
+        self._results: Dict[str, Optional[BatchItemStatus]] = dict.fromkeys(items)
+
+    def succeeded(self, item: str) -> None:
+        self._results[item] = BatchItemStatus(item=item, status=200)
+
+    def failed(self, item: str, error: AppError) -> None:
+        self._results[item] = BatchItemStatus(
+            item=item,
+            status=error.status_code,
+            code=error.code,
+            message=error.message,
+        )
+
+    @property
+    def results(self) -> List[BatchItemStatus]:
+        """Recorded outcomes; items listed but never recorded are left out."""
+        return [result for result in self._results.values() if result is not None]
+
+    @property
+    def status_code(self) -> int:
+        """The HTTP status for the whole batch."""
+        return self.status_code_without()
+
+    def status_code_without(self, *codes: str) -> int:
+        """
+        The HTTP status for the whole batch, counting items that failed with
+        one of codes as done, e.g. unknown symbols a batch lists separately.
+        """
+        statuses = {
+            200 if result.code in codes else result.status for result in self.results
+        }
+        if statuses <= {200}:
+            return 200
+        if len(statuses) == 1:
+            return statuses.pop()  # Everything failed, and the same way
+        return MULTI_STATUS
@@ -0,0 +1,115 @@
+"""
+Query parameter combination rules shared by endpoints.
+
+FastAPI validates each parameter on its own; these helpers catch requests
+whose parameters are individually fine but don't make sense together, such
+as a `range` token alongside explicit `from`/`to` dates. Rather than quietly
+preferring one, endpoints reject the request with a 400 naming the conflict.
+
+Rules take the parameters as a name -> value mapping using the names the
+client sent (aliases, not Python names), so messages match the URL.
+"""
+
+from typing import Any, Dict, Iterable, Optional, Sequence, Tuple
+
+SORT_ORDERS = ("asc", "desc")
+
+
+class ParamConflictError(ValueError):
+    """Query parameters that conflict or are incomplete together."""
+
+
+def _given(params: Dict[str, Any], names: Iterable[str]) -> list:
+    return [name for name in names if params.get(name) is not None]
+
+
+def _join(names: Sequence[str]) -> str:
+    return "/".join(f"'{name}'" for name in names)
+
+
+def mutually_exclusive(params: Dict[str, Any], *groups: Sequence[str]) -> None:
+    """
+    Allow parameters from at most one group.
+
+    Example: mutually_exclusive(params, ("range",), ("days",), ("from", "to"))
+
+    Raises:
+        ParamConflictError: Naming the parameters given from different groups
+    """
+    used = [group for group in groups if _given(params, group)]
+    if len(used) > 1:
+        sent = [name for group in used for name in _given(params, group)]
+        raise ParamConflictError(
+            f"{_join(sent)} can't be combined; use only one of "
+            + ", ".join(_join(group) for group in groups)
+        )
+
+
+def requires(params: Dict[str, Any], name: str, *required: str) -> None:
+    """
+    If `name` is given, every parameter in `required` must be too.
+
+    Raises:
+        ParamConflictError: Naming the missing parameters
+    """
+    if params.get(name) is None:
+        return
+    missing = [other for other in required if params.get(other) is None]
+    if missing:
+        raise ParamConflictError(f"'{name}' also requires {_join(missing)}")
+
+
+def check_sort(
+    sort_by: Optional[str],
+    order: Optional[str],
+    fields: Iterable[str],
+) -> Optional[Tuple[str, bool]]:
+    """
+    Validate a sort_by/order pair; both or neither must be given.
+
+    Returns:
+        (field, descending), or None when no sort was requested
+
+    Raises:
+        ParamConflictError: If only one is given, the field isn't sortable
+            or the order isn't asc/desc
+    """
+    params = {"sort_by": sort_by, "order": order}
+    requires(params, "order", "sort_by")
+    if sort_by is None:
+        return None
+    fields = list(fields)
+    if sort_by not in fields:
+        raise ParamConflictError(
+            f"Can't sort by '{sort_by}'; use one of {', '.join(fields)}"
+        )
+    if order is None or order.lower() not in SORT_ORDERS:
+        raise ParamConflictError(
+            f"'sort_by' requires 'order' to be {' or '.join(SORT_ORDERS)}"
+        )
+    return sort_by, order.lower() == "desc"
+
+
+def parse_sort(
+    sort: Optional[str], fields: Iterable[str]
+) -> Optional[Tuple[str, bool]]:
+    """
+    Validate a single `sort` parameter: a field, `-` prefixed for descending.
+
+    Returns:
+        (field, descending), or None when no sort was requested
+
+    Raises:
+        ParamConflictError: If the field isn't sortable
+    """
+    if sort is None:
+        return None
+    descending = sort.startswith("-")
+    field = sort[1:] if descending else sort
+    fields = list(fields)
+    if field not in fields:
+        raise ParamConflictError(
+            f"Can't sort by '{field}'; use one of {', '.join(fields)}, "
+            "with a '-' prefix for descending"
+        )
+    return field, descending
@@ -0,0 +1,104 @@
+"""
+Return and risk metrics shared by the analytics services.
+
+All functions take a value series sampled once per period (daily by
+default) and annualize with TRADING_DAYS_PER_YEAR.
+"""
+
+import math
+from typing import List, Optional, Sequence
+
+from app.core.config import settings
+
+TRADING_DAYS_PER_YEAR = 252
+
+
+def period_returns(values: Sequence[float]) -> List[float]:
+    return [values[i] / values[i - 1] - 1 for i in range(1, len(values))]
+
+
+def total_return(values: Sequence[float]) -> float:
+    return values[-1] / values[0] - 1 if values and values[0] else 0.0
+
+
+def cagr(
+    values: Sequence[float], periods_per_year: int = TRADING_DAYS_PER_YEAR
+) -> float:
+    """Compound annual growth rate over the series; 0 for a degenerate one."""
+    if len(values) < 2 or values[0] <= 0 or values[-1] < 0:
+        return 0.0
+    years = (len(values) - 1) / periods_per_year
+    return (values[-1] / values[0]) ** (1 / years) - 1
+
+
+def max_drawdown(values: Sequence[float]) -> float:
+    """Largest peak-to-trough fall as a fraction of the peak, e.g. 0.2."""
+    peak = 0.0
+    drawdown = 0.0
+    for value in values:
+        peak = max(peak, value)
+        if peak > 0:
+            drawdown = max(drawdown, (peak - value) / peak)
+    return drawdown
+
+
+def ulcer_index(values: Sequence[float]) -> float:
+    """
+    Root mean square of the percent drawdowns from the running peak, e.g.
+    10.0; 0 for a series that never falls. Unlike max drawdown it grows with
+    how long, not just how far, the series stays under water.
+    """
+    if not values:
+        return 0.0
+    peak = 0.0
+    squares = 0.0
+    for value in values:
+        peak = max(peak, value)
+        if peak > 0:
+            squares += (100 * (peak - value) / peak) ** 2
+    return math.sqrt(squares / len(values))
+
+
+def annualized_volatility(
+    values: Sequence[float], periods_per_year: int = TRADING_DAYS_PER_YEAR
+) -> float:
+    """Annualized standard deviation of period returns."""
+    returns = period_returns(values)
+    if len(returns) < 2:
+        return 0.0
+    mean = sum(returns) / len(returns)
+    variance = sum((r - mean) ** 2 for r in returns) / (len(returns) - 1)
+    return math.sqrt(variance) * math.sqrt(periods_per_year)
+
+
+def sharpe_ratio(
+    values: Sequence[float],
+    risk_free_rate: float = settings.RISK_FREE_RATE,
+    periods_per_year: int = TRADING_DAYS_PER_YEAR,
+) -> Optional[float]:
+    """Annualized mean excess return over volatility; None without volatility."""
+    returns = period_returns(values)
+    volatility = annualized_volatility(values, periods_per_year)
+    if not volatility:
+        return None
+    mean_excess = sum(returns) / len(returns) - risk_free_rate / periods_per_year
+    return mean_excess * periods_per_year / volatility
+
+
+def beta(
+    returns: Sequence[float], benchmark_returns: Sequence[float]
+) -> Optional[float]:
+    """
+    Sensitivity of returns to the benchmark's over the same periods: their
+    covariance over the benchmark's variance. None if the benchmark is flat.
+    """
+    n = len(returns)
+    if n < 2:
+        return None
+    mean = sum(returns) / n
+    benchmark_mean = sum(benchmark_returns) / n
+    covariance = sum(
+        (r - mean) * (b - benchmark_mean) for r, b in zip(returns, benchmark_returns)
+    )
+    variance = sum((b - benchmark_mean) ** 2 for b in benchmark_returns)
+    return covariance / variance if variance else None
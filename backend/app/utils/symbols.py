@@ -0,0 +1,89 @@
+"""
+Symbol normalization and validation, and the compliance allow/blocklist.
+
+Symbols from URLs must look like a ticker (1-10 letters) before they're
+looked up. SYMBOL_BLOCKLIST names symbols that must not be viewed or traded. When
+SYMBOL_ALLOWLIST is non-empty, only the symbols in it are accessible; the
+blocklist still applies on top. Symbols are compared after normalization,
+so " aapl" and "AAPL" are the same symbol; every symbol a handler takes goes
+through normalize_symbol before it's looked up or stored.
+"""
+
+import re
+from typing import Callable, Iterable, List, TypeVar
+
+from app.core.config import settings
+
+T = TypeVar("T")
+
+SYMBOL_PATTERN = re.compile(r"[A-Za-z]{1,10}")
+
+_QUOTES = "'\"`"
+
+
+class InvalidSymbolError(ValueError):
+    """The text can't be a ticker symbol."""
+
+    def __init__(self, symbol: str):
+        self.symbol = symbol
+        super().__init__(f"'{symbol}' isn't a valid symbol; use 1-10 letters")
+
+
+class SymbolRestrictedError(ValueError):
+    """The symbol is blocked, or missing from the allowlist."""
+
+    def __init__(self, symbol: str):
+        self.symbol = symbol
+        super().__init__(f"Symbol {symbol} is restricted")
+
+
+def normalize_symbol(symbol: str) -> str:
+    """
+    The canonical form of a symbol as typed or pasted by a user.
+
+    Surrounding whitespace and quotes are dropped and, unless
+    SYMBOL_CASE_SENSITIVE is set, the symbol is upper-cased, so " 'aapl' "
+    and "AAPL" are stored and looked up as the same symbol.
+    """
+    symbol = symbol.strip()
+    while len(symbol) > 1 and symbol[0] == symbol[-1] and symbol[0] in _QUOTES:
+        symbol = symbol[1:-1].strip()
+    return symbol if settings.SYMBOL_CASE_SENSITIVE else symbol.upper()
+
+
+def parse_symbol(symbol: str) -> str:
+    """
+    Normalize a symbol and make sure it looks like a ticker.
+
+    Raises:
+        InvalidSymbolError: If it isn't 1-10 letters
+    """
+    normalized = normalize_symbol(symbol)
+    if not SYMBOL_PATTERN.fullmatch(normalized):
+        raise InvalidSymbolError(symbol)
+    return normalized
+
+
+def symbol_allowed(symbol: str) -> bool:
+    symbol = normalize_symbol(symbol)
+    if symbol in settings.SYMBOL_BLOCKLIST:
+        return False
+    return not settings.SYMBOL_ALLOWLIST or symbol in settings.SYMBOL_ALLOWLIST
+
+
+def check_symbol(symbol: str) -> str:
+    """
+    Normalize a symbol and make sure it's accessible.
+
+    Raises:
+        SymbolRestrictedError: If compliance rules exclude it
+    """
+    symbol = normalize_symbol(symbol)
+    if not symbol_allowed(symbol):
+        raise SymbolRestrictedError(symbol)
+    return symbol
+
+
+def filter_allowed(items: Iterable[T], symbol_of: Callable[[T], str]) -> List[T]:
+    """Drop the items whose symbol is restricted, e.g. from listings."""
+    return [item for item in items if symbol_allowed(symbol_of(item))]
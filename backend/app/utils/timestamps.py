@@ -0,0 +1,18 @@
+"""
+Timestamp normalization for Quant-Dash.
+
+Stored times are naive UTC, like the datetime.utcnow() the services' clocks
+default to. Python can't compare or subtract naive and aware datetimes, so
+times with a zone (e.g. "2025-01-06T15:30:00Z" or "+02:00") are converted
+to UTC and stripped of it on the way in; times without one are taken to be
+UTC already.
+"""
+
+from datetime import datetime, timezone
+
+
+def naive_utc(at: datetime) -> datetime:
+    """at in UTC without a time zone; naive times are returned as they are."""
+    if at.tzinfo is None:
+        return at
+    return at.astimezone(timezone.utc).replace(tzinfo=None)
@@ -0,0 +1,64 @@
+"""
+In-memory cache with a time to live per entry.
+
+Entries expire lazily: a read past an entry's expiry drops it and misses.
+Entries that are never read again stay until evict_expired runs, so owners
+call it periodically to keep the map from growing without bound.
+
+All methods take a lock, so the cache can be shared with worker threads.
+"""
+
+import threading
+import time
+from typing import Any, Callable, Dict, Optional, Tuple
+
+
+class TTLCache:
+    """Map of keys to values that each expire ttl seconds after being set."""
+
+    def __init__(self, clock: Callable[[], float] = time.monotonic):
+        self.clock = clock
+        self._entries: Dict[str, Tuple[float, Any]] = {}  # key -> (expires, value)
+        self._lock = threading.Lock()
+
+    def get(self, key: str) -> Optional[Any]:
+        """The value stored under key, or None if absent or expired."""
+        with self._lock:
+            entry = self._entries.get(key)
+            if entry is None:
+                return None
+            if entry[0] <= self.clock():
+                del self._entries[key]
+                return None
+            return entry[1]
+
+    def set(self, key: str, value: Any, ttl: float) -> None:
+        """Store value under key for ttl seconds; a ttl of 0 or less removes it."""
+        with self._lock:
+            if ttl <= 0:
+                self._entries.pop(key, None)
+                return
+            self._entries[key] = (self.clock() + ttl, value)
+
+    def delete(self, key: str) -> None:
+        with self._lock:
+            self._entries.pop(key, None)
+
+    def evict_expired(self) -> int:
+        """Drop every expired entry, returning how many were dropped."""
+        with self._lock:
+            now = self.clock()
+            expired = [
+                key for key, (expires, _) in self._entries.items() if expires <= now
+            ]
+            for key in expired:
+                del self._entries[key]
+            return len(expired)
+
+    def clear(self) -> None:
+        with self._lock:
+            self._entries.clear()
+
+    def __len__(self) -> int:
+        with self._lock:
+            return len(self._entries)
@@ -0,0 +1,127 @@
+"""
+Tick feeds that drive the WebSocket hub.
+
+A feed hides how live prices are obtained:
+- StreamingFeed relays an upstream push connection and reconnects with
+  exponential backoff, re-subscribing every symbol after a reconnect
+- PollingFeed periodically fetches quotes for providers without a push feed
+
+Both expose subscribe/unsubscribe/stream, so the hub doesn't care which one
+it is given.
+"""
+
+import asyncio
+import logging
+import time
+from typing import AsyncIterator, Dict, Set, Union
+
+from app.core.config import settings
+from app.data.provider_base import (
+    MarketProvider,
+    StreamingProvider,
+    quote_price,
+    supports_streaming,
+)
+
+logger = logging.getLogger(__name__)
+
+
+class StreamingFeed:
+    """Relays ticks from a streaming provider, reconnecting on disconnect."""
+
+    def __init__(
+        self,
+        provider: StreamingProvider,
+        initial_backoff: float = settings.STREAM_RECONNECT_INITIAL_BACKOFF_SECONDS,
+        max_backoff: float = settings.STREAM_RECONNECT_MAX_BACKOFF_SECONDS,
+    ):
+        self.provider = provider
+        self.initial_backoff = initial_backoff
+        self.max_backoff = max_backoff
+        self.symbols: Set[str] = set()
+
+    async def subscribe(self, symbols):
+        self.symbols.update(symbols)
+        await self.provider.subscribe(list(symbols))
+
+    async def unsubscribe(self, symbols):
+        self.symbols.difference_update(symbols)
+        await self.provider.unsubscribe(list(symbols))
+
+    async def stream(self) -> AsyncIterator[Dict]:
+        """Yield ticks forever, surviving upstream disconnects."""
+        backoff = self.initial_backoff
+        connected = False
+        while True:
+            try:
+                if not connected:
+                    await self.provider.connect()
+                    if self.symbols:
+                        await self.provider.subscribe(sorted(self.symbols))
+                    connected = True
+
+                async for tick in self.provider.stream():
+                    backoff = self.initial_backoff
+                    yield tick
+
+                logger.warning("Upstream stream ended, reconnecting")
+            except asyncio.CancelledError:
+                raise
+            except Exception as e:
+                logger.warning("Upstream stream error, reconnecting: %s", e)
+
+            connected = False
+            await asyncio.sleep(backoff)
+            backoff = min(backoff * 2, self.max_backoff)
+
+
+class PollingFeed:
+    """Fetches quotes for subscribed symbols on a fixed interval."""
+
+    def __init__(
+        self,
+        provider: MarketProvider,
+        interval: float = settings.STREAM_POLL_INTERVAL_SECONDS,
+    ):
+        self.provider = provider
+        self.interval = interval
+        self.symbols: Set[str] = set()
+
+    async def subscribe(self, symbols):
+        self.symbols.update(symbols)
+
+    async def unsubscribe(self, symbols):
+        self.symbols.difference_update(symbols)
+
+    async def stream(self) -> AsyncIterator[Dict]:
+        """Yield one tick per subscribed symbol every interval."""
+        while True:
+            for symbol in sorted(self.symbols):
+                try:
+                    quote = await self.provider.get_quote(symbol)
+                except Exception as e:
+                    logger.warning("Polling quote for %s failed: %s", symbol, e)
+                    continue
+
+                price = quote_price(quote)
+                if price is None:
+                    continue
+                yield {
+                    "type": "tick",
+                    "symbol": symbol,
+                    "price": price,
+                    "ts": int(time.time() * 1000),
+                }
+            await asyncio.sleep(self.interval)
+
+
+Feed = Union[StreamingFeed, PollingFeed]
+
+
+def create_feed(provider: MarketProvider) -> Feed:
+    """Use the provider's push feed when it has one, otherwise poll it."""
+    if supports_streaming(provider):
+        logger.info("Using streaming feed for %s", type(provider).__name__)
+        return StreamingFeed(provider)
+    logger.info("Provider %s can't stream, polling", type(provider).__name__)
+    return PollingFeed(provider)
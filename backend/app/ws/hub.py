@@ -5,19 +5,40 @@ WebSocket Hub for real-time communication.
 import asyncio
 import json
 import logging
-from typing import Dict, List, Set
-
-from app.data.provider_base import MarketProvider
-from fastapi import WebSocket
+from typing import Dict, List, Optional, Set
+
+from app.core.config import settings
+from app.services.market import MarketService
+from app.utils.symbols import (
+    InvalidSymbolError,
+    SymbolRestrictedError,
+    check_symbol,
+    normalize_symbol,
+    parse_symbol,
+)
+from app.ws.feed import Feed
+from fastapi import WebSocket, WebSocketDisconnect
 
 logger = logging.getLogger(__name__)
 
 
 class ConnectionManager:
-    def __init__(self, provider: MarketProvider):
-        self.provider = provider
+    def __init__(
+        self,
+        feed: Feed,
+        ping_interval: Optional[float] = None,
+        market: Optional[MarketService] = None,
+        max_symbols: Optional[int] = None,
+    ):
+        self.feed = feed
+        self.ping_interval = ping_interval or settings.WS_PING_INTERVAL_SECONDS
+        self.market = market  # Source of the stock updates push_stocks sends
+        if max_symbols is None:
+            max_symbols = settings.WS_MAX_SYMBOLS_PER_CONNECTION
+        self.max_symbols = max_symbols
         self.active_connections: List[WebSocket] = []
         self.subscriptions: Dict[str, Set[WebSocket]] = {}
+
     async def connect(self, websocket: WebSocket):
         await websocket.accept()
         self.active_connections.append(websocket)
@@ -41,29 +62,115 @@ class ConnectionManager:
 
         if symbols_to_unsubscribe:
             try:
-                await self.provider.unsubscribe(symbols_to_unsubscribe)
+                await self.feed.unsubscribe(symbols_to_unsubscribe)
             except Exception as e:
-                logger.exception("Failed to unsubscribe from provider: %s", e)
+                logger.exception("Failed to unsubscribe from feed: %s", e)
+
+    async def serve(self, websocket: WebSocket):
+        """
+        Run a client's session until it disconnects or stops responding.
+
+        Any message from the client shows it's alive. After ping_interval of
+        silence it's sent {"type": "ping"}, and if another interval passes
+        without a message it's disconnected.
+        """
+        await self.connect(websocket)
+        try:
+            pinged = False
+            while True:
+                try:
+                    message = await asyncio.wait_for(
+                        websocket.receive_text(), self.ping_interval
+                    )
+                except asyncio.TimeoutError:
+                    if pinged:
+                        logger.info(f"WebSocket ping timeout: {websocket.client}")
+                        await websocket.close()
+                        return
+                    await websocket.send_text(json.dumps({"type": "ping"}))
+                    pinged = True
+                    continue
+                pinged = False
+                await self.handle_message(websocket, message)
+        except WebSocketDisconnect:
+            # Client disconnected, which is an expected event.
+            pass
+        except Exception as e:
+            # Log other unexpected errors for debugging.
+            logger.warning(f"WebSocket error for client {websocket.client}: {e}")
+        finally:
+            await self.disconnect(websocket)
 
     async def handle_message(self, websocket: WebSocket, message: str):
+        """
+        Apply a client's subscribe or unsubscribe message.
+
+        Takes {"action": "subscribe", "symbols": ["AAPL", "GOOGL"]} or the
+        single-symbol {"type": "subscribe", "symbol": "AAPL"}, and the same
+        for unsubscribe. A bare {"symbols": [...]} subscribes. Anything else,
+        such as a pong, is ignored.
+        """
         try:
             data = json.loads(message)
-            message_type = data.get("type")
-            if message_type == "subscribe":
-                symbol = data.get("symbol")
-                if symbol:
-                    await self.subscribe(websocket, symbol)
-            elif message_type == "unsubscribe":
-                symbol = data.get("symbol")
-                if symbol:
-                    await self.unsubscribe(websocket, symbol)
         except json.JSONDecodeError:
             logger.error("Invalid JSON message received")
+            return
+        if not isinstance(data, dict):
+            return
+
+        action = data.get("action") or data.get("type")
+        symbols = data.get("symbols")
+        if action is None and isinstance(symbols, list):
+            action = "subscribe"
+        if not isinstance(symbols, list):
+            symbols = [data.get("symbol")]
+        for symbol in symbols:
+            if not isinstance(symbol, str) or not symbol:
+                continue
+            if action == "subscribe":
+                await self._checked_subscribe(websocket, symbol)
+            elif action == "unsubscribe":
+                await self.unsubscribe(websocket, normalize_symbol(symbol))
+
+    async def _checked_subscribe(self, websocket: WebSocket, symbol: str):
+        """Subscribe, or tell the client why the symbol can't be streamed."""
+        try:
+            symbol = check_symbol(parse_symbol(symbol))
+        except InvalidSymbolError as e:
+            await self._send_error(websocket, "stock.symbol_invalid", symbol, e)
+            return
+        except SymbolRestrictedError as e:
+            await self._send_error(websocket, "stock.restricted", e.symbol, e)
+            return
+        if websocket not in self.subscriptions.get(symbol, ()):
+            if self.symbol_count(websocket) >= self.max_symbols:
+                error = ValueError(
+                    f"A connection can subscribe to at most {self.max_symbols} "
+                    "symbols; unsubscribe from one first"
+                )
+                await self._send_error(
+                    websocket, "stream.too_many_symbols", symbol, error
+                )
+                return
+        await self.subscribe(websocket, symbol)
+
+    def symbol_count(self, websocket: WebSocket) -> int:
+        """How many symbols a connection is subscribed to."""
+        return sum(websocket in sockets for sockets in self.subscriptions.values())
+
+    async def _send_error(
+        self, websocket: WebSocket, code: str, symbol: str, error: Exception
+    ):
+        await websocket.send_text(
+            json.dumps(
+                {"type": "error", "code": code, "symbol": symbol, "message": str(error)}
+            )
+        )
 
     async def subscribe(self, websocket: WebSocket, symbol: str):
         if symbol not in self.subscriptions:
             self.subscriptions[symbol] = set()
-            await self.provider.subscribe([symbol])
+            await self.feed.subscribe([symbol])
         self.subscriptions[symbol].add(websocket)
         logger.info(f"Subscribed {websocket.client} to {symbol}")
 
@@ -72,15 +179,15 @@ class ConnectionManager:
             self.subscriptions[symbol].remove(websocket)
             if not self.subscriptions[symbol]:
                 del self.subscriptions[symbol]
-                await self.provider.unsubscribe([symbol])
+                await self.feed.unsubscribe([symbol])
             logger.info(f"Unsubscribed {websocket.client} from {symbol}")
 
     async def broadcast_ticks(self):
-        """Background task: read ticks from provider.stream() and broadcast to subscribers."""
+        """Background task: read ticks from feed.stream() and broadcast to subscribers."""
         logger.info("Starting broadcast_ticks task")
         while True:
             try:
-                async for tick in self.provider.stream():
+                async for tick in self.feed.stream():
                     # Normalize tick to a dict
                     if hasattr(tick, "dict"):
                         payload = tick.dict()
@@ -118,3 +225,30 @@ class ConnectionManager:
                 logger.exception("Error in broadcast_ticks loop, retrying in 1s")
                 await asyncio.sleep(1)
 
+    async def push_stocks(self):
+        """
+        Send each subscribed symbol's stock to its subscribers, as
+        {"type": "stock", "data": {...}}.
+
+        Each stock is looked up once however many connections want it, through
+        the market's quote cache, so this runs on a short interval alongside
+        the tick feed.
+        """
+        if self.market is None:
+            return
+        for symbol, sockets in list(self.subscriptions.items()):
+            try:
+                stock = await self.market.find_stock(symbol)
+            except Exception as e:
+                logger.warning("Stock update for %s failed: %s", symbol, e)
+                continue
+            if stock is None:
+                continue
+            message = json.dumps(
+                {"type": "stock", "data": stock.model_dump(mode="json")}
+            )
+            for ws in list(sockets):
+                try:
+                    await ws.send_text(message)
+                except Exception:
+                    await self.disconnect(ws)
@@ -0,0 +1,8 @@
+"""
+Shared pytest configuration for the backend test suite.
+"""
+
+import os
+
+# Settings require a secret key; tests never rely on its value.
+os.environ.setdefault("SECRET_KEY", "test-secret-key")
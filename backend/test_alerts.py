@@ -0,0 +1,109 @@
+"""
+Tests for triggered alerts and acknowledgment.
+"""
+
+import asyncio
+from datetime import datetime, timedelta
+
+from app.models.schemas import AlertCondition, PriceAlertCreate
+from app.services.alerts import AlertService
+
+
+def _alert(service, symbol, condition, threshold, user_id=1):
+    return asyncio.run(
+        service.create_alert(
+            user_id,
+            PriceAlertCreate(symbol=symbol, condition=condition, threshold=threshold),
+        )
+    )
+
+
+def _unacknowledged(service, user_id=1):
+    return [a.id for a in asyncio.run(service.get_unacknowledged_alerts(user_id))]
+
+
+def test_lists_triggered_alerts_not_yet_acknowledged():
+    service = AlertService()
+    above = _alert(service, "AAPL", AlertCondition.ABOVE, 150)
+    below = _alert(service, "MSFT", AlertCondition.BELOW, 300)
+    _alert(service, "TSLA", AlertCondition.ABOVE, 500)
+    _alert(service, "AAPL", AlertCondition.ABOVE, 100, user_id=2)
+
+    asyncio.run(service.evaluate_prices({"AAPL": 155.0, "MSFT": 310.0}))
+    assert _unacknowledged(service) == [above.id]
+    asyncio.run(service.evaluate_prices({"MSFT": 290.0}))
+    assert set(_unacknowledged(service)) == {above.id, below.id}
+
+
+def test_acknowledging_hides_the_alert():
+    service = AlertService()
+    alert = _alert(service, "AAPL", AlertCondition.ABOVE, 150)
+    asyncio.run(service.evaluate_prices({"AAPL": 155.0}))
+
+    acked = asyncio.run(service.acknowledge(1, alert.id))
+    assert acked.acknowledged_at is not None
+    assert _unacknowledged(service) == []
+    # Another user's alert, or one that doesn't exist, can't be acknowledged
+    assert asyncio.run(service.acknowledge(2, alert.id)) is None
+    assert asyncio.run(service.acknowledge(1, 99)) is None
+
+
+def test_retrigger_resets_acknowledgment():
+    service = AlertService()
+    alert = _alert(service, "AAPL", AlertCondition.ABOVE, 150)
+    asyncio.run(service.evaluate_prices({"AAPL": 155.0}))
+    asyncio.run(service.acknowledge(1, alert.id))
+
+    # Acknowledged alerts are re-armed, so the next crossing fires again
+    assert asyncio.run(service.active_symbols()) == ["AAPL"]
+    retriggered = asyncio.run(service.evaluate_prices({"AAPL": 160.0}))
+    assert [a.id for a in retriggered] == [alert.id]
+    assert retriggered[0].acknowledged_at is None
+    assert retriggered[0].triggered_price == 160.0
+    assert _unacknowledged(service) == [alert.id]
+
+
+def test_pct_change_fires_once_against_the_previous_close():
+    service = AlertService()
+    alert = _alert(service, "AAPL", AlertCondition.PCT_CHANGE, 5)
+    closes = {"AAPL": 100.0}
+
+    assert asyncio.run(service.evaluate_prices({"AAPL": 104.0}, closes)) == []
+    # No previous close, or no price: skipped rather than guessed
+    assert asyncio.run(service.evaluate_prices({"AAPL": 90.0})) == []
+    assert asyncio.run(service.evaluate_prices({"MSFT": 1.0}, closes)) == []
+
+    # Each tick moves under 5%, but the day's move from the close adds up
+    fired = asyncio.run(service.evaluate_prices({"AAPL": 94.5}, closes))
+    assert [a.id for a in fired] == [alert.id]
+    # One-shot: it doesn't fire again until acknowledged
+    assert asyncio.run(service.evaluate_prices({"AAPL": 90.0}, closes)) == []
+    assert asyncio.run(service.active_symbols(AlertCondition.PCT_CHANGE)) == []
+
+
+def test_events_are_polled_since_the_last_one_seen():
+    now = [datetime(2025, 3, 3, 15, 0)]
+    service = AlertService(clock=lambda: now[0])
+    first = _alert(service, "AAPL", AlertCondition.ABOVE, 150)
+    second = _alert(service, "MSFT", AlertCondition.PCT_CHANGE, 2)
+    _alert(service, "MSFT", AlertCondition.BELOW, 100, user_id=2)
+
+    asyncio.run(service.evaluate_prices({"AAPL": 151.0}))
+    seen = now[0]
+    now[0] += timedelta(minutes=1)
+    asyncio.run(service.evaluate_prices({"MSFT": 306.0}, {"MSFT": 300.0}))
+
+    events = asyncio.run(service.get_events(1))
+    assert [(e.alert_id, e.price) for e in events] == [
+        (first.id, 151.0),
+        (second.id, 306.0),
+    ]
+    (latest,) = asyncio.run(service.get_events(1, since=seen))
+    assert (latest.previous_close, latest.triggered_at) == (300.0, now[0])
+
+    # Deleting an alert keeps its events; only the owner can delete it
+    assert not asyncio.run(service.delete_alert(2, first.id))
+    assert asyncio.run(service.delete_alert(1, first.id))
+    assert [a.id for a in asyncio.run(service.get_alerts(1))] == [second.id]
+    assert len(asyncio.run(service.get_events(1))) == 2
+    assert asyncio.run(service.get_events(2)) == []
@@ -0,0 +1,138 @@
+"""
+Tests for the Alpha Vantage provider and the market service's fallback to it.
+"""
+
+import asyncio
+from datetime import date
+
+import pytest
+from app.core import errors
+from app.data.alphavantage import (
+    AlphaVantageError,
+    AlphaVantageRateLimitError,
+    AlphaVantageService,
+)
+from app.services.market import MarketService
+
+QUOTE = {
+    "Global Quote": {
+        "01. symbol": "IBM",
+        "05. price": "182.5000",
+        "06. volume": "3100000",
+        "07. latest trading day": "2025-08-04",
+        "08. previous close": "180.0000",
+        "09. change": "2.5000",
+        "10. change percent": "1.3889%",
+    }
+}
+
+DAILY = {
+    "Time Series (Daily)": {
+        "2025-08-04": {"4. close": "182.50"},
+        "2025-08-01": {"4. close": "180.00"},
+        "2025-07-31": {"4. close": "179.25"},
+    }
+}
+
+
+class FakeResponse:
+    def __init__(self, body, status=200):
+        self.body = body
+        self.status = status
+
+    async def __aenter__(self):
+        return self
+
+    async def __aexit__(self, *exc):
+        pass
+
+    async def json(self):
+        return dict(self.body)
+
+    async def text(self):
+        return str(self.body)
+
+
+class FakeServer:
+    """Answers each Alpha Vantage function with a canned response."""
+
+    def __init__(self, responses):
+        self.responses = responses
+        self.calls = []
+
+    def get(self, url, params=None):
+        self.calls.append(dict(params))
+        return self.responses[params["function"]]
+
+
+def _service(responses):
+    service = AlphaVantageService("key")
+    service.session = FakeServer(responses)
+    return service
+
+
+def test_quote_is_normalized():
+    service = _service({"GLOBAL_QUOTE": FakeResponse(QUOTE)})
+    quote = asyncio.run(service.get_quote("ibm"))
+    assert quote["symbol"] == "IBM"
+    assert quote["price"] == 182.5
+    assert quote["change_percent"] == pytest.approx(1.3889)
+    assert quote["volume"] == 3100000
+    assert service.session.calls[0]["apikey"] == "key"
+
+
+def test_rate_limit_note_and_429_raise_typed_error():
+    note = {"Note": "Thank you for using Alpha Vantage! Our standard API..."}
+    service = _service({"GLOBAL_QUOTE": FakeResponse(note)})
+    with pytest.raises(AlphaVantageRateLimitError):
+        asyncio.run(service.get_quote("IBM"))
+
+    service = _service({"GLOBAL_QUOTE": FakeResponse({}, status=429)})
+    with pytest.raises(AlphaVantageRateLimitError):
+        asyncio.run(service.get_quote("IBM"))
+
+    error = {"Error Message": "Invalid API call."}
+    service = _service({"GLOBAL_QUOTE": FakeResponse(error)})
+    with pytest.raises(AlphaVantageError) as raised:
+        asyncio.run(service.get_quote("IBM"))
+    assert not isinstance(raised.value, AlphaVantageRateLimitError)
+
+
+def test_rate_limit_is_a_429_for_clients():
+    assert errors.provider_rate_limited("Alpha Vantage").status_code == 429
+
+
+def test_daily_closes_are_the_latest_days_in_order():
+    service = _service({"TIME_SERIES_DAILY": FakeResponse(DAILY)})
+    closes = asyncio.run(service.get_daily_closes("IBM", 2))
+    assert closes == {date(2025, 8, 1): 180.0, date(2025, 8, 4): 182.5}
+    assert service.session.calls[0]["outputsize"] == "compact"
+
+
+def test_unknown_stock_is_fetched_once_and_stored():
+    service = _service({"GLOBAL_QUOTE": FakeResponse(QUOTE)})
+    market = MarketService(service, "alphavantage")
+
+    stock = asyncio.run(market.find_stock("IBM"))
+    assert (stock.symbol, stock.price, stock.volume) == ("IBM", 182.5, 3100000)
+    again = asyncio.run(market.find_stock("ibm"))
+    assert again.id == stock.id
+    assert len(service.session.calls) == 1
+
+
+def test_unknown_stock_without_a_price_is_not_stored():
+    service = _service({"GLOBAL_QUOTE": FakeResponse({"Global Quote": {}})})
+    market = MarketService(service, "alphavantage")
+    assert asyncio.run(market.find_stock("ZZZZ")) is None
+    assert asyncio.run(market.get_stock_by_symbol("ZZZZ")) is None
+
+
+def test_history_is_backfilled_from_the_provider():
+    service = _service({"TIME_SERIES_DAILY": FakeResponse(DAILY)})
+    market = MarketService(service, "alphavantage")
+    start, end = date(2025, 8, 1), date(2025, 8, 4)
+
+    history = asyncio.run(market.get_stock_history("IBM", start, end))
+    assert [point.close for point in history.closes] == [180.0, 182.5]
+    asyncio.run(market.get_stock_history("IBM", start, end))
+    assert len(service.session.calls) == 1
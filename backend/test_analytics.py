@@ -0,0 +1,203 @@
+"""
+Tests for the dollar-cost averaging backfill and risk-parity weights.
+"""
+
+import asyncio
+from datetime import date, timedelta
+
+import pytest
+from app.core.config import settings
+from app.models.schemas import ContributionInterval, CostModel, DCARequest
+from app.services.analytics import (
+    AnalyticsService,
+    compute_risk_parity,
+    contribution_dates,
+    estimate_cost,
+    simulate_dca,
+    xirr,
+)
+from app.services.market import MarketService
+
+# Jan 1 is a market holiday, so January's purchase fills on Jan 2
+PRICES = {
+    "TEST": [
+        (date(2024, 1, 2), 10.0),
+        (date(2024, 1, 15), 12.0),
+        (date(2024, 2, 1), 20.0),
+        (date(2024, 3, 1), 10.0),
+        (date(2024, 4, 1), 20.0),
+    ],
+    "HALF": [
+        (date(2024, 1, 2), 50.0),
+        (date(2024, 2, 1), 50.0),
+        (date(2024, 3, 1), 50.0),
+        (date(2024, 4, 1), 50.0),
+    ],
+}
+
+
+def test_dca_single_symbol_hand_computed():
+    # 100/month buys 10 + 5 + 10 + 5 = 30 shares, worth 600 at 20
+    result = simulate_dca(PRICES, {"TEST": 1.0}, 100, date(2024, 1, 1))
+
+    assert [p.date for p in result.equity_curve] == [
+        date(2024, 1, 2),
+        date(2024, 2, 1),
+        date(2024, 3, 1),
+        date(2024, 4, 1),
+    ]
+    assert [p.value for p in result.equity_curve] == [100.0, 300.0, 250.0, 600.0]
+    assert result.total_contributed == 400.0
+    assert result.ending_value == 600.0
+    assert result.shares == {"TEST": 30.0}
+    assert result.total_return == pytest.approx(0.5)
+    # 400 invested at 10 on Jan 2 would be 40 shares worth 800
+    assert result.lump_sum.ending_value == 800.0
+    assert result.lump_sum.total_return == pytest.approx(1.0)
+    assert result.money_weighted_return > result.total_return
+
+
+def test_trade_cost_of_each_component():
+    # 200 shares at 50: 10,000 notional
+    commission = estimate_cost(50.0, 200, CostModel(commission_per_share=0.005))
+    assert (commission.notional, commission.commission, commission.total) == (
+        10000.0,
+        1.0,
+        1.0,
+    )
+    assert estimate_cost(50.0, 200, CostModel(fee_bps=10)).fee == 10.0
+    assert estimate_cost(50.0, 200, CostModel(slippage_bps=5)).slippage == 5.0
+
+    combined = estimate_cost(
+        50.0, 200, CostModel(commission_per_share=0.005, fee_bps=10, slippage_bps=5)
+    )
+    assert combined.total == 16.0
+    assert combined.cost_bps == pytest.approx(16.0)
+    assert estimate_cost(50.0, 200, CostModel()).total == 0.0
+
+
+def test_dca_costs_reduce_shares_bought():
+    # 25% of every purchase goes to costs: 100 buys 8 shares at 10, 4 at 20
+    costs = CostModel(fee_bps=1500, slippage_bps=1000)
+    result = simulate_dca(PRICES, {"TEST": 1.0}, 100, date(2024, 1, 1), costs=costs)
+
+    assert result.shares == {"TEST": 24.0}
+    assert result.total_costs == 80.0
+    assert result.ending_value == 480.0
+    # 400 at 12.50 a share all-in is 32 shares worth 640
+    assert result.lump_sum.ending_value == 640.0
+    assert simulate_dca(PRICES, {"TEST": 1.0}, 100, date(2024, 1, 1)).total_costs == 0
+
+
+def test_dca_weight_map_and_lump_sum_buy_fractional_shares():
+    result = simulate_dca(
+        PRICES,
+        {"TEST": 0.5, "HALF": 0.5},
+        100,
+        date(2024, 1, 1),
+        end=date(2024, 2, 15),
+        lump_sum=50,
+    )
+
+    # Jan: 150 -> 7.5 TEST + 1.5 HALF; Feb: 100 -> 2.5 TEST + 1 HALF
+    assert result.total_contributed == 250.0
+    assert result.ending_value == pytest.approx(10 * 20 + 2.5 * 50)
+    assert result.equity_curve[-1].date == date(2024, 2, 1)
+
+
+def test_dca_weekly_contributions_hand_computed():
+    # Weekly from Jan 1: fills Jan 2 at 10, Jan 15 (for the 8th and the 15th)
+    # at 12, then Feb 1 (for the 22nd and the 29th) at 20
+    result = simulate_dca(
+        PRICES,
+        {"TEST": 1.0},
+        60,
+        date(2024, 1, 1),
+        end=date(2024, 2, 1),
+        interval=ContributionInterval.WEEKLY,
+    )
+
+    assert result.total_contributed == 300.0
+    assert result.shares["TEST"] == pytest.approx(6 + 5 + 5 + 3 + 3)
+    assert result.ending_value == pytest.approx(22 * 20)
+    assert result.total_return == pytest.approx(440 / 300 - 1, abs=1e-6)
+
+
+def test_xirr_matches_simple_annual_return():
+    flows = [(date(2023, 1, 1), -100.0), (date(2024, 1, 1), 110.0)]
+    assert xirr(flows) == pytest.approx(0.10, abs=1e-6)
+
+
+def test_contribution_dates_and_validation():
+    assert contribution_dates(date(2024, 11, 15), date(2025, 2, 1)) == [
+        date(2024, 11, 15),
+        date(2024, 12, 1),
+        date(2025, 1, 1),
+        date(2025, 2, 1),
+    ]
+    quarterly = ContributionInterval.QUARTERLY
+    assert contribution_dates(date(2024, 11, 15), date(2025, 5, 1), quarterly) == [
+        date(2024, 11, 15),
+        date(2025, 2, 1),
+        date(2025, 5, 1),
+    ]
+    biweekly = ContributionInterval.BIWEEKLY
+    assert contribution_dates(date(2024, 1, 1), date(2024, 1, 28), biweekly) == [
+        date(2024, 1, 1),
+        date(2024, 1, 15),
+    ]
+    with pytest.raises(ValueError):
+        simulate_dca(PRICES, {"NONE": 1.0}, 100, date(2024, 1, 1))
+    for bad in (
+        {},
+        {"symbol": "AAPL", "weights": {"AAPL": 1.0}},
+        {"weights": {"AAPL": 0.5, "MSFT": 0.4}},
+    ):
+        with pytest.raises(ValueError):
+            DCARequest(contribution=500, start_date=date(2024, 1, 1), **bad)
+
+
+def _series(returns, start=date(2024, 1, 1), price=100.0):
+    series = [(start, price)]
+    for i, r in enumerate(returns):
+        price *= 1 + r
+        series.append((start + timedelta(days=i + 1), price))
+    return series
+
+
+def test_risk_parity_two_assets_weights_inverse_to_volatility():
+    pattern = [0.01, -0.005, 0.007, -0.012, 0.004, 0.009, -0.003, -0.006] * 5
+    noise = [0.002, -0.001, -0.003, 0.001] * 10
+    prices = {
+        "CALM": _series(pattern),
+        "WILD": _series([3 * r + n for r, n in zip(pattern, noise)]),
+    }
+
+    result = compute_risk_parity(prices)
+
+    calm, wild = result.weights
+    assert result.converged
+    assert result.observations == 40
+    assert calm.weight + wild.weight == pytest.approx(1)
+    assert calm.weight > wild.weight
+    # With two assets, equal risk means weights proportional to 1/volatility
+    assert calm.weight / wild.weight == pytest.approx(
+        wild.volatility / calm.volatility, rel=1e-4
+    )
+    assert calm.risk_contribution == pytest.approx(0.5, abs=1e-6)
+    assert wild.risk_contribution == pytest.approx(0.5, abs=1e-6)
+
+
+def test_risk_parity_service_caps_and_missing_history(monkeypatch):
+    market = MarketService()
+    service = AnalyticsService(market)
+    market.put_closes("AAA", dict(_series([0.01, -0.02, 0.015, 0.005])))
+
+    with pytest.raises(ValueError, match="No price history for BBB"):
+        asyncio.run(service.risk_parity(["AAA", "BBB"], date(2024, 1, 1)))
+    with pytest.raises(ValueError, match="at least 2 distinct"):
+        asyncio.run(service.risk_parity(["AAA", "aaa"], date(2024, 1, 1)))
+
+    monkeypatch.setattr(settings, "RISK_PARITY_MAX_SYMBOLS", 2)
+    with pytest.raises(ValueError, match="At most 2 symbols"):
+        asyncio.run(service.risk_parity(["AAA", "BBB", "CCC"], date(2024, 1, 1)))
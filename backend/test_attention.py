@@ -0,0 +1,77 @@
+"""
+Tests for the positions-needing-attention aggregation.
+"""
+
+import asyncio
+
+from app.models.schemas import AlertCondition, PriceAlertCreate
+from app.services.alerts import AlertService
+from app.services.attention import AttentionService
+from app.services.market import MarketService
+from app.services.portfolio import PortfolioService
+
+
+def _service(**thresholds):
+    return AttentionService(
+        PortfolioService(), MarketService(), AlertService(), **thresholds
+    )
+
+
+def _reasons(flagged):
+    return {
+        item.position.stock_symbol: [r.code for r in item.reasons] for item in flagged
+    }
+
+
+def test_positions_hitting_each_flag():
+    # Seed weights: AAPL 17.6% (target 20%), GOOGL 64.3% (60%), MSFT 18.1% (20%)
+    service = _service(drift_percent=4.0)
+    service.market._put_stock({"symbol": "AAPL", "change_percent": -6.2})
+
+    alert = asyncio.run(
+        service.alerts.create_alert(
+            1,
+            PriceAlertCreate(
+                symbol="msft", condition=AlertCondition.ABOVE, threshold=300
+            ),
+        )
+    )
+    # An alert that hasn't fired doesn't flag anything
+    asyncio.run(
+        service.alerts.create_alert(
+            1,
+            PriceAlertCreate(
+                symbol="AAPL", condition=AlertCondition.BELOW, threshold=100
+            ),
+        )
+    )
+    asyncio.run(service.alerts.evaluate("MSFT", 310.5))
+
+    flagged = asyncio.run(service.get_attention(1))
+
+    assert _reasons(flagged) == {
+        "AAPL": ["large_move"],
+        "GOOGL": ["drift"],
+        "MSFT": ["alert_triggered"],
+    }
+    messages = {i.position.stock_symbol: i.reasons[0].message for i in flagged}
+    assert "-6.20%" in messages["AAPL"]
+    assert "+4.3 points" in messages["GOOGL"]
+    assert f"Alert {alert.id}" in messages["MSFT"]
+
+
+def test_position_with_several_reasons():
+    service = _service(drift_percent=4.0)
+    service.market._put_stock({"symbol": "GOOGL", "change_percent": 8.0})
+
+    flagged = asyncio.run(service.get_attention(1))
+
+    assert _reasons(flagged) == {"GOOGL": ["large_move", "drift"]}
+
+
+def test_no_flags_with_default_thresholds():
+    assert asyncio.run(_service().get_attention(1)) == []
+
+
+def test_unknown_portfolio():
+    assert asyncio.run(_service().get_attention(99)) is None
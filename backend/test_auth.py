@@ -140,7 +140,7 @@ def test_configuration():
     print(f"Refresh token expiry: {settings.REFRESH_TOKEN_EXPIRE_DAYS} days")
     print(f"JWT Algorithm: {settings.JWT_ALGORITHM}")
     print(f"Project name: {settings.PROJECT_NAME}")
-    print(f"CORS origins: {settings.BACKEND_CORS_ORIGINS}")
+    print(f"CORS origins: {settings.CORS_ALLOWED_ORIGINS}")
 
     # Check if required settings are present
     required_settings = [
@@ -0,0 +1,110 @@
+"""
+Tests for the request body size limit and strict request body parsing.
+"""
+
+import asyncio
+import json
+
+import pytest
+from app.core import errors
+from app.core.bodylimit import BodySizeLimitMiddleware, RequestBodyTooLarge
+from app.models.schemas import CostEstimateRequest, Position
+
+
+def _run(max_bytes, chunks, headers=(), scope_type="http"):
+    """Send chunks through the middleware to an app that reads them all."""
+    read, sent = [], []
+
+    async def app(scope, receive, send):
+        while True:
+            message = await receive()
+            read.append(message["body"])
+            if not message["more_body"]:
+                break
+        await send({"type": "http.response.start", "status": 200, "headers": []})
+
+    pending = [
+        {"type": "http.request", "body": chunk, "more_body": i < len(chunks) - 1}
+        for i, chunk in enumerate(chunks)
+    ]
+
+    async def receive():
+        return pending.pop(0)
+
+    async def send(message):
+        sent.append(message)
+
+    scope = {"type": scope_type, "headers": list(headers)}
+    middleware = BodySizeLimitMiddleware(app, max_bytes=max_bytes)
+    asyncio.run(middleware(scope, receive, send))
+    return read, sent
+
+
+def test_a_declared_length_over_the_limit_is_refused_unread():
+    read, sent = _run(10, [b"x" * 11], headers=[(b"content-length", b"11")])
+
+    assert read == []
+    assert sent[0]["status"] == 413
+    body = json.loads(b"".join(m.get("body", b"") for m in sent[1:]))
+    assert body["code"] == "validation.body_too_large"
+    assert body["context"] == {"max_bytes": 10}
+
+
+def test_chunked_bodies_stop_once_over_the_limit():
+    with pytest.raises(RequestBodyTooLarge) as exc:
+        _run(10, [b"x" * 6, b"x" * 6, b"x" * 6])
+    assert exc.value.status_code == 413
+
+    # Exactly at the limit is fine, with or without a length
+    read, sent = _run(10, [b"x" * 4, b"x" * 6])
+    assert read == [b"x" * 4, b"x" * 6]
+    read, _ = _run(10, [b"x" * 10], headers=[(b"Content-Length", b"10")])
+    assert read == [b"x" * 10]
+
+
+def test_no_limit_and_non_http_scopes_pass_through():
+    read, _ = _run(0, [b"x" * 100], headers=[(b"content-length", b"100")])
+    assert read == [b"x" * 100]
+    read, _ = _run(10, [b"x" * 100], scope_type="websocket")
+    assert read == [b"x" * 100]
+
+
+def test_each_body_failure_has_its_own_code():
+    too_large = errors.from_http_status(413, "Request body is larger than 10 bytes")
+    assert (too_large.code, too_large.status_code) == (
+        "validation.body_too_large",
+        413,
+    )
+
+    malformed = [{"type": "json_invalid", "loc": ("body", 3), "msg": "x"}]
+    assert errors.from_validation_errors(malformed).code == "validation.body_malformed"
+
+    unknown = [
+        {"type": "extra_forbidden", "loc": ("body", "quantiy"), "msg": "x"},
+        {"type": "missing", "loc": ("body", "quantity"), "msg": "Field required"},
+    ]
+    error = errors.from_validation_errors(unknown)
+    assert (error.code, error.status_code) == ("validation.field_unknown", 400)
+    assert error.message == "Unknown field(s): body.quantiy"
+    assert error.context == {
+        "fields": [{"field": "body.quantiy", "message": "Unknown field"}]
+    }
+
+
+def test_request_bodies_reject_unknown_fields():
+    assert CostEstimateRequest(price=10, quantity=2).quantity == 2
+    with pytest.raises(ValueError, match="quantiy"):
+        CostEstimateRequest(price=10, quantity=2, quantiy=3)
+
+    # Responses built on a body model still take whatever their row holds
+    position = Position(
+        id=1,
+        portfolio_id=1,
+        stock_symbol="AAPL",
+        quantity=1,
+        average_price=1.0,
+        current_value=1.0,
+        total_gain=0.0,
+        stored_at="2024-01-01",
+    )
+    assert position.stock_symbol == "AAPL"
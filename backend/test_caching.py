@@ -0,0 +1,57 @@
+"""
+Tests for per-route Cache-Control headers.
+"""
+
+import pytest
+from app.core.caching import PRIVATE_NO_STORE, resolve_cache_control
+
+pytest.importorskip("httpx")
+
+from app.main import app  # noqa: E402
+from fastapi.testclient import TestClient  # noqa: E402
+
+# No context manager: startup (provider connections, jobs) isn't needed
+client = TestClient(app)
+
+
+def test_only_successful_anonymous_responses_use_the_declared_policy():
+    assert resolve_cache_control("public, max-age=60", False, 200) == (
+        "public, max-age=60"
+    )
+    assert resolve_cache_control("public, max-age=60", False, 404) is None
+    # A batch where some items failed
+    assert resolve_cache_control("public, max-age=5", False, 207) is None
+    assert resolve_cache_control("public, max-age=60", True, 200) == PRIVATE_NO_STORE
+    assert resolve_cache_control(None, False, 200) is None
+
+
+def test_public_route_is_cacheable():
+    response = client.get("/api/v1/market/stocks")
+    assert response.status_code == 200
+    assert response.headers["cache-control"] == "public, max-age=60"
+
+    quote = client.get("/api/v1/market/stocks/AAPL")
+    assert quote.headers["cache-control"] == "public, max-age=5"
+
+    missing = client.get("/api/v1/market/stocks/ZZZZ")
+    assert missing.status_code == 404
+    assert "cache-control" not in missing.headers
+
+
+def test_int64_encoding_is_part_of_the_cache_key():
+    for path in ("/api/v1/market/stocks", "/api/v1/market/stocks/AAPL"):
+        response = client.get(path, headers={"X-Int64-As-String": "true"})
+        assert response.headers["cache-control"].startswith("public")
+        assert "X-Int64-As-String" in response.headers["vary"]
+
+
+def test_private_route_is_never_stored():
+    response = client.get("/api/v1/portfolio/")
+    assert response.status_code == 200
+    assert response.headers["cache-control"] == PRIVATE_NO_STORE
+
+    # Credentials override a public route's policy
+    authenticated = client.get(
+        "/api/v1/market/stocks", headers={"Authorization": "Bearer abc"}
+    )
+    assert authenticated.headers["cache-control"] == PRIVATE_NO_STORE
@@ -0,0 +1,63 @@
+"""
+Tests for the cash drag estimate.
+"""
+
+import asyncio
+from datetime import date, timedelta
+
+import pytest
+from app.core.config import settings
+from app.services.market import MarketService
+from app.services.performance import PerformanceService
+from app.services.portfolio import PortfolioService
+
+START = date(2025, 3, 3)
+
+
+def _service(benchmark_closes):
+    portfolios, market = PortfolioService(), MarketService()
+    market.put_closes(
+        "SPY",
+        {START + timedelta(days=i): close for i, close in enumerate(benchmark_closes)},
+    )
+    return PerformanceService(portfolios, market), portfolios
+
+
+def test_fixed_cash_drags_by_the_benchmark_return():
+    service, portfolios = _service([100.0, 102.0, 99.0, 105.0, 110.0])
+    asyncio.run(portfolios.set_cash_balance(1, 10_000.0, START - timedelta(days=30)))
+
+    result = asyncio.run(service.cash_drag(1, START, START + timedelta(days=4)))
+    assert result.benchmark == "SPY"
+    assert result.benchmark_return == pytest.approx(0.10)
+    assert result.average_cash_balance == 10_000.0
+    assert result.drag == pytest.approx(1_000.0)
+
+
+def test_cash_interest_and_deposits(monkeypatch):
+    monkeypatch.setattr(settings, "RISK_FREE_RATE", 0.05)
+    service, portfolios = _service([100.0] * 366)
+    asyncio.run(portfolios.set_cash_balance(1, 1_000.0, START))
+    end = START + timedelta(days=365)
+
+    # A flat benchmark costs the interest the cash would have earned
+    result = asyncio.run(service.cash_drag(1, START, end))
+    assert result.cash_return == pytest.approx(0.05)
+    assert result.drag == pytest.approx(-50.0)
+
+    # A deposit counts as invested from the day it lands
+    monkeypatch.setattr(settings, "RISK_FREE_RATE", 0.0)
+    service, portfolios = _service([100.0, 100.0, 120.0])
+    asyncio.run(portfolios.set_cash_balance(1, 1_000.0, START))
+    asyncio.run(portfolios.set_cash_balance(1, 3_000.0, START + timedelta(days=1)))
+    result = asyncio.run(service.cash_drag(1, START, START + timedelta(days=2)))
+    assert result.drag == pytest.approx(600.0)
+    assert result.average_cash_balance == pytest.approx(7_000 / 3, abs=0.01)
+
+
+def test_missing_portfolio_and_benchmark():
+    service, portfolios = _service([100.0])
+    assert asyncio.run(service.cash_drag(99, START, START)) is None
+    with pytest.raises(ValueError, match="2 days of SPY"):
+        asyncio.run(service.cash_drag(1, START, START + timedelta(days=5)))
+    assert not asyncio.run(portfolios.set_cash_balance(99, 1.0, START))
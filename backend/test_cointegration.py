@@ -0,0 +1,129 @@
+"""
+Tests for the Engle-Granger cointegration test and the pair analysis.
+"""
+
+import asyncio
+import random
+from datetime import date, timedelta
+
+import pytest
+from app.services.analytics import AnalyticsService, compute_cointegration
+from app.services.market import MarketService
+from app.utils.cointegration import (
+    MIN_OBSERVATIONS,
+    adf_statistic,
+    critical_value,
+    engle_granger,
+    linear_fit,
+)
+
+START = date(2024, 1, 1)
+
+
+def _walk(rng, n, start=100.0):
+    values = [start]
+    for _ in range(n - 1):
+        values.append(values[-1] + rng.gauss(0, 1))
+    return values
+
+
+def _cointegrated_pair(n=250, seed=4):
+    # b wanders; a tracks 1.5 * b + 10 with a mean-reverting AR(1) spread
+    rng = random.Random(seed)
+    b = _walk(rng, n)
+    spread, a = 0.0, []
+    for value in b:
+        spread = 0.5 * spread + rng.gauss(0, 1)
+        a.append(10 + 1.5 * value + spread)
+    return a, b
+
+
+def _dated(values, start=START):
+    return [(start + timedelta(days=i), value) for i, value in enumerate(values)]
+
+
+def test_linear_fit_recovers_an_exact_line():
+    fit = linear_fit([5.0, 7.0, 9.0, 11.0], [1.0, 2.0, 3.0, 4.0])
+    assert (fit.intercept, fit.slope) == pytest.approx((3.0, 2.0))
+    assert fit.residuals == pytest.approx([0.0] * 4)
+    with pytest.raises(ValueError, match="constant"):
+        linear_fit([1.0, 2.0], [3.0, 3.0])
+
+
+def test_adf_separates_noise_from_a_random_walk():
+    rng = random.Random(3)
+    noise = [rng.gauss(0, 1) for _ in range(250)]
+    walk = _walk(random.Random(3), 250, start=0.0)
+
+    statistic, observations = adf_statistic(noise, lags=1)
+    assert observations == 248  # 249 changes, one used as a lag
+    assert statistic < -8
+    assert adf_statistic(walk, lags=1)[0] > critical_value(0.10, 248)
+    with pytest.raises(ValueError, match="too few"):
+        adf_statistic([1.0, 2.0, 1.0], lags=1)
+
+
+def test_critical_values_tighten_with_significance():
+    assert critical_value(0.01, 250) < critical_value(0.05, 250)
+    assert critical_value(0.05, 250) < critical_value(0.10, 250)
+    # Large samples approach MacKinnon's asymptotic value
+    assert critical_value(0.05, 10**9) == pytest.approx(-3.33613)
+    with pytest.raises(ValueError, match="one of 0.01, 0.05, 0.1"):
+        critical_value(0.2, 250)
+
+
+def test_a_synthetic_cointegrated_pair_is_detected():
+    a, b = _cointegrated_pair()
+
+    for lags in (0, 1, 4):
+        result = engle_granger(a, b, significance=0.01, lags=lags)
+        assert result.cointegrated
+        assert result.adf_statistic < result.critical_value
+        assert result.hedge_ratio == pytest.approx(1.5, abs=0.05)
+        assert result.observations == 249 - lags
+
+
+def test_independent_random_walks_are_not_cointegrated():
+    rng = random.Random(11)
+    a, b = _walk(rng, 250), _walk(rng, 250)
+
+    result = engle_granger(a, b, significance=0.10)
+    assert not result.cointegrated
+    assert result.adf_statistic > result.critical_value
+
+
+def test_too_few_or_misaligned_closes_are_rejected():
+    a, b = _cointegrated_pair(n=MIN_OBSERVATIONS - 1)
+    with pytest.raises(ValueError, match=f"at least {MIN_OBSERVATIONS}"):
+        engle_granger(a, b)
+    with pytest.raises(ValueError, match="30 and 29 values"):
+        engle_granger(a + [1.0], b)
+
+    # Plenty of closes each, but on alternate days, so none line up
+    a, b = _cointegrated_pair(n=100)
+    dated_a, dated_b = _dated(a)[::2], _dated(b)[1::2]
+    with pytest.raises(ValueError, match="got 0"):
+        compute_cointegration(dated_a, dated_b)
+
+
+def test_service_tests_stored_closes_of_a_pair():
+    market = MarketService()
+    service = AnalyticsService(market)
+    a, b = _cointegrated_pair()
+    market.put_closes("AAA", dict(_dated(a)))
+    market.put_closes("BBB", dict(_dated(b)))
+    end = START + timedelta(days=249)
+
+    result = asyncio.run(service.cointegration("aaa", "BBB", START, end))
+
+    assert (result.symbol_a, result.symbol_b) == ("AAA", "BBB")
+    assert (result.start, result.end) == (START, end)
+    assert result.observations == 250
+    assert result.cointegrated
+    assert result.significance == 0.05 and result.lags == 1
+    assert result.hedge_ratio == pytest.approx(1.5, abs=0.05)
+
+    with pytest.raises(ValueError, match="two different symbols"):
+        asyncio.run(service.cointegration("AAA", "aaa", START))
+    with pytest.raises(ValueError, match="No price history for CCC"):
+        asyncio.run(service.cointegration("AAA", "CCC", START))
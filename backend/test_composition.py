@@ -0,0 +1,94 @@
+"""
+Tests for reconstructing past portfolio composition from the ledger.
+"""
+
+import asyncio
+from datetime import date, datetime, timedelta
+
+from app.models.schemas import TradeSide, TransactionCreate
+from app.services.composition import CompositionService
+from app.services.ledger import LedgerService
+from app.services.market import MarketService
+from app.services.portfolio import PortfolioService
+
+START = date(2025, 3, 3)  # A Monday
+
+
+def _service():
+    market = MarketService()
+    for symbol, first_close in (("AAPL", 100.0), ("MSFT", 300.0)):
+        market.put_closes(
+            symbol,
+            {START + timedelta(days=i): first_close + i for i in range(5)},
+        )
+    ledger = LedgerService()
+    for day, symbol, side, quantity in [
+        (0, "AAPL", TradeSide.BUY, 10),
+        (0, "MSFT", TradeSide.BUY, 2),
+        (2, "AAPL", TradeSide.SELL, 4),
+        (3, "MSFT", TradeSide.SELL, 2),
+    ]:
+        asyncio.run(
+            ledger.record(
+                1,
+                TransactionCreate(
+                    symbol=symbol,
+                    side=side,
+                    quantity=quantity,
+                    price=100.0,
+                    executed_at=datetime(2025, 3, 3 + day, 15),
+                ),
+            )
+        )
+    return CompositionService(PortfolioService(), ledger, market)
+
+
+def _composition(service, as_of, portfolio_id=1):
+    return asyncio.run(service.get_composition(portfolio_id, as_of))
+
+
+def test_replays_buys_and_sells_up_to_the_day():
+    service = _service()
+
+    # After the buys, before the AAPL sell
+    first = _composition(service, START + timedelta(days=1))
+    assert [(h.symbol, h.quantity, h.close) for h in first.holdings] == [
+        ("AAPL", 10, 101.0),
+        ("MSFT", 2, 301.0),
+    ]
+    assert first.total_value == 1010.0 + 602.0
+
+    # Between the AAPL sell and the MSFT sell
+    between = _composition(service, START + timedelta(days=2))
+    assert [(h.symbol, h.quantity) for h in between.holdings] == [
+        ("AAPL", 6),
+        ("MSFT", 2),
+    ]
+    assert between.total_value == 6 * 102.0 + 2 * 302.0
+    assert between.holdings[0].weight == round(612.0 / 1216.0, 6)
+
+    # MSFT was sold out
+    after = _composition(service, START + timedelta(days=3))
+    assert [h.symbol for h in after.holdings] == ["AAPL"]
+    assert after.holdings[0].weight == 1.0
+
+
+def test_before_the_first_transaction_is_empty():
+    composition = _composition(_service(), START - timedelta(days=1))
+    assert (composition.holdings, composition.total_value) == ([], 0.0)
+
+
+def test_weekend_uses_the_last_close_and_unknown_portfolio_is_none():
+    service = _service()
+    saturday = START + timedelta(days=5)
+
+    composition = _composition(service, saturday)
+    assert composition.holdings[0].close == 104.0  # Friday's
+    assert composition.missing_prices == []
+
+    # No close within the week before
+    late = _composition(service, START + timedelta(days=30))
+    assert late.missing_prices == ["AAPL"]
+    assert (late.holdings[0].value, late.total_value) == (None, 0.0)
+
+    assert _composition(service, saturday, portfolio_id=99) is None
@@ -0,0 +1,51 @@
+"""
+Tests for portfolio concentration (HHI).
+"""
+
+import asyncio
+
+import pytest
+from app.services.analytics import hhi, normalized_hhi
+from app.services.concentration import ConcentrationService
+from app.services.portfolio import PortfolioService
+
+
+def _service(values):
+    portfolios = PortfolioService()
+    for position in portfolios.portfolios.list_positions():
+        portfolios.portfolios.delete_position(position["id"])
+    for i, value in enumerate(values):
+        portfolios._insert_position(1, f"S{i}", 1, value, value)
+    return ConcentrationService(portfolios)
+
+
+def test_equal_weights_give_one_over_n():
+    for n in (1, 4, 10):
+        assert hhi([1 / n] * n) == pytest.approx(1 / n)
+    assert normalized_hhi([0.25] * 4) == pytest.approx(0.0)
+    assert normalized_hhi([1.0]) == 1.0
+
+    result = asyncio.run(_service([2500.0] * 4).get_concentration(1))
+    assert result.positions == 4
+    assert result.hhi == pytest.approx(0.25)
+    assert result.effective_positions == pytest.approx(4.0)
+    # At the threshold isn't above it
+    assert not result.concentrated
+
+
+def test_one_dominant_position_is_concentrated():
+    result = asyncio.run(_service([9000.0, 500.0, 500.0]).get_concentration(1))
+
+    assert result.hhi == pytest.approx(0.81 + 0.0025 + 0.0025)
+    assert result.normalized_hhi == pytest.approx((0.815 - 1 / 3) / (2 / 3), abs=1e-6)
+    assert result.effective_positions == pytest.approx(1 / 0.815, abs=1e-4)
+    assert result.concentrated
+
+
+def test_empty_and_unknown_portfolio():
+    result = asyncio.run(_service([]).get_concentration(1))
+    assert result.positions == 0 and result.hhi is None
+    assert not result.concentrated
+    assert asyncio.run(_service([]).get_concentration(99)) is None
+    with pytest.raises(ValueError):
+        hhi([])
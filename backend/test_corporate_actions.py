@@ -0,0 +1,119 @@
+"""
+Tests for listing the corporate actions that affected a portfolio.
+"""
+
+import asyncio
+from datetime import date, datetime
+
+import pytest
+from app.models.schemas import (
+    CorporateActionCreate,
+    CorporateActionType,
+    TradeSide,
+    TransactionCreate,
+)
+from app.services.corporate_actions import CorporateActionService
+from app.services.ledger import LedgerService
+from app.services.portfolio import PortfolioService
+
+SPLIT = CorporateActionType.SPLIT
+DIVIDEND = CorporateActionType.DIVIDEND
+
+
+def _service(actions):
+    """
+    The sample portfolio (AAPL, GOOGL, MSFT) with AAPL bought on 3 March and
+    half sold on 2 June; GOOGL and MSFT have no transactions.
+    """
+    ledger = LedgerService()
+    for day, side, quantity in [
+        (datetime(2025, 3, 3, 15), TradeSide.BUY, 10),
+        (datetime(2025, 6, 2, 15), TradeSide.SELL, 5),
+    ]:
+        asyncio.run(
+            ledger.record(
+                1,
+                TransactionCreate(
+                    symbol="AAPL",
+                    side=side,
+                    quantity=quantity,
+                    price=100.0,
+                    executed_at=day,
+                ),
+            )
+        )
+    service = CorporateActionService(PortfolioService(), ledger)
+    for symbol, action_type, ex_date, amount in actions:
+        asyncio.run(
+            service.record_action(
+                CorporateActionCreate(
+                    symbol=symbol,
+                    type=action_type,
+                    ex_date=ex_date,
+                    split_ratio=amount if action_type == SPLIT else None,
+                    dividend_per_share=amount if action_type == DIVIDEND else None,
+                )
+            )
+        )
+    return service
+
+
+def _actions(service, start, end, portfolio_id=1):
+    return asyncio.run(service.get_portfolio_actions(portfolio_id, start, end))
+
+
+def test_held_splits_and_dividends_are_listed_by_ex_date():
+    service = _service(
+        [
+            ("MSFT", DIVIDEND, date(2025, 5, 15), 0.83),
+            ("aapl", SPLIT, date(2025, 4, 10), 4.0),
+            ("TSLA", SPLIT, date(2025, 4, 1), 3.0),  # Never held
+            ("AAPL", DIVIDEND, date(2025, 8, 11), 0.26),
+        ]
+    )
+
+    result = _actions(service, date(2025, 1, 1), date(2025, 12, 31))
+    assert [(a.symbol, a.type, a.ex_date) for a in result.actions] == [
+        ("AAPL", SPLIT, date(2025, 4, 10)),
+        ("MSFT", DIVIDEND, date(2025, 5, 15)),
+        ("AAPL", DIVIDEND, date(2025, 8, 11)),
+    ]
+    split, msft_dividend, aapl_dividend = result.actions
+    assert (split.split_ratio, split.quantity) == (4.0, 10)
+    assert split.dividend_amount is None
+    # MSFT isn't in the ledger, so its current position counts
+    assert (msft_dividend.quantity, msft_dividend.dividend_amount) == (5, 4.15)
+    # Half the AAPL was sold before this ex-date
+    assert (aapl_dividend.quantity, aapl_dividend.dividend_amount) == (5, 1.3)
+
+
+def test_actions_outside_the_period_or_before_buying_are_left_out():
+    service = _service(
+        [
+            ("AAPL", DIVIDEND, date(2025, 2, 10), 0.25),  # Before the first buy
+            ("AAPL", DIVIDEND, date(2025, 3, 3), 0.25),  # Bought on the ex-date
+            ("GOOGL", SPLIT, date(2025, 7, 1), 20.0),
+        ]
+    )
+
+    assert _actions(service, date(2025, 1, 1), date(2025, 6, 30)).actions == []
+    result = _actions(service, date(2025, 7, 1), date(2025, 7, 1))
+    assert [(a.symbol, a.quantity) for a in result.actions] == [("GOOGL", 2)]
+    assert _actions(service, date(2025, 1, 1), date(2025, 12, 31), 99) is None
+
+
+def test_amount_must_match_the_action_type():
+    for action_type, split_ratio, dividend_per_share in [
+        (SPLIT, None, None),
+        (SPLIT, 2.0, 0.5),
+        (DIVIDEND, None, None),
+        (DIVIDEND, 2.0, None),
+    ]:
+        with pytest.raises(ValueError):
+            CorporateActionCreate(
+                symbol="AAPL",
+                type=action_type,
+                ex_date=date(2025, 1, 1),
+                split_ratio=split_ratio,
+                dividend_per_share=dividend_per_share,
+            )
@@ -0,0 +1,148 @@
+"""
+Tests for the CORS origin allowlist.
+"""
+
+import asyncio
+import json
+
+import pytest
+from app.core.config import Settings
+from app.core.cors import AllowedOrigins, CORSMiddleware
+
+
+def test_allowed_origins_are_read_comma_separated(monkeypatch):
+    monkeypatch.delenv("CORS_ALLOWED_ORIGINS", raising=False)
+    monkeypatch.delenv("BACKEND_CORS_ORIGINS", raising=False)
+    assert Settings().CORS_ALLOWED_ORIGINS is None
+    assert Settings(CORS_ALLOWED_ORIGINS=" ").CORS_ALLOWED_ORIGINS is None
+
+    origins = "https://app.example.com/, http://localhost:3000"
+    assert Settings(CORS_ALLOWED_ORIGINS=origins).CORS_ALLOWED_ORIGINS == [
+        "https://app.example.com",
+        "http://localhost:3000",
+    ]
+    # The old name still works
+    legacy = Settings(BACKEND_CORS_ORIGINS="http://localhost:4200")
+    assert legacy.CORS_ALLOWED_ORIGINS == ["http://localhost:4200"]
+
+    with pytest.raises(ValueError, match="only a leading"):
+        Settings(CORS_ALLOWED_ORIGINS="https://app.*.example.com")
+
+
+def test_exact_and_wildcard_origins():
+    origins = AllowedOrigins(
+        ["https://app.example.com", "https://*.example.org", "*.example.net"]
+    )
+    assert origins.allow_origin("https://app.example.com") == "https://app.example.com"
+    assert origins.allow_origin("https://App.Example.com") == "https://App.Example.com"
+    assert origins.allow_origin("http://app.example.com") is None
+    assert origins.allow_origin("https://app.example.com:8443") is None
+
+    assert origins.allow_origin("https://a.b.example.org") == "https://a.b.example.org"
+    assert origins.allow_origin("https://example.org") is None
+    assert origins.allow_origin("http://a.example.org") is None
+    assert origins.allow_origin("https://a.example.org.evil.test") is None
+    # No scheme: either http or https
+    assert origins.allow_origin("http://a.example.net") == "http://a.example.net"
+    assert origins.allow_origin("https://a.example.net") == "https://a.example.net"
+
+    assert AllowedOrigins(None).allow_origin("https://any.test") == "*"
+    assert AllowedOrigins(["*"]).allow_origin("https://any.test") == "*"
+
+
+def _request(origins, origin, method="GET", request_headers=None, sent=None):
+    """
+    The status and headers the middleware sends for a request from origin;
+    every message sent is added to sent when it's given.
+    """
+
+    async def app(scope, receive, send):
+        headers = [(b"vary", b"Accept-Encoding")]
+        await send({"type": "http.response.start", "status": 200, "headers": headers})
+        await send({"type": "http.response.body", "body": b""})
+
+    middleware = CORSMiddleware(app, allowed_origins=origins, max_age=600)
+    headers = [(b"origin", origin.encode())]
+    if method == "OPTIONS":
+        headers.append((b"access-control-request-method", b"DELETE"))
+    if request_headers:
+        headers.append((b"access-control-request-headers", request_headers.encode()))
+    scope = {"type": "http", "method": method, "path": "/", "headers": headers}
+    sent = [] if sent is None else sent
+
+    async def receive():
+        return {"type": "http.request", "body": b""}
+
+    async def send(message):
+        sent.append(message)
+
+    asyncio.run(middleware(scope, receive, send))
+    return sent[0]["status"], {k.decode(): v.decode() for k, v in sent[0]["headers"]}
+
+
+def _cors(headers):
+    return {k: v for k, v in headers.items() if k.startswith("access-control-")}
+
+
+ORIGINS = ["https://app.example.com", "https://*.example.org"]
+
+
+def test_simple_requests_echo_matching_origins():
+    for origin in ("https://app.example.com", "https://beta.example.org"):
+        status, headers = _request(ORIGINS, origin)
+        assert status == 200
+        assert _cors(headers) == {
+            "access-control-allow-origin": origin,
+            "access-control-allow-credentials": "true",
+        }
+        assert headers["vary"] == "Accept-Encoding, Origin"
+
+    status, headers = _request(ORIGINS, "https://evil.test")
+    assert status == 200 and _cors(headers) == {}
+    assert headers["vary"] == "Accept-Encoding, Origin"
+
+    # Any origin, without credentials
+    _, headers = _request(None, "https://any.test")
+    assert _cors(headers) == {"access-control-allow-origin": "*"}
+
+
+def test_preflights_allow_the_requested_headers():
+    status, headers = _request(
+        ORIGINS,
+        "https://beta.example.org",
+        method="OPTIONS",
+        request_headers="Authorization, X-Custom-Header",
+    )
+    assert status == 204
+    assert _cors(headers) == {
+        "access-control-allow-origin": "https://beta.example.org",
+        "access-control-allow-credentials": "true",
+        "access-control-allow-methods": "DELETE",
+        "access-control-allow-headers": "Authorization, X-Custom-Header",
+        "access-control-max-age": "600",
+    }
+    assert headers["vary"] == "Origin"
+
+    # Nothing asked for, nothing allowed
+    _, headers = _request(ORIGINS, "https://app.example.com", method="OPTIONS")
+    assert "access-control-allow-headers" not in headers
+
+    _, headers = _request(None, "https://any.test", method="OPTIONS")
+    assert headers["access-control-allow-origin"] == "*"
+    assert "access-control-allow-credentials" not in headers
+
+
+def test_preflights_from_other_origins_are_refused_without_cors_headers():
+    sent = []
+    status, headers = _request(
+        ORIGINS, "https://example.org", method="OPTIONS", sent=sent
+    )
+    assert status == 400
+    assert _cors(headers) == {}
+    assert headers["vary"] == "Origin"
+
+    # The standard error body, like every other API error
+    assert headers["content-type"] == "application/json"
+    body = json.loads(sent[1]["body"])
+    assert body["error"] is True
+    assert body["code"] == "http.origin_not_allowed"
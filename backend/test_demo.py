@@ -0,0 +1,157 @@
+"""
+Tests for demo mode: the synthetic provider, dataset reset and live feed.
+"""
+
+import asyncio
+import json
+from datetime import date, datetime, timezone
+
+from app.data.synthetic import SyntheticProvider, is_market_open
+from app.services.alerts import AlertService
+from app.services.demo import DEMO_USER_ID, DemoService
+from app.services.market import MarketService
+from app.services.portfolio import PortfolioService
+from app.services.refresher import QuoteRefresher
+from app.ws.feed import PollingFeed
+from app.ws.hub import ConnectionManager
+
+# Monday 2025-08-04, 10:00 in New York
+MARKET_OPEN_TS = datetime(2025, 8, 4, 14, 0, tzinfo=timezone.utc).timestamp()
+
+
+class FakeClock:
+    def __init__(self, now=MARKET_OPEN_TS):
+        self.now = now
+
+    def __call__(self):
+        return self.now
+
+
+class FakeClient:
+    def __init__(self):
+        self.client = "fake-client"
+        self.sent = []
+
+    async def accept(self):
+        pass
+
+    async def send_text(self, message: str):
+        self.sent.append(json.loads(message))
+
+
+def _demo(clock):
+    provider = SyntheticProvider({}, seed=7, clock=clock)
+    market, portfolios, alerts = MarketService(), PortfolioService(), AlertService()
+    demo = DemoService(provider, market, portfolios, alerts, seed=7)
+    asyncio.run(demo.reset())
+    return demo, QuoteRefresher(provider, market, portfolios, alerts)
+
+
+def test_market_hours():
+    utc = timezone.utc
+    assert is_market_open(datetime(2025, 8, 4, 13, 30, tzinfo=utc))  # 9:30 ET
+    assert not is_market_open(datetime(2025, 8, 4, 20, 0, tzinfo=utc))  # 16:00 ET
+    assert not is_market_open(datetime(2025, 8, 2, 15, 0, tzinfo=utc))  # Saturday
+
+
+def test_walk_is_deterministic_and_anchored():
+    def path(seed, polls):
+        clock = FakeClock()
+        provider = SyntheticProvider({"AAPL": 150.25}, seed=seed, clock=clock)
+        prices = []
+        for seconds in polls:
+            clock.now = MARKET_OPEN_TS + seconds
+            prices.append(asyncio.run(provider.get_quote("aapl"))["price"])
+        return prices
+
+    # Polling frequency doesn't change where the walk ends up
+    sparse = path(1, [0, 600])
+    dense = path(1, range(0, 601, 7))
+    assert sparse[-1] == dense[-1]
+    assert sparse[0] == 150.25
+    assert path(2, [0, 600])[-1] != sparse[-1]
+    # Ten minutes of a 1.5% daily volatility walk stays close to the anchor
+    assert 140 < sparse[-1] < 160
+
+
+def test_daily_closes_end_at_the_anchor_and_need_no_key():
+    provider = SyntheticProvider({"AAPL": 150.25}, seed=1, clock=FakeClock())
+    closes = asyncio.run(provider.get_daily_closes("aapl", 5))
+
+    # The five trading days up to Monday 2025-08-04, skipping the weekend
+    assert list(closes)[0] == date(2025, 7, 29)
+    assert closes[date(2025, 8, 4)] == 150.25
+    longer = asyncio.run(provider.get_daily_closes("AAPL", 20))
+    assert all(longer[day] == close for day, close in closes.items())
+
+    # So history backfills without a real provider
+    market = MarketService(provider, "synthetic")
+    history = asyncio.run(
+        market.get_stock_history("AAPL", date(2025, 7, 29), date(2025, 8, 4))
+    )
+    assert [point.close for point in history.closes] == list(closes.values())
+
+
+def test_reset_seeds_demo_portfolios_and_undoes_writes():
+    clock = FakeClock()
+    demo, refresher = _demo(clock)
+
+    portfolios = demo.portfolios.portfolios.list(DEMO_USER_ID)
+    assert len(portfolios) == 3
+    asyncio.run(
+        demo.portfolios.create_position(
+            {
+                "portfolio_id": 2,
+                "stock_symbol": "tsla",
+                "quantity": 1,
+                "average_price": 1,
+            }
+        )
+    )
+    assert len(asyncio.run(demo.portfolios.get_positions(2))) == 3
+
+    asyncio.run(demo.reset())
+    assert len(asyncio.run(demo.portfolios.get_positions(2))) == 2
+    assert demo.resets == 2
+
+
+def test_refresher_moves_prices_values_and_triggers_planted_alerts():
+    clock = FakeClock()
+    demo, refresher = _demo(clock)
+    seeded = asyncio.run(demo.market.get_stock_by_symbol("MSFT"))
+
+    clock.now += 3600
+    assert asyncio.run(refresher.refresh_once()) == 3
+    stock = asyncio.run(demo.market.get_stock_by_symbol("MSFT"))
+    assert stock.price != seeded.price
+    assert stock.change == round(seeded.change + stock.price - seeded.price, 2)
+    position = asyncio.run(demo.portfolios.get_positions(2))[0]
+    assert position.current_value == round(position.quantity * stock.price, 2)
+
+    asyncio.run(demo.plant_alert())
+    asyncio.run(refresher.refresh_once())
+    triggered = asyncio.run(demo.alerts.get_triggered_alerts(DEMO_USER_ID))
+    assert len(triggered) == 1
+
+
+def test_smoke_quotes_change_over_the_stream():
+    clock = FakeClock()
+    demo, _ = _demo(clock)
+
+    async def scenario():
+        manager = ConnectionManager(PollingFeed(demo.provider, interval=0.01))
+        client = FakeClient()
+        await manager.connect(client)
+        await manager.subscribe(client, "AAPL")
+        task = asyncio.create_task(manager.broadcast_ticks())
+
+        deadline = asyncio.get_running_loop().time() + 2.0
+        prices = set()
+        while len(prices) < 2:
+            assert asyncio.get_running_loop().time() < deadline, prices
+            clock.now += 5
+            await asyncio.sleep(0.02)
+            prices = {tick["price"] for tick in client.sent}
+        task.cancel()
+
+    asyncio.run(scenario())
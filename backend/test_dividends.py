@@ -0,0 +1,39 @@
+"""
+Tests for the value-weighted dividend yield.
+"""
+
+import asyncio
+
+from app.services.dividends import DividendService
+from app.services.market import MarketService
+from app.services.portfolio import PortfolioService
+
+
+def _two_position_service():
+    portfolios = PortfolioService()
+    for position in portfolios.portfolios.list_positions():
+        portfolios.portfolios.delete_position(position["id"])
+    portfolios._insert_position(1, "MSFT", 10, 250.00, 3000.00)
+    portfolios._insert_position(1, "GOOGL", 1, 900.00, 1000.00)
+
+    market = MarketService()
+    market._put_stock({"symbol": "MSFT", "dividend_yield": 0.02})
+    market._put_stock({"symbol": "GOOGL", "dividend_yield": None})
+    return DividendService(portfolios, market)
+
+
+def test_non_payer_counts_as_zero_and_is_noted():
+    result = asyncio.run(_two_position_service().get_portfolio_yield(1))
+
+    # (3000 * 0.02 + 1000 * 0) / 4000
+    assert result.total_value == 4000.00
+    assert result.weighted_yield == 0.015
+    assert result.missing_yield == ["GOOGL"]
+    assert [(p.stock_symbol, p.dividend_yield, p.weight) for p in result.positions] == [
+        ("MSFT", 0.02, 0.75),
+        ("GOOGL", None, 0.25),
+    ]
+
+
+def test_unknown_portfolio():
+    assert asyncio.run(_two_position_service().get_portfolio_yield(99)) is None
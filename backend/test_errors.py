@@ -0,0 +1,173 @@
+"""
+Tests for the error catalog and its constructors.
+"""
+
+import inspect
+
+from app.core import errors
+from app.core.faults import InjectedDatabaseTimeout
+from app.core.logging import request_id_var
+from sqlalchemy import exc as sa_exc
+
+# Golden list: codes are public API. Update this deliberately, never to make
+# a rename pass.
+EXPECTED_CODES = [
+    "alert.not_found",
+    "analytics.invalid_request",
+    "auth.account_locked",
+    "auth.account_suspended",
+    "auth.email_unverified",
+    "auth.forbidden",
+    "auth.invalid_credentials",
+    "auth.login_rejected",
+    "auth.password_rejected",
+    "auth.refresh_rejected",
+    "auth.registration_rejected",
+    "auth.reset_token_invalid",
+    "auth.token_expired",
+    "auth.token_invalid",
+    "auth.token_missing",
+    "auth.user_not_found",
+    "auth.verification_token_invalid",
+    "database.conflict",
+    "database.unavailable",
+    "fault.injected",
+    "http.error",
+    "http.method_not_allowed",
+    "http.not_found",
+    "http.origin_not_allowed",
+    "http.timeout",
+    "internal.error",
+    "portfolio.not_found",
+    "position.adjustment_invalid",
+    "position.import_invalid",
+    "position.not_found",
+    "position.symbol_held",
+    "provider.not_supported",
+    "provider.rate_limited",
+    "provider.unavailable",
+    "rate_limit.exceeded",
+    "rate_limit.unavailable",
+    "stock.not_found",
+    "stock.restricted",
+    "stock.symbol_invalid",
+    "transaction.duplicate",
+    "transaction.invalid",
+    "transaction.price_moved",
+    "validation.body_malformed",
+    "validation.body_too_large",
+    "validation.field_invalid",
+    "validation.field_unknown",
+    "validation.history_range_invalid",
+    "validation.param_conflict",
+    "validation.param_invalid",
+    "watchlist.name_taken",
+    "watchlist.not_found",
+    "watchlist.order_invalid",
+]
+
+
+def _call_with_sample_args(constructor):
+    args = [
+        1 if param.annotation is int else "sample"
+        for param in inspect.signature(constructor).parameters.values()
+        if param.default is inspect.Parameter.empty
+    ]
+    return constructor(*args)
+
+
+def test_catalog_codes_are_unique_and_locked():
+    codes = [spec.code for spec in errors.ERROR_SPECS]
+    assert len(codes) == len(set(codes))
+    assert sorted(codes) == EXPECTED_CODES
+    assert [entry["code"] for entry in errors.get_error_catalog()] == EXPECTED_CODES
+
+
+def test_every_code_is_reachable_from_a_constructor():
+    reached = {}
+    for constructor in errors.ERROR_CONSTRUCTORS:
+        error = _call_with_sample_args(constructor)
+        assert isinstance(error, errors.AppError)
+        reached[error.code] = error
+
+    assert sorted(reached) == EXPECTED_CODES
+    for code, error in reached.items():
+        if code != "http.error":
+            assert error.status_code == errors.ERROR_CATALOG[code].status
+
+
+def test_error_body_and_http_status_mapping():
+    body = errors.error_body(errors.stock_not_found("ZZZZ"))
+    assert body == {
+        "error": True,
+        "code": "stock.not_found",
+        "message": "Stock with symbol 'ZZZZ' not found",
+        "detail": None,
+    }
+    token = request_id_var.set("abc123")
+    try:
+        assert errors.error_body(errors.internal_error())["request_id"] == "abc123"
+    finally:
+        request_id_var.reset(token)
+
+    assert errors.from_http_status(404, "Not Found").code == "http.not_found"
+    assert errors.from_http_status(405, "x").code == "http.method_not_allowed"
+    assert errors.from_http_status(503, "x").code == "internal.error"
+    malformed = [{"type": "json_invalid", "loc": ("body", 9), "msg": "x"}]
+    assert errors.from_validation_errors(malformed).status_code == 400
+    missing = [{"type": "missing", "loc": ("body", "quantity"), "msg": "x"}]
+    assert errors.from_validation_errors(missing).code == "validation.field_invalid"
+    not_a_number = [{"type": "int_parsing", "loc": ("path", "position_id"), "msg": "x"}]
+    bad_id = errors.from_validation_errors(not_a_number)
+    assert (bad_id.code, bad_id.status_code) == ("validation.param_invalid", 400)
+    assert bad_id.context == {"fields": [{"field": "path.position_id", "message": "x"}]}
+    teapot = errors.from_http_status(418, "I'm a teapot")
+    assert (teapot.code, teapot.status_code, teapot.message) == (
+        "http.error",
+        418,
+        "I'm a teapot",
+    )
+
+
+def test_every_kind_of_error_has_the_same_envelope():
+    invalid = [
+        {"type": "missing", "loc": ("body", "quantity"), "msg": "Field required"},
+        {"type": "greater_than", "loc": ("query", "days"), "msg": "Must be > 0"},
+    ]
+    for error, status in [
+        (errors.stock_not_found("ZZZZ"), 404),
+        (errors.from_validation_errors(invalid), 400),
+        (errors.watchlist_name_taken("Tech"), 409),
+        (errors.provider_unavailable("Finnhub"), 502),
+        (errors.internal_error(), 500),
+    ]:
+        body = errors.error_body(error)
+        assert error.status_code == status
+        assert body["error"] is True
+        assert body["code"] in errors.ERROR_CATALOG
+        assert isinstance(body["message"], str) and body["message"]
+        assert set(body) <= {"error", "code", "message", "detail", "context"}
+
+    # Validation failures list each field for the client to show
+    assert errors.error_body(errors.from_validation_errors(invalid))["context"] == {
+        "fields": [
+            {"field": "body.quantity", "message": "Field required"},
+            {"field": "query.days", "message": "Must be > 0"},
+        ]
+    }
+    assert "context" not in errors.error_body(errors.field_invalid("Bad"))
+
+
+def test_database_errors_keep_the_driver_message_out_of_the_body():
+    leaky = "duplicate key value violates unique constraint \"stocks_symbol_key\""
+    for exc, code in [
+        (sa_exc.IntegrityError("INSERT INTO stocks ...", {}, Exception(leaky)), 409),
+        (sa_exc.OperationalError("SELECT 1", {}, Exception("host 10.0.0.5")), 503),
+        (sa_exc.ProgrammingError("SELECT nope", {}, Exception("syntax")), 500),
+        (InjectedDatabaseTimeout("Injected db_timeout for dependency 'stocks'"), 503),
+    ]:
+        error = errors.database_error(exc)
+        assert error.status_code == code
+        body = errors.error_body(error)
+        assert "stocks" not in str(body) and "10.0.0.5" not in str(body)
+        assert "SELECT" not in str(body) and "syntax" not in str(body)
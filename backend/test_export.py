@@ -0,0 +1,84 @@
+"""
+Tests for portfolio exports.
+"""
+
+import asyncio
+import csv
+import io
+from datetime import date, datetime, timedelta
+
+import pytest
+from app.models.schemas import ExportFormat, TradeSide, TransactionCreate
+from app.services.export import ExportService
+from app.services.ledger import LedgerService
+from app.services.market import MarketService
+from app.services.performance import PerformanceService
+from app.services.portfolio import PortfolioService
+
+START = date(2025, 1, 6)
+
+
+def _service():
+    portfolios = PortfolioService()
+    for position in portfolios.portfolios.list_positions():
+        portfolios.portfolios.delete_position(position["id"])
+    portfolios._insert_position(1, "AAPL", 10, 100.0, 1000.0, tags=["core"])
+    market = MarketService()
+    market.put_closes("AAPL", {START + timedelta(days=i): 100.0 + i for i in range(3)})
+    ledger = LedgerService()
+    asyncio.run(
+        ledger.record(
+            1,
+            TransactionCreate(
+                symbol="AAPL",
+                side=TradeSide.BUY,
+                quantity=10,
+                price=100.0,
+                fees=1.5,
+                executed_at=datetime(2025, 1, 6, 15, 30),
+            ),
+        )
+    )
+    return ExportService(portfolios, ledger, PerformanceService(portfolios, market))
+
+
+def _export(export_format, portfolio_id=1):
+    return asyncio.run(
+        _service().export(
+            portfolio_id, export_format, START, START + timedelta(days=2)
+        )
+    )
+
+
+def test_csv_lists_positions():
+    rows = list(csv.reader(io.StringIO(_export(ExportFormat.CSV).decode())))
+
+    assert rows[0][:3] == ["Symbol", "Quantity", "Average price"]
+    assert rows[1] == ["AAPL", "10", "100.0", "1000.0", "0.0", "", "core"]
+
+
+def test_xlsx_has_typed_sheets():
+    openpyxl = pytest.importorskip("openpyxl")
+
+    workbook = openpyxl.load_workbook(io.BytesIO(_export(ExportFormat.XLSX)))
+
+    assert workbook.sheetnames == ["Positions", "Transactions", "Performance"]
+    positions = workbook["Positions"]
+    assert positions["A2"].value == "AAPL"
+    assert positions["B2"].value == 10 and positions["B2"].data_type == "n"
+    transactions = workbook["Transactions"]
+    assert transactions["A2"].value == datetime(2025, 1, 6, 15, 30)
+    assert transactions["A2"].is_date
+    assert transactions["F2"].value == 1.5
+    performance = list(workbook["Performance"].values)
+    assert performance[0] == ("Date", "Value", "Daily return")
+    assert performance[1][0] == datetime(2025, 1, 6)
+    assert [row[1:] for row in performance[1:]] == [
+        (1000.0, None),
+        (1010.0, 0.01),
+        (1020.0, 0.009901),
+    ]
+
+
+def test_unknown_portfolio():
+    assert _export(ExportFormat.XLSX, portfolio_id=99) is None
@@ -0,0 +1,180 @@
+"""
+Tests for fault injection and the degraded paths it exercises.
+"""
+
+import asyncio
+
+import pytest
+from app.api.v1.endpoints.market import get_stock
+from app.core import errors
+from app.core.config import settings
+from app.core.faults import (
+    FaultInjectingProxy,
+    FaultInjector,
+    FaultKind,
+    FaultRule,
+    FaultTarget,
+    InjectedDatabaseTimeout,
+    InjectedFault,
+    InjectedRateLimit,
+    fault_injection_available,
+    inject_repository_faults,
+)
+from app.services.market import MarketService
+from app.services.search import SearchService
+from app.ws.feed import PollingFeed
+
+
+class QuoteProvider:
+    def __init__(self):
+        self.calls = 0
+
+    async def get_quote(self, symbol):
+        self.calls += 1
+        return {"price": 101.0}
+
+    async def get_history(self, symbol, interval, limit):
+        return []
+
+
+def provider_failure(kind=FaultKind.PROVIDER_RATE_LIMIT):
+    return FaultRule(
+        target=FaultTarget.DEPENDENCY, match="finnhub", error_rate=1.0, kind=kind
+    )
+
+
+def test_full_provider_failure_raises_rate_limit():
+    injector = FaultInjector(enabled=True)
+    injector.set_rules([provider_failure()])
+    provider = QuoteProvider()
+    proxy = FaultInjectingProxy(provider, "finnhub", injector)
+
+    with pytest.raises(InjectedRateLimit):
+        asyncio.run(proxy.get_quote("AAPL"))
+    assert provider.calls == 0
+
+    # Rules only apply to the dependency name they target
+    other = FaultInjectingProxy(provider, "polygon", injector)
+    assert asyncio.run(other.get_quote("AAPL")) == {"price": 101.0}
+
+
+def test_db_timeout_is_a_timeout_error():
+    injector = FaultInjector(enabled=True)
+    injector.set_rules([provider_failure(FaultKind.DB_TIMEOUT)])
+    with pytest.raises(TimeoutError):
+        asyncio.run(injector.apply(FaultTarget.DEPENDENCY, "finnhub"))
+    assert issubclass(InjectedDatabaseTimeout, InjectedFault)
+
+
+def db_timeout(repository):
+    return FaultRule(
+        target=FaultTarget.DEPENDENCY,
+        match=repository,
+        error_rate=1.0,
+        kind=FaultKind.DB_TIMEOUT,
+    )
+
+
+def test_repository_calls_are_fault_points():
+    injector = FaultInjector(enabled=True)
+    market = MarketService()
+    inject_repository_faults({"stocks": market, "market_data": market}, injector)
+    # Wrapping twice doesn't nest the proxies
+    inject_repository_faults({"stocks": market}, injector)
+    assert not isinstance(market.stocks._target, FaultInjectingProxy)
+
+    injector.set_rules([db_timeout("stocks")])
+    with pytest.raises(InjectedDatabaseTimeout):
+        asyncio.run(market.get_stock_by_symbol("AAPL"))
+    assert market.market_data.get_closes("AAPL") == {}
+
+    injector.clear()
+    assert asyncio.run(market.get_stock_by_symbol("AAPL")).symbol == "AAPL"
+
+
+def test_stock_lookups_degrade_to_the_cache_during_a_db_timeout(monkeypatch):
+    """Cached stocks are still served; the rest get a 503 until it clears."""
+    monkeypatch.setattr(settings, "QUOTE_CACHE_TTL", 30.0)
+    injector = FaultInjector(enabled=True)
+    market = MarketService()
+    inject_repository_faults({"stocks": market}, injector)
+
+    def lookup(symbol):
+        return asyncio.run(
+            get_stock(
+                symbol,
+                user_id=None,
+                as_string=False,
+                market_service=market,
+                search_service=SearchService(market),
+            )
+        )
+
+    lookup("AAPL")
+    injector.set_rules([db_timeout("stocks")])
+    assert lookup("AAPL").price == 150.25
+
+    with pytest.raises(InjectedDatabaseTimeout) as exc:
+        lookup("MSFT")
+    error = errors.database_error(exc.value)
+    assert (error.code, error.status_code) == ("database.unavailable", 503)
+
+    injector.clear()
+    assert lookup("MSFT").symbol == "MSFT"
+
+
+def test_polling_feed_degrades_then_recovers():
+    """Failed quotes are skipped, and ticks resume once the fault clears."""
+    injector = FaultInjector(enabled=True)
+    injector.set_rules([provider_failure()])
+    provider = QuoteProvider()
+    feed = PollingFeed(
+        FaultInjectingProxy(provider, "finnhub", injector), interval=0.001
+    )
+
+    async def first_tick():
+        await feed.subscribe(["AAPL"])
+        asyncio.get_running_loop().call_later(0.02, injector.clear)
+        return await asyncio.wait_for(feed.stream().__anext__(), timeout=1)
+
+    tick = asyncio.run(first_tick())
+    assert tick["symbol"] == "AAPL"
+    assert tick["price"] == 101.0
+    assert provider.calls == 1
+
+
+def test_route_rules_match_by_prefix():
+    injector = FaultInjector(enabled=True)
+    injector.set_rules(
+        [
+            FaultRule(
+                target=FaultTarget.ROUTE,
+                match="/api/v1/market",
+                error_rate=1.0,
+                status_code=502,
+            )
+        ]
+    )
+
+    with pytest.raises(InjectedFault) as exc:
+        asyncio.run(injector.apply(FaultTarget.ROUTE, "/api/v1/market/stocks"))
+    assert exc.value.rule.status_code == 502
+    asyncio.run(injector.apply(FaultTarget.ROUTE, "/api/v1/portfolio/"))
+
+    injector.clear()
+    assert injector.list_rules() == []
+
+
+def test_fault_injection_never_available_in_production(monkeypatch):
+    monkeypatch.setattr(settings, "FAULT_INJECTION", True)
+    monkeypatch.setattr(settings, "ENVIRONMENT", "staging")
+    assert fault_injection_available()
+
+    monkeypatch.setattr(settings, "ENVIRONMENT", "production")
+    assert not fault_injection_available()
+    injector = FaultInjector()
+    with pytest.raises(RuntimeError):
+        injector.set_rules([provider_failure()])
+    # A disabled injector is inert even if rules somehow exist
+    injector._rules = [provider_failure()]
+    asyncio.run(injector.apply(FaultTarget.DEPENDENCY, "finnhub"))
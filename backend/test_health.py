@@ -0,0 +1,62 @@
+"""
+Tests for the readiness check and database pool settings.
+"""
+
+import asyncio
+import time
+from types import SimpleNamespace
+
+import pytest
+from app.api.v1.endpoints import health
+from app.core.config import Settings, settings
+from app.database import session
+
+
+def _ready(monkeypatch, required, failure=None):
+    monkeypatch.setattr(settings, "DATABASE_REQUIRED", required)
+
+    async def ping(timeout):
+        return failure
+
+    monkeypatch.setattr(health, "ping", ping)
+    response = SimpleNamespace(status_code=200)
+    body = asyncio.run(health.readiness_check(response))
+    return response.status_code, body
+
+
+def test_ready_without_a_required_database(monkeypatch):
+    status, body = _ready(monkeypatch, required=False, failure="unreachable")
+    assert (status, body.status, body.checks) == (200, "ready", {})
+
+
+def test_unreachable_database_is_a_503(monkeypatch):
+    status, body = _ready(monkeypatch, required=True)
+    assert (status, body.checks) == (200, {"database": "ok"})
+
+    status, body = _ready(monkeypatch, required=True, failure="connection refused")
+    assert (status, body.status) == (503, "unavailable")
+    assert body.checks == {"database": "connection refused"}
+
+
+def test_ping_gives_up_after_the_timeout(monkeypatch):
+    monkeypatch.setattr(session, "_select_one", lambda: time.sleep(0.5))
+    assert asyncio.run(session.ping(0.05)) == "No answer within 0.05s"
+
+    def refused():
+        raise ConnectionError("connection refused")
+
+    monkeypatch.setattr(session, "_select_one", refused)
+    assert asyncio.run(session.ping(1.0)) == "connection refused"
+
+
+def test_pool_options_follow_the_limits(monkeypatch):
+    monkeypatch.setattr(settings, "DB_MAX_OPEN_CONNS", 20)
+    monkeypatch.setattr(settings, "DB_MAX_IDLE_CONNS", 5)
+    monkeypatch.setattr(settings, "DB_CONN_MAX_LIFETIME_SECONDS", 1800)
+
+    options = session.pool_options()
+    assert (options["pool_size"], options["max_overflow"]) == (5, 15)
+    assert options["pool_recycle"] == 1800
+
+    with pytest.raises(ValueError, match="DB_MAX_IDLE_CONNS"):
+        Settings(DB_MAX_OPEN_CONNS=5, DB_MAX_IDLE_CONNS=10)
@@ -0,0 +1,132 @@
+"""
+Tests for the average holding period of open positions.
+"""
+
+import asyncio
+from datetime import datetime, timedelta, timezone
+
+from app.models.schemas import TradeSide, TransactionCreate
+from app.services.holding_period import HoldingPeriodService, held_days
+from app.services.ledger import LedgerService
+from app.services.portfolio import PortfolioService
+
+TODAY = datetime(2025, 3, 1, 12, 0)
+
+
+def _service():
+    return HoldingPeriodService(
+        PortfolioService(), LedgerService(), clock=lambda: TODAY
+    )
+
+
+def _position(service, portfolio_id, symbol, quantity, value):
+    asyncio.run(
+        service.portfolios.create_position(
+            {
+                "portfolio_id": portfolio_id,
+                "stock_symbol": symbol,
+                "quantity": quantity,
+                "average_price": 100.0,
+                "current_value": value,
+            }
+        )
+    )
+
+
+def _trade(service, portfolio_id, side, symbol, quantity, price, day):
+    """A trade at noon on day, an ISO date."""
+    asyncio.run(
+        service.ledger.record(
+            portfolio_id,
+            TransactionCreate(
+                symbol=symbol,
+                side=side,
+                quantity=quantity,
+                price=price,
+                executed_at=datetime.fromisoformat(f"{day}T12:00"),
+            ),
+        )
+    )
+
+
+def test_held_days():
+    assert held_days(datetime(2025, 2, 28, 0, 0), TODAY) == 1.5
+    assert held_days(datetime(2025, 3, 2), TODAY) == 0.0
+
+
+def test_positions_are_weighted_by_value():
+    service = _service()
+    portfolio_id = service.portfolios.create_portfolio(user_id=9)
+    # AAA: 10 shares at 200, held a year
+    _position(service, portfolio_id, "AAA", 10, 2000.0)
+    _trade(service, portfolio_id, TradeSide.BUY, "AAA", 10, 150.0, "2024-03-01")
+    # BBB: 15 shares at 50; the sell closes 5 of the older lot
+    _position(service, portfolio_id, "BBB", 15, 750.0)
+    _trade(service, portfolio_id, TradeSide.BUY, "BBB", 10, 40.0, "2025-01-30")
+    _trade(service, portfolio_id, TradeSide.BUY, "BBB", 10, 45.0, "2025-02-19")
+    _trade(service, portfolio_id, TradeSide.SELL, "BBB", 5, 48.0, "2025-02-20")
+
+    holding_period = asyncio.run(service.get_holding_period(portfolio_id))
+
+    aaa, bbb = holding_period.positions
+    assert (aaa.symbol, aaa.quantity, aaa.value) == ("AAA", 10, 2000.0)
+    assert aaa.average_days == 365.0
+    assert aaa.oldest_lot_at == datetime(2024, 3, 1, 12)
+    # 5 shares held 30 days and 10 held 10 days
+    assert (bbb.symbol, bbb.quantity, bbb.value) == ("BBB", 15, 750.0)
+    assert bbb.average_days == 16.67
+    assert bbb.oldest_lot_at == datetime(2025, 1, 30, 12)
+
+    # (2000 * 365 + 250 * 30 + 500 * 10) / 2750
+    assert holding_period.average_days == 270.0
+    assert holding_period.as_of == TODAY
+
+
+def test_lots_without_a_position_are_valued_at_cost():
+    service = _service()
+    portfolio_id = service.portfolios.create_portfolio(user_id=9)
+    empty = asyncio.run(service.get_holding_period(portfolio_id))
+    assert empty.average_days is None and empty.positions == []
+
+    _position(service, portfolio_id, "AAA", 10, 1000.0)
+    _trade(service, portfolio_id, TradeSide.BUY, "AAA", 10, 100.0, "2025-02-19")
+    _trade(service, portfolio_id, TradeSide.BUY, "CCC", 30, 100.0, "2025-01-30")
+
+    holding_period = asyncio.run(service.get_holding_period(portfolio_id))
+
+    assert [p.value for p in holding_period.positions] == [3000.0, 1000.0]
+    assert holding_period.average_days == 25.0  # (3000 * 30 + 1000 * 10) / 4000
+
+
+def test_times_with_a_zone_count_from_their_instant():
+    service = HoldingPeriodService(
+        PortfolioService(),
+        LedgerService(),
+        clock=lambda: TODAY.replace(tzinfo=timezone.utc),
+    )
+    portfolio_id = service.portfolios.create_portfolio(user_id=9)
+    _position(service, portfolio_id, "AAA", 10, 1000.0)
+    # 14:00 at +02:00 is noon UTC, ten days before TODAY
+    executed_at = datetime(2025, 2, 19, 14, tzinfo=timezone(timedelta(hours=2)))
+    asyncio.run(
+        service.ledger.record(
+            portfolio_id,
+            TransactionCreate(
+                symbol="AAA",
+                side=TradeSide.BUY,
+                quantity=10,
+                price=100.0,
+                executed_at=executed_at,
+            ),
+        )
+    )
+
+    holding_period = asyncio.run(service.get_holding_period(portfolio_id))
+
+    assert holding_period.average_days == 10.0
+    assert holding_period.as_of == TODAY
+    assert held_days(executed_at, TODAY) == 10.0
+
+
+def test_unknown_portfolio():
+    assert asyncio.run(_service().get_holding_period(999)) is None
@@ -0,0 +1,121 @@
+"""
+Tests for caching provider HTTP responses.
+"""
+
+import asyncio
+
+import pytest
+from app.data.finnhub import FinnhubError, FinnhubService
+from app.data.http_cache import ResponseCache, cache_key, cache_ttl
+
+
+class FakeClock:
+    def __init__(self):
+        self.now = 1000.0
+
+    def __call__(self):
+        return self.now
+
+
+class FakeResponse:
+    def __init__(self, status, body, headers):
+        self.status = status
+        self.body = body
+        self.headers = headers
+
+    async def __aenter__(self):
+        return self
+
+    async def __aexit__(self, *exc):
+        pass
+
+    async def json(self):
+        return dict(self.body)
+
+    async def text(self):
+        return str(self.body)
+
+
+class CountingServer:
+    """Stands in for the upstream session, counting the requests it serves."""
+
+    def __init__(self, status=200, headers=None):
+        self.status = status
+        self.headers = headers or {}
+        self.requests = []
+
+    def get(self, url, params=None):
+        self.requests.append((url, dict(params or {})))
+        body = {"c": 100.0 + len(self.requests)}
+        return FakeResponse(self.status, body, self.headers)
+
+
+def _finnhub(server, clock, default_ttl=2.0):
+    finnhub = FinnhubService("key", cache=ResponseCache(default_ttl, clock=clock))
+    finnhub.session = server
+    return finnhub
+
+
+def test_second_identical_call_is_served_from_cache():
+    clock = FakeClock()
+    server = CountingServer()
+    finnhub = _finnhub(server, clock)
+
+    first = asyncio.run(finnhub.get_quote("AAPL"))
+    second = asyncio.run(finnhub.get_quote("AAPL"))
+    assert len(server.requests) == 1
+    assert second["c"] == first["c"]
+    assert server.requests[0][1]["token"] == "key"
+
+    # A different URL is a different entry; expiry goes back upstream
+    asyncio.run(finnhub.get_quote("MSFT"))
+    assert len(server.requests) == 2
+    clock.now += 2.5
+    assert asyncio.run(finnhub.get_quote("AAPL"))["c"] != first["c"]
+    assert len(server.requests) == 3
+
+
+def test_cache_control_from_the_provider_wins():
+    clock = FakeClock()
+    server = CountingServer(headers={"Cache-Control": "public, max-age=30"})
+    finnhub = _finnhub(server, clock)
+    asyncio.run(finnhub.get_quote("AAPL"))
+    clock.now += 20
+    asyncio.run(finnhub.get_quote("AAPL"))
+    assert len(server.requests) == 1
+
+    server = CountingServer(headers={"Cache-Control": "no-store"})
+    finnhub = _finnhub(server, clock)
+    asyncio.run(finnhub.get_quote("AAPL"))
+    asyncio.run(finnhub.get_quote("AAPL"))
+    assert len(server.requests) == 2
+
+
+def test_errors_are_not_cached():
+    server = CountingServer(status=500)
+    finnhub = _finnhub(server, FakeClock())
+    for _ in range(2):
+        with pytest.raises(FinnhubError):
+            asyncio.run(finnhub.get_quote("AAPL"))
+    assert len(server.requests) == 2
+
+
+def test_cache_key_and_ttl():
+    url = "https://finnhub.io/api/v1/quote"
+    assert cache_key(url, {"symbol": "AAPL", "token": "a"}) == cache_key(
+        url, {"token": "b", "symbol": "AAPL"}
+    )
+    assert cache_key(url) == url
+    assert cache_ttl(None, 2.0) == 2.0
+    assert cache_ttl("max-age=0", 2.0) == 0.0
+    assert cache_ttl("private, max-age=60", 2.0) == 0.0
+    assert cache_ttl("public", 2.0) == 2.0
+
+
+def test_raw_quotes_bypass_the_cache():
+    server = CountingServer()
+    finnhub = _finnhub(server, FakeClock())
+    asyncio.run(finnhub.get_quote("AAPL"))
+    raw = asyncio.run(finnhub.get_raw_quote("AAPL"))
+    assert len(server.requests) == 2
+    assert raw == {"c": 102.0}
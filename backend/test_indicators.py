@@ -0,0 +1,166 @@
+"""
+Tests for technical indicators and the indicator series of a stock.
+"""
+
+import asyncio
+from datetime import date, timedelta
+
+import pytest
+from app.api.v1.endpoints.market import get_stock_indicators
+from app.core.errors import AppError
+from app.models.schemas import IndicatorType
+from app.services.market import MarketService
+from app.utils.indicators import ema, rsi, sma
+
+CLOSES = [10.0, 11.0, 12.0, 11.0, 13.0, 12.0]
+
+# Wilder's RSI worked example as published by StockCharts, with its RSI(14)
+# from the 15th close on
+PUBLISHED_CLOSES = [
+    44.3389, 44.0902, 44.1497, 43.6124, 44.3278, 44.8264, 45.0955, 45.4245,
+    45.8433, 46.0826, 45.8931, 46.0328, 45.6140, 46.2820, 46.2820, 46.0028,
+    46.0328, 46.4116, 46.2222, 45.6439, 46.2122, 46.2521, 45.7137, 46.4515,
+    45.7835, 45.3548, 44.0288, 44.1783, 44.2181, 44.5672, 43.4205, 42.6628,
+    43.1314,
+]  # fmt: skip
+PUBLISHED_RSI_14 = [
+    70.53, 66.32, 66.55, 69.41, 66.36, 57.97, 62.93, 63.26, 56.06, 62.38,
+    54.71, 50.42, 39.99, 41.46, 41.87, 45.46, 37.30, 33.08, 37.77,
+]  # fmt: skip
+
+
+def _stock_with_closes(closes):
+    market = MarketService()
+    start = date.today() - timedelta(days=len(closes) - 1)
+    market.put_closes(
+        "AAPL", {start + timedelta(days=i): close for i, close in enumerate(closes)}
+    )
+    return market, start
+
+
+def test_sma_averages_each_window():
+    assert sma(CLOSES, 3) == [None, None, 11.0, pytest.approx(34 / 3), 12.0, 12.0]
+    assert sma(CLOSES, 6) == [None] * 5 + [pytest.approx(69 / 6)]
+
+
+def test_ema_is_seeded_with_the_sma():
+    # alpha = 2 / (3 + 1) = 0.5, starting from the mean of the first three
+    assert ema(CLOSES, 3) == [None, None, 11.0, 11.0, 12.0, 12.0]
+    assert ema([1.0, 2.0, 3.0, 4.0], 2) == [None, 1.5, pytest.approx(2.5), 3.5]
+
+
+def test_rsi_uses_wilder_smoothing():
+    # Changes +1, +1, -1, +2, -1. The first averages are 2/3 gain and 1/3
+    # loss (RS 2); each later one keeps 2/3 of the last and adds 1/3 of the
+    # new change, giving RS 5 and then 20/13.
+    values = rsi(CLOSES, 3)
+    assert values[:3] == [None, None, None]
+    assert values[3:] == [
+        pytest.approx(100 - 100 / 3),
+        pytest.approx(100 - 100 / 6),
+        pytest.approx(100 - 100 * 13 / 33),
+    ]
+    # Nothing but gains
+    assert rsi([1.0, 2.0, 3.0], 2) == [None, None, 100.0]
+
+
+def test_rsi_matches_the_published_example():
+    values = rsi(PUBLISHED_CLOSES, 14)
+    assert values[:14] == [None] * 14
+    assert [round(v, 2) for v in values[14:]] == PUBLISHED_RSI_14
+
+
+def test_period_must_fit_the_closes():
+    for indicator, period in [
+        (sma, 0),
+        (sma, 1),  # Would just repeat the closes
+        (ema, 1),
+        (rsi, -1),
+        (sma, len(CLOSES) + 1),
+        (ema, len(CLOSES) + 1),
+        (rsi, len(CLOSES)),  # Needs one more close than the period
+    ]:
+        with pytest.raises(ValueError):
+            indicator(CLOSES, period)
+
+
+def test_stock_indicators_line_up_with_the_closes():
+    market, start = _stock_with_closes(CLOSES)
+
+    def series(types, period):
+        return asyncio.run(
+            market.get_indicators("aapl", types, period, start, date.today())
+        )
+
+    result = series([IndicatorType.RSI, IndicatorType.SMA], 3)
+    assert (result.symbol, result.period) == ("AAPL", 3)
+    assert result.types == [IndicatorType.RSI, IndicatorType.SMA]
+    assert [p.close for p in result.points] == CLOSES
+    assert result.points[0].date == start
+    assert [p.values[IndicatorType.RSI] for p in result.points] == [
+        None,
+        None,
+        None,
+        66.6667,
+        83.3333,
+        60.6061,
+    ]
+    assert [p.values[IndicatorType.SMA] for p in result.points][2:] == [
+        11.0,
+        11.3333,
+        12.0,
+        12.0,
+    ]
+    # Only the requested indicators are computed
+    assert all(IndicatorType.EMA not in p.values for p in result.points)
+
+    with pytest.raises(ValueError, match="needs 7 closes"):
+        series([IndicatorType.SMA], 7)
+    unknown = market.get_indicators(
+        "ZZZZ", [IndicatorType.SMA], 3, start, date.today()
+    )
+    assert asyncio.run(unknown) is None
+
+
+def _indicators(market, types, period=14, start=None, end=None):
+    return asyncio.run(
+        get_stock_indicators(
+            "AAPL",
+            types=types,
+            period=period,
+            days=None,
+            start=start,
+            end=end,
+            market_service=market,
+        )
+    )
+
+
+def test_endpoint_parses_types_and_range():
+    market, start = _stock_with_closes(PUBLISHED_CLOSES)
+
+    result = _indicators(market, "RSI, ema,rsi", start=start)
+    assert result.types == [IndicatorType.RSI, IndicatorType.EMA]
+    assert (result.start, result.end) == (start, date.today())
+    assert result.points[14].values[IndicatorType.RSI] == pytest.approx(70.53, 1e-4)
+
+    # Only the closes from/to are used, so the warm-up starts again at from
+    days = [start + timedelta(days=30), start + timedelta(days=31)]
+    later = _indicators(market, "sma", period=2, start=days[0], end=days[1])
+    assert [p.date for p in later.points] == days
+    assert [p.values[IndicatorType.SMA] for p in later.points] == [
+        None,
+        pytest.approx((43.4205 + 42.6628) / 2, abs=1e-4),
+    ]
+
+
+def test_endpoint_rejects_bad_types_and_periods():
+    market, start = _stock_with_closes(CLOSES)
+
+    for types, period in [("macd", 3), ("sma,", 1), (" , ", 3), ("rsi", 6)]:
+        with pytest.raises(AppError) as exc:
+            _indicators(market, types, period)
+        assert (exc.value.status_code, exc.value.code) == (
+            400,
+            "analytics.invalid_request",
+        )
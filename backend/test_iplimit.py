@@ -0,0 +1,99 @@
+"""
+Tests for the per-IP token bucket on the provider-backed endpoints.
+"""
+
+import asyncio
+import json
+from types import SimpleNamespace
+
+from app.core.clientip import client_ip
+from app.core.iplimit import IPRateLimiter, IPRateLimitMiddleware
+
+
+class FakeClock:
+    def __init__(self):
+        self.now = 1000.0
+
+    def __call__(self):
+        return self.now
+
+
+def _request(path="/api/v1/market/stocks", ip="203.0.113.7", forwarded=None):
+    headers = {"x-forwarded-for": forwarded} if forwarded else {}
+    return SimpleNamespace(
+        url=SimpleNamespace(path=path),
+        client=SimpleNamespace(host=ip),
+        headers=headers,
+    )
+
+
+def test_bursts_are_allowed_then_refilled_at_the_rate():
+    clock = FakeClock()
+    limiter = IPRateLimiter(rate=2, burst=3, idle_seconds=60, clock=clock)
+
+    assert [limiter.acquire("a") for _ in range(3)] == [0, 0, 0]
+    assert limiter.acquire("a") == 0.5  # One token comes back every 0.5s
+    assert limiter.acquire("b") == 0  # Other IPs have their own bucket
+
+    clock.now += 0.5
+    assert limiter.acquire("a") == 0
+    assert limiter.acquire("a") > 0
+
+    # Refills stop at the burst size
+    clock.now += 100
+    assert [limiter.acquire("a") for _ in range(4)][-1] > 0
+
+
+def test_a_zero_rate_never_limits():
+    limiter = IPRateLimiter(rate=0, burst=1, idle_seconds=60)
+    assert all(limiter.acquire("a") == 0 for _ in range(100))
+
+
+def test_idle_ips_are_swept():
+    clock = FakeClock()
+    limiter = IPRateLimiter(rate=1, burst=5, idle_seconds=60, clock=clock)
+    limiter.acquire("idle")
+    clock.now += 30
+    limiter.acquire("active")
+    clock.now += 30
+
+    assert asyncio.run(limiter.sweep()) == 1
+    assert list(limiter.buckets) == ["active"]
+
+
+def test_forwarded_for_is_honored_only_behind_trusted_proxies():
+    spoofed = _request(ip="10.0.0.2", forwarded="1.1.1.1, 198.51.100.4")
+
+    assert client_ip(spoofed, trusted_proxies=0) == "10.0.0.2"
+    # The proxy appended the address it saw; the rest came from the client
+    assert client_ip(spoofed, trusted_proxies=1) == "198.51.100.4"
+    assert client_ip(spoofed, trusted_proxies=2) == "1.1.1.1"
+    # Fewer entries than proxies: the header's incomplete, use the peer
+    assert client_ip(spoofed, trusted_proxies=3) == "10.0.0.2"
+    assert client_ip(_request(ip="10.0.0.2"), trusted_proxies=1) == "10.0.0.2"
+
+
+def test_middleware_answers_429_with_retry_after_on_limited_paths():
+    limiter = IPRateLimiter(rate=0.25, burst=1, idle_seconds=60, clock=FakeClock())
+    middleware = IPRateLimitMiddleware(
+        None, limiter=limiter, paths=["/api/v1/market/"], trusted_proxies=1
+    )
+
+    async def call_next(request):
+        return SimpleNamespace(status_code=200)
+
+    def dispatch(request):
+        return asyncio.run(middleware.dispatch(request, call_next))
+
+    assert dispatch(_request(forwarded="198.51.100.4")).status_code == 200
+    response = dispatch(_request(forwarded="198.51.100.4"))
+    assert response.status_code == 429
+    assert response.headers["Retry-After"] == "4"
+    body = json.loads(response.body)
+    assert body["code"] == "rate_limit.exceeded"
+
+    # Another client behind the same proxy isn't affected...
+    assert dispatch(_request(forwarded="198.51.100.5")).status_code == 200
+    # ...and other paths aren't limited at all
+    for path in ("/api/v1/portfolio", "/api/v1/marketing"):
+        assert dispatch(_request(path, forwarded="198.51.100.4")).status_code == 200
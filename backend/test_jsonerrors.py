@@ -0,0 +1,106 @@
+"""
+Tests for rewriting plain-text API errors into ErrorResponse bodies.
+"""
+
+import asyncio
+import json
+from types import SimpleNamespace
+
+from app.core.jsonerrors import JSONErrorMiddleware, is_api_path, is_json
+
+
+def _response(status_code, body=b"", headers=None):
+    async def chunks():
+        if body:
+            yield body
+
+    return SimpleNamespace(
+        status_code=status_code, headers=dict(headers or {}), body_iterator=chunks()
+    )
+
+
+def _dispatch(response, path="/api/v1/market/stocks"):
+    async def call_next(request):
+        return response
+
+    middleware = JSONErrorMiddleware(None, prefix="/api/v1")
+    request = SimpleNamespace(url=SimpleNamespace(path=path))
+    return asyncio.run(middleware.dispatch(request, call_next))
+
+
+def test_plain_text_errors_get_an_error_body():
+    # What Starlette's TrustedHostMiddleware answers for an unknown host
+    response = _dispatch(
+        _response(
+            400,
+            b"Invalid host header",
+            {
+                "content-type": "text/plain; charset=utf-8",
+                "content-length": "19",
+                "vary": "Origin",
+            },
+        )
+    )
+
+    assert response.status_code == 400
+    body = json.loads(response.body)
+    assert body["error"] is True
+    assert body["code"] == "http.error"
+    assert body["message"] == "Invalid host header"
+    assert response.headers["vary"] == "Origin"
+    assert "content-length" not in response.headers
+
+
+def test_status_specific_codes_and_headers_are_kept():
+    for status, content, code in [
+        (404, b"Not Found", "http.not_found"),
+        (405, b"", "http.method_not_allowed"),
+        (500, b"Traceback (most recent call last): ...", "internal.error"),
+        (503, b"", "internal.error"),
+    ]:
+        response = _dispatch(_response(status, content, {"X-Request-ID": "abc"}))
+        assert response.status_code == status
+        body = json.loads(response.body)
+        assert body["code"] == code
+        # The ID set by the request log is carried into the body
+        assert body["request_id"] == "abc"
+        if status >= 500:
+            # Server error text never reaches the client
+            assert body["message"] == "Unexpected server error"
+
+
+def test_an_empty_body_gets_the_status_phrase():
+    response = _dispatch(_response(429, headers={"Retry-After": "30"}))
+    body = json.loads(response.body)
+    assert body["message"] == "Too Many Requests"
+    assert response.headers["Retry-After"] == "30"
+
+
+def test_json_success_and_non_api_responses_pass_through():
+    for response, path in [
+        (
+            _response(422, b'{"error": true}', {"content-type": "application/json"}),
+            "/api/v1/portfolio",
+        ),
+        (
+            _response(
+                400, b"{}", {"content-type": "application/problem+json; charset=utf-8"}
+            ),
+            "/api/v1/portfolio",
+        ),
+        (_response(200, b"ok", {"content-type": "text/plain"}), "/api/v1/health"),
+        (_response(404, b"Not Found", {"content-type": "text/plain"}), "/static/a"),
+        (_response(404, b"Not Found", {"content-type": "text/plain"}), "/api/v10"),
+    ]:
+        assert _dispatch(response, path) is response
+
+
+def test_api_paths_and_json_types():
+    assert is_api_path("/api/v1", "/api/v1")
+    assert is_api_path("/api/v1/market/stocks", "/api/v1")
+    assert not is_api_path("/api/v1x", "/api/v1")
+    assert not is_api_path("/", "/api/v1")
+    assert is_json("application/json; charset=utf-8")
+    assert is_json("Application/Problem+JSON")
+    assert not is_json("text/plain")
+    assert not is_json("")
@@ -0,0 +1,74 @@
+"""
+Tests for lazily constructed providers.
+"""
+
+import asyncio
+
+import pytest
+from app.data.lazy import LazyProvider
+from app.data.provider_base import supports_daily_closes, supports_streaming
+
+
+class QuoteOnlyProvider:
+    async def get_quote(self, symbol):
+        return {"price": 100.0, "symbol": symbol}
+
+    async def get_history(self, symbol, interval, limit):
+        return []
+
+    async def stream(self):
+        yield {"type": "tick"}
+
+
+def _lazy(outcomes):
+    """A LazyProvider whose builds follow outcomes, counting each build."""
+    built = []
+
+    async def create():
+        built.append(len(built))
+        await asyncio.sleep(0)  # Let the other first calls pile up
+        outcome = outcomes[len(built) - 1]
+        if isinstance(outcome, Exception):
+            raise outcome
+        return outcome
+
+    return LazyProvider(QuoteOnlyProvider, create), built
+
+
+def test_concurrent_first_calls_build_once():
+    lazy, built = _lazy([QuoteOnlyProvider()])
+    assert not lazy.initialized
+
+    async def first_calls():
+        return await asyncio.gather(*(lazy.get_quote(f"S{i}") for i in range(20)))
+
+    quotes = asyncio.run(first_calls())
+
+    assert built == [0]
+    assert [q["symbol"] for q in quotes] == [f"S{i}" for i in range(20)]
+    assert lazy.initialized
+
+
+def test_failed_build_is_raised_then_retried():
+    lazy, built = _lazy([ConnectionError("provider down"), QuoteOnlyProvider()])
+
+    with pytest.raises(ConnectionError, match="provider down"):
+        asyncio.run(lazy.get_quote("AAPL"))
+    assert not lazy.initialized
+
+    assert asyncio.run(lazy.get_quote("AAPL"))["price"] == 100.0
+    assert built == [0, 1]
+
+
+def test_capabilities_match_the_provider_class_before_building():
+    lazy, built = _lazy([QuoteOnlyProvider()])
+
+    assert not supports_daily_closes(lazy)
+
+    async def first_tick():
+        async for tick in lazy.stream():
+            return tick
+
+    assert asyncio.run(first_tick()) == {"type": "tick"}
+    assert built == [0]
+    assert supports_streaming(lazy) is False  # No connect/subscribe
@@ -0,0 +1,417 @@
+"""
+Tests for the market data service.
+"""
+
+import asyncio
+from datetime import date, datetime, timedelta
+
+import pytest
+from app.api.v1.endpoints.market import _symbol_list, post_live_quotes
+from app.core.config import settings
+from app.core.errors import AppError
+from app.core.querybudget import query_budget
+from app.data.provider_base import ProviderNotSupportedError
+from app.models.schemas import MarketDataCreate, QuoteBatchRequest, StockCreate
+from app.services.market import MarketService
+from fastapi import Response
+
+
+class BidAskProvider:
+    async def get_quote(self, symbol):
+        return {"price": 150.25}
+
+    async def get_history(self, symbol, interval, limit):
+        return []
+
+    async def get_level1(self, symbol):
+        return {
+            "bid": 150.20,
+            "ask": 150.30,
+            "bid_size": 300,
+            "ask_size": 500,
+            "timestamp": 1754389800000,
+        }
+
+
+class QuoteOnlyProvider:
+    async def get_quote(self, symbol):
+        return {"price": 150.25}
+
+    async def get_history(self, symbol, interval, limit):
+        return []
+
+
+def test_level1_quote_includes_spread():
+    service = MarketService(BidAskProvider())
+
+    quote = asyncio.run(service.get_level1("aapl"))
+
+    assert quote.symbol == "AAPL"
+    assert (quote.bid, quote.ask) == (150.20, 150.30)
+    assert (quote.bid_size, quote.ask_size) == (300, 500)
+    assert quote.spread == pytest.approx(0.10)
+    assert quote.spread_percent == pytest.approx(0.10 / 150.25 * 100, abs=1e-4)
+
+
+def test_level1_unsupported_provider():
+    for service in (MarketService(QuoteOnlyProvider()), MarketService()):
+        with pytest.raises(ProviderNotSupportedError):
+            asyncio.run(service.get_level1("AAPL"))
+
+
+class RawQuoteProvider:
+    """Finnhub-shaped provider whose cached path would return stale data."""
+
+    def __init__(self):
+        self.raw_calls = 0
+
+    async def get_quote(self, symbol):
+        return {"price": 1.0}
+
+    async def get_history(self, symbol, interval, limit):
+        return []
+
+    async def get_raw_quote(self, symbol):
+        self.raw_calls += 1
+        return {"c": 151.25, "d": 3.15, "dp": 2.13, "pc": 148.10, "t": 1754389800}
+
+
+def test_provider_debug_returns_raw_and_parsed_quote():
+    provider = RawQuoteProvider()
+    service = MarketService(provider, "finnhub")
+    stored = asyncio.run(service.get_stock_by_symbol("AAPL"))
+
+    debug = asyncio.run(service.debug_quote("aapl"))
+    assert provider.raw_calls == 1
+    assert debug.provider == "finnhub"
+    assert debug.raw == {
+        "c": 151.25,
+        "d": 3.15,
+        "dp": 2.13,
+        "pc": 148.10,
+        "t": 1754389800,
+    }
+    assert debug.price == 151.25
+    assert debug.stock.symbol == "AAPL"
+    assert debug.stock.price == 151.25
+    assert debug.stock.change == round(stored.change + 1.0, 2)
+
+    # Debugging stores nothing
+    assert asyncio.run(service.get_stock_by_symbol("AAPL")).price == stored.price
+    assert asyncio.run(service.debug_quote("ZZZZ")).stock is None
+
+    with pytest.raises(ProviderNotSupportedError):
+        asyncio.run(MarketService().debug_quote("AAPL"))
+
+
+def _put_trading_closes(market, days):
+    market.put_closes("AAPL", {day: 200.0 + i for i, day in enumerate(days)})
+
+
+def test_coverage_of_contiguous_range_has_no_gaps():
+    market = MarketService()
+    # Skips the weekend and Martin Luther King Jr. Day (Monday the 20th)
+    days = [date(2025, 1, d) for d in (15, 16, 17, 21, 22)]
+    _put_trading_closes(market, days)
+
+    coverage = asyncio.run(market.get_coverage("aapl"))
+    assert (coverage.symbol, coverage.granularity) == ("AAPL", "1d")
+    assert (coverage.start, coverage.end) == (date(2025, 1, 15), date(2025, 1, 22))
+    assert coverage.rows == 5
+    assert coverage.gaps == []
+
+
+def test_coverage_reports_missing_trading_day():
+    market = MarketService()
+    # Good Friday (April 18th) is a holiday; the 16th is simply missing
+    days = [date(2025, 4, d) for d in (14, 15, 17, 21)]
+    _put_trading_closes(market, days)
+
+    coverage = asyncio.run(market.get_coverage("AAPL"))
+    assert coverage.rows == 4
+    assert coverage.gaps == [date(2025, 4, 16)]
+
+
+def test_coverage_without_stored_closes():
+    market = MarketService()
+    coverage = asyncio.run(market.get_coverage("AAPL"))
+    assert (coverage.rows, coverage.start, coverage.gaps) == (0, None, [])
+    assert asyncio.run(market.get_coverage("ZZZZ")) is None
+
+
+def test_history_returns_stored_closes_in_range():
+    market = MarketService()
+    _put_trading_closes(market, [date(2025, 1, d) for d in (13, 14, 15, 16)])
+
+    history = asyncio.run(
+        market.get_stock_history("aapl", date(2025, 1, 14), date(2025, 1, 15))
+    )
+    assert history.symbol == "AAPL"
+    assert [(p.date, p.close) for p in history.closes] == [
+        (date(2025, 1, 14), 201.0),
+        (date(2025, 1, 15), 202.0),
+    ]
+    assert (
+        asyncio.run(
+            market.get_stock_history("ZZZZ", date(2025, 1, 1), date(2025, 1, 31))
+        )
+        is None
+    )
+
+
+def test_history_serves_stored_ohlc_bars_in_range():
+    market = MarketService()
+    market.put_bars(
+        MarketDataCreate(
+            symbol="aapl",
+            date=datetime(2025, 1, day),
+            open_price=200.0 + day,
+            high_price=205.0 + day,
+            low_price=195.0 + day,
+            close_price=201.0 + day,
+            volume=1000 * day,
+        )
+        for day in (16, 14, 15)
+    )
+
+    history = asyncio.run(
+        market.get_stock_history("AAPL", date(2025, 1, 15), date(2025, 1, 31))
+    )
+    assert [(bar.date.date(), bar.open_price) for bar in history.bars] == [
+        (date(2025, 1, 15), 215.0),
+        (date(2025, 1, 16), 216.0),
+    ]
+    # Bars carry their closes into the close series
+    assert [p.close for p in history.closes] == [216.0, 217.0]
+
+    empty = asyncio.run(
+        market.get_stock_history("AAPL", date(2024, 1, 1), date(2024, 3, 31))
+    )
+    assert (empty.bars, empty.closes) == ([], [])
+
+
+def test_empty_catalog_lists_no_stocks():
+    market = MarketService()
+    market.stocks.clear()
+    # An empty list, so the endpoint serves [] rather than null
+    assert asyncio.run(market.get_stocks()) == []
+
+
+def test_stock_pages_and_total(monkeypatch):
+    market = MarketService()
+    symbols = [stock.symbol for stock in asyncio.run(market.get_stocks())]
+
+    first = asyncio.run(market.list_stocks(2, 0))
+    second = asyncio.run(market.list_stocks(2, 2))
+    assert [s.symbol for s in first + second] == symbols[:4]
+    assert asyncio.run(market.list_stocks(10, len(symbols))) == []
+    assert asyncio.run(market.count_stocks()) == len(symbols)
+
+    # Restricted symbols are neither listed nor counted
+    monkeypatch.setattr(settings, "SYMBOL_BLOCKLIST", [symbols[0]])
+    assert asyncio.run(market.list_stocks(1, 0))[0].symbol == symbols[1]
+    assert asyncio.run(market.count_stocks()) == len(symbols) - 1
+
+
+def test_stock_pages_sort_and_filter_by_price():
+    market = MarketService()
+    market.stocks.clear()
+    for symbol, price, volume in [("AAA", 30.0, 5), ("BBB", 10.0, 9), ("CCC", 20.0, 5)]:
+        market._put_stock(
+            {
+                "symbol": symbol,
+                "name": symbol,
+                "price": price,
+                "change": 0.0,
+                "change_percent": 0.0,
+                "volume": volume,
+            }
+        )
+
+    def symbols(*args):
+        return [s.symbol for s in asyncio.run(market.list_stocks(*args))]
+
+    assert symbols(10, 0, ("price", False)) == ["BBB", "CCC", "AAA"]
+    assert symbols(2, 1, ("price", True)) == ["CCC", "BBB"]
+    # Ties keep catalog order either way
+    assert symbols(10, 0, ("volume", True)) == ["BBB", "AAA", "CCC"]
+    assert symbols(10, 0, None, 15.0, 30.0) == ["AAA", "CCC"]
+    assert asyncio.run(market.count_stocks(15.0, 25.0)) == 1
+
+
+def test_stock_list_can_be_limited_to_symbols():
+    market = MarketService()
+    catalog = [stock.symbol for stock in asyncio.run(market.get_stocks())]
+    wanted = [catalog[2], "ZZZZ", catalog[0]]
+
+    # Catalog order, and a symbol with no stock is just absent
+    with query_budget(10) as budget:
+        listed = asyncio.run(market.list_stocks(10, 0, None, None, None, wanted))
+        total = asyncio.run(market.count_stocks(None, None, wanted))
+    assert [s.symbol for s in listed] == [catalog[0], catalog[2]]
+    assert total == 2
+    assert budget.count == 2  # One read each, however many symbols
+
+
+def test_symbol_list_is_normalized_and_bounded():
+    assert _symbol_list(" aapl,MSFT,,AAPL , googl") == ["AAPL", "MSFT", "GOOGL"]
+
+    too_many = ",".join(f"S{chr(65 + i // 26)}{chr(65 + i % 26)}" for i in range(101))
+    for raw, code in [
+        (" , ", "validation.param_invalid"),
+        (too_many, "validation.param_invalid"),
+        ("AAPL,BRK/B", "stock.symbol_invalid"),
+    ]:
+        with pytest.raises(AppError) as exc:
+            _symbol_list(raw)
+        assert (exc.value.status_code, exc.value.code) == (400, code)
+
+
+def test_stock_lookups_are_cached_until_the_stock_changes(monkeypatch):
+    monkeypatch.setattr(settings, "QUOTE_CACHE_TTL", 30.0)
+    market = MarketService()
+
+    with query_budget(10) as budget:
+        first = asyncio.run(market.find_stock("aapl"))
+        again = asyncio.run(market.find_stock("AAPL"))
+    assert again == first
+    assert budget.count == 1
+
+    market.apply_quote("AAPL", 160.0)
+    with query_budget(10) as budget:
+        assert asyncio.run(market.find_stock("AAPL")).price == 160.0
+    assert budget.count == 1
+
+    # With no TTL, every lookup reads the store
+    monkeypatch.setattr(settings, "QUOTE_CACHE_TTL", 0.0)
+    market.reset()
+    with query_budget(10) as budget:
+        asyncio.run(market.find_stock("AAPL"))
+        asyncio.run(market.find_stock("AAPL"))
+    assert budget.count == 2
+
+
+class PartialProvider:
+    """Quotes NEWCO, has nothing for ZZZZ and fails on DOWN."""
+
+    def __init__(self):
+        self.asked = []
+
+    async def get_quote(self, symbol):
+        self.asked.append(symbol)
+        if symbol == "DOWN":
+            raise RuntimeError("upstream timeout")
+        return {"price": 12.5} if symbol == "NEWCO" else {}
+
+
+def test_stock_batch_reports_unknown_symbols_separately(monkeypatch):
+    monkeypatch.setattr(settings, "QUOTE_CACHE_TTL", 30.0)
+    market = MarketService()
+    provider = PartialProvider()
+    market.set_provider(provider, "test")
+
+    requested = ["msft", "ZZZZ", "AAPL", "MSFT", "NEWCO", "DOWN", "aapl"]
+    with query_budget(10) as budget:
+        batch = asyncio.run(market.find_stocks(requested))
+
+    # Repeats collapse into one entry, in the order first requested
+    assert list(batch.stocks) == ["MSFT", "AAPL", "NEWCO"]
+    assert batch.stocks["NEWCO"].price == 12.5
+    assert [quote.symbol for quote in batch.quotes] == ["MSFT", "AAPL", "NEWCO"]
+    assert batch.missing == ["ZZZZ", "DOWN"]
+    assert batch.not_found == ["ZZZZ"]
+    assert [(r.item, r.status) for r in batch.results] == [
+        ("MSFT", 200),
+        ("ZZZZ", 404),
+        ("AAPL", 200),
+        ("NEWCO", 200),
+        ("DOWN", 502),
+    ]
+    # One store read for every symbol, and only store misses hit the provider
+    assert budget.count == 1
+    assert provider.asked == ["ZZZZ", "NEWCO", "DOWN"]
+
+    # Now cached or stored, so neither the store nor the provider is asked
+    provider.asked.clear()
+    with query_budget(10) as budget:
+        again = asyncio.run(market.find_stocks(["AAPL", "NEWCO"]))
+    assert list(again.stocks) == ["AAPL", "NEWCO"]
+    assert budget.count == 0 and provider.asked == []
+
+
+def test_stock_batch_without_a_provider_or_with_restricted_symbols(monkeypatch):
+    monkeypatch.setattr(settings, "SYMBOL_BLOCKLIST", ["MSFT"])
+    batch = asyncio.run(MarketService().find_stocks(["AAPL", "MSFT", "ZZZZ"]))
+
+    assert list(batch.stocks) == ["AAPL"]
+    assert batch.not_found == batch.missing == ["MSFT", "ZZZZ"]
+
+
+def test_quotes_map_known_symbols_to_their_stocks():
+    market = MarketService()
+    market.set_provider(PartialProvider(), "test")
+
+    def quote(symbols, live=False):
+        response = Response()
+        request = QuoteBatchRequest(symbols=symbols, live=live)
+        batch = asyncio.run(post_live_quotes(request, response, False, market))
+        return batch, response.status_code
+
+    # Unknown symbols are listed in not_found without failing the request
+    batch, status = quote(["aapl", "ZZZZ", "AAPL", "MSFT"])
+    assert list(batch.stocks) == ["AAPL", "MSFT"]
+    assert batch.stocks["MSFT"].symbol == "MSFT"
+    assert batch.not_found == ["ZZZZ"]
+    assert [(r.item, r.status) for r in batch.results] == [
+        ("AAPL", 200),
+        ("ZZZZ", 404),
+        ("MSFT", 200),
+    ]
+    assert status == 200
+
+    batch, status = quote(["ZZZZ", "YYYY"])
+    assert batch.stocks == {} and batch.not_found == ["ZZZZ", "YYYY"]
+    assert status == 200
+
+    # A rejected symbol still makes the batch a partial success
+    batch, status = quote(["AAPL", "$$$"])
+    assert list(batch.stocks) == ["AAPL"]
+    assert status == 207
+
+    # Repeats count once towards the limit of 100, or 50 live
+    batch, status = quote(["AAPL", " aapl"] * 30)
+    assert list(batch.stocks) == ["AAPL"] and len(batch.results) == 1
+    assert status == 200
+    symbols = [f"Q{chr(65 + i // 26)}{chr(65 + i % 26)}" for i in range(101)]
+    quote(symbols[:100])
+    with pytest.raises(AppError):
+        quote(symbols)
+    with pytest.raises(AppError):
+        quote(symbols[:51], live=True)
+    batch, status = quote(symbols[:50] * 2, live=True)
+    assert batch.stocks == {} and len(batch.results) == 50
+
+
+def test_upserting_a_stock_recomputes_change_percent_and_advances_updated_at():
+    market = MarketService()
+    stock = StockCreate(
+        symbol="newco", name="NewCo", price=102.0, change=2.0, volume=10
+    )
+
+    created, is_new = asyncio.run(market.upsert_stock(stock))
+    assert is_new and created.symbol == "NEWCO"
+    assert created.change_percent == 2.0  # 2 over the previous close of 100
+
+    # A clock behind the stored row still moves updated_at forward
+    later = datetime.utcnow() + timedelta(days=1)
+    market._put_stock({"symbol": "NEWCO", "updated_at": later})
+    stock.price, stock.change, stock.change_percent = 95.0, -5.0, 99.0
+    updated, is_new = asyncio.run(market.upsert_stock(stock))
+    assert not is_new and updated.id == created.id
+    assert (updated.price, updated.change_percent) == (95.0, -5.0)
+    assert updated.updated_at > later
+
+    # Live quotes are stored the same way
+    quoted = market.apply_quote("NEWCO", 110.0)
+    assert (quoted.change, quoted.change_percent) == (10.0, 10.0)
+    assert quoted.updated_at > updated.updated_at
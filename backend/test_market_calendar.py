@@ -0,0 +1,54 @@
+"""
+Tests for the regular-session filter over intraday points.
+"""
+
+from datetime import datetime, timedelta, timezone
+from types import SimpleNamespace
+
+from app.utils.market_calendar import in_regular_session, regular_session_only
+
+UTC = timezone.utc
+
+
+def _bar(year, month, day, hour, minute=0):
+    return SimpleNamespace(date=datetime(year, month, day, hour, minute, tzinfo=UTC))
+
+
+def test_mixed_regular_and_extended_hours_points():
+    # Monday 4 Aug 2025, summer time: the session is 13:30-20:00 UTC
+    bars = [
+        _bar(2025, 8, 4, 8),  # 4:00 ET pre-market
+        _bar(2025, 8, 4, 13, 29),  # 9:29 ET, a minute early
+        _bar(2025, 8, 4, 13, 30),  # 9:30 ET open
+        _bar(2025, 8, 4, 17),
+        _bar(2025, 8, 4, 19, 59),
+        _bar(2025, 8, 4, 20),  # 16:00 ET close
+        _bar(2025, 8, 4, 23),  # 19:00 ET post-market
+    ]
+
+    regular = regular_session_only(bars, lambda bar: bar.date)
+
+    assert [bar.date.strftime("%H:%M") for bar in regular] == [
+        "13:30",
+        "17:00",
+        "19:59",
+    ]
+    assert len(bars) - len(regular) == 4
+
+
+def test_session_follows_daylight_saving_and_the_calendar():
+    # Monday 13 Jan 2025, winter time: the session is 14:30-21:00 UTC
+    assert not in_regular_session(datetime(2025, 1, 13, 14, 0, tzinfo=UTC))
+    assert in_regular_session(datetime(2025, 1, 13, 20, 30, tzinfo=UTC))
+    # Weekends and holidays have no session (4 Jul 2025 is a Friday)
+    assert not in_regular_session(datetime(2025, 8, 2, 15, 0, tzinfo=UTC))
+    assert not in_regular_session(datetime(2025, 7, 4, 15, 0, tzinfo=UTC))
+
+
+def test_naive_and_offset_moments():
+    # Naive datetimes are UTC
+    assert in_regular_session(datetime(2025, 8, 4, 14, 0))
+    assert not in_regular_session(datetime(2025, 8, 4, 9, 45))
+    # 9:45 in New York, given with its own offset
+    eastern = timezone(timedelta(hours=-4))
+    assert in_regular_session(datetime(2025, 8, 4, 9, 45, tzinfo=eastern))
@@ -0,0 +1,145 @@
+"""
+Tests for Prometheus counters, histograms, provider counts and the
+separate metrics listener.
+"""
+
+import asyncio
+from types import SimpleNamespace
+
+import pytest
+from app.core.metrics import (
+    UNMATCHED_ROUTE,
+    Counter,
+    Histogram,
+    InstrumentedProvider,
+    MetricsMiddleware,
+    db_query_duration,
+    http_request_duration,
+    http_requests,
+    provider_calls,
+    provider_errors,
+    register_gauge,
+    render_metrics,
+    start_metrics_server,
+)
+from app.core.querybudget import counts_as_query
+from app.core.slo import SLOTracker
+
+
+def test_counter_and_histogram_text_format():
+    requests = Counter("demo_requests_total", "Demo requests")
+    requests.inc({"route": "/a"})
+    requests.inc({"route": "/a"}, 2)
+    requests.inc({"route": 'say "hi"\n'})
+    latency = Histogram("demo_seconds", "Demo latency", buckets=(0.1, 1))
+    for seconds in (0.05, 0.5, 3):
+        latency.observe({"route": "/a"}, seconds)
+
+    assert requests.render() == [
+        "# HELP demo_requests_total Demo requests",
+        "# TYPE demo_requests_total counter",
+        'demo_requests_total{route="/a"} 3.0',
+        'demo_requests_total{route="say \\"hi\\"\\n"} 1.0',
+    ]
+    # Buckets are cumulative and end with +Inf, which equals the count
+    assert latency.render()[2:] == [
+        'demo_seconds_bucket{le="0.1",route="/a"} 1',
+        'demo_seconds_bucket{le="1",route="/a"} 2',
+        'demo_seconds_bucket{le="+Inf",route="/a"} 3',
+        'demo_seconds_sum{route="/a"} 3.55',
+        'demo_seconds_count{route="/a"} 3',
+    ]
+
+
+def test_gauges_without_labels_render_bare():
+    register_gauge("demo_connections", "Demo connections", lambda: [({}, 2)])
+    assert "\ndemo_connections 2\n" in render_metrics()
+
+
+def test_middleware_counts_by_method_route_template_and_status():
+    ticks = iter([0.0, 0.2, 1.0, 1.5])
+    middleware = MetricsMiddleware(
+        None, tracker=SLOTracker([]), timer=lambda: next(ticks)
+    )
+
+    def request(path, route):
+        return SimpleNamespace(
+            method="POST", scope={"route": route}, url=SimpleNamespace(path=path)
+        )
+
+    async def created(request):
+        return SimpleNamespace(status_code=201)
+
+    async def missing(request):
+        return SimpleNamespace(status_code=404)
+
+    route = SimpleNamespace(path="/api/v1/watchlists/{watchlist_id}")
+    labels = {"method": "POST", "route": route.path, "status": "201"}
+    unmatched = {"method": "POST", "route": UNMATCHED_ROUTE, "status": "404"}
+    before = (http_requests.value(labels), http_requests.value(unmatched))
+
+    asyncio.run(middleware.dispatch(request("/api/v1/watchlists/7", route), created))
+    asyncio.run(middleware.dispatch(request("/wp-login.php", None), missing))
+
+    assert http_requests.value(labels) == before[0] + 1
+    assert http_requests.value(unmatched) == before[1] + 1
+    assert http_request_duration.count({"method": "POST", "route": route.path}) >= 1
+    assert "/wp-login.php" not in render_metrics()
+
+
+def test_provider_calls_and_errors_are_counted():
+    class Provider:
+        name = "fake"
+
+        async def get_quote(self, symbol):
+            if symbol == "BAD":
+                raise RuntimeError("upstream down")
+            return {"symbol": symbol}
+
+    provider = InstrumentedProvider(Provider(), "fake")
+    labels = {"provider": "fake", "method": "get_quote"}
+    calls, errors = provider_calls.value(labels), provider_errors.value(labels)
+
+    assert asyncio.run(provider.get_quote("AAPL")) == {"symbol": "AAPL"}
+    with pytest.raises(RuntimeError):
+        asyncio.run(provider.get_quote("BAD"))
+
+    assert provider_calls.value(labels) == calls + 2
+    assert provider_errors.value(labels) == errors + 1
+    assert provider.name == "fake"
+
+
+def test_repository_queries_are_timed():
+    class Repository:
+        @counts_as_query
+        async def find(self):
+            return 1
+
+    name = {"query": "test_repository_queries_are_timed.<locals>.Repository.find"}
+    before = db_query_duration.count(name)
+    asyncio.run(Repository().find())
+    assert db_query_duration.count(name) == before + 1
+
+
+def test_metrics_listener_serves_only_metrics():
+    async def get(port, path):
+        reader, writer = await asyncio.open_connection("127.0.0.1", port)
+        writer.write(f"GET {path} HTTP/1.1\r\nHost: x\r\n\r\n".encode())
+        await writer.drain()
+        response = await reader.read()
+        writer.close()
+        return response.decode()
+
+    async def scrape():
+        server = await start_metrics_server("127.0.0.1", 0)
+        port = server.sockets[0].getsockname()[1]
+        try:
+            return await get(port, "/metrics"), await get(port, "/")
+        finally:
+            server.close()
+            await server.wait_closed()
+
+    metrics, other = asyncio.run(scrape())
+    assert metrics.startswith("HTTP/1.1 200 OK")
+    assert "# TYPE http_requests_total counter" in metrics
+    assert other.startswith("HTTP/1.1 404 Not Found")
@@ -0,0 +1,130 @@
+"""
+Tests for the versioned schema migrations, run against SQLite.
+"""
+
+import contextlib
+import re
+import sqlite3
+import tempfile
+from pathlib import Path
+
+import pytest
+from app.database.migrate import (
+    Migration,
+    MigrationError,
+    load_migrations,
+    migrate,
+    split_statements,
+)
+
+
+class SQLiteEngine:
+    """Just enough of a SQLAlchemy engine for migrate(), over sqlite3."""
+
+    def __init__(self):
+        self.db = sqlite3.connect(":memory:", isolation_level=None)
+
+    @contextlib.contextmanager
+    def begin(self):
+        self.db.execute("BEGIN")
+        try:
+            yield self
+        except Exception:
+            self.db.execute("ROLLBACK")
+            raise
+        self.db.execute("COMMIT")
+
+    def exec_driver_sql(self, sql):
+        # SQLite has no operator classes, so the Postgres ones are dropped
+        return self.db.execute(re.sub(r" \w+_pattern_ops\b", "", sql))
+
+    def tables(self):
+        rows = self.db.execute("SELECT name FROM sqlite_master WHERE type = 'table'")
+        return sorted(row[0] for row in rows)
+
+    def columns(self, table):
+        return [row[1] for row in self.db.execute(f"PRAGMA table_info({table})")]
+
+
+STOCKS = Migration(
+    1,
+    "stocks",
+    "-- The catalog\nCREATE TABLE stocks (\n    symbol TEXT PRIMARY KEY\n);\n",
+)
+SECTOR = Migration(2, "stock_sector", "ALTER TABLE stocks ADD COLUMN sector TEXT;")
+
+
+def test_pending_migrations_apply_in_order_once():
+    engine = SQLiteEngine()
+
+    assert migrate(engine, [STOCKS]) == [1]
+    assert migrate(engine, [SECTOR, STOCKS]) == [2]
+    assert migrate(engine, [STOCKS, SECTOR]) == []
+
+    assert engine.tables() == ["schema_migrations", "stocks"]
+    assert engine.columns("stocks") == ["symbol", "sector"]
+    recorded = engine.db.execute("SELECT version, name FROM schema_migrations")
+    assert recorded.fetchall() == [(1, "stocks"), (2, "stock_sector")]
+
+
+def test_a_failing_migration_is_rolled_back_and_stops_the_run():
+    engine = SQLiteEngine()
+    broken = Migration(
+        2,
+        "half_done",
+        "CREATE TABLE watchlists (id INTEGER);\nALTER TABLE nowhere ADD x TEXT;",
+    )
+    later = Migration(3, "later", "CREATE TABLE later (id INTEGER);")
+
+    with pytest.raises(MigrationError, match=r"Migration 2 \(half_done\) failed"):
+        migrate(engine, [STOCKS, broken, later])
+
+    # Version 1 stays applied; version 2's first statement was undone
+    assert engine.tables() == ["schema_migrations", "stocks"]
+    assert migrate(engine, [STOCKS, SECTOR]) == [2]
+
+
+def test_shipped_migrations_apply_to_sqlite():
+    engine = SQLiteEngine()
+    shipped = load_migrations()
+
+    assert migrate(engine) == [m.version for m in shipped]
+    assert migrate(engine) == []
+
+    assert {"stocks", "portfolios", "positions", "transactions"} <= set(
+        engine.tables()
+    )
+    assert "realized_gain" in engine.columns("portfolios")
+    assert engine.columns("watchlist_items") == ["watchlist_id", "symbol", "added_at"]
+
+
+def test_statements_split_at_line_end_semicolons():
+    sql = "-- comment\nCREATE TABLE a (\n  x TEXT DEFAULT ';'\n);\n\nSELECT 1"
+    assert split_statements(sql) == [
+        "CREATE TABLE a (\n  x TEXT DEFAULT ';'\n)",
+        "SELECT 1",
+    ]
+
+
+def test_migration_files_are_numbered():
+    shipped = load_migrations()
+    assert [m.version for m in shipped] == list(range(1, len(shipped) + 1))
+    assert shipped[0].name == "initial"
+
+    with tempfile.TemporaryDirectory() as directory:
+        path = Path(directory)
+        (path / "0002_sector.sql").write_text("SELECT 2;")
+        (path / "0001_stocks.sql").write_text("SELECT 1;")
+        assert [(m.version, m.name) for m in load_migrations(path)] == [
+            (1, "stocks"),
+            (2, "sector"),
+        ]
+
+        (path / "2_again.sql").write_text("SELECT 2;")
+        with pytest.raises(ValueError, match="reuses version 2"):
+            load_migrations(path)
+        (path / "2_again.sql").unlink()
+
+        (path / "sector.sql").write_text("SELECT 3;")
+        with pytest.raises(ValueError, match="isn't named"):
+            load_migrations(path)
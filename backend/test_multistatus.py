@@ -0,0 +1,58 @@
+"""
+Tests for per-item outcomes of batch requests.
+"""
+
+import asyncio
+
+from app.core import errors
+from app.data.finnhub import FinnhubRateLimitError
+from app.services.market import MarketService
+from app.utils.multistatus import MultiStatus
+
+
+class FakeProvider:
+    """Quotes AAPL and MSFT, has no price for ZZZZ and is rate limited on TSLA."""
+
+    async def get_quote(self, symbol):
+        if symbol == "TSLA":
+            raise FinnhubRateLimitError("Rate limit exceeded")
+        prices = {"AAPL": 150.0, "MSFT": 300.0}
+        return {"symbol": symbol, "price": prices.get(symbol)}
+
+
+def test_batch_status_code():
+    statuses = MultiStatus(["A", "B"])
+    statuses.succeeded("A")
+    statuses.succeeded("B")
+    assert statuses.status_code == 200
+
+    statuses.failed("B", errors.stock_not_found("B"))
+    assert statuses.status_code == 207
+
+    statuses.failed("A", errors.stock_not_found("A"))
+    assert statuses.status_code == 404
+    assert statuses.status_code_without("stock.not_found") == 200
+    statuses.failed("A", errors.symbol_invalid("bad"))
+    assert statuses.status_code_without("stock.not_found") == 207
+    assert statuses.status_code == 207
+
+
+def test_mixed_batch_reports_each_symbol_in_request_order():
+    market = MarketService(FakeProvider(), "fake")
+    statuses = MultiStatus(["AAPL", "$$$", "TSLA", "ZZZZ", "MSFT"])
+    statuses.failed("$$$", errors.symbol_invalid("Not a ticker"))
+
+    batch = asyncio.run(
+        market.get_live_quotes(["AAPL", "TSLA", "ZZZZ", "MSFT"], statuses)
+    )
+
+    assert [quote.symbol for quote in batch.quotes] == ["AAPL", "MSFT"]
+    assert batch.missing == ["TSLA", "ZZZZ"]
+    assert [(r.item, r.status, r.code) for r in batch.results] == [
+        ("AAPL", 200, None),
+        ("$$$", 400, "stock.symbol_invalid"),
+        ("TSLA", 429, "provider.rate_limited"),
+        ("ZZZZ", 404, "stock.not_found"),
+        ("MSFT", 200, None),
+    ]
+    assert statuses.status_code == 207
@@ -0,0 +1,199 @@
+"""
+Tests for period-over-period portfolio performance.
+"""
+
+import asyncio
+from datetime import date, timedelta
+
+import pytest
+
+from app.core.config import settings
+from app.services.analytics import calmar_ratio, treynor_ratio
+from app.services.market import MarketService
+from app.services.performance import PerformanceService
+from app.services.portfolio import PortfolioService
+from app.utils.returns import (
+    TRADING_DAYS_PER_YEAR,
+    annualized_volatility,
+    beta,
+    cagr,
+    sharpe_ratio,
+    total_return,
+    ulcer_index,
+)
+
+START = date(2025, 1, 1)
+
+
+def _service(closes_by_symbol, quantities):
+    portfolios = PortfolioService()
+    for position in portfolios.portfolios.list_positions():
+        portfolios.portfolios.delete_position(position["id"])
+    market = MarketService()
+    for symbol, quantity in quantities.items():
+        portfolios._insert_position(1, symbol, quantity, 0.0, 0.0)
+        market.put_closes(
+            symbol,
+            {
+                START + timedelta(days=i): close
+                for i, close in enumerate(closes_by_symbol[symbol])
+            },
+        )
+    return PerformanceService(portfolios, market)
+
+
+def test_compare_periods_reports_each_period_and_deltas():
+    service = _service(
+        {
+            "AAPL": [100, 102, 101, 105, 110, 108, 104, 103],
+            "MSFT": [50, 50, 51, 52, 52, 51, 50, 49],
+        },
+        {"AAPL": 2, "MSFT": 4},
+    )
+    a_end = START + timedelta(days=3)
+    b_start, b_end = START + timedelta(days=4), START + timedelta(days=7)
+
+    result = asyncio.run(service.compare_periods(1, START, a_end, b_start, b_end))
+
+    a_values = [400, 404, 406, 418]
+    b_values = [428, 420, 408, 402]
+    assert result.a.observations == 4
+    assert result.a.total_return == pytest.approx(total_return(a_values), abs=1e-6)
+    assert result.b.total_return == pytest.approx(402 / 428 - 1, abs=1e-6)
+    assert result.a.volatility == pytest.approx(
+        annualized_volatility(a_values), abs=1e-6
+    )
+    assert result.b.sharpe == pytest.approx(sharpe_ratio(b_values), abs=1e-4)
+    assert result.deltas.total_return == pytest.approx(
+        result.a.total_return - result.b.total_return, abs=1e-6
+    )
+    assert result.deltas.sharpe == pytest.approx(
+        result.a.sharpe - result.b.sharpe, abs=1e-4
+    )
+
+
+def test_compare_periods_needs_prices_in_both_periods():
+    service = _service({"AAPL": [100, 101, 102]}, {"AAPL": 1})
+    later = START + timedelta(days=30)
+
+    with pytest.raises(ValueError, match="at least 2 days"):
+        asyncio.run(
+            service.compare_periods(
+                1, START, START + timedelta(days=2), later, later + timedelta(days=5)
+            )
+        )
+    assert asyncio.run(service.compare_periods(99, START, later, START, later)) is None
+
+
+def test_calmar_ratio_and_zero_drawdown_guard():
+    assert calmar_ratio(0.12, 0.24) == pytest.approx(0.5)
+    # Drawdowns given as negative fractions use their magnitude
+    assert calmar_ratio(0.12, -0.3) == pytest.approx(0.4)
+    assert calmar_ratio(0.12, 0.0) == 0.0
+
+
+def test_ulcer_index_of_a_dip_and_recovery():
+    # 10% and 20% under the 100 peak, then 5% under the new 120 peak
+    dipping = [100, 90, 80, 100, 120, 114]
+    assert ulcer_index(dipping) == pytest.approx(((100 + 400 + 25) / 6) ** 0.5)
+    assert ulcer_index(dipping) > 0
+    # Staying down longer scores worse than the same fall recovered at once
+    assert ulcer_index([100, 80, 80, 80, 100]) > ulcer_index([100, 80, 100, 100, 100])
+
+    assert ulcer_index([100, 101, 105, 110]) == 0.0
+    assert ulcer_index([50, 50, 50]) == 0.0
+    assert ulcer_index([]) == 0.0
+
+
+def test_treynor_ratio_and_zero_beta_error():
+    assert treynor_ratio(0.12, 0.02, 0.8) == pytest.approx(0.125)
+    assert treynor_ratio(0.05, 0.03, -0.5) == pytest.approx(-0.04)
+
+    with pytest.raises(ValueError, match="beta of zero"):
+        treynor_ratio(0.12, 0.02, 0.0)
+
+
+def test_metrics_over_one_period():
+    # Up 10%, down to 88 (a 20% drawdown from 110), then back to 121
+    closes = [100, 110, 88, 121]
+    service = _service({"AAPL": closes}, {"AAPL": 1})
+    end = START + timedelta(days=3)
+
+    metrics = asyncio.run(service.metrics(1, START, end))
+
+    growth = 1.21 ** (TRADING_DAYS_PER_YEAR / 3) - 1
+    assert metrics.max_drawdown == pytest.approx(0.2)
+    assert metrics.ulcer_index == pytest.approx(10.0)  # sqrt(20² / 4)
+    assert metrics.cagr == pytest.approx(cagr(closes), rel=1e-6)
+    assert cagr(closes) == pytest.approx(growth)
+    assert metrics.calmar == pytest.approx(growth / 0.2, rel=1e-4)
+
+    rising = _service({"AAPL": [100, 101, 102]}, {"AAPL": 1})
+    flat = asyncio.run(rising.metrics(1, START, START + timedelta(days=2)))
+    assert (flat.max_drawdown, flat.ulcer_index, flat.calmar) == (0.0, 0.0, 0.0)
+    assert asyncio.run(service.metrics(99, START, end)) is None
+
+
+def _growing(start: float, returns):
+    closes = [start]
+    for r in returns:
+        closes.append(closes[-1] * (1 + r))
+    return closes
+
+
+def test_rolling_beta_follows_a_regime_change():
+    market_returns = [0.01, -0.02, 0.015, 0.005, -0.01, 0.02, -0.005, 0.01] * 3
+    # The holding moves half as much as the market, then twice as much
+    holding_returns = [0.5 * r for r in market_returns[:12]] + [
+        2 * r for r in market_returns[12:]
+    ]
+    service = _service({"XYZ": _growing(50, holding_returns)}, {"XYZ": 1})
+    spy = _growing(400, market_returns)
+    service.market.put_closes(
+        "SPY", {START + timedelta(days=i): close for i, close in enumerate(spy)}
+    )
+    end = START + timedelta(days=len(spy) - 1)
+
+    rolling = asyncio.run(service.rolling_beta(1, START, end, window=8))
+
+    assert (rolling.benchmark, rolling.window) == ("SPY", 8)
+    assert len(rolling.points) == len(market_returns) - 8 + 1
+    assert rolling.points[0].date == START + timedelta(days=8)
+    assert rolling.points[-1].date == end
+    # Windows inside a regime see that regime's beta; ones spanning the
+    # change land in between
+    assert rolling.points[0].beta == pytest.approx(0.5)
+    assert rolling.points[4].beta == pytest.approx(0.5)
+    assert rolling.points[-1].beta == pytest.approx(2.0)
+    assert 0.5 < rolling.points[8].beta < 2.0
+
+    with pytest.raises(ValueError, match="needs 26 days"):
+        asyncio.run(service.rolling_beta(1, START, end, window=25))
+    assert asyncio.run(service.rolling_beta(99, START, end, window=8)) is None
+
+
+def test_beta_of_a_flat_benchmark_is_undefined():
+    assert beta([0.01, -0.02], [0.02, -0.04]) == pytest.approx(0.5)
+    assert beta([0.01, -0.02], [0.0, 0.0]) is None
+
+
+def test_metrics_include_beta_and_treynor_against_the_benchmark(monkeypatch):
+    monkeypatch.setattr(settings, "RISK_FREE_RATE", 0.02)
+    market_returns = [0.01, -0.02, 0.015, 0.005, -0.01, 0.02]
+    service = _service(
+        {"XYZ": _growing(50, [2 * r for r in market_returns])}, {"XYZ": 1}
+    )
+    end = START + timedelta(days=len(market_returns))
+
+    # No benchmark prices yet
+    unbenchmarked = asyncio.run(service.metrics(1, START, end))
+    assert (unbenchmarked.beta, unbenchmarked.treynor) == (None, None)
+
+    spy = _growing(400, market_returns)
+    service.market.put_closes(
+        "SPY", {START + timedelta(days=i): close for i, close in enumerate(spy)}
+    )
+    metrics = asyncio.run(service.metrics(1, START, end))
+
+    assert metrics.beta == pytest.approx(2.0)
+    assert metrics.treynor == pytest.approx((metrics.cagr - 0.02) / 2.0, rel=1e-3)
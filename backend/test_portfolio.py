@@ -0,0 +1,285 @@
+"""
+Tests for the portfolio service and who can reach its portfolios.
+"""
+
+import asyncio
+
+import pytest
+from app.api.v1.endpoints.portfolio import (
+    _owned_portfolio,
+    close_position,
+    replace_own_position,
+)
+from app.core.errors import AppError
+from app.models.schemas import (
+    PositionAdjustment,
+    PositionBase,
+    PositionCreate,
+    PositionUpdate,
+)
+from app.services.market import MarketService
+from app.services.portfolio import PortfolioService
+
+
+def _position(service, symbol):
+    positions = asyncio.run(service.get_positions(1))
+    return next(p for p in positions if p.stock_symbol == symbol)
+
+
+def test_two_for_one_split_doubles_quantity_and_halves_average_price():
+    service = PortfolioService()
+    aapl = _position(service, "AAPL")
+
+    adjusted = asyncio.run(
+        service.adjust_position(1, aapl.id, PositionAdjustment(split_ratio=2))
+    )
+
+    assert adjusted.quantity == aapl.quantity * 2
+    assert adjusted.average_price == pytest.approx(aapl.average_price / 2)
+    # Cost basis and value are unchanged by a split
+    assert adjusted.total_gain == pytest.approx(aapl.total_gain)
+
+    audit = asyncio.run(service.get_audit_log(1))
+    assert audit[-1]["action"] == "position.adjusted"
+    assert audit[-1]["details"]["before"]["quantity"] == aapl.quantity
+
+
+def test_explicit_average_price_override():
+    service = PortfolioService()
+    msft = _position(service, "MSFT")
+
+    adjusted = asyncio.run(
+        service.adjust_position(
+            1, msft.id, PositionAdjustment(average_price=250.0, reason="fix")
+        )
+    )
+
+    assert adjusted.quantity == msft.quantity
+    assert adjusted.average_price == 250.0
+    assert asyncio.run(service.get_audit_log(1))[-1]["details"]["reason"] == "fix"
+
+
+def test_adjustment_validation():
+    for bad in ({}, {"split_ratio": 2, "average_price": 10}, {"split_ratio": -1}):
+        with pytest.raises(ValueError):
+            PositionAdjustment(**bad)
+
+
+def test_fractional_split_and_unknown_position():
+    service = PortfolioService()
+    googl = _position(service, "GOOGL")  # 2 shares
+
+    with pytest.raises(ValueError):
+        asyncio.run(
+            service.adjust_position(1, googl.id, PositionAdjustment(split_ratio=0.25))
+        )
+    missing = asyncio.run(
+        service.adjust_position(99, googl.id, PositionAdjustment(split_ratio=2))
+    )
+    assert missing is None
+
+
+def test_create_position_with_tags_and_filter_by_tag():
+    service = PortfolioService()
+    created = asyncio.run(
+        service.create_position(
+            PositionCreate(
+                portfolio_id=1,
+                stock_symbol="nvda",
+                quantity=4,
+                average_price=420.0,
+                notes="AI capex cycle",
+                tags=[" Speculative", "growth", "speculative"],
+            ).model_dump()
+        )
+    )
+    assert created.stock_symbol == "NVDA"
+    assert created.notes == "AI capex cycle"
+    assert created.tags == ["speculative", "growth"]
+
+    msft = _position(service, "MSFT")
+    asyncio.run(service.update_position(1, msft.id, {"tags": ["Long-Term", "growth"]}))
+
+    growth = asyncio.run(service.get_positions(1, tag="GROWTH"))
+    assert [p.stock_symbol for p in growth] == ["MSFT", "NVDA"]
+    speculative = asyncio.run(service.get_positions(1, tag="speculative"))
+    assert [p.stock_symbol for p in speculative] == ["NVDA"]
+    assert asyncio.run(service.get_positions(1, tag="value")) == []
+    assert len(asyncio.run(service.get_positions(1))) == 4
+
+
+def test_partial_update_keeps_other_fields():
+    service = PortfolioService()
+    aapl = _position(service, "AAPL")
+
+    updated = asyncio.run(
+        service.update_position(1, aapl.id, {"notes": "Services margin story"})
+    )
+    assert updated.notes == "Services margin story"
+    assert (updated.quantity, updated.tags) == (aapl.quantity, aapl.tags)
+    assert asyncio.run(service.update_position(2, aapl.id, {"notes": "x"})) is None
+
+
+def test_partial_update_rejects_null_quantity_and_price_but_clears_tags():
+    for field in ("quantity", "average_price"):
+        with pytest.raises(ValueError):
+            PositionUpdate(**{field: None})
+
+    service = PortfolioService()
+    msft = _position(service, "MSFT")
+    asyncio.run(service.update_position(1, msft.id, {"tags": ["growth"]}))
+    changes = PositionUpdate(tags=None, notes=None).model_dump(exclude_unset=True)
+
+    updated = asyncio.run(service.update_position(1, msft.id, changes))
+
+    assert (updated.tags, updated.notes) == ([], None)
+    assert updated.quantity == msft.quantity
+    assert _position(service, "MSFT").current_value == msft.current_value
+
+
+def test_delete_position():
+    service = PortfolioService()
+    aapl = _position(service, "AAPL")
+
+    assert asyncio.run(service.delete_position(2, aapl.id)) is False
+    assert asyncio.run(service.delete_position(1, aapl.id)) is True
+    symbols = [p.stock_symbol for p in asyncio.run(service.get_positions(1))]
+    assert "AAPL" not in symbols
+    assert asyncio.run(service.delete_position(1, aapl.id)) is False
+
+    audit = asyncio.run(service.get_audit_log(1))
+    assert audit[-1]["action"] == "position.deleted"
+
+
+def test_closing_a_position_updates_totals():
+    service = PortfolioService()
+    aapl = _position(service, "AAPL")
+    before = asyncio.run(service.get_portfolio(1))
+
+    assert asyncio.run(close_position(aapl.id, 1, service)) is None
+
+    after = asyncio.run(service.get_portfolio(1))
+    assert after.total_value == pytest.approx(before.total_value - aapl.current_value)
+    assert after.total_gain == pytest.approx(before.total_gain - aapl.total_gain)
+    # Gone, and never reachable from another user's portfolio
+    service.ensure_portfolio(7)
+    msft = _position(service, "MSFT")
+    for user_id, position_id in ((1, aapl.id), (7, msft.id)):
+        with pytest.raises(AppError) as raised:
+            asyncio.run(close_position(position_id, user_id, service))
+        assert raised.value.code == "position.not_found"
+
+
+def test_creating_a_held_symbol_merges_at_weighted_average_price():
+    service = PortfolioService()
+    aapl = _position(service, "AAPL")  # 10 shares at 145.00
+
+    merged = asyncio.run(
+        service.create_position(
+            PositionCreate(
+                portfolio_id=1,
+                stock_symbol="aapl",
+                quantity=30,
+                average_price=165.0,
+                tags=["core"],
+            ).model_dump()
+        )
+    )
+
+    assert merged.id == aapl.id
+    assert merged.quantity == 40
+    assert merged.average_price == pytest.approx((10 * 145.0 + 30 * 165.0) / 40)
+    assert merged.current_value == pytest.approx(aapl.current_value + 30 * 165.0)
+    assert merged.tags == ["core"]
+    assert len(asyncio.run(service.get_positions(1))) == 3
+
+    portfolio = asyncio.run(service.get_portfolio_by_id(1))
+    positions = asyncio.run(service.get_positions(1))
+    assert portfolio.total_value == pytest.approx(
+        sum(p.current_value for p in positions)
+    )
+    audit = asyncio.run(service.get_audit_log(1))
+    assert audit[-1]["action"] == "position.merged"
+
+
+def test_replacing_a_position_keeps_one_position_per_symbol():
+    service = PortfolioService()
+    aapl = _position(service, "AAPL")
+
+    def replace(symbol, position_id=aapl.id, user_id=1):
+        body = PositionBase(stock_symbol=symbol, quantity=12, average_price=150.0)
+        return asyncio.run(replace_own_position(position_id, body, user_id, service))
+
+    replaced = replace("aapl")
+    assert (replaced.id, replaced.quantity, replaced.average_price) == (
+        aapl.id,
+        12,
+        150.0,
+    )
+    with pytest.raises(AppError) as raised:
+        replace("MSFT")
+    assert raised.value.code == "position.symbol_held"
+    assert raised.value.status_code == 409
+    assert _position(service, "AAPL").quantity == 12
+
+    service.ensure_portfolio(7)
+    with pytest.raises(AppError) as raised:
+        replace("NVDA", user_id=7)
+    assert raised.value.code == "position.not_found"
+
+
+class FakeRequest:
+    def __init__(self, **path_params):
+        self.path_params = path_params
+
+
+def test_portfolio_routes_only_reach_the_users_own_portfolio():
+    service = PortfolioService()
+    other = service.ensure_portfolio(7)
+    assert service.ensure_portfolio(7) == other
+    assert service.ensure_portfolio(1) == 1
+
+    def check(user_id, **path_params):
+        return asyncio.run(
+            _owned_portfolio(FakeRequest(**path_params), user_id, service)
+        )
+
+    check(1, portfolio_id="1")
+    check(7, portfolio_id=str(other))
+    check(7)  # Routes without a portfolio in the path
+    for user_id, portfolio_id in ((7, "1"), (1, str(other)), (1, "999")):
+        with pytest.raises(AppError) as raised:
+            check(user_id, portfolio_id=portfolio_id)
+        assert raised.value.code == "portfolio.not_found"
+
+
+def test_portfolio_totals_follow_stored_stock_prices():
+    market = MarketService()
+    market.apply_quote("AAPL", 200.0)
+    service = PortfolioService(market)
+    asyncio.run(
+        service.create_position(
+            {
+                "portfolio_id": 1,
+                "stock_symbol": "ZZZZ",
+                "quantity": 3,
+                "average_price": 10.0,
+            }
+        )
+    )
+
+    portfolio = asyncio.run(service.get_portfolio(1))
+    positions = {p.stock_symbol: p for p in portfolio.positions}
+    aapl = positions["AAPL"]
+    assert aapl.current_value == pytest.approx(aapl.quantity * 200.0)
+    assert aapl.total_gain == pytest.approx(
+        (200.0 - aapl.average_price) * aapl.quantity
+    )
+    # A symbol with no stock is still listed, worth nothing
+    assert (positions["ZZZZ"].current_value, positions["ZZZZ"].total_gain) == (0, -30)
+    assert portfolio.total_value == pytest.approx(
+        sum(p.current_value for p in portfolio.positions)
+    )
+    assert portfolio.total_gain == pytest.approx(
+        sum(p.total_gain for p in portfolio.positions)
+    )
@@ -0,0 +1,204 @@
+"""
+Tests for importing positions from CSV and exporting positions and history.
+"""
+
+import asyncio
+import csv
+import io
+from datetime import date, datetime
+
+import pytest
+from app.core.config import settings
+from app.models.schemas import ImportMode, MarketDataCreate
+from app.services.export import ExportService, history_csv
+from app.services.ledger import LedgerService
+from app.services.market import MarketService
+from app.services.performance import PerformanceService
+from app.services.portfolio import PortfolioService
+from app.services.position_import import (
+    PositionImportError,
+    PositionImportService,
+    parse_positions_csv,
+)
+
+
+def _parse(text):
+    return parse_positions_csv(text.encode("utf-8"))
+
+
+def _holdings(portfolios, portfolio_id=1):
+    return sorted(
+        (r["stock_symbol"], r["quantity"], r["average_price"], r["current_value"])
+        for r in portfolios.portfolios.list_positions(portfolio_id)
+    )
+
+
+def _import(service, text, mode=ImportMode.MERGE):
+    return asyncio.run(service.import_csv(1, text.encode("utf-8"), mode))
+
+
+def test_malformed_headers_are_one_error_on_row_1():
+    for text, reason in [
+        ("", "The file is empty"),
+        ("symbol,quantity\nAAPL,10\n", "missing column average_price"),
+        (
+            "Symbol,Quantity,Average Price,symbol\nAAPL,10,150,AAPL\n",
+            "column symbol appears 2 times",
+        ),
+        ("ticker,qty,price\nAAPL,10,150\n", "missing column symbol"),
+    ]:
+        rows, errors = _parse(text)
+        assert rows == []
+        assert [(e.row, reason in e.reason) for e in errors] == [(1, True)]
+
+
+def test_quoted_fields_and_extra_columns_are_read():
+    text = (
+        "\ufeffNotes,Symbol,Quantity,Average price\n"  # As a spreadsheet saves it
+        '"Core, long-term"," aapl ","1,000","150.25"\n'
+        '"Says ""buy""",MSFT,5,0\n'
+        "\n"
+        "Growth,GOOGL,2,\n"
+    )
+    rows, errors = _parse(text)
+
+    assert rows == [("AAPL", 1000, 150.25), ("MSFT", 5, 0.0)]
+    assert [(e.row, e.reason) for e in errors] == [(5, "average_price is empty")]
+
+
+def test_every_bad_row_and_duplicate_symbol_is_reported():
+    text = (
+        "symbol,quantity,average_price\n"
+        "AAPL,10,150\n"
+        "not a symbol!,1,1\n"
+        "MSFT,2.5,300\n"
+        "GOOGL,-1,100\n"
+        "TSLA,3,abc\n"
+        "aapl,4,155\n"
+        "NVDA,3,-2\n"
+    )
+    rows, errors = _parse(text)
+
+    assert rows == [("AAPL", 10, 150.0)]
+    reasons = {e.row: e.reason for e in errors}
+    assert sorted(reasons) == [3, 4, 5, 6, 7, 8]
+    assert "isn't a valid symbol" in reasons[3]
+    assert "whole number" in reasons[4] and "whole number" in reasons[5]
+    assert reasons[6] == "average_price 'abc' isn't a number"
+    assert reasons[7] == "AAPL is already on row 2"
+    assert "negative" in reasons[8]
+
+
+def test_restricted_symbols_are_row_errors(monkeypatch):
+    monkeypatch.setattr(settings, "SYMBOL_BLOCKLIST", ["TSLA"])
+    rows, errors = _parse("symbol,quantity,average_price\nTSLA,1,200\n")
+    assert rows == []
+    assert [(e.row, e.reason) for e in errors] == [(2, "Symbol TSLA is restricted")]
+
+
+def test_merge_updates_held_symbols_and_keeps_the_rest():
+    portfolios = PortfolioService()
+    service = PositionImportService(portfolios)
+    before = dict((s, v) for s, _, _, v in _holdings(portfolios))
+
+    result = _import(
+        service, "symbol,quantity,average_price\nAAPL,20,140\nNVDA,4,100.5\n"
+    )
+
+    counts = (result.rows, result.created, result.updated, result.removed)
+    assert counts == (2, 1, 1, 0)
+    holdings = {h[0]: h[1:] for h in _holdings(portfolios)}
+    assert sorted(holdings) == ["AAPL", "GOOGL", "MSFT", "NVDA"]
+    # AAPL keeps its price per share; NVDA is valued at cost
+    assert holdings["AAPL"] == (20, 140.0, before["AAPL"] * 2)
+    assert holdings["NVDA"] == (4, 100.5, 402.0)
+    assert holdings["MSFT"][2] == before["MSFT"]
+
+
+def test_replace_wipes_existing_positions_first():
+    portfolios = PortfolioService()
+    service = PositionImportService(portfolios)
+
+    result = _import(
+        service, "symbol,quantity,average_price\nAAPL,1,100\n", ImportMode.REPLACE
+    )
+
+    assert (result.created, result.updated, result.removed) == (1, 0, 3)
+    assert _holdings(portfolios) == [("AAPL", 1, 100.0, 100.0)]
+
+
+def test_a_file_with_errors_or_no_rows_imports_nothing():
+    portfolios = PortfolioService()
+    service = PositionImportService(portfolios)
+    before = _holdings(portfolios)
+
+    for text in [
+        "symbol,quantity,average_price\nNVDA,1,100\nAMD,x,1\n",
+        "symbol,quantity,average_price\n\n",
+    ]:
+        with pytest.raises(PositionImportError) as exc:
+            _import(service, text, ImportMode.REPLACE)
+        assert len(exc.value.errors) == 1
+    assert _holdings(portfolios) == before
+
+
+def test_a_failure_while_applying_rolls_back(monkeypatch):
+    portfolios = PortfolioService()
+    before = _holdings(portfolios)
+    audit = asyncio.run(portfolios.get_audit_log(1))
+
+    # Blocked after the file was checked, as if by a concurrent config change
+    monkeypatch.setattr(settings, "SYMBOL_BLOCKLIST", ["NVDA"])
+    with pytest.raises(ValueError):
+        asyncio.run(
+            portfolios.import_positions(
+                1, [("AAPL", 1, 1.0), ("NVDA", 1, 1.0)], replace=True
+            )
+        )
+
+    assert _holdings(portfolios) == before
+    assert asyncio.run(portfolios.get_audit_log(1)) == audit
+
+
+def test_positions_export_can_be_imported_back():
+    portfolios = PortfolioService()
+    performance = PerformanceService(portfolios, MarketService())
+    exporter = ExportService(portfolios, LedgerService(), performance)
+    before = _holdings(portfolios)
+
+    content = asyncio.run(exporter.positions_csv(1))
+    result = asyncio.run(
+        PositionImportService(portfolios).import_csv(1, content, ImportMode.REPLACE)
+    )
+
+    assert result.created == len(before)
+    assert [h[:3] for h in _holdings(portfolios)] == [h[:3] for h in before]
+    assert asyncio.run(exporter.positions_csv(99)) is None
+
+
+def test_history_csv_has_a_row_per_close():
+    market = MarketService()
+    market.put_closes("AAPL", {date(2025, 1, 6): 100.0})
+    market.put_bars(
+        [
+            MarketDataCreate(
+                symbol="AAPL",
+                date=datetime(2025, 1, 7),
+                open_price=100.5,
+                high_price=103.0,
+                low_price=99.0,
+                close_price=102.0,
+                volume=1200,
+            )
+        ]
+    )
+    history = asyncio.run(
+        market.get_stock_history("AAPL", date(2025, 1, 1), date(2025, 1, 31))
+    )
+
+    rows = list(csv.reader(io.StringIO(history_csv(history).decode("utf-8"))))
+    assert rows == [
+        ["Date", "Open", "High", "Low", "Close", "Volume"],
+        ["2025-01-06", "", "", "", "100.0", ""],
+        ["2025-01-07", "100.5", "103.0", "99.0", "102.0", "1200"],
+    ]
@@ -0,0 +1,116 @@
+"""
+Tests for the per-request provider time budget.
+"""
+
+import asyncio
+
+import pytest
+from app.core.providerbudget import (
+    ProviderBudgetExhausted,
+    provider_budget,
+    within_budget,
+)
+from app.data.finnhub import FinnhubService
+from app.data.http_cache import ResponseCache
+from app.services.market import MarketService
+
+
+class FakeClock:
+    def __init__(self):
+        self.now = 1000.0
+
+    def __call__(self):
+        return self.now
+
+
+class FakeResponse:
+    status = 200
+    headers = {}
+
+    def __init__(self, body):
+        self.body = body
+
+    async def __aenter__(self):
+        return self
+
+    async def __aexit__(self, *exc):
+        pass
+
+    async def json(self):
+        return dict(self.body)
+
+
+class SlowServer:
+    """Upstream where every request takes one second of the fake clock."""
+
+    def __init__(self, clock):
+        self.clock = clock
+        self.symbols = []
+
+    def get(self, url, params=None):
+        self.symbols.append(params["symbol"])
+        self.clock.now += 1.0
+        return FakeResponse({"c": 100.0 + len(self.symbols)})
+
+
+def _market(server):
+    finnhub = FinnhubService("key", cache=ResponseCache(0))
+    finnhub.session = server
+    return MarketService(finnhub, "finnhub")
+
+
+SYMBOLS = ["AAPL", "MSFT", "GOOGL", "AMZN", "TSLA", "NVDA", "META", "NFLX"]
+
+
+def test_budget_exhausted_mid_batch_returns_partial_results():
+    clock = FakeClock()
+    server = SlowServer(clock)
+    market = _market(server)
+
+    async def fetch():
+        with provider_budget(3.0, clock) as budget:
+            return await market.get_live_quotes(SYMBOLS), budget
+
+    batch, budget = asyncio.run(fetch())
+
+    # The first five calls start within the budget (five run at once); by the
+    # time the rest would start, five seconds have gone
+    assert [quote.symbol for quote in batch.quotes] == SYMBOLS[:5]
+    assert batch.missing == SYMBOLS[5:]
+    assert batch.partial is True
+    assert server.symbols == SYMBOLS[:5]
+    assert budget.refused == 3
+    assert [r.code for r in batch.results[5:]] == ["provider.unavailable"] * 3
+
+
+def test_batch_within_budget_is_complete():
+    clock = FakeClock()
+    market = _market(SlowServer(clock))
+
+    async def fetch():
+        with provider_budget(30.0, clock):
+            return await market.get_live_quotes(SYMBOLS + ["aapl"])
+
+    batch = asyncio.run(fetch())
+    assert [quote.symbol for quote in batch.quotes] == SYMBOLS
+    assert (batch.missing, batch.partial) == ([], False)
+
+    # Outside a request there's no budget at all
+    unbounded = asyncio.run(market.get_live_quotes(SYMBOLS))
+    assert unbounded.partial is False
+
+
+def test_call_refused_once_budget_is_spent():
+    clock = FakeClock()
+
+    async def call():
+        return "quote"
+
+    async def run():
+        with provider_budget(1.0, clock):
+            assert await within_budget(call()) == "quote"
+            clock.now += 1.0
+            with pytest.raises(ProviderBudgetExhausted):
+                await within_budget(call())
+
+    asyncio.run(run())
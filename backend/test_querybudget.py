@@ -0,0 +1,71 @@
+"""
+Tests for the per-request query budget.
+"""
+
+import asyncio
+import logging
+from types import SimpleNamespace
+
+import pytest
+from app.core.querybudget import (
+    QueryBudgetExceeded,
+    QueryBudgetMiddleware,
+    count_query,
+    query_budget,
+)
+from app.services.alerts import AlertService
+from app.services.attention import AttentionService
+from app.services.market import MarketService
+from app.services.portfolio import PortfolioService
+
+
+class Records(logging.Handler):
+    def __init__(self):
+        super().__init__(logging.WARNING)
+        self.messages = []
+
+    def emit(self, record):
+        self.messages.append(record.getMessage())
+
+
+def _n_plus_one_handler():
+    """Looks up each position's stock and alerts one query at a time."""
+    service = AttentionService(PortfolioService(), MarketService(), AlertService())
+    return service.get_attention(1)
+
+
+def test_strict_budget_fails_on_n_plus_one():
+    with pytest.raises(QueryBudgetExceeded, match="over its budget of 4"):
+        with query_budget(4, strict=True, label="GET /attention"):
+            asyncio.run(_n_plus_one_handler())
+
+    # Same handler within a realistic budget passes
+    with query_budget(20, strict=True) as budget:
+        asyncio.run(_n_plus_one_handler())
+    assert budget.queries["MarketService.get_stock_by_symbol"] == 3
+    assert not budget.exceeded
+
+
+def test_middleware_warns_once_per_request_over_budget():
+    records = Records()
+    logging.getLogger("app.core.querybudget").addHandler(records)
+    middleware = QueryBudgetMiddleware(None, limit=2)
+    request = SimpleNamespace(method="GET", url=SimpleNamespace(path="/positions"))
+
+    async def handler(request):
+        for _ in range(5):
+            count_query("PortfolioService.get_positions")
+        return SimpleNamespace(status_code=200)
+
+    try:
+        response = asyncio.run(middleware.dispatch(request, handler))
+    finally:
+        logging.getLogger("app.core.querybudget").removeHandler(records)
+
+    assert response.status_code == 200
+    assert len(records.messages) == 2
+    assert "GET /positions issued 3 queries" in records.messages[0]
+    assert "finished with 5 queries (budget 2)" in records.messages[1]
+
+    # Outside a request budget nothing is counted
+    count_query("PortfolioService.get_positions")
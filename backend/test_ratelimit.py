@@ -0,0 +1,107 @@
+"""
+Tests for the API-wide rate limit, per signed-in user or per IP.
+"""
+
+import asyncio
+from types import SimpleNamespace
+
+from app.core import deps
+from app.core.config import settings
+from app.core.errors import AppError
+from app.core.security import security
+
+
+class FakeRedis:
+    """Sliding windows kept as lists of request times, enough for RateLimiter."""
+
+    def __init__(self):
+        self.windows = {}
+
+    def pipeline(self):
+        return FakePipeline(self.windows)
+
+
+class FakePipeline:
+    def __init__(self, windows):
+        self.windows = windows
+        self.ops = []
+
+    def zremrangebyscore(self, key, low, high):
+        def remove():
+            times = self.windows.get(key, [])
+            self.windows[key] = [t for t in times if not low <= t <= high]
+
+        self.ops.append(remove)
+
+    def zcard(self, key):
+        self.ops.append(lambda: len(self.windows.get(key, [])))
+
+    def zadd(self, key, mapping):
+        times = list(mapping.values())
+        self.ops.append(lambda: self.windows.setdefault(key, []).extend(times))
+
+    def expire(self, key, seconds):
+        self.ops.append(lambda: True)
+
+    def execute(self):
+        return [op() for op in self.ops]
+
+
+def _limit(monkeypatch, anonymous=3, authenticated=10):
+    redis = FakeRedis()
+    monkeypatch.setattr(deps, "get_redis_client", lambda: redis)
+    monkeypatch.setattr(settings, "API_RATE_LIMIT_ANONYMOUS", anonymous)
+    monkeypatch.setattr(settings, "API_RATE_LIMIT_AUTHENTICATED", authenticated)
+
+
+def _allowed(token=None, ip="203.0.113.7", forwarded=None):
+    headers = {"x-forwarded-for": forwarded} if forwarded else {}
+    request = SimpleNamespace(client=SimpleNamespace(host=ip), headers=headers)
+    credentials = SimpleNamespace(credentials=token) if token else None
+    try:
+        asyncio.run(deps.api_rate_limit(request, credentials))
+    except AppError as e:
+        assert (e.status_code, e.code) == (429, "rate_limit.exceeded")
+        return False
+    return True
+
+
+def test_signed_in_users_get_their_own_higher_limit(monkeypatch):
+    _limit(monkeypatch)
+    token = security.create_access_token({"sub": "7"})
+
+    # Anonymous traffic from the NAT's address runs out after 3...
+    assert [_allowed() for _ in range(4)] == [True, True, True, False]
+    # ...while a signed-in user behind it still has 10 of their own
+    assert [_allowed(token) for _ in range(11)] == [True] * 10 + [False]
+
+    other = security.create_access_token({"sub": "8"})
+    assert _allowed(other)
+
+
+def test_invalid_tokens_count_against_the_ip(monkeypatch):
+    _limit(monkeypatch)
+    assert [_allowed("not-a-token") for _ in range(3)] == [True] * 3
+    assert not _allowed()
+    assert _allowed(ip="198.51.100.1")
+
+
+def test_anonymous_callers_behind_a_proxy_count_by_their_own_address(monkeypatch):
+    _limit(monkeypatch, anonymous=1)
+    monkeypatch.setattr(settings, "TRUSTED_PROXY_COUNT", 1)
+    proxy = "10.0.0.2"
+
+    # The same address the per-IP limiter keys on, not the proxy's
+    assert _allowed(ip=proxy, forwarded="203.0.113.7")
+    assert _allowed(ip=proxy, forwarded="198.51.100.1")
+    assert not _allowed(ip=proxy, forwarded="9.9.9.9, 203.0.113.7")
+
+
+def test_zero_limit_checks_nothing(monkeypatch):
+    _limit(monkeypatch, anonymous=0)
+
+    def unreachable():
+        raise AssertionError("Redis shouldn't be contacted")
+
+    monkeypatch.setattr(deps, "get_redis_client", unreachable)
+    assert all(_allowed() for _ in range(100))
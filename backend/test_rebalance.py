@@ -0,0 +1,111 @@
+"""
+Tests for rebalancing suggestions and the gains their sells would realize.
+"""
+
+import asyncio
+from datetime import date, datetime
+
+from app.models.schemas import HoldingTerm, TradeSide, TransactionCreate
+from app.services.ledger import LedgerService
+from app.services.portfolio import PortfolioService
+from app.services.rebalance import RebalanceService, holding_term
+
+TODAY = datetime(2025, 3, 1, 12, 0)
+
+
+def _service():
+    return RebalanceService(PortfolioService(), LedgerService(), clock=lambda: TODAY)
+
+
+def _position(service, portfolio_id, symbol, quantity, value, target_weight):
+    asyncio.run(
+        service.portfolios.create_position(
+            {
+                "portfolio_id": portfolio_id,
+                "stock_symbol": symbol,
+                "quantity": quantity,
+                "average_price": 120.0,
+                "current_value": value,
+                "target_weight": target_weight,
+            }
+        )
+    )
+
+
+def _buy(service, portfolio_id, symbol, quantity, price, executed_at, fees=0.0):
+    asyncio.run(
+        service.ledger.record(
+            portfolio_id,
+            TransactionCreate(
+                symbol=symbol,
+                side=TradeSide.BUY,
+                quantity=quantity,
+                price=price,
+                fees=fees,
+                executed_at=executed_at,
+            ),
+        )
+    )
+
+
+def test_holding_term_turns_long_after_a_year():
+    bought = date(2024, 3, 1)
+    assert holding_term(bought, date(2025, 3, 1)) == HoldingTerm.SHORT
+    assert holding_term(bought, date(2025, 3, 2)) == HoldingTerm.LONG
+    # A 29 February purchase has its anniversary on 28 February
+    assert holding_term(date(2024, 2, 29), date(2025, 2, 28)) == HoldingTerm.SHORT
+    assert holding_term(date(2024, 2, 29), date(2025, 3, 1)) == HoldingTerm.LONG
+
+
+def test_a_sell_closing_a_long_term_lot_is_classified():
+    service = _service()
+    portfolio_id = service.portfolios.create_portfolio(user_id=9)
+    # AAA is 80% of 25,000 against a 50% target: sell 37 at 200, buy 150 BBB
+    _position(service, portfolio_id, "AAA", 100, 20000.0, 0.5)
+    _position(service, portfolio_id, "BBB", 100, 5000.0, 0.5)
+    _buy(service, portfolio_id, "AAA", 30, 100.0, datetime(2023, 1, 10), fees=30.0)
+    _buy(service, portfolio_id, "AAA", 70, 150.0, datetime(2024, 9, 2))
+
+    plan = asyncio.run(service.get_plan(portfolio_id))
+
+    sell, buy = plan.trades
+    assert (sell.symbol, sell.side, sell.quantity) == ("AAA", TradeSide.SELL, 37)
+    assert (buy.symbol, buy.side, buy.quantity) == ("BBB", TradeSide.BUY, 150)
+    assert (sell.current_weight, sell.target_weight) == (0.8, 0.5)
+    assert buy.lots == [] and buy.realized_gain is None
+
+    # The 2023 lot goes first: 30 * (200 - 101), then 7 of the 2024 lot
+    long_lot, short_lot = sell.lots
+    assert (long_lot.quantity, long_lot.term) == (30, HoldingTerm.LONG)
+    assert (long_lot.cost_basis, long_lot.gain) == (3030.0, 2970.0)
+    assert (short_lot.quantity, short_lot.term) == (7, HoldingTerm.SHORT)
+    assert short_lot.gain == 350.0
+    assert sell.unmatched_quantity == 0 and sell.realized_gain == 3320.0
+
+    assert plan.as_of == TODAY.date()
+    assert plan.tax.long_term_gain == 2970.0
+    assert plan.tax.short_term_gain == 350.0
+    assert plan.tax.unclassified_gain == 0.0
+    assert plan.tax.total_gain == 3320.0
+
+
+def test_shares_without_ledger_lots_are_unclassified():
+    service = _service()
+    portfolio_id = service.portfolios.create_portfolio(user_id=9)
+    # Sell 10 of CCC; the ledger only knows 4, bought at 90
+    _position(service, portfolio_id, "CCC", 20, 3000.0, 0.375)
+    _position(service, portfolio_id, "DDD", 10, 1000.0, None)
+    _buy(service, portfolio_id, "CCC", 4, 90.0, datetime(2025, 1, 2))
+
+    plan = asyncio.run(service.get_plan(portfolio_id))
+
+    (sell,) = plan.trades  # DDD has no target, so isn't traded
+    assert (sell.symbol, sell.quantity) == ("CCC", 10)
+    assert sell.unmatched_quantity == 6
+    assert plan.tax.short_term_gain == 240.0  # 4 * (150 - 90)
+    assert plan.tax.unclassified_gain == 180.0  # 6 * (150 - 120 average)
+    assert plan.tax.total_gain == sell.realized_gain == 420.0
+
+
+def test_unknown_portfolio():
+    assert asyncio.run(_service().get_plan(999)) is None
@@ -0,0 +1,120 @@
+"""
+Tests for the quote refresher's batched alert evaluation.
+"""
+
+import asyncio
+from collections import Counter
+from datetime import datetime, timedelta
+
+from app.models.schemas import AlertCondition, PriceAlertCreate
+from app.services.alerts import AlertService
+from app.services.market import MarketService
+from app.services.portfolio import PortfolioService
+from app.services.refresher import QuoteRefresher
+
+
+class CountingProvider:
+    def __init__(self, prices):
+        self.prices = prices
+        self.lookups = Counter()
+
+    async def get_quote(self, symbol):
+        self.lookups[symbol] += 1
+        if symbol not in self.prices:
+            raise RuntimeError(f"no quote for {symbol}")
+        return {"price": self.prices[symbol]}
+
+    async def get_history(self, symbol, interval, limit):
+        return []
+
+
+def _refresher(prices, alerts_spec, catalog=True):
+    provider = CountingProvider(prices)
+    market, alerts = MarketService(), AlertService()
+    if not catalog:
+        market.stocks.clear()
+    for user_id, symbol, condition, threshold in alerts_spec:
+        alert = PriceAlertCreate(
+            symbol=symbol, condition=condition, threshold=threshold
+        )
+        asyncio.run(alerts.create_alert(user_id, alert))
+    return QuoteRefresher(provider, market, PortfolioService(), alerts), provider
+
+
+ABOVE, BELOW = AlertCondition.ABOVE, AlertCondition.BELOW
+
+
+def test_alerts_sharing_symbols_cost_one_lookup_per_symbol():
+    refresher, provider = _refresher(
+        {"AAPL": 155.0, "MSFT": 300.0},
+        [
+            (1, "AAPL", ABOVE, 150.0),  # fires
+            (1, "aapl", ABOVE, 160.0),
+            (2, "AAPL", BELOW, 155.0),  # fires, at the threshold
+            (2, "MSFT", BELOW, 310.0),  # fires
+            (3, "MSFT", ABOVE, 320.0),
+            (3, "MSFT", BELOW, 290.0),
+        ],
+        catalog=False,
+    )
+
+    assert asyncio.run(refresher.refresh_once()) == 2
+    assert provider.lookups == Counter({"AAPL": 1, "MSFT": 1})
+    alerts = refresher.alerts
+    fired = [
+        alert.id
+        for user_id in (1, 2, 3)
+        for alert in asyncio.run(alerts.get_triggered_alerts(user_id))
+    ]
+    assert sorted(fired) == [1, 3, 4]
+    assert asyncio.run(alerts.active_symbols()) == ["AAPL", "MSFT"]
+
+    # Next cycle: still one lookup per symbol, and fired alerts stay fired
+    provider.prices["AAPL"] = 161.0
+    asyncio.run(refresher.refresh_once())
+    assert provider.lookups == Counter({"AAPL": 2, "MSFT": 2})
+    assert len(asyncio.run(alerts.get_triggered_alerts(1))) == 2
+
+
+def test_catalog_and_alert_symbols_are_fetched_together():
+    refresher, provider = _refresher(
+        {"AAPL": 155.0, "MSFT": 300.0, "GOOGL": 2700.0},
+        [(1, "MSFT", BELOW, 310.0), (1, "TSLA", ABOVE, 1.0)],
+    )
+
+    # TSLA's failed quote doesn't stop the catalog symbols
+    assert asyncio.run(refresher.refresh_once()) == 3
+    assert provider.lookups == Counter({"AAPL": 1, "GOOGL": 1, "MSFT": 1, "TSLA": 1})
+    assert asyncio.run(refresher.market.get_stock_by_symbol("MSFT")).price == 300.0
+    triggered = asyncio.run(refresher.alerts.get_triggered_alerts(1))
+    assert [alert.symbol for alert in triggered] == ["MSFT"]
+
+
+def test_pct_change_alerts_compare_with_the_previous_close():
+    refresher, provider = _refresher(
+        {"AAPL": 104.0, "TSLA": 50.0},
+        [(1, "AAPL", AlertCondition.PCT_CHANGE, 5.0)],
+        catalog=False,
+    )
+    # TSLA has neither stored closes nor a catalog entry
+    asyncio.run(
+        refresher.alerts.create_alert(
+            1,
+            PriceAlertCreate(
+                symbol="TSLA", condition=AlertCondition.PCT_CHANGE, threshold=1.0
+            ),
+        )
+    )
+    today = datetime.utcnow().date()
+    # Today's close (if already stored) isn't the previous one
+    refresher.market.market_data.upsert_closes(
+        "AAPL", {today - timedelta(days=1): 100.0, today: 103.0}
+    )
+
+    assert asyncio.run(refresher.refresh_once()) == 2
+    assert asyncio.run(refresher.alerts.get_events(1)) == []
+
+    provider.prices["AAPL"] = 105.5
+    asyncio.run(refresher.refresh_once())
+    (event,) = asyncio.run(refresher.alerts.get_events(1))
+    assert (event.symbol, event.price, event.previous_close) == ("AAPL", 105.5, 100.0)
@@ -0,0 +1,86 @@
+"""
+Tests for the in-memory repositories and the portfolio totals computed over
+them.
+"""
+
+import asyncio
+from datetime import datetime
+
+import pytest
+from app.repositories.memory import (
+    InMemoryPortfolioRepository,
+    InMemoryStockRepository,
+)
+from app.services.market import MarketService
+from app.services.portfolio import PortfolioService
+
+# name, [(symbol, quantity, average_price, price or None if unlisted)],
+# expected total_value, expected total_gain
+AGGREGATION_CASES = [
+    ("empty portfolio", [], 0.0, 0.0),
+    ("single gain", [("AAA", 10, 5.0, 7.5)], 75.0, 25.0),
+    ("single loss", [("AAA", 4, 20.0, 12.5)], 50.0, -30.0),
+    (
+        "gains and losses net out",
+        [("AAA", 10, 5.0, 7.5), ("BBB", 2, 100.0, 87.5)],
+        250.0,
+        0.0,
+    ),
+    ("unlisted stock is worth nothing", [("AAA", 3, 10.0, None)], 0.0, -30.0),
+    ("flat at cost", [("AAA", 5, 10.0, 10.0)], 50.0, 0.0),
+    ("rounded to cents", [("AAA", 3, 0.1111, 0.3333)], 1.0, 0.67),
+]
+
+
+def _portfolio_for(holdings):
+    stocks, portfolios = InMemoryStockRepository(), InMemoryPortfolioRepository()
+    market = MarketService(stocks=stocks)
+    service = PortfolioService(market, portfolios)
+    portfolio_id = service.create_portfolio(user_id=2)
+    for symbol, quantity, average_price, price in holdings:
+        if price is not None:
+            stocks.upsert({"symbol": symbol, "price": price})
+        portfolios.upsert_position(
+            {
+                "portfolio_id": portfolio_id,
+                "stock_symbol": symbol,
+                "quantity": quantity,
+                "average_price": average_price,
+                "current_value": 0.0,
+                "target_weight": None,
+                "notes": None,
+                "tags": [],
+            }
+        )
+    return asyncio.run(service.get_portfolio_by_id(portfolio_id))
+
+
+def test_portfolio_totals_sum_positions_at_stock_prices():
+    for name, holdings, total_value, total_gain in AGGREGATION_CASES:
+        portfolio = _portfolio_for(holdings)
+        assert len(portfolio.positions) == len(holdings), name
+        assert portfolio.total_value == pytest.approx(total_value), name
+        assert portfolio.total_gain == pytest.approx(total_gain), name
+
+
+def test_rows_handed_out_are_copies():
+    repo = InMemoryPortfolioRepository()
+    row = repo.upsert({"user_id": 1, "updated_at": datetime(2025, 1, 1)})
+
+    row["user_id"] = 99
+    assert repo.get(row["id"])["user_id"] == 1
+    repo.upsert(row)
+    assert repo.get(row["id"])["user_id"] == 99
+
+
+def test_deleting_a_portfolio_drops_its_positions():
+    repo = InMemoryPortfolioRepository()
+    first, second = repo.upsert({"user_id": 1}), repo.upsert({"user_id": 2})
+    kept = repo.upsert_position({"portfolio_id": second["id"]})
+    repo.upsert_position({"portfolio_id": first["id"]})
+
+    assert repo.delete(first["id"]) is True
+    assert repo.delete(first["id"]) is False
+    assert repo.list_positions() == [kept]
+    # Position ids aren't reused
+    assert repo.upsert_position({"portfolio_id": second["id"]})["id"] == 3
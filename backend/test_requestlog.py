@@ -0,0 +1,88 @@
+"""
+Tests for request IDs, request log lines and crash recovery.
+"""
+
+import asyncio
+import json
+import logging
+from types import SimpleNamespace
+
+from app.core.logging import JsonFormatter, RequestIdFilter, current_request_id
+from app.core.requestlog import REQUEST_ID_HEADER, RequestLogMiddleware
+
+
+class ListHandler(logging.Handler):
+    def __init__(self):
+        super().__init__()
+        self.records = []
+        self.addFilter(RequestIdFilter())
+
+    def emit(self, record):
+        self.records.append(record)
+
+
+def _dispatch(endpoint, ticks=(10.0, 10.25)):
+    clock = iter(ticks)
+    middleware = RequestLogMiddleware(None, timer=lambda: next(clock))
+    request = SimpleNamespace(method="GET", url=SimpleNamespace(path="/api/v1/x"))
+
+    root = logging.getLogger()
+    handler, level = ListHandler(), root.level
+    root.addHandler(handler)
+    root.setLevel(logging.INFO)
+    try:
+        response = asyncio.run(middleware.dispatch(request, endpoint))
+    finally:
+        root.removeHandler(handler)
+        root.setLevel(level)
+    return response, handler.records
+
+
+def test_requests_get_an_id_shared_by_their_logs():
+    async def endpoint(request):
+        logging.getLogger("app.services.portfolio").info("valuing positions")
+        return SimpleNamespace(status_code=200, headers={"content-length": "12"})
+
+    response, records = _dispatch(endpoint)
+
+    request_id = response.headers[REQUEST_ID_HEADER]
+    assert len(request_id) == 32
+    service_log, request_log = records
+    assert service_log.request_id == request_log.request_id == request_id
+    assert request_log.getMessage() == "GET /api/v1/x 200 12B 250.0ms"
+    assert (request_log.status, request_log.size, request_log.duration_ms) == (
+        200,
+        12,
+        250.0,
+    )
+    # The ID only lives as long as the request
+    assert current_request_id() is None
+
+
+def test_unhandled_errors_become_a_500_body():
+    async def endpoint(request):
+        raise RuntimeError("boom")
+
+    response, records = _dispatch(endpoint)
+
+    assert response.status_code == 500
+    body = json.loads(response.body)
+    assert body["code"] == "internal.error"
+    # The body carries the ID so a failure can be found in the logs
+    assert body["request_id"] == response.headers[REQUEST_ID_HEADER]
+    assert records[0].exc_info[0] is RuntimeError
+    assert records[0].request_id == response.headers[REQUEST_ID_HEADER]
+    assert records[-1].status == 500
+
+
+def test_json_log_lines_carry_extra_fields():
+    record = logging.makeLogRecord(
+        {"name": "app.x", "levelname": "INFO", "msg": "GET %s", "args": ("/a",)}
+    )
+    record.status = 200
+    RequestIdFilter().filter(record)
+
+    entry = json.loads(JsonFormatter().format(record))
+
+    assert entry["message"] == "GET /a"
+    assert (entry["status"], entry["request_id"], entry["level"]) == (200, "-", "INFO")
@@ -0,0 +1,102 @@
+"""
+Tests for ranked symbol search.
+"""
+
+import asyncio
+
+from app.services.market import MarketService
+from app.services.search import SearchService, similarity
+
+
+def _service(rows):
+    market = MarketService()
+    market.stocks.clear()
+    for symbol, name, cap in rows:
+        market._put_stock(
+            {
+                "symbol": symbol,
+                "name": name,
+                "price": 100.0,
+                "change": 1.0,
+                "change_percent": 1.0,
+                "volume": 1000,
+                "market_cap": cap,
+                "pe_ratio": None,
+            }
+        )
+    return SearchService(market)
+
+
+CATALOG = [
+    ("AAPL", "Apple Inc.", "2.4T"),
+    ("APP", "AppLovin Corporation", "100B"),
+    ("AMAT", "Applied Materials", "150B"),
+    ("GOOGL", "Alphabet Inc.", "1.8T"),
+    ("MSFT", "Microsoft Corporation", "2.3T"),
+]
+
+
+def test_typo_falls_back_to_trigram_similarity():
+    service = _service(CATALOG)
+
+    results = asyncio.run(service.search("GOOGLE"))
+
+    assert results[0].symbol == "GOOGL"
+    assert results[0].match_type == "fuzzy"
+    assert similarity("GOOGLE", "GOOGL") >= 0.3
+
+
+def test_ranking_prefers_exact_then_prefix_with_ties_by_market_cap_and_symbol():
+    service = _service(CATALOG + [("APPX", "Apex Co", "100B"), ("APPY", "Apy", "100B")])
+
+    results = asyncio.run(service.search("app"))
+    symbols = [r.symbol for r in results]
+
+    # Exact symbol first, then symbol prefixes (equal cap ties break by symbol),
+    # then name prefixes ordered by market cap
+    assert symbols[:5] == ["APP", "APPX", "APPY", "AAPL", "AMAT"]
+    assert results[0].highlights == {"symbol": [[0, 3]]}
+    assert results[3].highlights == {"name": [[0, 3]]}
+
+
+def test_recency_boost_is_isolated_per_user():
+    service = _service(CATALOG)
+    asyncio.run(service.record_view(1, "AMAT"))
+
+    boosted = [r.symbol for r in asyncio.run(service.search("app", user_id=1))]
+    other_user = [r.symbol for r in asyncio.run(service.search("app", user_id=2))]
+    anonymous = [r.symbol for r in asyncio.run(service.search("app"))]
+
+    assert boosted.index("AMAT") < boosted.index("AAPL")
+    assert other_user.index("AAPL") < other_user.index("AMAT")
+    assert anonymous == other_user
+
+
+def test_market_cap_and_recency_never_lift_a_result_past_its_tier():
+    service = _service(
+        [
+            ("APP", "AppLovin Corporation", "1M"),
+            ("APPX", "Apex Co", "3T"),
+            ("APPY", "Apy", "1M"),
+            ("AAPL", "Apple Inc.", "2.4T"),
+            ("SNAP", "Snapple Group", "3T"),
+            ("AMAT", "Applied Materials", "1M"),
+        ]
+    )
+    for symbol in ("SNAP", "AAPL", "APPX"):
+        asyncio.run(service.record_view(1, symbol))
+
+    results = asyncio.run(service.search("app", user_id=1))
+    by_symbol = {r.symbol: r for r in results}
+
+    # Exact symbol, symbol prefixes, then name prefixes and substrings
+    assert [r.symbol for r in results] == [
+        "APP",
+        "APPX",
+        "APPY",
+        "AAPL",
+        "SNAP",
+        "AMAT",
+    ]
+    assert by_symbol["APPX"].score > by_symbol["APP"].score
+    assert by_symbol["AAPL"].score > by_symbol["APPY"].score
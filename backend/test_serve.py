@@ -0,0 +1,158 @@
+"""
+Tests for serving HTTP/2 through Hypercorn, timeouts and graceful shutdown.
+"""
+
+import asyncio
+import json
+import socket
+
+import httpx
+import pytest
+from app.core.config import settings
+from app.serve import (
+    InFlightRequests,
+    http2_enabled,
+    hypercorn_config,
+    with_write_timeout,
+)
+
+hypercorn_asyncio = pytest.importorskip("hypercorn.asyncio")
+pytest.importorskip("h2")
+
+
+async def echo_protocol(scope, receive, send):
+    """ASGI app answering with the HTTP version the server saw."""
+    if scope["type"] == "lifespan":
+        while (await receive())["type"] != "lifespan.shutdown":
+            await send({"type": "lifespan.startup.complete"})
+        await send({"type": "lifespan.shutdown.complete"})
+        return
+    await send(
+        {
+            "type": "http.response.start",
+            "status": 200,
+            "headers": [(b"content-type", b"text/plain")],
+        }
+    )
+    await send({"type": "http.response.body", "body": scope["http_version"].encode()})
+
+
+def _free_port() -> int:
+    with socket.socket() as sock:
+        sock.bind(("127.0.0.1", 0))
+        return sock.getsockname()[1]
+
+
+async def _get_once_up(client: httpx.AsyncClient, url: str) -> httpx.Response:
+    """GET url, retrying while the server is still starting."""
+    for _ in range(50):
+        try:
+            return await client.get(url)
+        except httpx.ConnectError:
+            await asyncio.sleep(0.05)
+    raise AssertionError(f"{url} never came up")
+
+
+def test_http2_is_opt_in(monkeypatch):
+    assert not http2_enabled()
+    monkeypatch.setattr(settings, "HTTP2_CLEARTEXT", True)
+    assert http2_enabled()
+
+
+def test_h2c_negotiates_http2(monkeypatch):
+    monkeypatch.setattr(settings, "HTTP2_CLEARTEXT", True)
+    port = _free_port()
+    config = hypercorn_config("127.0.0.1", port)
+
+    async def scenario():
+        shutdown = asyncio.Event()
+        server = asyncio.create_task(
+            hypercorn_asyncio.serve(
+                echo_protocol, config, shutdown_trigger=shutdown.wait
+            )
+        )
+        try:
+            # Prior knowledge: speak HTTP/2 from the first byte, no TLS
+            async with httpx.AsyncClient(http1=False, http2=True) as client:
+                response = await _get_once_up(client, f"http://127.0.0.1:{port}/")
+            assert response.http_version == "HTTP/2"
+            assert response.text == "2"
+        finally:
+            shutdown.set()
+            await server
+
+    asyncio.run(scenario())
+
+
+def test_shutdown_drains_in_flight_requests_and_refuses_new_ones():
+    port = _free_port()
+    url = f"http://127.0.0.1:{port}/"
+    config = hypercorn_config("127.0.0.1", port)
+
+    async def scenario():
+        entered, release, shutdown = asyncio.Event(), asyncio.Event(), asyncio.Event()
+
+        async def slow_write(scope, receive, send):
+            if scope["type"] != "http":
+                return await echo_protocol(scope, receive, send)
+            entered.set()
+            await release.wait()
+            await send({"type": "http.response.start", "status": 200})
+            await send({"type": "http.response.body", "body": b"saved"})
+
+        server = asyncio.create_task(
+            hypercorn_asyncio.serve(slow_write, config, shutdown_trigger=shutdown.wait)
+        )
+        async with httpx.AsyncClient() as client:
+            in_flight = asyncio.create_task(_get_once_up(client, url))
+            await entered.wait()
+            shutdown.set()
+            await asyncio.sleep(0.2)
+
+            async with httpx.AsyncClient() as late_client:
+                with pytest.raises(httpx.ConnectError):
+                    await late_client.get(url)
+
+            release.set()
+            response = await in_flight
+        await server
+        return response
+
+    response = asyncio.run(scenario())
+    assert (response.status_code, response.text) == (200, "saved")
+
+
+def test_responses_past_the_write_timeout_are_cut_off():
+    async def stuck(scope, receive, send):
+        await asyncio.sleep(10)
+
+    sent = []
+
+    async def send(message):
+        sent.append(message)
+
+    scope = {"type": "http", "method": "GET", "path": "/slow", "headers": []}
+    asyncio.run(with_write_timeout(stuck, 0.05)(scope, None, send))
+
+    assert sent[0]["status"] == 503
+    assert json.loads(sent[1]["body"])["code"] == "http.timeout"
+    assert with_write_timeout(stuck, 0) is stuck
+
+
+def test_requests_cancelled_at_shutdown_are_counted():
+    async def slow(scope, receive, send):
+        await asyncio.sleep(10)
+
+    tracked = InFlightRequests(slow)
+
+    async def scenario():
+        request = asyncio.create_task(tracked({"type": "http"}, None, None))
+        lifespan = asyncio.create_task(tracked({"type": "lifespan"}, None, None))
+        await asyncio.sleep(0)
+        # What the server does once the grace period runs out
+        request.cancel()
+        lifespan.cancel()
+        await asyncio.gather(request, lifespan, return_exceptions=True)
+
+    asyncio.run(scenario())
+    assert tracked.cut_off == 1
@@ -0,0 +1,125 @@
+"""
+Tests for SLO tracking, error budgets and the metrics middleware.
+"""
+
+import asyncio
+from types import SimpleNamespace
+
+from app.core.metrics import (
+    MetricsMiddleware,
+    register_slo_gauges,
+    render_metrics,
+)
+from app.core.slo import SLODefinition, SLOTracker
+
+
+class FakeClock:
+    def __init__(self, now=0.0):
+        self.now = now
+
+    def __call__(self):
+        return self.now
+
+    def advance(self, minutes):
+        self.now += minutes * 60
+
+
+def quotes_tracker(clock, window_minutes=10):
+    definition = SLODefinition(
+        name="quotes",
+        route="/api/v1/market/stocks*",
+        latency_ms=200,
+        target=0.99,
+        window_minutes=window_minutes,
+    )
+    return SLOTracker([definition], clock=clock)
+
+
+def test_budget_math_for_synthetic_traffic():
+    clock = FakeClock()
+    tracker = quotes_tracker(clock)
+
+    # 1000 requests: 995 good, 3 too slow, 2 server errors -> 0.5% bad
+    for _ in range(995):
+        tracker.record("/api/v1/market/stocks/{symbol}", 50, 200)
+    for _ in range(3):
+        tracker.record("/api/v1/market/stocks/{symbol}", 250, 200)
+    for _ in range(2):
+        tracker.record("/api/v1/market/stocks", 10, 503)
+    # Client errors count as good, other routes aren't tracked
+    tracker.record("/api/v1/market/stocks/{symbol}", 10, 404)
+    tracker.record("/api/v1/portfolio/", 5000, 500)
+
+    [status] = tracker.report()
+    assert (status.total, status.good) == (1001, 996)
+    assert abs(status.burn_rate - (5 / 1001) / 0.01) < 1e-6
+    assert abs(status.error_budget_remaining - (1 - status.burn_rate)) < 1e-6
+
+
+def test_no_traffic_leaves_budget_untouched():
+    [status] = quotes_tracker(FakeClock()).report()
+    assert status.total == 0
+    assert status.compliance is None
+    assert (status.burn_rate, status.error_budget_remaining) == (0.0, 1.0)
+
+
+def test_requests_leave_the_window_at_the_boundary():
+    clock = FakeClock()
+    tracker = quotes_tracker(clock, window_minutes=10)
+
+    for _ in range(2):
+        tracker.record("/api/v1/market/stocks", 10, 500)  # minute 0
+    clock.advance(5)
+    for _ in range(98):
+        tracker.record("/api/v1/market/stocks", 10, 200)  # minute 5
+
+    # Minute 9 is the last that still covers minute 0
+    clock.advance(4)
+    [status] = tracker.report()
+    assert (status.total, status.good) == (100, 98)
+    assert abs(status.burn_rate - 2.0) < 1e-9
+    assert abs(status.error_budget_remaining + 1.0) < 1e-9
+
+    # At minute 10 the failures have aged out
+    clock.advance(1)
+    [status] = tracker.report()
+    assert (status.total, status.good) == (98, 98)
+    assert status.error_budget_remaining == 1.0
+
+    # Minute 15 reuses minute 5's slot in the ring, which must be reset
+    clock.advance(5)
+    tracker.record("/api/v1/market/stocks", 10, 500)
+    [status] = tracker.report()
+    assert (status.total, status.good) == (1, 0)
+
+
+def test_middleware_records_route_template_and_exposes_gauges():
+    clock = FakeClock()
+    tracker = quotes_tracker(clock)
+    ticks = iter([0.0, 0.3, 1.0, 1.01])
+    middleware = MetricsMiddleware(None, tracker=tracker, timer=lambda: next(ticks))
+
+    def request(path, template):
+        return SimpleNamespace(
+            method="GET",
+            scope={"route": SimpleNamespace(path=template)},
+            url=SimpleNamespace(path=path),
+        )
+
+    async def ok(request):
+        return SimpleNamespace(status_code=200)
+
+    # 300ms misses the latency target; the second request is fast
+    for path in ("/api/v1/market/stocks/AAPL", "/api/v1/market/stocks/MSFT"):
+        asyncio.run(
+            middleware.dispatch(request(path, "/api/v1/market/stocks/{symbol}"), ok)
+        )
+
+    [status] = tracker.report()
+    assert (status.total, status.good) == (2, 1)
+
+    register_slo_gauges(tracker)
+    text = render_metrics()
+    assert "# TYPE slo_burn_rate gauge" in text
+    assert 'slo_burn_rate{slo="quotes"} 50.0' in text
+    assert 'slo_error_budget_remaining{slo="quotes"} -49.0' in text
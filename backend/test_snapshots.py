@@ -0,0 +1,122 @@
+"""
+Tests for daily portfolio snapshots and the metrics and history read from them.
+"""
+
+import asyncio
+from datetime import date, timedelta
+
+import pytest
+from app.models.schemas import Granularity
+from app.services.market import MarketService
+from app.services.performance import PerformanceService
+from app.services.portfolio import PortfolioService
+from app.services.snapshots import SnapshotService
+from app.utils.returns import total_return
+
+START = date(2025, 1, 6)
+
+
+def _services():
+    portfolios = PortfolioService()
+    for position in portfolios.portfolios.list_positions():
+        portfolios.portfolios.delete_position(position["id"])
+    portfolios._insert_position(1, "AAPL", 10, 100.0, 1000.0)
+    market = MarketService()
+    market.put_closes("AAPL", {START + timedelta(days=i): 100.0 + i for i in range(4)})
+    snapshots = SnapshotService(portfolios)
+    return portfolios, snapshots, PerformanceService(portfolios, market, snapshots)
+
+
+def test_snapshot_records_value_and_holdings_once_per_day():
+    portfolios, snapshots, _ = _services()
+
+    first = asyncio.run(snapshots.take_all(START))
+    assert [(s.portfolio_id, s.total_value, s.total_gain) for s in first] == [
+        (1, 1000.0, 0.0)
+    ]
+    assert [(h.stock_symbol, h.quantity) for h in first[0].holdings] == [("AAPL", 10)]
+
+    # Running the job again the same day replaces that day's snapshot
+    position = portfolios.portfolios.list_positions()[0]
+    portfolios.portfolios.upsert_position({**position, "current_value": 1100.0})
+    asyncio.run(snapshots.take_all(START))
+    stored = asyncio.run(snapshots.get_snapshots(1, START, START))
+    assert [s.total_value for s in stored] == [1100.0]
+
+    assert asyncio.run(snapshots.take(999, START)) is None
+
+
+def test_metrics_prefer_snapshots_and_reconstruct_gaps():
+    _, snapshots, performance = _services()
+    # Closes value the holdings at 1000, 1010, 1020, 1030; snapshots replace
+    # the second day and add a day with no closes at all
+    asyncio.run(snapshots.take(1, START + timedelta(days=1)))
+    snapshots._snapshots[(1, START + timedelta(days=1))]["total_value"] = 990.0
+    asyncio.run(snapshots.take(1, START + timedelta(days=6)))
+
+    end = START + timedelta(days=6)
+    values = asyncio.run(performance.portfolio_values(1, START, end))
+    assert values == [1000.0, 990.0, 1020.0, 1030.0, 1000.0]
+
+    metrics = asyncio.run(performance.metrics(1, START, end))
+    assert metrics.observations == 5
+    assert metrics.total_return == pytest.approx(total_return(values), abs=1e-6)
+
+
+def _history(snapshots, values_by_day, granularity, end):
+    for day, value in values_by_day.items():
+        asyncio.run(snapshots.take(1, day))
+        snapshots._snapshots[(1, day)]["total_value"] = value
+    return asyncio.run(snapshots.get_history(1, START, end, granularity))
+
+
+def test_history_leaves_out_days_without_a_snapshot():
+    _, snapshots, _ = _services()
+    # Monday to Wednesday, then Friday; nothing on Thursday or the weekend
+    values = {
+        START + timedelta(days=i): value
+        for i, value in [(0, 1000.0), (1, 1100.0), (2, 880.0), (4, 990.0)]
+    }
+
+    history = _history(snapshots, values, Granularity.DAILY, START + timedelta(days=6))
+
+    assert [(p.date, p.total_value) for p in history.points] == sorted(values.items())
+    assert history.period_return == pytest.approx(-0.01)
+    assert history.max_drawdown == pytest.approx(0.2)  # 1100 down to 880
+
+
+def test_weekly_and_monthly_history_keep_each_bucket_last_snapshot():
+    _, snapshots, _ = _services()
+    # START is a Monday; two snapshots in its week, one the next week and
+    # one in February
+    values = {
+        START: 1000.0,
+        START + timedelta(days=3): 1050.0,
+        START + timedelta(days=8): 1200.0,
+        date(2025, 2, 3): 900.0,
+    }
+    end = date(2025, 2, 28)
+
+    weekly = _history(snapshots, values, Granularity.WEEKLY, end)
+    assert [(p.date, p.total_value) for p in weekly.points] == [
+        (START + timedelta(days=3), 1050.0),
+        (START + timedelta(days=8), 1200.0),
+        (date(2025, 2, 3), 900.0),
+    ]
+    assert weekly.period_return == pytest.approx(900 / 1050 - 1, abs=1e-6)
+
+    monthly = asyncio.run(snapshots.get_history(1, START, end, Granularity.MONTHLY))
+    assert [p.total_value for p in monthly.points] == [1200.0, 900.0]
+    assert monthly.max_drawdown == pytest.approx(0.25)
+
+    empty = asyncio.run(snapshots.get_history(1, end, end, Granularity.DAILY))
+    assert (empty.points, empty.period_return, empty.max_drawdown) == ([], None, None)
+
+
+def test_history_is_empty_before_the_first_snapshot():
+    _, snapshots, _ = _services()
+
+    history = asyncio.run(snapshots.get_history(1, START, START + timedelta(days=6)))
+
+    assert history.points == []
+    assert history.period_return is None and history.max_drawdown is None
@@ -0,0 +1,69 @@
+"""
+Tests for application startup with the keyless market data setups.
+"""
+
+import asyncio
+
+from app import main
+from app.core.config import settings
+from app.core.jobs import JobScheduler
+from app.services.alerts import AlertService
+from app.services.demo import DEMO_USER_ID
+from app.services.market import MarketService
+from app.services.portfolio import PortfolioService
+
+
+def _start(monkeypatch, **overrides):
+    """Start and stop the app against fresh services; returns them."""
+    for name, value in overrides.items():
+        monkeypatch.setattr(settings, name, value)
+    monkeypatch.setattr(settings, "METRICS_PORT", 0)
+    market, portfolios, scheduler = MarketService(), PortfolioService(), JobScheduler()
+    monkeypatch.setattr(main, "market_service", market)
+    monkeypatch.setattr(main, "portfolio_service", portfolios)
+    monkeypatch.setattr(main, "alert_service", AlertService())
+    monkeypatch.setattr(main, "job_scheduler", scheduler)
+    monkeypatch.setattr(main, "state", {})
+
+    async def start_and_stop():
+        await main.startup_event()
+        await main.shutdown_event()
+
+    asyncio.run(start_and_stop())
+    return market, portfolios, scheduler
+
+
+def test_demo_mode_starts_on_synthetic_data(monkeypatch):
+    market, portfolios, scheduler = _start(monkeypatch, DEMO_MODE=True)
+
+    assert market.provider_name == "synthetic"
+    portfolio = asyncio.run(portfolios.get_portfolio(DEMO_USER_ID))
+    assert portfolio is not None and portfolio.positions
+    assert scheduler.get("demo_reset") is not None
+    assert scheduler.get("quote_refresh") is not None
+
+
+def test_migrations_run_at_startup_when_the_database_is_required(monkeypatch):
+    runs = []
+    monkeypatch.setattr(main, "migrate", lambda: runs.append(True))
+
+    _start(monkeypatch, DEMO_MODE=True, DATABASE_REQUIRED=False)
+    assert runs == []
+    _start(monkeypatch, DEMO_MODE=True, DATABASE_REQUIRED=True)
+    assert runs == [True]
+
+
+def test_synthetic_provider_starts_without_a_key(monkeypatch):
+    market, _, scheduler = _start(
+        monkeypatch,
+        DEMO_MODE=False,
+        MARKET_DATA_PROVIDER="synthetic",
+        FINNHUB_API_KEY="",
+    )
+
+    assert market.provider_name == "synthetic"
+    assert scheduler.get("quote_refresh") is not None
+    # Ticks start from the stored prices
+    stock = asyncio.run(market.get_stocks())[0]
+    quote = asyncio.run(market.provider.get_quote(stock.symbol))
+    assert quote["symbol"] == stock.symbol and quote["price"] > 0
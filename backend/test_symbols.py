@@ -0,0 +1,117 @@
+"""
+Tests for symbol validation and the compliance allow/blocklist.
+"""
+
+import asyncio
+import json
+
+import pytest
+from app.core.config import settings
+from app.services.market import MarketService
+from app.services.portfolio import PortfolioService
+from app.services.search import SearchService
+from app.utils.symbols import (
+    InvalidSymbolError,
+    SymbolRestrictedError,
+    check_symbol,
+    normalize_symbol,
+    parse_symbol,
+    symbol_allowed,
+)
+from app.ws.feed import PollingFeed
+from app.ws.hub import ConnectionManager
+
+
+class FakeClient:
+    def __init__(self):
+        self.client = "fake-client"
+        self.sent = []
+
+    async def accept(self):
+        pass
+
+    async def send_text(self, message: str):
+        self.sent.append(json.loads(message))
+
+
+class QuoteProvider:
+    async def get_quote(self, symbol):
+        return {"price": 1.0}
+
+
+def _position(symbol):
+    return {
+        "portfolio_id": 1,
+        "stock_symbol": symbol,
+        "quantity": 1,
+        "average_price": 10.0,
+    }
+
+
+def test_blocked_symbol_is_rejected(monkeypatch):
+    monkeypatch.setattr(settings, "SYMBOL_BLOCKLIST", ["TSLA"])
+    portfolios = PortfolioService()
+
+    with pytest.raises(SymbolRestrictedError, match="TSLA"):
+        asyncio.run(portfolios.create_position(_position(" tsla ")))
+    with pytest.raises(SymbolRestrictedError):
+        asyncio.run(portfolios.update_position(1, 1, {"stock_symbol": "Tsla"}))
+    assert asyncio.run(portfolios.get_positions(1))[0].stock_symbol == "AAPL"
+
+    created = asyncio.run(portfolios.create_position(_position(" msft")))
+    assert created.stock_symbol == "MSFT"
+
+    # Nor can it be watched over the WebSocket
+    manager = ConnectionManager(PollingFeed(QuoteProvider(), interval=60))
+    client = FakeClient()
+    message = json.dumps({"type": "subscribe", "symbol": "tsla"})
+    asyncio.run(manager.handle_message(client, message))
+    assert manager.subscriptions == {}
+    assert client.sent[0]["code"] == "stock.restricted"
+
+
+def test_allowlist_permits_only_listed_symbols(monkeypatch):
+    monkeypatch.setattr(settings, "SYMBOL_ALLOWLIST", ["AAPL", "MSFT"])
+    monkeypatch.setattr(settings, "SYMBOL_BLOCKLIST", ["MSFT"])
+
+    assert check_symbol("aapl ") == "AAPL"
+    assert not symbol_allowed("GOOGL")
+    # The blocklist still applies to allowlisted symbols
+    assert not symbol_allowed("MSFT")
+
+    search = SearchService(MarketService())
+    assert asyncio.run(search.search("microsoft")) == []
+    assert [r.symbol for r in asyncio.run(search.search("apple"))] == ["AAPL"]
+
+
+def test_symbols_from_urls_must_look_like_tickers():
+    assert parse_symbol(" aapl ") == "AAPL"
+    assert parse_symbol("GOOGL") == "GOOGL"
+    for garbage in ["../../etc", "", "AAPL1", "BRK/B", "ABCDEFGHIJK"]:
+        with pytest.raises(InvalidSymbolError):
+            parse_symbol(garbage)
+
+
+def test_messy_symbols_normalize_to_one_symbol():
+    for messy in ["aapl", " AAPL", "aapl ", "\tAaPl\n", "'AAPL'", '"aapl"', "` aapl `"]:
+        assert normalize_symbol(messy) == "AAPL"
+        assert parse_symbol(messy) == "AAPL"
+
+
+def test_messy_symbols_share_one_position_and_price():
+    portfolios = PortfolioService()
+    asyncio.run(portfolios.create_position(_position("'nflx'")))
+    asyncio.run(portfolios.create_position(_position("Nflx ")))
+    positions = asyncio.run(portfolios.get_positions(1))
+    assert [p.quantity for p in positions if p.stock_symbol == "NFLX"] == [2]
+
+    market = MarketService()
+    stock = asyncio.run(market.get_stock_by_symbol(' "aapl" '))
+    assert stock.symbol == "AAPL"
+
+
+def test_case_sensitive_symbols_keep_their_case(monkeypatch):
+    monkeypatch.setattr(settings, "SYMBOL_CASE_SENSITIVE", True)
+
+    assert normalize_symbol(" 'BRKb' ") == "BRKb"
+    assert parse_symbol("aapl") == "aapl"
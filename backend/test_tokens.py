@@ -0,0 +1,52 @@
+"""
+Tests for the bearer token checks that guard authenticated routes.
+"""
+
+import asyncio
+from datetime import timedelta
+
+import jwt
+import pytest
+from app.core.config import Settings, settings
+from app.core.deps import get_current_user_id, get_current_user_token
+from app.core.errors import AppError
+from app.core.security import security
+
+
+def _rejection(dependency, token) -> AppError:
+    with pytest.raises(AppError) as exc:
+        asyncio.run(dependency(token))
+    assert exc.value.status_code == 401
+    return exc.value
+
+
+def test_valid_token_yields_its_user():
+    token = security.create_access_token({"sub": "7"})
+    assert asyncio.run(get_current_user_id(token)) == 7
+
+
+def test_missing_expired_and_forged_tokens_are_rejected():
+    assert _rejection(get_current_user_token, None).code == "auth.token_missing"
+
+    expired = security.create_access_token(
+        {"sub": "7"}, expires_delta=timedelta(seconds=-1)
+    )
+    assert _rejection(get_current_user_id, expired).code == "auth.token_expired"
+
+    claims = {
+        "sub": "7",
+        "type": "access",
+        "iss": settings.JWT_ISSUER,
+        "aud": settings.JWT_AUDIENCE,
+    }
+    unsigned = jwt.encode(claims, None, algorithm="none")
+    forged = jwt.encode(claims, "not-our-secret", algorithm="HS256")
+    for token in (unsigned, forged):
+        assert _rejection(get_current_user_id, token).code == "auth.token_invalid"
+
+
+def test_signing_settings(monkeypatch):
+    monkeypatch.delenv("SECRET_KEY", raising=False)
+    assert Settings(JWT_SECRET="from-env").SECRET_KEY == "from-env"
+    with pytest.raises(ValueError, match="signing algorithm"):
+        Settings(SECRET_KEY="x", JWT_ALGORITHM="none")
@@ -0,0 +1,362 @@
+"""
+Tests for the transaction ledger and closed-trade statistics.
+"""
+
+import asyncio
+from datetime import datetime, timedelta
+
+import pytest
+from app.api.v1.endpoints.portfolio import record_transaction
+from app.core.config import settings
+from app.core.errors import AppError
+from app.data.finnhub import FinnhubRateLimitError
+from app.models.schemas import (
+    PositionCheckStatus,
+    TradeSide,
+    TransactionCreate,
+    TransactionRequest,
+)
+from app.services.ledger import (
+    DuplicateTransactionError,
+    LedgerService,
+    PriceMovedError,
+)
+from app.services.market import MarketService
+from app.services.portfolio import PortfolioService
+from app.services.trades import TradeStatsService
+
+DAY = datetime(2025, 1, 6, 15, 30)
+
+
+class FixedQuoteProvider:
+    def __init__(self, price):
+        self.price = price
+
+    async def get_quote(self, symbol):
+        return {"symbol": symbol, "c": self.price}
+
+
+def _record(ledger, day, symbol, side, quantity, price, fees=0.0, **price_check):
+    return asyncio.run(
+        ledger.record(
+            1,
+            TransactionCreate(
+                symbol=symbol,
+                side=side,
+                quantity=quantity,
+                price=price,
+                fees=fees,
+                executed_at=DAY + timedelta(days=day),
+            ),
+            **price_check,
+        )
+    )
+
+
+def test_trade_stats_over_winning_and_losing_trades():
+    ledger = LedgerService()
+    buy, sell = TradeSide.BUY, TradeSide.SELL
+    _record(ledger, 0, "AAPL", buy, 10, 100.0)
+    _record(ledger, 1, "AAPL", buy, 10, 110.0)
+    # Closes the first lot and half the second: 1200 - (1000 + 550) = -350
+    _record(ledger, 2, "AAPL", sell, 15, 80.0)
+    # Closes the rest of the second lot: 5 * 150 - 550 = +200
+    _record(ledger, 3, "aapl", sell, 5, 150.0)
+    # Fees on both sides: 10 * 60 - 5 - (10 * 50 + 5) = +90
+    _record(ledger, 4, "MSFT", buy, 10, 50.0, fees=5.0)
+    _record(ledger, 5, "MSFT", sell, 10, 60.0, fees=5.0)
+    # Still open, so it doesn't count
+    _record(ledger, 6, "GOOGL", buy, 1, 2500.0)
+
+    service = TradeStatsService(PortfolioService(), ledger)
+    stats = asyncio.run(service.get_trade_stats(1))
+
+    assert stats.closed_trades == 3
+    assert (stats.wins, stats.losses) == (2, 1)
+    assert stats.win_rate == pytest.approx(2 / 3, abs=1e-4)
+    assert stats.average_win == 145.0
+    assert stats.average_loss == -350.0
+    assert stats.profit_factor == pytest.approx(290 / 350, abs=1e-4)
+
+
+def test_no_closed_trades_and_unknown_portfolio():
+    ledger = LedgerService()
+    _record(ledger, 0, "AAPL", TradeSide.BUY, 1, 100.0)
+    service = TradeStatsService(PortfolioService(), ledger)
+
+    stats = asyncio.run(service.get_trade_stats(1))
+    assert stats.closed_trades == 0
+    assert stats.win_rate is None and stats.profit_factor is None
+    assert asyncio.run(service.get_trade_stats(99)) is None
+
+
+def test_ledger_rejects_selling_more_than_was_held():
+    ledger = LedgerService()
+    _record(ledger, 0, "AAPL", TradeSide.BUY, 10, 100.0)
+    _record(ledger, 2, "AAPL", TradeSide.SELL, 8, 100.0)
+
+    with pytest.raises(ValueError, match="more shares than were held"):
+        _record(ledger, 3, "AAPL", TradeSide.SELL, 5, 100.0)
+    # Held 10 on day 1, but the day-2 sell would then be uncovered
+    with pytest.raises(ValueError, match="more shares than were held"):
+        _record(ledger, 1, "AAPL", TradeSide.SELL, 5, 100.0)
+    assert len(asyncio.run(ledger.get_transactions(1))) == 2
+
+
+def test_price_within_tolerance_is_recorded():
+    ledger = LedgerService(MarketService(FixedQuoteProvider(100.5)))
+    transaction = _record(
+        ledger, 0, "AAPL", TradeSide.BUY, 10, 100.5, expected_price=100.0
+    )
+    assert transaction.price == 100.5
+    # Tolerance is a fraction of the expected price and may be set per request
+    _record(
+        ledger,
+        1,
+        "AAPL",
+        TradeSide.BUY,
+        1,
+        100.5,
+        expected_price=98.0,
+        tolerance=0.03,
+    )
+    assert len(asyncio.run(ledger.get_transactions(1))) == 2
+
+
+def test_price_outside_tolerance_is_rejected_with_current_price():
+    ledger = LedgerService(MarketService(FixedQuoteProvider(103.0)))
+    with pytest.raises(PriceMovedError) as excinfo:
+        _record(
+            ledger,
+            0,
+            "AAPL",
+            TradeSide.BUY,
+            10,
+            100.0,
+            expected_price=100.0,
+            tolerance=0.02,
+        )
+    assert excinfo.value.current_price == 103.0
+    assert excinfo.value.expected_price == 100.0
+    assert asyncio.run(ledger.get_transactions(1)) == []
+
+
+class FailingQuoteProvider:
+    async def get_quote(self, symbol):
+        raise FinnhubRateLimitError("Rate limit exceeded")
+
+
+def test_a_failed_price_check_is_a_provider_error():
+    portfolios = PortfolioService()
+    portfolio_id = portfolios.create_portfolio(user_id=1)
+    market = MarketService(FailingQuoteProvider(), "finnhub")
+    ledger = LedgerService(market, portfolios=portfolios)
+    request = TransactionRequest(
+        symbol="AAPL",
+        side=TradeSide.BUY,
+        quantity=10,
+        price=100.0,
+        executed_at=DAY,
+        expected_price=100.0,
+    )
+
+    with pytest.raises(AppError) as excinfo:
+        asyncio.run(
+            record_transaction(portfolio_id, request, False, portfolios, ledger)
+        )
+
+    assert (excinfo.value.status_code, excinfo.value.code) == (
+        429,
+        "provider.rate_limited",
+    )
+    assert asyncio.run(ledger.get_transactions(portfolio_id)) == []
+
+
+def test_a_quick_resubmission_is_rejected_unless_forced(monkeypatch):
+    monkeypatch.setattr(settings, "DUPLICATE_TRANSACTION_WINDOW_SECONDS", 10)
+    now = [datetime(2025, 1, 6, 15, 30, 0)]
+    ledger = LedgerService(clock=lambda: now[0])
+    first = _record(ledger, 0, "AAPL", TradeSide.BUY, 10, 100.0)
+
+    # A double-click: same symbol, side, quantity and price seconds later
+    now[0] += timedelta(seconds=3)
+    with pytest.raises(DuplicateTransactionError) as excinfo:
+        _record(ledger, 0, "aapl", TradeSide.BUY, 10, 100.0)
+    assert excinfo.value.existing == first
+
+    # Any difference makes it a different transaction
+    _record(ledger, 0, "AAPL", TradeSide.BUY, 11, 100.0)
+    _record(ledger, 0, "AAPL", TradeSide.SELL, 10, 100.0)
+
+    # Forced, or once the window has passed, it's recorded
+    _record(ledger, 0, "AAPL", TradeSide.BUY, 10, 100.0, force=True)
+    now[0] += timedelta(seconds=11)
+    _record(ledger, 0, "AAPL", TradeSide.BUY, 10, 100.0)
+    assert len(asyncio.run(ledger.get_transactions(1))) == 5
+
+    monkeypatch.setattr(settings, "DUPLICATE_TRANSACTION_WINDOW_SECONDS", 0)
+    _record(ledger, 0, "AAPL", TradeSide.BUY, 10, 100.0)
+    assert len(asyncio.run(ledger.get_transactions(1))) == 6
+
+
+def _tsla(portfolios):
+    positions = asyncio.run(portfolios.get_positions(1))
+    return next((p for p in positions if p.stock_symbol == "TSLA"), None)
+
+
+def test_transactions_update_positions_at_average_cost():
+    portfolios = PortfolioService()
+    ledger = LedgerService(portfolios=portfolios)
+
+    _record(ledger, 0, "tsla", TradeSide.BUY, 10, 100.0, fees=10.0)
+    _record(ledger, 1, "TSLA", TradeSide.BUY, 10, 121.0)
+    held = _tsla(portfolios)
+    assert (held.quantity, held.average_price) == (20, 111.0)
+
+    _record(ledger, 2, "TSLA", TradeSide.SELL, 5, 131.0, fees=1.0)
+    held = _tsla(portfolios)
+    assert (held.quantity, held.average_price) == (15, 111.0)
+    portfolio = asyncio.run(portfolios.get_portfolio_by_id(1))
+    assert portfolio.realized_gain == 99.0  # 5 * (131 - 111) - 1
+    assert portfolio.total_gain == pytest.approx(
+        portfolio.unrealized_gain + portfolio.realized_gain
+    )
+
+    # Rejected transactions change neither the ledger nor the position
+    with pytest.raises(ValueError, match="more shares than were held"):
+        _record(ledger, 3, "TSLA", TradeSide.SELL, 16, 131.0)
+    with pytest.raises(ValueError, match="whole shares"):
+        _record(ledger, 3, "TSLA", TradeSide.BUY, 0.5, 131.0)
+    assert len(asyncio.run(ledger.get_transactions(1))) == 3
+    assert _tsla(portfolios).quantity == 15
+
+    # Selling the rest closes the position
+    _record(ledger, 4, "TSLA", TradeSide.SELL, 15, 101.0)
+    assert _tsla(portfolios) is None
+    portfolio = asyncio.run(portfolios.get_portfolio_by_id(1))
+    assert portfolio.realized_gain == -51.0  # 99 + 15 * (101 - 111)
+
+    days = (DAY + timedelta(days=1)).date(), (DAY + timedelta(days=2)).date()
+    listed = asyncio.run(ledger.get_transactions(1, "TSLA", *days))
+    assert [t.side for t in listed] == [TradeSide.BUY, TradeSide.SELL]
+
+
+def test_positions_are_checked_and_rebuilt_from_the_ledger():
+    portfolios = PortfolioService()
+    ledger = LedgerService(portfolios=portfolios)
+    _record(ledger, 0, "TSLA", TradeSide.BUY, 10, 100.0)
+    _record(ledger, 1, "TSLA", TradeSide.SELL, 4, 110.0)
+    position = _tsla(portfolios)
+    asyncio.run(
+        portfolios.update_position(1, position.id, {"quantity": 99, "notes": "x"})
+    )
+    symbols = sorted(p.stock_symbol for p in asyncio.run(portfolios.get_positions(1)))
+    transactions = asyncio.run(ledger.get_transactions(1))
+
+    check = asyncio.run(portfolios.rebuild_positions(1, transactions))
+
+    assert not check.applied and not check.consistent
+    assert check.ledger_realized_gain == check.realized_gain == 40.0
+    checks = {c.symbol: c for c in check.positions}
+    tsla = checks.pop("TSLA")
+    assert (tsla.quantity, tsla.ledger_quantity) == (99, 6)
+    assert tsla.status == PositionCheckStatus.DIFFERS
+    # The seeded positions never went through the ledger
+    assert {c.status for c in checks.values()} == {PositionCheckStatus.UNTRACKED}
+    assert _tsla(portfolios).quantity == 99  # A dry run changes nothing
+
+    rebuilt = asyncio.run(portfolios.rebuild_positions(1, transactions, apply=True))
+
+    assert rebuilt.applied and rebuilt.positions == check.positions
+    tsla = _tsla(portfolios)
+    assert (tsla.quantity, tsla.average_price, tsla.notes) == (6, 100.0, "x")
+    kept = sorted(p.stock_symbol for p in asyncio.run(portfolios.get_positions(1)))
+    assert kept == symbols
+    assert asyncio.run(portfolios.rebuild_positions(1, transactions)).consistent
+    assert asyncio.run(portfolios.rebuild_positions(99, transactions)) is None
+
+
+def test_a_back_dated_sell_realizes_its_gain_at_the_cost_then():
+    portfolios = PortfolioService()
+    ledger = LedgerService(portfolios=portfolios)
+    _record(ledger, 0, "TSLA", TradeSide.BUY, 10, 100.0)
+    _record(ledger, 2, "TSLA", TradeSide.BUY, 10, 200.0)
+    # Executed before the second buy, when the average cost was 100
+    _record(ledger, 1, "TSLA", TradeSide.SELL, 5, 120.0)
+
+    portfolio = asyncio.run(portfolios.get_portfolio_by_id(1))
+    assert portfolio.realized_gain == 100.0
+    # 5 left at 100, then 10 at 200
+    held = _tsla(portfolios)
+    assert (held.quantity, held.average_price) == (15, 166.6667)
+    transactions = asyncio.run(ledger.get_transactions(1))
+    assert asyncio.run(portfolios.rebuild_positions(1, transactions)).consistent
+
+
+def test_times_with_and_without_a_zone_share_a_ledger():
+    portfolios = PortfolioService()
+    ledger = LedgerService(portfolios=portfolios)
+    portfolio_id = portfolios.create_portfolio(user_id=9)
+    trade = '{"symbol": "TSLA", "quantity": 10, "price": 100, "side": '
+    bodies = [
+        trade + '"buy", "executed_at": "2025-01-06T15:30:00Z"}',
+        # No zone, so UTC: half an hour after the buy
+        trade + '"sell", "executed_at": "2025-01-06T16:00:00"}',
+    ]
+    for body in bodies:
+        request = TransactionRequest.model_validate_json(body)
+        asyncio.run(
+            record_transaction(portfolio_id, request, False, portfolios, ledger)
+        )
+
+    listed = asyncio.run(ledger.get_transactions(portfolio_id))
+    assert [t.executed_at for t in listed] == [
+        datetime(2025, 1, 6, 15, 30),
+        datetime(2025, 1, 6, 16, 0),
+    ]
+    stats = asyncio.run(
+        TradeStatsService(portfolios, ledger).get_trade_stats(portfolio_id)
+    )
+    assert stats.closed_trades == 1
+
+    # An offset is converted, and sorts by the instant it stands for
+    moved = TransactionCreate.model_validate_json(
+        trade + '"buy", "executed_at": "2025-01-06T17:00:00+02:00"}'
+    )
+    assert moved.executed_at == datetime(2025, 1, 6, 15, 0)
+
+
+def test_a_position_entered_directly_can_be_sold_from():
+    portfolios = PortfolioService()
+    ledger = LedgerService(portfolios=portfolios)
+    asyncio.run(
+        portfolios.create_position(
+            {
+                "portfolio_id": 1,
+                "stock_symbol": "TSLA",
+                "quantity": 10,
+                "average_price": 100.0,
+            }
+        )
+    )
+
+    _record(ledger, 0, "TSLA", TradeSide.SELL, 4, 110.0)
+
+    held = _tsla(portfolios)
+    assert (held.quantity, held.average_price) == (6, 100.0)
+    assert asyncio.run(portfolios.get_portfolio_by_id(1)).realized_gain == 40.0
+    # The position's shares still bound what can be sold
+    with pytest.raises(ValueError, match="when 6 are held"):
+        _record(ledger, 1, "TSLA", TradeSide.SELL, 7, 110.0)
+    _record(ledger, 2, "TSLA", TradeSide.SELL, 6, 90.0)
+    assert _tsla(portfolios) is None
+    transactions = asyncio.run(ledger.get_transactions(1))
+    assert len(transactions) == 2
+
+    # The ledger can't cost those sells, so it leaves them to the position
+    stats = TradeStatsService(portfolios, ledger)
+    assert asyncio.run(stats.get_trade_stats(1)).closed_trades == 0
+    check = asyncio.run(portfolios.rebuild_positions(1, transactions, apply=True))
+    assert check.consistent and check.ledger_realized_gain == 0.0
+    assert "TSLA" not in {c.symbol for c in check.positions}
+    assert asyncio.run(portfolios.get_portfolio_by_id(1)).realized_gain == -20.0
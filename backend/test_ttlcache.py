@@ -0,0 +1,52 @@
+"""
+Tests for the in-memory TTL cache.
+"""
+
+from app.utils.ttlcache import TTLCache
+
+
+class FakeClock:
+    def __init__(self):
+        self.now = 1000.0
+
+    def __call__(self):
+        return self.now
+
+
+def test_entries_expire_on_read():
+    clock = FakeClock()
+    cache = TTLCache(clock)
+    cache.set("AAPL", 150.25, ttl=30)
+
+    clock.now += 29
+    assert cache.get("AAPL") == 150.25
+    clock.now += 1
+    assert cache.get("AAPL") is None
+    assert len(cache) == 0
+    assert cache.get("MSFT") is None
+
+
+def test_zero_ttl_and_delete_remove_entries():
+    cache = TTLCache(FakeClock())
+    cache.set("AAPL", 1.0, ttl=30)
+    cache.set("AAPL", 2.0, ttl=0)
+    assert cache.get("AAPL") is None
+
+    cache.set("MSFT", 3.0, ttl=30)
+    cache.delete("MSFT")
+    cache.delete("MSFT")  # Already gone, nothing to do
+    assert len(cache) == 0
+
+
+def test_evict_expired_drops_only_expired_entries():
+    clock = FakeClock()
+    cache = TTLCache(clock)
+    cache.set("AAPL", 1.0, ttl=10)
+    cache.set("MSFT", 2.0, ttl=10)
+    cache.set("NVDA", 3.0, ttl=60)
+
+    clock.now += 10
+    assert cache.evict_expired() == 2
+    assert len(cache) == 1
+    assert cache.get("NVDA") == 3.0
+    assert cache.evict_expired() == 0
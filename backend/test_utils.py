@@ -0,0 +1,128 @@
+"""
+Tests for shared utility helpers.
+"""
+
+import json
+from datetime import date
+
+import pytest
+from app.core.config import settings
+from app.utils.history import (
+    RANGE_TOKENS,
+    HistoryRangeError,
+    check_date_range,
+    resolve_history_days,
+)
+from app.utils.jsonenc import (
+    encode_response,
+    parse_int64_preference,
+    stringify_int64_fields,
+)
+from app.utils.params import (
+    ParamConflictError,
+    check_sort,
+    mutually_exclusive,
+    parse_sort,
+    requires,
+)
+
+
+def test_int64_fields_stay_numeric_by_default(monkeypatch):
+    monkeypatch.setattr(settings, "INT64_AS_STRING", False)
+    assert parse_int64_preference(None) is False
+    assert parse_int64_preference("garbage") is False
+    assert parse_int64_preference("false") is False
+
+    stock = {"symbol": "AAPL", "volume": 12345678901, "price": 150.25}
+    assert encode_response(stock, as_string=False) is stock
+    assert json.dumps(stock) == (
+        '{"symbol": "AAPL", "volume": 12345678901, "price": 150.25}'
+    )
+
+
+def test_int64_fields_encoded_as_strings(monkeypatch):
+    assert parse_int64_preference("true") is True
+    monkeypatch.setattr(settings, "INT64_AS_STRING", True)
+    assert parse_int64_preference(None) is True
+    assert parse_int64_preference("0") is False
+
+    payload = [
+        {"symbol": "AAPL", "volume": 12345678901, "price": 150.25},
+        {"symbol": "MSFT", "volume": None, "history": [{"volume": 7}]},
+    ]
+    assert json.dumps(stringify_int64_fields(payload)) == (
+        '[{"symbol": "AAPL", "volume": "12345678901", "price": 150.25}, '
+        '{"symbol": "MSFT", "volume": null, "history": [{"volume": "7"}]}]'
+    )
+
+
+def test_history_range_within_cap():
+    assert resolve_history_days(30, max_days=365) == 30
+    assert resolve_history_days(range_token="1y", max_days=365) == 365
+    # MAX clamps to the cap instead of being unbounded
+    assert resolve_history_days(range_token="MAX", max_days=365) == 365
+    check_date_range(date(2024, 1, 1), date(2024, 12, 31))
+
+
+def test_every_range_token_fits_the_default_cap():
+    assert resolve_history_days(range_token="10Y") == 3652
+    for token, days in RANGE_TOKENS.items():
+        assert resolve_history_days(range_token=token) == days
+    # Ten calendar years of dates, three of them leap years
+    check_date_range(date(2015, 1, 1), date(2024, 12, 31))
+
+
+def test_history_range_beyond_cap_rejected(monkeypatch):
+    monkeypatch.setattr(settings, "MAX_HISTORY_DAYS", 365)
+
+    with pytest.raises(HistoryRangeError) as exc:
+        resolve_history_days(18250)
+    assert "maximum is 365 days" in str(exc.value)
+    for token in ("5Y", "forever"):
+        with pytest.raises(HistoryRangeError):
+            resolve_history_days(range_token=token)
+    with pytest.raises(HistoryRangeError):
+        check_date_range(date(2020, 1, 1), date(2024, 1, 1))
+
+
+def test_range_conflicts_with_explicit_dates():
+    history_groups = (("days",), ("range",), ("from", "to"))
+    params = {"days": None, "range": "1Y", "from": date(2024, 1, 1), "to": None}
+    with pytest.raises(ParamConflictError) as exc:
+        mutually_exclusive(params, *history_groups)
+    assert "'range'/'from' can't be combined" in str(exc.value)
+
+    # Either side alone is fine, and so is nothing at all
+    mutually_exclusive({"range": "1Y"}, *history_groups)
+    dates = {"from": date(2024, 1, 1), "to": date(2024, 6, 1)}
+    mutually_exclusive(dates, *history_groups)
+    mutually_exclusive({}, *history_groups)
+
+    with pytest.raises(ParamConflictError, match="'to' also requires 'from'"):
+        requires({"to": date(2024, 6, 1)}, "to", "from")
+
+
+def test_sort_by_needs_a_valid_order():
+    fields = ("symbol", "value")
+    assert check_sort(None, None, fields) is None
+    assert check_sort("value", "DESC", fields) == ("value", True)
+    assert check_sort("symbol", "asc", fields) == ("symbol", False)
+
+    with pytest.raises(ParamConflictError, match="requires 'order'"):
+        check_sort("value", None, fields)
+    with pytest.raises(ParamConflictError, match="requires 'order'"):
+        check_sort("value", "up", fields)
+    with pytest.raises(ParamConflictError, match="'order' also requires 'sort_by'"):
+        check_sort(None, "asc", fields)
+    with pytest.raises(ParamConflictError, match="Can't sort by 'name'"):
+        check_sort("name", "asc", fields)
+
+
+def test_sort_param_descends_with_a_minus():
+    fields = ("symbol", "price")
+    assert parse_sort(None, fields) is None
+    assert parse_sort("price", fields) == ("price", False)
+    assert parse_sort("-price", fields) == ("price", True)
+
+    with pytest.raises(ParamConflictError, match="Can't sort by 'name'"):
+        parse_sort("-name", fields)
@@ -0,0 +1,56 @@
+"""
+Tests for the value-weighted portfolio PE ratio.
+"""
+
+import asyncio
+
+from app.services.market import MarketService
+from app.services.portfolio import PortfolioService
+from app.services.valuation import ValuationService
+
+
+def _service(holdings):
+    portfolios = PortfolioService()
+    for position in portfolios.portfolios.list_positions():
+        portfolios.portfolios.delete_position(position["id"])
+    market = MarketService()
+    for symbol, value, pe_ratio in holdings:
+        portfolios._insert_position(1, symbol, 1, value, value)
+        market._put_stock(
+            {
+                "symbol": symbol,
+                "name": symbol,
+                "price": value,
+                "change": 0.0,
+                "change_percent": 0.0,
+                "volume": 0,
+                "pe_ratio": pe_ratio,
+            }
+        )
+    return ValuationService(portfolios, market)
+
+
+def test_weighted_pe_excludes_negative_and_missing():
+    service = _service(
+        [
+            ("AAA", 1000.0, 10.0),
+            ("BBB", 3000.0, 30.0),
+            ("CCC", 2000.0, -15.0),
+            ("DDD", 500.0, None),
+        ]
+    )
+
+    result = asyncio.run(service.get_portfolio_pe(1))
+
+    # (1000 * 10 + 3000 * 30) / 4000
+    assert result.weighted_pe == 25.0
+    assert result.included_value == 4000.0
+    assert result.excluded_count == 2
+    assert result.excluded == ["CCC", "DDD"]
+
+
+def test_no_usable_pe_and_unknown_portfolio():
+    service = _service([("CCC", 2000.0, -15.0)])
+    result = asyncio.run(service.get_portfolio_pe(1))
+    assert (result.weighted_pe, result.excluded_count) == (None, 1)
+    assert asyncio.run(service.get_portfolio_pe(99)) is None
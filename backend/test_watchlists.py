@@ -0,0 +1,194 @@
+"""
+Tests for watchlists: idempotent creation and symbol changes.
+"""
+
+import asyncio
+from types import SimpleNamespace
+
+import pytest
+from app.api.v1.endpoints.watchlists import (
+    add_watchlist_symbol,
+    create_watchlist,
+    delete_watchlist,
+    remove_watchlist_symbol,
+    reorder_watchlists,
+)
+from app.core.errors import AppError
+from app.models.schemas import WatchlistCreate, WatchlistOrder
+from app.services.market import MarketService
+from app.services.watchlists import WatchlistService
+
+
+def _create(service, name, symbols=(), strict=False, user_id=1):
+    response = SimpleNamespace(status_code=201)
+    watchlist = asyncio.run(
+        create_watchlist(
+            WatchlistCreate(name=name, symbols=list(symbols)),
+            response,
+            strict=strict,
+            user_id=user_id,
+            watchlist_service=service,
+        )
+    )
+    return watchlist, response.status_code
+
+
+def test_retried_create_returns_the_existing_watchlist():
+    service = WatchlistService()
+
+    first, status = _create(service, "Tech", ["aapl", "MSFT", " AAPL"])
+    assert status == 201
+    assert first.symbols == ["AAPL", "MSFT"]
+
+    again, status = _create(service, " Tech ", ["NVDA"])
+    assert status == 200
+    assert again == first
+    assert len(asyncio.run(service.get_watchlists(1))) == 1
+
+    # Names are only unique per user
+    other, status = _create(service, "Tech", user_id=2)
+    assert (status, other.user_id) == (201, 2)
+    assert other.id != first.id
+
+
+def test_strict_create_conflicts_on_a_taken_name():
+    service = WatchlistService()
+    _create(service, "Tech")
+
+    with pytest.raises(AppError) as exc:
+        _create(service, "Tech", strict=True)
+    assert (exc.value.status_code, exc.value.code) == (409, "watchlist.name_taken")
+
+    _, status = _create(service, "Energy", strict=True)
+    assert status == 201
+
+
+def _add(service, watchlist_id, symbol, user_id=1):
+    response = SimpleNamespace(status_code=201)
+    watchlist = asyncio.run(
+        add_watchlist_symbol(
+            watchlist_id,
+            symbol,
+            response,
+            user_id=user_id,
+            watchlist_service=service,
+            market_service=service.market,
+        )
+    )
+    return watchlist, response.status_code
+
+
+def _error(call):
+    with pytest.raises(AppError) as exc:
+        call()
+    return exc.value.status_code, exc.value.code
+
+
+def test_symbols_are_added_once_and_priced():
+    service = WatchlistService(MarketService())
+    watchlist, _ = _create(service, "Tech", ["AAPL"])
+
+    added, status = _add(service, watchlist.id, "msft")
+    assert status == 201
+    assert added.symbols == ["AAPL", "MSFT"]
+
+    again, status = _add(service, watchlist.id, "MSFT")
+    assert status == 200
+    assert again.symbols == ["AAPL", "MSFT"]
+
+    [listed] = asyncio.run(service.get_watchlists(1))
+    aapl = asyncio.run(service.market.get_stock_by_symbol("AAPL"))
+    assert listed.items[0].symbol == "AAPL"
+    assert (listed.items[0].price, listed.items[0].change_percent) == (
+        aapl.price,
+        aapl.change_percent,
+    )
+
+
+def test_adding_unknown_stocks_or_to_other_watchlists_is_404():
+    service = WatchlistService(MarketService())
+    watchlist, _ = _create(service, "Tech")
+
+    assert _error(lambda: _add(service, watchlist.id, "ZZZZ")) == (
+        404,
+        "stock.not_found",
+    )
+    assert _error(lambda: _add(service, watchlist.id, "A1")) == (
+        400,
+        "stock.symbol_invalid",
+    )
+    # Another user's watchlist looks the same as a missing one
+    assert _error(lambda: _add(service, watchlist.id, "AAPL", user_id=2)) == (
+        404,
+        "watchlist.not_found",
+    )
+
+
+def test_removing_symbols_and_deleting_watchlists():
+    service = WatchlistService(MarketService())
+    watchlist, _ = _create(service, "Tech", ["AAPL", "MSFT"])
+
+    def remove(symbol):
+        asyncio.run(
+            remove_watchlist_symbol(
+                watchlist.id, symbol, user_id=1, watchlist_service=service
+            )
+        )
+
+    remove("aapl")
+    remove("AAPL")  # Not on the list any more, nothing to do
+    [listed] = asyncio.run(service.get_watchlists(1))
+    assert listed.symbols == ["MSFT"]
+
+    def delete(user_id=1):
+        asyncio.run(
+            delete_watchlist(watchlist.id, user_id=user_id, watchlist_service=service)
+        )
+
+    assert _error(lambda: delete(user_id=2)) == (404, "watchlist.not_found")
+    delete()
+    assert asyncio.run(service.get_watchlists(1)) == []
+    assert _error(lambda: remove("MSFT")) == (404, "watchlist.not_found")
+
+    # The name is free again, and the new watchlist starts empty
+    recreated, status = _create(service, "Tech")
+    assert (status, recreated.items) == (201, [])
+
+
+def test_watchlists_are_listed_in_the_order_set():
+    service = WatchlistService()
+    tech, _ = _create(service, "Tech")
+    energy, _ = _create(service, "Energy")
+    banks, _ = _create(service, "Banks")
+    other, _ = _create(service, "Other", user_id=2)
+
+    def names():
+        return [w.name for w in asyncio.run(service.get_watchlists(1))]
+
+    def reorder(ids):
+        return asyncio.run(
+            reorder_watchlists(
+                WatchlistOrder(ids=ids), user_id=1, watchlist_service=service
+            )
+        )
+
+    assert names() == ["Tech", "Energy", "Banks"]
+    reordered = reorder([banks.id, tech.id, energy.id])
+    assert [(w.name, w.sort_order) for w in reordered] == [
+        ("Banks", 0),
+        ("Tech", 1),
+        ("Energy", 2),
+    ]
+    assert names() == ["Banks", "Tech", "Energy"]
+    # New watchlists go last
+    _create(service, "Crypto")
+    assert names() == ["Banks", "Tech", "Energy", "Crypto"]
+
+    # Anything but each of the user's watchlists once changes nothing
+    for ids in (
+        [tech.id, energy.id, banks.id],
+        [tech.id, energy.id, banks.id, banks.id],
+        [tech.id, energy.id, banks.id, other.id],
+    ):
+        assert _error(lambda: reorder(ids)) == (400, "watchlist.order_invalid")
+    assert names() == ["Banks", "Tech", "Energy", "Crypto"]
@@ -0,0 +1,234 @@
+"""
+Tests for the WebSocket hub and its tick feeds.
+"""
+
+import asyncio
+import json
+
+from app.services.market import MarketService
+from app.ws.feed import PollingFeed, StreamingFeed, create_feed
+from app.ws.hub import ConnectionManager
+
+
+class FakeClient:
+    """Stands in for a browser's WebSocket connection."""
+
+    def __init__(self):
+        self.client = "fake-client"
+        self.sent = []
+
+    async def accept(self):
+        pass
+
+    async def send_text(self, message: str):
+        self.sent.append(json.loads(message))
+
+
+class FakeUpstream:
+    """Streaming provider whose pushes are driven by the test."""
+
+    def __init__(self):
+        self.connects = 0
+        self.subscribed = []
+        self.queue: asyncio.Queue = asyncio.Queue()
+
+    async def connect(self):
+        self.connects += 1
+
+    async def subscribe(self, symbols):
+        self.subscribed.extend(symbols)
+
+    async def unsubscribe(self, symbols):
+        pass
+
+    async def stream(self):
+        while True:
+            item = await self.queue.get()
+            if isinstance(item, Exception):
+                raise item
+            yield item
+
+    async def get_quote(self, symbol):
+        return {"price": 1.0}
+
+    async def get_history(self, symbol, interval, limit):
+        return []
+
+
+class PollOnlyProvider:
+    async def get_quote(self, symbol):
+        return {"c": 187.5}
+
+    async def get_history(self, symbol, interval, limit):
+        return []
+
+
+async def _wait_for(predicate, timeout=2.0):
+    deadline = asyncio.get_running_loop().time() + timeout
+    while not predicate():
+        if asyncio.get_running_loop().time() > deadline:
+            raise AssertionError("condition not met before timeout")
+        await asyncio.sleep(0.01)
+
+
+def test_upstream_tick_reaches_subscriber_and_survives_reconnect():
+    async def scenario():
+        upstream = FakeUpstream()
+        feed = create_feed(upstream)
+        assert isinstance(feed, StreamingFeed)
+        feed.initial_backoff = 0
+
+        manager = ConnectionManager(feed)
+        client = FakeClient()
+        await manager.connect(client)
+        await manager.handle_message(
+            client, json.dumps({"type": "subscribe", "symbol": "AAPL"})
+        )
+        task = asyncio.create_task(manager.broadcast_ticks())
+
+        await upstream.queue.put({"type": "tick", "symbol": "AAPL", "price": 1.0})
+        await _wait_for(lambda: len(client.sent) == 1)
+
+        # Upstream drops; the feed reconnects and re-subscribes
+        await upstream.queue.put(ConnectionError("upstream closed"))
+        await upstream.queue.put({"type": "tick", "symbol": "AAPL", "price": 2.0})
+        await _wait_for(lambda: len(client.sent) == 2)
+
+        task.cancel()
+        assert [m["price"] for m in client.sent] == [1.0, 2.0]
+        assert upstream.connects == 2
+        # Once on subscribe, then again after each (re)connect
+        assert upstream.subscribed.count("AAPL") == 3
+
+    asyncio.run(scenario())
+
+
+def test_provider_without_streaming_falls_back_to_polling():
+    async def scenario():
+        feed = create_feed(PollOnlyProvider())
+        assert isinstance(feed, PollingFeed)
+        feed.interval = 0.01
+
+        manager = ConnectionManager(feed)
+        client = FakeClient()
+        await manager.connect(client)
+        await manager.subscribe(client, "MSFT")
+        task = asyncio.create_task(manager.broadcast_ticks())
+
+        await _wait_for(lambda: len(client.sent) >= 1)
+        task.cancel()
+        assert client.sent[0]["symbol"] == "MSFT"
+        assert client.sent[0]["price"] == 187.5
+
+    asyncio.run(scenario())
+
+
+def test_clients_only_receive_their_own_symbols():
+    async def scenario():
+        upstream = FakeUpstream()
+        manager = ConnectionManager(create_feed(upstream))
+        first, second = FakeClient(), FakeClient()
+        for client, symbols in ((first, ["AAPL", "GOOGL"]), (second, ["MSFT"])):
+            await manager.connect(client)
+            await manager.handle_message(
+                client, json.dumps({"action": "subscribe", "symbols": symbols})
+            )
+        await manager.handle_message(
+            first, json.dumps({"action": "unsubscribe", "symbols": ["GOOGL"]})
+        )
+        task = asyncio.create_task(manager.broadcast_ticks())
+
+        for symbol in ("GOOGL", "MSFT", "AAPL"):
+            await upstream.queue.put({"type": "tick", "symbol": symbol, "price": 1.0})
+        await _wait_for(lambda: first.sent and second.sent)
+
+        task.cancel()
+        assert [m["symbol"] for m in first.sent] == ["AAPL"]
+        assert [m["symbol"] for m in second.sent] == ["MSFT"]
+
+    asyncio.run(scenario())
+
+
+class SilentClient(FakeClient):
+    """A client that never sends anything, as if its tab went to sleep."""
+
+    def __init__(self):
+        super().__init__()
+        self.closed = False
+
+    async def receive_text(self):
+        await asyncio.sleep(3600)
+
+    async def close(self):
+        self.closed = True
+
+
+def test_silent_client_is_pinged_then_dropped():
+    async def scenario():
+        manager = ConnectionManager(create_feed(PollOnlyProvider()), ping_interval=0.01)
+        client = SilentClient()
+        serving = asyncio.create_task(manager.serve(client))
+        await manager.subscribe(client, "AAPL")
+
+        await asyncio.wait_for(serving, 1.0)
+        assert client.sent == [{"type": "ping"}]
+        assert client.closed
+        assert manager.active_connections == []
+        assert manager.subscriptions == {}
+
+    asyncio.run(scenario())
+
+
+def test_bare_symbol_list_subscribes_up_to_the_limit():
+    async def scenario():
+        manager = ConnectionManager(create_feed(PollOnlyProvider()), max_symbols=2)
+        client = FakeClient()
+        await manager.connect(client)
+        await manager.handle_message(
+            client, json.dumps({"symbols": ["AAPL", "googl", "MSFT"]})
+        )
+        # Re-subscribing to a symbol it already has doesn't count again
+        await manager.handle_message(client, json.dumps({"symbols": ["AAPL"]}))
+
+        assert sorted(manager.subscriptions) == ["AAPL", "GOOGL"]
+        assert manager.symbol_count(client) == 2
+        assert [(m["code"], m["symbol"]) for m in client.sent] == [
+            ("stream.too_many_symbols", "MSFT")
+        ]
+
+    asyncio.run(scenario())
+
+
+def test_a_limit_of_zero_refuses_every_symbol():
+    async def scenario():
+        manager = ConnectionManager(create_feed(PollOnlyProvider()), max_symbols=0)
+        client = FakeClient()
+        await manager.connect(client)
+        await manager.handle_message(client, json.dumps({"symbols": ["AAPL"]}))
+
+        assert manager.subscriptions == {}
+        assert [m["code"] for m in client.sent] == ["stream.too_many_symbols"]
+
+    asyncio.run(scenario())
+
+
+def test_subscribers_are_pushed_their_stocks():
+    async def scenario():
+        market = MarketService()
+        manager = ConnectionManager(create_feed(PollOnlyProvider()), market=market)
+        first, second = FakeClient(), FakeClient()
+        for client in (first, second):
+            await manager.connect(client)
+        await manager.subscribe(first, "AAPL")
+        await manager.subscribe(second, "AAPL")
+        await manager.subscribe(second, "MSFT")
+
+        await manager.push_stocks()
+
+        assert [(m["type"], m["data"]["symbol"]) for m in first.sent] == [
+            ("stock", "AAPL")
+        ]
+        assert sorted(m["data"]["symbol"] for m in second.sent) == ["AAPL", "MSFT"]
+        assert first.sent[0]["data"]["price"] == second.sent[0]["data"]["price"]
+
+    asyncio.run(scenario())